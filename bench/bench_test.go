@@ -0,0 +1,118 @@
+package bench
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jsvensson/paletteswap"
+)
+
+// themeFiles are the repository themes that render with the repository templates.
+var themeFiles = []string{
+	"../theme.pstheme",
+	"../themes/opencode.pstheme",
+}
+
+const templatesDir = "../templates"
+
+func loadThemes(b *testing.B) []*paletteswap.Theme {
+	b.Helper()
+	themes := make([]*paletteswap.Theme, len(themeFiles))
+	for i, path := range themeFiles {
+		theme, err := paletteswap.Load(path)
+		if err != nil {
+			b.Fatalf("loading %s: %v", path, err)
+		}
+		themes[i] = theme
+	}
+	return themes
+}
+
+// copyTemplates returns a directory holding n copies of each repository
+// template, to simulate a large template collection.
+func copyTemplates(b *testing.B, n int) string {
+	b.Helper()
+	matches, err := filepath.Glob(filepath.Join(templatesDir, "*.tmpl"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	dir := b.TempDir()
+	for _, match := range matches {
+		src, err := os.ReadFile(match)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i := range n {
+			name := fmt.Sprintf("%d-%s", i, filepath.Base(match))
+			if err := os.WriteFile(filepath.Join(dir, name), src, 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return dir
+}
+
+func BenchmarkLoad(b *testing.B) {
+	for _, path := range themeFiles {
+		b.Run(filepath.Base(path), func(b *testing.B) {
+			for b.Loop() {
+				if _, err := paletteswap.Load(path); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	theme := loadThemes(b)[0]
+	e := &paletteswap.Engine{TemplatesDir: templatesDir, OutputDir: b.TempDir()}
+	for b.Loop() {
+		if err := e.Run(theme); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGenerateMatrix renders 24 themes against 24 copies of each
+// template, comparing a reused Engine, which parses each template once,
+// with a new Engine per theme.
+func BenchmarkGenerateMatrix(b *testing.B) {
+	const themeCount, templateCopies = 24, 24
+
+	loaded := loadThemes(b)
+	themes := make([]*paletteswap.Theme, themeCount)
+	for i := range themes {
+		themes[i] = loaded[i%len(loaded)]
+	}
+	tmplDir := copyTemplates(b, templateCopies)
+	outDirs := make([]string, themeCount)
+	for i := range outDirs {
+		outDirs[i] = filepath.Join(b.TempDir(), fmt.Sprint(i))
+	}
+
+	b.Run("reused engine", func(b *testing.B) {
+		e := &paletteswap.Engine{TemplatesDir: tmplDir}
+		for b.Loop() {
+			for i, theme := range themes {
+				e.OutputDir = outDirs[i]
+				if err := e.Run(theme); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("engine per theme", func(b *testing.B) {
+		for b.Loop() {
+			for i, theme := range themes {
+				e := &paletteswap.Engine{TemplatesDir: tmplDir, OutputDir: outDirs[i]}
+				if err := e.Run(theme); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
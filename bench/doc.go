@@ -0,0 +1,7 @@
+// Package bench holds benchmarks for generating theme packs: loading themes
+// and rendering many themes against many templates. Run them with
+//
+//	go test -bench . -benchmem ./bench
+//
+// and add -cpuprofile or -memprofile to profile the hot paths.
+package bench
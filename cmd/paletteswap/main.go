@@ -1,21 +1,64 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/jsvensson/paletteswap"
+	"github.com/jsvensson/paletteswap/internal/a11y"
+	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/derive"
 	"github.com/jsvensson/paletteswap/internal/format"
+	"github.com/jsvensson/paletteswap/internal/graph"
+	"github.com/jsvensson/paletteswap/internal/lint"
+	"github.com/jsvensson/paletteswap/internal/lsp"
+	"github.com/jsvensson/paletteswap/internal/metrics"
+	"github.com/jsvensson/paletteswap/internal/preview"
 	"github.com/spf13/cobra"
 )
 
 var (
-	flagTheme     string
-	flagOut       string
-	flagTemplates string
-	flagApp       []string
-	flagCheck     bool
-	version       = "dev" // Injected at build time via ldflags
+	flagTheme      string
+	flagThemes     []string
+	flagOut        string
+	flagTemplates  string
+	flagApp        []string
+	flagCheck      bool
+	flagSummary    bool
+	flagStdinName  string
+	flagVerbose    bool
+	flagLSP        = lsp.Options{Limits: paletteswap.DefaultLimits}
+	flagLogFile    string
+	flagFormat     string
+	flagSchema     int
+	flagJSON       bool
+	flagRequire    string
+	flagAddr       string
+	flagWatch      bool
+	flagLineEnding string
+	flagAppearance string
+	flagDeriveOut  string
+	flagGenAppear  string // generate --appearance; derive's has another default
+	flagAllowOut   bool
+	flagIndent     int
+	flagAlign      bool
+	flagHexCase    string
+	flagSortANSI   bool
+	flagMaxDist    float64
+	flagOutputs    string
+	version        = "dev" // Injected at build time via ldflags
 )
 
 var rootCmd = &cobra.Command{
@@ -33,9 +76,145 @@ var generateCmd = &cobra.Command{
 var fmtCmd = &cobra.Command{
 	Use:   "fmt [files...]",
 	Short: "Format .pstheme files",
-	Long:  "Format one or more .pstheme files in-place. Prints the name of each file that was modified.",
-	Args:  cobra.MinimumNArgs(1),
-	RunE:  runFmt,
+	Long: `Format one or more .pstheme files in-place. Prints the name of each file that was modified.
+
+With --stdin-filename (or a single "-" argument), reads content from stdin and
+writes the formatted result to stdout, for editor format-on-save integrations.
+The filename is used in error messages and to find .editorconfig settings.
+If the content cannot be parsed, nothing is written to stdout.
+
+With --summary, prints the lines added and removed per file and a final
+count instead.
+
+For pre-commit hooks, fmt exits 1 if --check found files that need
+formatting, and 2 on any error, such as a file that could not be read,
+parsed or written, or an unknown flag.
+
+Without --indent, the indentation follows indent_size in the .editorconfig
+files that apply to each file, and is two spaces if they set none.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if isStdinArgs(args) {
+			return nil
+		}
+		if flagStdinName != "" {
+			return fmtError(fmt.Errorf("cannot combine --stdin-filename with file arguments"))
+		}
+		return fmtError(cobra.MinimumNArgs(1)(cmd, args))
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return fmtError(runFmt(cmd, args))
+	},
+}
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run the .pstheme language server",
+	Long: `Run the .pstheme language server. This is the same server as the standalone
+pstheme-lsp binary, so editors can be pointed at the paletteswap executable.
+
+By default the server communicates over stdin/stdout.`,
+	Args: cobra.NoArgs,
+	RunE: runLSP,
+}
+
+var a11yCmd = &cobra.Command{
+	Use:   "a11y",
+	Short: "Report WCAG contrast ratios of a theme",
+	Long: `Print the WCAG contrast ratio of theme.foreground, the cursor and the
+selection on their backgrounds, and of every syntax scope and ANSI color on
+theme.background, with AA (4.5:1) and AAA (7:1) pass/fail for normal text.
+
+Use --json for machine-readable output, and --require aa or --require aaa to
+exit non-zero when any pair fails that level, e.g. in CI.`,
+	Args: cobra.NoArgs,
+	RunE: runA11y,
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Report problems and lint findings in a theme",
+	Long: `Analyze a theme file the way the language server does and print every
+problem: errors that stop the theme from loading, and findings of the lint
+rules, each tagged with its rule ID and name (e.g. PS002 low-contrast).
+
+Rule severities can be changed, or rules turned off, in the lint block of the
+theme's settings block. Use --json for machine-readable output. Exits non-zero
+if any problem has error severity.
+
+With --outputs, also renders the templates and reports each generated file in
+that directory that is missing or differs from what generate would write, as
+an error, so CI can catch themes that were not regenerated.`,
+	Args: cobra.NoArgs,
+	RunE: runCheck,
+}
+
+var whichCmd = &cobra.Command{
+	Use:   "which COLOR",
+	Short: "Find the theme entries that resolve to a color",
+	Long: `Print the palette, theme, ansi, syntax and semantic entries of a theme that
+resolve to a hex color, such as one picked from the screen. If no entry has
+exactly that color, the entries within --max-distance of it (in OKLAB) are
+printed, closest first. Exits non-zero if there are none.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runWhich,
+}
+
+var previewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Serve an HTML preview of a theme",
+	Long: `Serve an HTML page previewing a theme: its palette, with how many entries
+use each color, the ANSI colors as a terminal would show them, and a code
+sample in the syntax colors.
+
+With --watch, open pages reload whenever the theme file changes, for a
+browser-based design loop.`,
+	Args: cobra.NoArgs,
+	RunE: runPreview,
+}
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Inspect available templates",
+}
+
+var templatesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List templates, their output files and the theme paths they reference",
+	Long: `List the templates in the templates directory. The APP column is the name
+accepted by "generate --app". References are found by scanning each template
+for path strings such as "palette.base" and fields such as .Theme.background.`,
+	Args: cobra.NoArgs,
+	RunE: runTemplatesList,
+}
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Print the color reference graph of a theme",
+	Long: `Print a graph of the references in a theme file: which palette colors and
+locals feed which theme, syntax, ansi and semantic entries. Edges through a
+function such as brighten() are labeled with the function name.
+
+Use --format dot for Graphviz (e.g. paletteswap graph | dot -Tsvg > graph.svg)
+or --format mermaid for Markdown documentation. --format usage prints a
+Markdown table of how many theme, ansi, syntax and semantic entries use each
+palette entry, directly or through locals and other entries, with a heat bar.`,
+	Args: cobra.NoArgs,
+	RunE: runGraph,
+}
+
+var deriveCmd = &cobra.Command{
+	Use:   "derive",
+	Short: "Bootstrap a light variant of a dark theme, or vice versa",
+	Long: `Write a copy of a theme with the opposite appearance, as a starting point to
+tweak by hand. Every hex color and [r, g, b] literal gets the inverse OKLCH
+lightness with its hue and chroma kept, so the background and foreground swap
+lightness. brighten() and darken() calls are swapped, and meta.appearance is
+set. References and comments are kept.
+
+By default the result is written next to the theme, e.g. dawn.pstheme becomes
+dawn-light.pstheme. Use --out - to print it instead.`,
+	Args: cobra.NoArgs,
+	RunE: runDerive,
 }
 
 var versionCmd = &cobra.Command{
@@ -47,53 +226,487 @@ var versionCmd = &cobra.Command{
 }
 
 func init() {
-	generateCmd.Flags().StringVar(&flagTheme, "theme", "theme.hcl", "path to theme HCL file")
-	generateCmd.Flags().StringVar(&flagOut, "out", "output", "output directory")
+	rootCmd.PersistentFlags().BoolVarP(&flagVerbose, "verbose", "v", false, "log progress and timings to stderr")
+	generateCmd.Flags().StringArrayVar(&flagThemes, "theme", []string{"theme.hcl"}, "path to theme HCL file (can be repeated; each theme is written to a subdirectory of --out)")
+	generateCmd.Flags().StringVar(&flagOut, "out", "output", "output directory; may use template actions, e.g. 'dist/{{ .Meta.Name | slug }}/{{ .Variant }}'")
 	generateCmd.Flags().StringVar(&flagTemplates, "templates", "templates", "templates directory")
-	generateCmd.Flags().StringArrayVar(&flagApp, "app", nil, "generate only for specific apps (can be repeated)")
+	generateCmd.Flags().IntVar(&flagSchema, "schema-version", 0, "template data contract version the templates target, overriding the theme's schema_version setting (0 = the setting, or latest)")
+	generateCmd.Flags().StringArrayVar(&flagApp, "app", nil, "generate only for apps matching this name or glob, with or without extension (can be repeated)")
+	generateCmd.Flags().BoolVar(&flagAllowOut, "allow-outside-out", false, "allow templates to write outside the output directory (absolute, ~ or .. paths)")
+	generateCmd.Flags().StringVar(&flagLineEnding, "line-endings", "preserve", "line endings of generated files: preserve (as in the template), lf, crlf or native")
+	generateCmd.Flags().StringVar(&flagGenAppear, "appearance", "", "generate for this appearance, dark or light, applying blocks with a matching when attribute (default: meta.appearance)")
+	fmtCmd.Flags().StringVar(&flagLineEnding, "line-endings", "preserve", "line endings of formatted files: preserve (as in the input), lf, crlf or native")
 	fmtCmd.Flags().BoolVarP(&flagCheck, "check", "c", false, "check if files are formatted (do not write changes)")
+	fmtCmd.Flags().BoolVar(&flagSummary, "summary", false, "print lines added and removed per file and a final count")
+	fmtCmd.Flags().StringVar(&flagStdinName, "stdin-filename", "", "format stdin to stdout, using this filename in error messages and to find .editorconfig settings")
+	fmtCmd.Flags().IntVar(&flagIndent, "indent", 0, "spaces per indentation level (default: indent_size from .editorconfig, or 2)")
+	fmtCmd.Flags().BoolVar(&flagAlign, "align", true, "align the '=' of consecutive attributes")
+	fmtCmd.Flags().StringVar(&flagHexCase, "hex-case", "preserve", "case of hex colors: preserve, lower or upper")
+	fmtCmd.Flags().BoolVar(&flagSortANSI, "reorder-ansi", true, "sort ansi block attributes into the canonical order")
+	lspCmd.Flags().StringVar(&flagLSP.Listen, "listen", "", "accept TCP connections on this address (e.g. :7998) instead of using stdio")
+	lspCmd.Flags().BoolVar(&flagLSP.NodeIPC, "node-ipc", false, "communicate over the Node.js IPC channel instead of stdio")
+	lspCmd.Flags().Bool("stdio", true, "communicate over stdin/stdout (the default; accepted for editor compatibility)")
+	lspCmd.Flags().StringVar(&flagLogFile, "log-file", "", "write logs to this file (default: stderr when --verbose is set)")
+	lspCmd.Flags().Int64Var(&flagLSP.Limits.MaxFileSize, "max-file-size", flagLSP.Limits.MaxFileSize, "maximum theme file size in bytes (0 disables)")
+	lspCmd.Flags().IntVar(&flagLSP.Limits.MaxPaletteEntries, "max-palette-entries", flagLSP.Limits.MaxPaletteEntries, "maximum number of palette entries (0 disables)")
+	lspCmd.Flags().IntVar(&flagLSP.Limits.MaxTransformSteps, "max-transform-steps", flagLSP.Limits.MaxTransformSteps, "maximum lightness transform and scale steps (0 disables)")
+	templatesListCmd.Flags().StringVar(&flagTemplates, "templates", "templates", "templates directory")
+	templatesCmd.AddCommand(templatesListCmd)
+	graphCmd.Flags().StringVar(&flagTheme, "theme", "theme.hcl", "path to theme HCL file")
+	graphCmd.Flags().StringVar(&flagFormat, "format", "dot", "output format: dot, mermaid or usage")
+	a11yCmd.Flags().StringVar(&flagTheme, "theme", "theme.hcl", "path to theme HCL file")
+	previewCmd.Flags().StringVar(&flagTheme, "theme", "theme.hcl", "path to theme HCL file")
+	previewCmd.Flags().StringVar(&flagAddr, "addr", "localhost:7999", "address to serve the preview on")
+	previewCmd.Flags().BoolVarP(&flagWatch, "watch", "w", false, "reload open pages when the theme file changes")
+	checkCmd.Flags().StringVar(&flagTheme, "theme", "theme.hcl", "path to theme HCL file")
+	checkCmd.Flags().BoolVar(&flagJSON, "json", false, "print the problems as JSON")
+	checkCmd.Flags().StringVar(&flagOutputs, "outputs", "", "also report generated files in this directory that are stale or missing")
+	checkCmd.Flags().StringVar(&flagTemplates, "templates", "templates", "templates directory, with --outputs")
+	checkCmd.Flags().StringArrayVar(&flagApp, "app", nil, "with --outputs, check only apps matching this name or glob (can be repeated)")
+	checkCmd.Flags().StringVar(&flagLineEnding, "line-endings", "preserve", "with --outputs, line endings the files were generated with: preserve (as in the template), lf, crlf or native")
+	a11yCmd.Flags().BoolVar(&flagJSON, "json", false, "print the report as JSON")
+	a11yCmd.Flags().StringVar(&flagRequire, "require", "", "fail if any pair is below this WCAG level: aa or aaa")
+	whichCmd.Flags().StringVar(&flagTheme, "theme", "theme.hcl", "path to theme HCL file")
+	whichCmd.Flags().Float64Var(&flagMaxDist, "max-distance", 0.02, "largest OKLAB distance of a near match when no entry matches exactly")
+	deriveCmd.Flags().StringVar(&flagTheme, "theme", "theme.hcl", "path to theme HCL file")
+	deriveCmd.Flags().StringVar(&flagAppearance, "appearance", "light", "appearance of the derived theme: dark or light")
+	deriveCmd.Flags().StringVarP(&flagDeriveOut, "out", "o", "", "file to write (default: the theme's name with -<appearance> appended; - for stdout)")
 	rootCmd.AddCommand(generateCmd)
+	rootCmd.AddCommand(graphCmd)
+	rootCmd.AddCommand(a11yCmd)
+	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(previewCmd)
+	rootCmd.AddCommand(deriveCmd)
+	rootCmd.AddCommand(whichCmd)
+	rootCmd.AddCommand(templatesCmd)
+	fmtCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error { return fmtError(err) })
 	rootCmd.AddCommand(fmtCmd)
+	rootCmd.AddCommand(lspCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
+// newLogger returns a stderr logger that reports warnings, and also debug
+// progress and timings when --verbose is set.
+func newLogger(cmd *cobra.Command) *slog.Logger {
+	level := slog.LevelWarn
+	if flagVerbose {
+		level = slog.LevelDebug
+	}
+	return slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), &slog.HandlerOptions{Level: level}))
+}
+
 func runGenerate(cmd *cobra.Command, args []string) error {
+	logger := newLogger(cmd)
+
+	le, err := format.ParseLineEnding(flagLineEnding)
+	if err != nil {
+		return err
+	}
+
+	// A single Engine is reused so each template is parsed only once.
+	e := &paletteswap.Engine{
+		TemplatesDir:    flagTemplates,
+		Apps:            flagApp,
+		Logger:          logger,
+		SchemaVersion:   flagSchema,
+		LineEnding:      le,
+		AllowOutsideOut: flagAllowOut,
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if flagVerbose {
+		// The Engine logs render times itself.
+		ctx = metrics.WithRecorder(ctx, func(phase, name string, d time.Duration) {
+			if phase != metrics.PhaseRender {
+				logger.Debug("timing", "phase", phase, "name", name, "duration", d)
+			}
+		})
+	}
+
+	dirs := make(map[string]string) // output directory -> theme path
+	for _, themePath := range flagThemes {
+		start := time.Now()
+		theme, err := paletteswap.LoadForAppearance(ctx, themePath, paletteswap.DefaultLimits, flagGenAppear)
+		if err != nil {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%s: %w", themePath, err)
+		}
+		logger.Debug("loaded theme", "path", themePath, "duration", time.Since(start))
+		for _, hint := range theme.MigrationHints {
+			logger.Warn("migration hint", "hint", hint)
+		}
+
+		dir, err := themeOutputDir(themePath, theme)
+		if err != nil {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%s: %w", themePath, err)
+		}
+		if other, ok := dirs[dir]; ok {
+			cmd.SilenceUsage = true
+			return &paletteswap.Error{Kind: paletteswap.KindConfig, Err: fmt.Errorf("%s and %s both generate into %s", other, themePath, dir)}
+		}
+		dirs[dir] = themePath
+
+		out := e.WithOutputDir(dir)
+		if err := out.RunContext(ctx, theme); err != nil {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("generating %s: %w", themePath, err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Generated theme files in %s\n", out.OutputDir)
+	}
+	return nil
+}
+
+// themeOutputDir returns the output directory for a theme. A --out with
+// template actions is expanded for the theme. Otherwise, with several
+// --theme flags, each theme gets a subdirectory of --out named after its file.
+func themeOutputDir(themePath string, theme *paletteswap.Theme) (string, error) {
+	if strings.Contains(flagOut, "{{") {
+		return paletteswap.ExpandOutputDir(flagOut, themePath, theme)
+	}
+	if len(flagThemes) < 2 {
+		return flagOut, nil
+	}
+	name := filepath.Base(themePath)
+	return filepath.Join(flagOut, strings.TrimSuffix(name, filepath.Ext(name))), nil
+}
+
+func runTemplatesList(cmd *cobra.Command, args []string) error {
+	infos, err := paletteswap.ListTemplates(flagTemplates)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "APP\tOUTPUT\tREFERENCES")
+	for _, info := range infos {
+		refs := strings.Join(info.References, ", ")
+		if info.Err != nil {
+			refs = "error: " + info.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", info.App, info.Output, refs)
+	}
+	return w.Flush()
+}
+
+func runA11y(cmd *cobra.Command, args []string) error {
+	if flagRequire != "" && flagRequire != "aa" && flagRequire != "aaa" {
+		return fmt.Errorf("unknown level %q (valid: aa, aaa)", flagRequire)
+	}
+
 	theme, err := paletteswap.Load(flagTheme)
 	if err != nil {
-		return fmt.Errorf("loading theme: %w", err)
+		return err
+	}
+	checks, err := a11y.Report(theme)
+	if err != nil {
+		return err
+	}
+
+	if flagJSON {
+		err = a11y.WriteJSON(cmd.OutOrStdout(), checks)
+	} else {
+		err = a11y.WriteTable(cmd.OutOrStdout(), checks)
+	}
+	if err != nil {
+		return err
+	}
+
+	if flagRequire != "" {
+		failed := 0
+		for _, c := range checks {
+			if !c.Passes(flagRequire) {
+				failed++
+			}
+		}
+		if failed > 0 {
+			cmd.SilenceUsage = true
+			return &paletteswap.Error{
+				Kind: paletteswap.KindValidation,
+				Err:  fmt.Errorf("%d of %d pairs are below WCAG %s", failed, len(checks), strings.ToUpper(flagRequire)),
+			}
+		}
+	}
+	return nil
+}
+
+// checkProblem is one problem reported by check.
+type checkProblem struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`   // 1-based; 0 for problems with a whole file
+	Column   int    `json:"column,omitempty"` // 1-based, in UTF-16 code units
+	Severity string `json:"severity"`
+	Rule     string `json:"rule,omitempty"` // lint rule ID, empty for other problems
+	Name     string `json:"name,omitempty"` // lint rule name
+	Message  string `json:"message"`
+}
+
+func runCheck(cmd *cobra.Command, args []string) error {
+	src, err := os.ReadFile(flagTheme)
+	if err != nil {
+		return &paletteswap.Error{Kind: paletteswap.KindIO, Err: fmt.Errorf("reading theme: %w", err)}
+	}
+
+	result := lsp.Analyze(flagTheme, string(src))
+	problems := make([]checkProblem, 0, len(result.Diagnostics))
+	errorKind := paletteswap.ErrorKind(0)
+	errorCount := 0
+	for _, d := range result.Diagnostics {
+		p := checkProblem{
+			File:     flagTheme,
+			Line:     int(d.Range.Start.Line) + 1,
+			Column:   int(d.Range.Start.Character) + 1,
+			Severity: "info",
+			Message:  d.Message,
+		}
+		if d.Code != nil {
+			if id, ok := d.Code.Value.(string); ok {
+				if rule, ok := lint.Lookup(id); ok {
+					p.Rule, p.Name = rule.ID, rule.Name
+				}
+			}
+		}
+		if d.Severity != nil {
+			switch *d.Severity {
+			case lsp.DiagError:
+				p.Severity = "error"
+				errorCount++
+				// Problems outside the lint rules keep the theme from loading.
+				if p.Rule == "" {
+					errorKind = paletteswap.KindConfig
+				} else if errorKind == 0 {
+					errorKind = paletteswap.KindValidation
+				}
+			case lsp.DiagWarning:
+				p.Severity = "warning"
+			}
+		}
+		problems = append(problems, p)
+	}
+
+	// Load the theme as generate does, so that check passes only for
+	// themes generate can use, even where the analysis misses a problem.
+	var loaded *paletteswap.Theme
+	if errorCount == 0 {
+		theme, err := paletteswap.Load(flagTheme)
+		if err != nil {
+			problems = append(problems, checkProblem{File: flagTheme, Severity: "error", Message: err.Error()})
+			errorCount++
+			errorKind = paletteswap.KindOf(err)
+			if errorKind == 0 {
+				errorKind = paletteswap.KindConfig
+			}
+		}
+		loaded = theme
+	}
+
+	if flagOutputs != "" && errorCount == 0 {
+		stale, err := staleOutputs(loaded)
+		if err != nil {
+			return err
+		}
+		for _, s := range stale {
+			msg := fmt.Sprintf("stale: differs from what %s renders; run paletteswap generate", s.Template)
+			if s.Missing {
+				msg = fmt.Sprintf("stale: missing; run paletteswap generate to render it from %s", s.Template)
+			}
+			problems = append(problems, checkProblem{File: s.Path, Severity: "error", Message: msg})
+			errorCount++
+			if errorKind == 0 {
+				errorKind = paletteswap.KindValidation
+			}
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	if flagJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(problems); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range problems {
+			if p.Line > 0 {
+				fmt.Fprintf(out, "%s:%d:%d: %s: %s", p.File, p.Line, p.Column, p.Severity, p.Message)
+			} else {
+				fmt.Fprintf(out, "%s: %s: %s", p.File, p.Severity, p.Message)
+			}
+			if p.Rule != "" {
+				fmt.Fprintf(out, " [%s %s]", p.Rule, p.Name)
+			}
+			fmt.Fprintln(out)
+		}
 	}
 
+	if errorCount > 0 {
+		cmd.SilenceUsage = true
+		return &paletteswap.Error{Kind: errorKind, Err: fmt.Errorf("%s: %d of %d problems are errors", flagTheme, errorCount, len(problems))}
+	}
+	return nil
+}
+
+// staleOutputs returns the generated files in --outputs that do not match
+// what the templates render for theme, loaded from --theme.
+func staleOutputs(theme *paletteswap.Theme) ([]paletteswap.StaleOutput, error) {
+	le, err := format.ParseLineEnding(flagLineEnding)
+	if err != nil {
+		return nil, err
+	}
 	e := &paletteswap.Engine{
 		TemplatesDir: flagTemplates,
-		OutputDir:    flagOut,
+		OutputDir:    flagOutputs,
 		Apps:         flagApp,
+		LineEnding:   le,
+	}
+	return e.Stale(theme)
+}
+
+func runWhich(cmd *cobra.Command, args []string) error {
+	c, err := color.ParseHex(args[0])
+	if err != nil {
+		return err
+	}
+
+	theme, err := paletteswap.Load(flagTheme)
+	if err != nil {
+		return err
+	}
+
+	matches := theme.Which(c, flagMaxDist)
+	if len(matches) == 0 {
+		cmd.SilenceUsage = true
+		if nearest := theme.Which(c, math.Inf(1)); len(nearest) > 0 {
+			return fmt.Errorf("no entry within %g of %s; the nearest is %s (%s, distance %.3f)",
+				flagMaxDist, c.Hex(), nearest[0].Path, nearest[0].Color.Hex(), nearest[0].Distance)
+		}
+		return fmt.Errorf("no entry within %g of %s", flagMaxDist, c.Hex())
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	for _, m := range matches {
+		if m.Distance == 0 {
+			fmt.Fprintf(w, "%s\t%s\n", m.Path, m.Color.Hex())
+		} else {
+			fmt.Fprintf(w, "%s\t%s\tdistance %.3f\n", m.Path, m.Color.Hex(), m.Distance)
+		}
+	}
+	return w.Flush()
+}
+
+func runGraph(cmd *cobra.Command, args []string) error {
+	src, err := os.ReadFile(flagTheme)
+	if err != nil {
+		return &paletteswap.Error{Kind: paletteswap.KindIO, Err: fmt.Errorf("reading theme: %w", err)}
+	}
+
+	g, err := graph.Build(flagTheme, src)
+	if err != nil {
+		return &paletteswap.Error{Kind: paletteswap.KindConfig, Err: err}
+	}
+
+	switch flagFormat {
+	case "dot":
+		return g.WriteDOT(cmd.OutOrStdout())
+	case "mermaid":
+		return g.WriteMermaid(cmd.OutOrStdout())
+	case "usage":
+		return g.WriteUsage(cmd.OutOrStdout())
+	default:
+		return fmt.Errorf("unknown format %q (valid: dot, mermaid, usage)", flagFormat)
+	}
+}
+
+func runDerive(cmd *cobra.Command, args []string) error {
+	src, err := os.ReadFile(flagTheme)
+	if err != nil {
+		return &paletteswap.Error{Kind: paletteswap.KindIO, Err: fmt.Errorf("reading theme: %w", err)}
 	}
 
-	if err := e.Run(theme); err != nil {
-		return fmt.Errorf("generating: %w", err)
+	out, err := derive.Derive(flagTheme, src, flagAppearance)
+	if err != nil {
+		cmd.SilenceUsage = true
+		return &paletteswap.Error{Kind: paletteswap.KindConfig, Err: err}
 	}
 
-	fmt.Fprintf(cmd.OutOrStdout(), "Generated theme files in %s\n", flagOut)
+	if flagDeriveOut == "-" {
+		_, err := cmd.OutOrStdout().Write(out)
+		return err
+	}
+	path := flagDeriveOut
+	if path == "" {
+		ext := filepath.Ext(flagTheme)
+		path = strings.TrimSuffix(flagTheme, ext) + "-" + flagAppearance + ext
+	}
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		cmd.SilenceUsage = true
+		return &paletteswap.Error{Kind: paletteswap.KindIO, Err: fmt.Errorf("writing %s: %w", path, err)}
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s\n", path)
 	return nil
 }
 
 func runFmt(cmd *cobra.Command, args []string) error {
-	hasErrors := false
-	needsFormatting := false
+	le, err := format.ParseLineEnding(flagLineEnding)
+	if err != nil {
+		return err
+	}
+	hc, err := format.ParseHexCase(flagHexCase)
+	if err != nil {
+		return err
+	}
+	if flagIndent < 0 {
+		return fmt.Errorf("--indent must not be negative")
+	}
+	opts := format.Options{
+		LineEnding:    le,
+		IndentSize:    flagIndent,
+		NoAlign:       !flagAlign,
+		HexCase:       hc,
+		KeepANSIOrder: !flagSortANSI,
+	}
+
+	if isStdinArgs(args) {
+		return runFmtStdin(cmd, opts)
+	}
+
+	// Each failure is reported as it happens; the first one decides the exit code.
+	var firstErr error
+	fail := func(kind paletteswap.ErrorKind, msg, path string, err error) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Error %s %s: %v\n", msg, path, err)
+		if firstErr == nil {
+			firstErr = &paletteswap.Error{Kind: kind, Err: fmt.Errorf("%s %s: %w", msg, path, err)}
+		}
+	}
+	changed, failed := 0, 0
 
 	for _, path := range args {
 		data, err := os.ReadFile(path)
 		if err != nil {
-			fmt.Fprintf(cmd.ErrOrStderr(), "Error reading %s: %v\n", path, err)
-			hasErrors = true
+			failed++
+			fail(paletteswap.KindIO, "reading", path, err)
+			continue
+		}
+
+		fileOpts, err := withEditorConfig(opts, path)
+		if err != nil {
+			failed++
+			fail(paletteswap.KindConfig, "formatting", path, err)
 			continue
 		}
 
+		// Format tolerates broken input; rewriting it would lose content.
 		content := string(data)
-		formatted, err := format.Format(content)
+		if err := format.Validate(path, content); err != nil {
+			failed++
+			fail(paletteswap.KindConfig, "parsing", path, err)
+			continue
+		}
+		formatted, err := format.FormatWithOptions(content, fileOpts)
 		if err != nil {
-			fmt.Fprintf(cmd.ErrOrStderr(), "Error formatting %s: %v\n", path, err)
-			hasErrors = true
+			failed++
+			fail(paletteswap.KindConfig, "formatting", path, err)
 			continue
 		}
 
@@ -101,26 +714,209 @@ func runFmt(cmd *cobra.Command, args []string) error {
 			continue
 		}
 
-		fmt.Fprintln(cmd.OutOrStdout(), path)
-		needsFormatting = true
+		changed++
+		if flagSummary {
+			added, removed := format.DiffStat(content, formatted)
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: +%d -%d\n", path, added, removed)
+		} else {
+			fmt.Fprintln(cmd.OutOrStdout(), path)
+		}
 
 		if !flagCheck {
 			if err := os.WriteFile(path, []byte(formatted), 0o644); err != nil {
-				fmt.Fprintf(cmd.ErrOrStderr(), "Error writing %s: %v\n", path, err)
-				hasErrors = true
+				failed++
+				fail(paletteswap.KindIO, "writing", path, err)
 			}
 		}
 	}
 
-	if hasErrors || (flagCheck && needsFormatting) {
-		os.Exit(1)
+	if flagSummary {
+		fmtSummary(cmd, len(args), changed, failed)
+	}
+	if firstErr == nil && flagCheck && changed > 0 {
+		firstErr = errNeedsFormatting
+	}
+	if firstErr != nil {
+		// Already reported above; only the exit code is left to set.
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+	}
+	return firstErr
+}
+
+// fmtSummary prints the final count of fmt --summary.
+func fmtSummary(cmd *cobra.Command, total, changed, failed int) {
+	out := cmd.OutOrStdout()
+	if flagCheck {
+		fmt.Fprintf(out, "%d of %d files need formatting\n", changed, total)
+	} else {
+		fmt.Fprintf(out, "%d of %d files reformatted\n", changed, total)
+	}
+	if failed > 0 {
+		fmt.Fprintf(out, "%d of %d files failed\n", failed, total)
+	}
+}
+
+// errNeedsFormatting is returned by fmt --check for unformatted input.
+var errNeedsFormatting = errors.New("files need formatting")
+
+// fmtError gives err the exit code of fmt, which unlike the other commands
+// is for use by pre-commit hooks, as with gofmt and diff: 1 if --check found
+// files that need formatting, and 2 for any other error, including usage
+// errors, which would otherwise also exit 1.
+func fmtError(err error) error {
+	if err == nil || errors.Is(err, errNeedsFormatting) {
+		return err
+	}
+	return &paletteswap.Error{Kind: paletteswap.KindConfig, Err: err}
+}
+
+// withEditorConfig returns opts with the indent size the .editorconfig files
+// set for path, unless --indent was given.
+func withEditorConfig(opts format.Options, path string) (format.Options, error) {
+	if opts.IndentSize != 0 {
+		return opts, nil
+	}
+	size, err := format.EditorConfigIndent(path)
+	if err != nil {
+		return opts, err
+	}
+	opts.IndentSize = size
+	return opts, nil
+}
+
+// isStdinArgs reports whether fmt should read from stdin: either a lone "-"
+// argument, or --stdin-filename with no file arguments.
+func isStdinArgs(args []string) bool {
+	if len(args) == 1 && args[0] == "-" {
+		return true
+	}
+	return flagStdinName != "" && len(args) == 0
+}
+
+// runFmtStdin formats stdin to stdout. Unparseable input is reported on
+// stderr with a non-zero exit and no output, so editors keep the buffer as-is.
+func runFmtStdin(cmd *cobra.Command, opts format.Options) error {
+	name := flagStdinName
+	if name == "" {
+		name = "<stdin>"
+	}
+
+	data, err := io.ReadAll(cmd.InOrStdin())
+	if err != nil {
+		return &paletteswap.Error{Kind: paletteswap.KindIO, Err: fmt.Errorf("reading stdin: %w", err)}
+	}
+
+	if flagStdinName != "" {
+		opts, err = withEditorConfig(opts, flagStdinName)
+		if err != nil {
+			return &paletteswap.Error{Kind: paletteswap.KindConfig, Err: err}
+		}
+	}
+
+	content := string(data)
+	if err := format.Validate(name, content); err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return &paletteswap.Error{Kind: paletteswap.KindConfig, Err: err}
+	}
+
+	formatted, err := format.FormatWithOptions(content, opts)
+	if err != nil {
+		return &paletteswap.Error{Kind: paletteswap.KindConfig, Err: fmt.Errorf("formatting %s: %w", name, err)}
+	}
+
+	if flagCheck {
+		if formatted != content {
+			fmt.Fprintln(cmd.OutOrStdout(), name)
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%s: %w", name, errNeedsFormatting)
+		}
+		return nil
+	}
+
+	fmt.Fprint(cmd.OutOrStdout(), formatted)
+	return nil
+}
+
+func runPreview(cmd *cobra.Command, args []string) error {
+	if _, err := os.Stat(flagTheme); err != nil {
+		return fmt.Errorf("reading theme: %w", err)
+	}
+
+	ln, err := net.Listen("tcp", flagAddr)
+	if err != nil {
+		return err
 	}
 
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := &preview.Server{
+		ThemePath: flagTheme,
+		Watch:     flagWatch,
+		Logger:    newLogger(cmd),
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Previewing %s at http://%s\n", flagTheme, ln.Addr())
+	if err := s.Serve(ctx, ln); err != nil {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("preview server: %w", err)
+	}
 	return nil
 }
 
+func runLSP(cmd *cobra.Command, args []string) error {
+	logger, closeLog, err := lsp.NewLogger(flagLogFile, flagVerbose)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	opts := flagLSP
+	opts.Version = version
+	opts.Logger = logger
+
+	// Stop cleanly when the editor kills us instead of closing stdin.
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer stop()
+
+	if err := lsp.Serve(ctx, opts); err != nil {
+		cmd.SilenceUsage = true // not a usage error
+		return fmt.Errorf("language server: %w", err)
+	}
+	return nil
+}
+
+// Exit codes let scripts and CI branch on the class of failure. fmt has its
+// own, see fmtError.
+const (
+	exitFailure    = 1 // any other error, e.g. a usage error
+	exitConfig     = 2 // the theme file is malformed or cannot be evaluated
+	exitValidation = 3 // the theme breaks a rule, or a11y --require failed
+	exitTemplate   = 4 // a template failed to parse or execute
+	exitIO         = 5 // reading or writing a file failed
+)
+
+// exitCode maps err to the exit code for its paletteswap.ErrorKind.
+func exitCode(err error) int {
+	switch paletteswap.KindOf(err) {
+	case paletteswap.KindConfig:
+		return exitConfig
+	case paletteswap.KindValidation:
+		return exitValidation
+	case paletteswap.KindTemplate:
+		return exitTemplate
+	case paletteswap.KindIO:
+		return exitIO
+	default:
+		return exitFailure
+	}
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/jsvensson/paletteswap/internal/lsp"
+	"github.com/jsvensson/paletteswap/internal/theme"
 )
 
 var version = "dev"
@@ -14,6 +18,20 @@ func main() {
 	var showVersion bool
 	flag.BoolVar(&showVersion, "version", false, "Print version and exit")
 	flag.BoolVar(&showVersion, "v", false, "Print version and exit (shorthand)")
+
+	var logFile string
+	var verbose bool
+	flag.StringVar(&logFile, "log-file", "", "Write logs to this file (default: stderr when -verbose is set)")
+	flag.BoolVar(&verbose, "verbose", false, "Log message traces and analysis timings")
+
+	opts := lsp.Options{Version: version, Limits: theme.DefaultLimits}
+	flag.StringVar(&opts.Listen, "listen", "", "Accept TCP connections on this address (e.g. :7998) instead of using stdio")
+	flag.BoolVar(&opts.NodeIPC, "node-ipc", false, "Communicate over the Node.js IPC channel instead of stdio")
+	flag.Bool("stdio", true, "Communicate over stdin/stdout (the default; accepted for editor compatibility)")
+
+	flag.Int64Var(&opts.Limits.MaxFileSize, "max-file-size", opts.Limits.MaxFileSize, "Maximum theme file size in bytes (0 disables)")
+	flag.IntVar(&opts.Limits.MaxPaletteEntries, "max-palette-entries", opts.Limits.MaxPaletteEntries, "Maximum number of palette entries (0 disables)")
+	flag.IntVar(&opts.Limits.MaxTransformSteps, "max-transform-steps", opts.Limits.MaxTransformSteps, "Maximum lightness transform and scale steps (0 disables)")
 	flag.Parse()
 
 	if showVersion {
@@ -21,8 +39,22 @@ func main() {
 		os.Exit(0)
 	}
 
-	s := lsp.NewServer(version)
-	if err := s.Run(); err != nil {
+	logger, closeLog, err := lsp.NewLogger(logFile, verbose)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer closeLog()
+	opts.Logger = logger
+
+	// Stop cleanly when the editor kills us instead of closing stdin, so
+	// pending diagnostics are flushed and no orphaned process is left.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer stop()
+
+	if err := lsp.Serve(ctx, opts); err != nil {
+		logger.Error("server stopped", "error", err)
+		closeLog()
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,106 @@
+package paletteswap
+
+import (
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// deprecation describes a template function, or a form of calling one,
+// that still works but is slated for removal. Each run logs a warning
+// listing the templates that use it.
+type deprecation struct {
+	replacement string // what to use instead, shown in the warning
+	// matches reports whether a call with these arguments uses the
+	// deprecated form. A value piped into the call is the last argument.
+	// Nil matches every call.
+	matches func(args []parse.Node) bool
+}
+
+// deprecatedAliases maps the old names of renamed template functions to
+// their current names. Old names keep working as aliases of the new ones.
+var deprecatedAliases = map[string]string{
+	"hexBare": "bhex",
+}
+
+// deprecatedFuncs lists the deprecated template functions and call forms,
+// keyed by function name. Remove an entry once theme packs have had a
+// release or two to migrate.
+var deprecatedFuncs = map[string]deprecation{
+	"hexBare": {replacement: "bhex"},
+	"palette": {replacement: `a "palette." path, e.g. hex "palette.base"`},
+	"style":   {replacement: `a block-prefixed path, e.g. style "syntax.keyword"`, matches: unprefixedPath},
+}
+
+// addDeprecatedAliases registers every deprecated alias in funcs.
+func addDeprecatedAliases(funcs template.FuncMap) {
+	for old, replacement := range deprecatedAliases {
+		funcs[old] = funcs[replacement]
+	}
+}
+
+// unprefixedPath reports whether the first argument is a string literal
+// that does not start with a block name, such as "custom.bold".
+func unprefixedPath(args []parse.Node) bool {
+	if len(args) == 0 {
+		return false
+	}
+	s, ok := args[0].(*parse.StringNode)
+	if !ok {
+		return false
+	}
+	block, _, _ := strings.Cut(s.Text, ".")
+	return !slices.Contains(pathBlocks, block)
+}
+
+// pipedOperand returns the value cmd pipes into the next command when it
+// is a lone constant, such as "custom.bold" in {{ "custom.bold" | style }},
+// and cmd itself otherwise, which no matcher takes for a literal.
+func pipedOperand(cmd *parse.CommandNode) parse.Node {
+	if len(cmd.Args) == 1 {
+		if s, ok := cmd.Args[0].(*parse.StringNode); ok {
+			return s
+		}
+	}
+	return cmd
+}
+
+// deprecatedUses returns the deprecated functions used by tmpl and the
+// templates it defines, sorted by name.
+func deprecatedUses(tmpl *template.Template) []string {
+	var used []string
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		walkTree(t.Tree.Root, func(node parse.Node) {
+			pipe, ok := node.(*parse.PipeNode)
+			if !ok {
+				return
+			}
+			for i, cmd := range pipe.Cmds {
+				if len(cmd.Args) == 0 {
+					continue
+				}
+				id, ok := cmd.Args[0].(*parse.IdentifierNode)
+				if !ok {
+					continue
+				}
+				d, ok := deprecatedFuncs[id.Ident]
+				if !ok || slices.Contains(used, id.Ident) {
+					continue
+				}
+				args := cmd.Args[1:]
+				if i > 0 {
+					args = append(slices.Clip(args), pipedOperand(pipe.Cmds[i-1]))
+				}
+				if d.matches == nil || d.matches(args) {
+					used = append(used, id.Ident)
+				}
+			}
+		})
+	}
+	slices.Sort(used)
+	return used
+}
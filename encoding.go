@@ -0,0 +1,41 @@
+package paletteswap
+
+import (
+	"encoding/binary"
+	"unicode/utf16"
+)
+
+// Output encodings a template can select with its pragma line, e.g.
+//
+//	#!ps: encoding=utf16le
+const (
+	encodingUTF8    = "utf8"     // UTF-8 without a byte-order mark (the default)
+	encodingUTF8BOM = "utf8-bom" // UTF-8 with a byte-order mark
+	encodingUTF16LE = "utf16le"  // UTF-16LE with a byte-order mark, as Windows registry files use
+)
+
+// outputEncodings lists the valid output encodings.
+var outputEncodings = []string{encodingUTF8, encodingUTF8BOM, encodingUTF16LE}
+
+// utf8BOM is the UTF-8 byte-order mark. Templates starting with one have it
+// removed before parsing, so it never reaches the output unless the
+// template asks for utf8-bom.
+const utf8BOM = "\uFEFF"
+
+// encode returns s, which is UTF-8, in the output encoding enc. The empty
+// encoding is utf8.
+func encode(s, enc string) []byte {
+	switch enc {
+	case encodingUTF8BOM:
+		return []byte(utf8BOM + s)
+	case encodingUTF16LE:
+		units := utf16.Encode([]rune(utf8BOM + s))
+		out := make([]byte, 0, 2*len(units))
+		for _, u := range units {
+			out = binary.LittleEndian.AppendUint16(out, u)
+		}
+		return out
+	default:
+		return []byte(s)
+	}
+}
@@ -1,98 +1,469 @@
 package paletteswap
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/format"
+	"github.com/jsvensson/paletteswap/internal/metrics"
 )
 
-// Engine loads and executes Go templates against a resolved Theme.
+// Engine loads and executes Go templates against a resolved Theme. Parsed
+// templates are cached across Run calls until their files change, so one
+// Engine should be reused when generating several themes.
+//
+// Run and RunContext may be called concurrently, for example from a watch
+// loop while another run is in progress. The exported fields must not be
+// changed while a run may be in progress; to render concurrently into
+// different directories, derive an Engine per directory with WithOutputDir.
 type Engine struct {
 	TemplatesDir string
 	OutputDir    string
-	Apps         []string // if non-empty, only render these template basenames
+	Apps         []string     // if non-empty, only render templates matching these names or globs (see matchApp)
+	Logger       *slog.Logger // if non-nil, receives debug logs of discovery and rendering
+	// SchemaVersion pins the TemplateData contract version templates are
+	// written against; Run fails with a KindConfig error if it is not in
+	// SupportedSchemaVersions. Zero means the theme's SchemaVersion, or
+	// TemplateDataVersion if that is zero too. Version 1 is the only
+	// version so far, so the pin changes no data yet.
+	SchemaVersion int
+	// LineEnding selects the line endings of generated files. The zero value
+	// keeps those of each template; LineEndingNative uses the platform's.
+	LineEnding LineEnding
+	// AllowOutsideOut lets output paths leave OutputDir: absolute paths,
+	// paths starting with "~" (the home directory), and paths climbing out
+	// with "..". Without it such paths are rejected; see outputPath.
+	AllowOutsideOut bool
+
+	mu    sync.Mutex
+	cache *templateCache // created on first use; shared with WithOutputDir copies
+}
+
+// templateCache holds the parsed templates of one or more Engines.
+type templateCache struct {
+	mu     sync.Mutex
+	parsed map[string]parsedTemplate // keyed by template path
+}
+
+// parsedTemplate is a template parsed with placeholderFuncs. It is reused
+// while the file's size and modification time are unchanged.
+type parsedTemplate struct {
+	tmpl       *template.Template
+	deprecated []string // deprecated function names the template uses
+	pragma     templatePragma
+	size       int64
+	modTime    time.Time
+}
+
+// placeholderFuncs declares the template functions at parse time. Each
+// render binds the functions of its theme to a clone of the parsed template.
+var placeholderFuncs = buildTemplateData(&Theme{}).FuncMap
+
+// logger returns the configured logger, or one that discards everything.
+func (e *Engine) logger() *slog.Logger {
+	if e.Logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return e.Logger
+}
+
+// WithOutputDir returns a copy of e that writes to dir. The copy shares the
+// template cache of e, so each template is still parsed only once.
+func (e *Engine) WithOutputDir(dir string) *Engine {
+	return &Engine{
+		TemplatesDir:    e.TemplatesDir,
+		OutputDir:       dir,
+		Apps:            e.Apps,
+		Logger:          e.Logger,
+		SchemaVersion:   e.SchemaVersion,
+		LineEnding:      e.LineEnding,
+		AllowOutsideOut: e.AllowOutsideOut,
+		cache:           e.templates(),
+	}
+}
+
+// templates returns the template cache of e, creating it if needed.
+func (e *Engine) templates() *templateCache {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cache == nil {
+		e.cache = &templateCache{parsed: make(map[string]parsedTemplate)}
+	}
+	return e.cache
 }
 
 // Run loads all .tmpl files from the templates directory, executes them
 // with the given theme data, and writes output files.
 func (e *Engine) Run(theme *Theme) error {
-	pattern := filepath.Join(e.TemplatesDir, "*.tmpl")
-	matches, err := filepath.Glob(pattern)
+	return e.RunContext(context.Background(), theme)
+}
+
+// RunContext is like Run but stops before the next template once ctx is
+// done, returning the context's error. Files already written are kept.
+func (e *Engine) RunContext(ctx context.Context, theme *Theme) error {
+	data, selected, appMatched, err := e.prepare(theme)
 	if err != nil {
-		return fmt.Errorf("globbing templates: %w", err)
-	}
-	if len(matches) == 0 {
-		return fmt.Errorf("no .tmpl files found in %s", e.TemplatesDir)
+		return err
 	}
+	log := e.logger()
 
 	if err := os.MkdirAll(e.OutputDir, 0755); err != nil {
-		return fmt.Errorf("creating output directory: %w", err)
+		return errorf(KindIO, "creating output directory: %w", err)
 	}
 
-	data := buildTemplateData(theme)
+	deprecated := make(map[string][]string) // deprecated function -> templates using it
 
-	for _, tmplPath := range matches {
-		baseName := strings.TrimSuffix(filepath.Base(tmplPath), ".tmpl")
+	// A failing template does not stop the others; failures are summarized.
+	var failed []error
+	rendered := 0
+	for _, tmplPath := range selected {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("stopped before %s: %w", tmplPath, err)
+		}
+		baseName := outputName(tmplPath)
 
-		if !e.shouldRender(baseName) {
+		start := time.Now()
+		rendered++
+		used, err := e.renderTemplate(tmplPath, baseName, data)
+		for _, name := range used {
+			deprecated[name] = append(deprecated[name], tmplPath)
+		}
+		if err != nil {
+			log.Debug("template failed", "template", tmplPath, "error", err)
+			failed = append(failed, err)
 			continue
 		}
+		metrics.Since(ctx, metrics.PhaseRender, tmplPath, start)
+		log.Debug("rendered template", "template", tmplPath,
+			"output", filepath.Join(e.OutputDir, baseName), "duration", time.Since(start))
+	}
+
+	for i, app := range e.Apps {
+		if !appMatched[i] {
+			log.Warn("app matched no templates", "app", app, "dir", e.TemplatesDir)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(deprecated)) {
+		log.Warn("template function is deprecated", "function", name,
+			"replacement", deprecatedFuncs[name].replacement, "templates", strings.Join(deprecated[name], ", "))
+	}
+
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return failed[0]
+	default:
+		return fmt.Errorf("%d of %d templates failed:\n%w", len(failed), rendered, errors.Join(failed...))
+	}
+}
+
+// outputName returns the output file name of the template at tmplPath.
+func outputName(tmplPath string) string {
+	return strings.TrimSuffix(filepath.Base(tmplPath), ".tmpl")
+}
+
+// prepare validates the settings of e and returns the template data for
+// theme and the templates to render: those selected by e.Apps whose
+// requirements the theme meets. appMatched records which app patterns
+// selected a template.
+func (e *Engine) prepare(theme *Theme) (data TemplateData, selected []string, appMatched []bool, err error) {
+	matches, err := findTemplates(e.TemplatesDir)
+	if err != nil {
+		return data, nil, nil, err
+	}
+	requested := e.SchemaVersion
+	if requested == 0 {
+		requested = theme.SchemaVersion
+	}
+	version, err := checkSchemaVersion(requested)
+	if err != nil {
+		return data, nil, nil, err
+	}
+	if _, err := format.ParseLineEnding(string(e.LineEnding)); err != nil {
+		return data, nil, nil, errorf(KindConfig, "%w", err)
+	}
+	for _, app := range e.Apps {
+		if _, err := path.Match(strings.ToLower(app), ""); err != nil {
+			return data, nil, nil, errorf(KindConfig, "invalid app pattern %q: %w", app, err)
+		}
+	}
+	log := e.logger()
+	log.Debug("discovered templates", "dir", e.TemplatesDir, "count", len(matches))
+
+	data = buildTemplateData(theme)
+	data.SchemaVersion = version
+	appMatched = make([]bool, len(e.Apps))
+	for _, tmplPath := range matches {
+		if e.shouldRender(outputName(tmplPath), appMatched) {
+			selected = append(selected, tmplPath)
+		} else {
+			log.Debug("skipping template", "template", tmplPath)
+		}
+	}
+	selected, err = e.checkRequirements(selected, data)
+	if err != nil {
+		return data, nil, nil, err
+	}
+	if err := e.checkCollisions(selected); err != nil {
+		return data, nil, nil, err
+	}
+	return data, selected, appMatched, nil
+}
 
-		if err := e.renderTemplate(tmplPath, baseName, data); err != nil {
-			return err
+// checkRequirements returns the templates of tmplPaths whose pragma
+// requirements the theme in data meets, leaving out with a warning those
+// that ask to be skipped otherwise. Any other unmet requirement is a
+// KindValidation error, returned before any template is rendered. Templates
+// that fail to parse are kept for rendering to report.
+func (e *Engine) checkRequirements(tmplPaths []string, data TemplateData) ([]string, error) {
+	var kept []string
+	var failed []error
+	for _, tmplPath := range tmplPaths {
+		p, err := e.parseTemplate(tmplPath)
+		if err != nil {
+			kept = append(kept, tmplPath)
+			continue
+		}
+		missing := p.pragma.missing(data)
+		switch {
+		case len(missing) == 0:
+			kept = append(kept, tmplPath)
+		case p.pragma.skip:
+			e.logger().Warn("skipping template: theme does not define what it requires",
+				"template", tmplPath, "missing", strings.Join(missing, ", "))
+		default:
+			failed = append(failed, errorf(KindValidation, "template %s requires %s, which the theme does not define",
+				tmplPath, strings.Join(missing, ", ")))
 		}
 	}
 
+	switch len(failed) {
+	case 0:
+		return kept, nil
+	case 1:
+		return nil, failed[0]
+	default:
+		return nil, fmt.Errorf("%d templates have unmet requirements:\n%w", len(failed), errors.Join(failed...))
+	}
+}
+
+// checkCollisions returns a KindConfig error naming both templates if two
+// of tmplPaths write to the same output file, so that neither overwrites
+// the other. Templates whose output path is invalid are left for rendering
+// to report.
+func (e *Engine) checkCollisions(tmplPaths []string) error {
+	writers := make(map[string]string, len(tmplPaths)) // output path -> template path
+	for _, tmplPath := range tmplPaths {
+		outPath, err := e.outputPath(outputName(tmplPath))
+		if err != nil {
+			continue
+		}
+		if other, ok := writers[outPath]; ok {
+			return errorf(KindConfig, "templates %s and %s both write to %s", other, tmplPath, outPath)
+		}
+		writers[outPath] = tmplPath
+	}
 	return nil
 }
 
-func (e *Engine) shouldRender(name string) bool {
+// shouldRender reports whether the template output name is selected by
+// e.Apps, recording in matched which app patterns selected it.
+func (e *Engine) shouldRender(name string, matched []bool) bool {
 	// If no apps are specified, render all.
 	if len(e.Apps) == 0 {
 		return true
 	}
 
-	return slices.Contains(e.Apps, name)
+	render := false
+	for i, app := range e.Apps {
+		if matchApp(app, name) {
+			matched[i] = true
+			render = true
+		}
+	}
+	return render
 }
 
-func (e *Engine) renderTemplate(tmplPath, outputName string, data templateData) error {
-	tmpl, err := template.New(filepath.Base(tmplPath)).Funcs(data.FuncMap).ParseFiles(tmplPath)
-	if err != nil {
-		return fmt.Errorf("parsing template %s: %w", tmplPath, err)
+// matchApp reports whether an app pattern selects the template output name.
+// Matching is case-insensitive, the pattern may use glob syntax ("kitty*"),
+// and it may name the output with or without its extension, so "zed",
+// "zed.json" and "zed.json.tmpl" all select zed.json.tmpl.
+func matchApp(pattern, name string) bool {
+	pattern = strings.TrimSuffix(strings.ToLower(pattern), ".tmpl")
+	name = strings.ToLower(name)
+	for _, candidate := range []string{name, strings.TrimSuffix(name, path.Ext(name))} {
+		if ok, _ := path.Match(pattern, candidate); ok {
+			return true
+		}
 	}
+	return false
+}
 
-	outPath := filepath.Join(e.OutputDir, outputName)
-	f, err := os.Create(outPath)
+// renderTemplate renders the template at tmplPath into outputName. It
+// returns the deprecated functions the template uses, even if rendering fails.
+func (e *Engine) renderTemplate(tmplPath, outputName string, data TemplateData) ([]string, error) {
+	p, err := e.parseTemplate(tmplPath)
 	if err != nil {
-		return fmt.Errorf("creating output file %s: %w", outPath, err)
+		return nil, err
 	}
-	defer f.Close()
+	tmpl, deprecated := p.tmpl, p.deprecated
+	tmpl.Funcs(data.FuncMap)
 
-	if err := tmpl.Execute(f, data); err != nil {
-		return fmt.Errorf("executing template %s: %w", tmplPath, err)
+	outPath, err := e.outputPath(outputName)
+	if err != nil {
+		return deprecated, err
+	}
+	if p.pragma.patch != "" {
+		return deprecated, e.patchOutput(outPath, tmplPath, tmpl, data, p.pragma.patch)
+	}
+	// Render in memory first, so a template that fails partway leaves any
+	// previous output in place rather than a truncated file.
+	var buf bytes.Buffer
+	if err := e.execute(&buf, tmpl, data, p.pragma.encoding); err != nil {
+		return deprecated, newTemplateError(tmplPath, outPath, err)
+	}
+	if err := os.WriteFile(outPath, buf.Bytes(), 0o666); err != nil {
+		return deprecated, errorf(KindIO, "writing output file %s: %w", outPath, err)
 	}
 
+	return deprecated, nil
+}
+
+// patchOutput renders tmpl into the marked region of the file at outPath,
+// creating the file or region if missing. The rest of the file is kept.
+func (e *Engine) patchOutput(outPath, tmplPath string, tmpl *template.Template, data TemplateData, prefix string) error {
+	var buf strings.Builder
+	if err := e.execute(&buf, tmpl, data, ""); err != nil {
+		return newTemplateError(tmplPath, outPath, err)
+	}
+	existing, err := os.ReadFile(outPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errorf(KindIO, "reading output file %s: %w", outPath, err)
+	}
+	patched, err := patchRegion(string(existing), buf.String(), prefix)
+	if err != nil {
+		return errorf(KindIO, "patching output file %s: %w", outPath, err)
+	}
+	if err := os.WriteFile(outPath, []byte(patched), 0o666); err != nil {
+		return errorf(KindIO, "writing output file %s: %w", outPath, err)
+	}
 	return nil
 }
 
-// templateData is the data passed to templates.
-type templateData struct {
-	Meta    Meta
-	Palette *color.Node
-	Theme   map[string]color.Color
-	Syntax  color.Tree
-	ANSI    map[string]color.Color
-	FuncMap template.FuncMap
+// outputPath returns the path the output file name is written to. Names
+// are relative to e.OutputDir. Unless e.AllowOutsideOut is set, a name that
+// is absolute, starts with "~" or climbs out of the output directory with
+// ".." is a KindConfig error, so templates cannot overwrite files elsewhere.
+func (e *Engine) outputPath(name string) (string, error) {
+	outside := func() (string, error) {
+		return "", errorf(KindConfig, "output path %s is outside the output directory %s (pass --allow-outside-out to allow it)", name, e.OutputDir)
+	}
+	if home, err := expandHome(name); err != nil || home != name {
+		if !e.AllowOutsideOut {
+			return outside()
+		}
+		if err != nil {
+			return "", errorf(KindIO, "output path %s: %w", name, err)
+		}
+		return home, nil
+	}
+	if filepath.IsAbs(name) {
+		if !e.AllowOutsideOut {
+			return outside()
+		}
+		return filepath.Clean(name), nil
+	}
+
+	p := filepath.Join(e.OutputDir, name)
+	rel, err := filepath.Rel(e.OutputDir, p)
+	if err != nil {
+		return "", errorf(KindConfig, "output path %s: %w", name, err)
+	}
+	if rel == "." {
+		return "", errorf(KindConfig, "output path %q does not name a file", name)
+	}
+	if (rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))) && !e.AllowOutsideOut {
+		return outside()
+	}
+	return p, nil
+}
+
+// execute runs tmpl into w, converting its line endings if e.LineEnding
+// asks for it, and writing it in the output encoding enc.
+func (e *Engine) execute(w io.Writer, tmpl *template.Template, data TemplateData, enc string) error {
+	if e.LineEnding == LineEndingPreserve && (enc == "" || enc == encodingUTF8) {
+		return tmpl.Execute(w, data)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+	out := buf.String()
+	if e.LineEnding != LineEndingPreserve {
+		out = format.ConvertLineEndings(out, e.LineEnding)
+	}
+	_, err := w.Write(encode(out, enc))
+	return err
+}
+
+// parseTemplate returns the parsed template at tmplPath with a clone of its
+// template, which the caller may bind its own functions to. The file is
+// parsed only if it is not cached or has changed since it was cached.
+func (e *Engine) parseTemplate(tmplPath string) (parsedTemplate, error) {
+	info, err := os.Stat(tmplPath)
+	if err != nil {
+		return parsedTemplate{}, errorf(KindIO, "parsing template %s: %w", tmplPath, err)
+	}
+
+	c := e.templates()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.parsed[tmplPath]
+	if !ok || p.size != info.Size() || !p.modTime.Equal(info.ModTime()) {
+		src, err := os.ReadFile(tmplPath)
+		if err != nil {
+			return parsedTemplate{}, errorf(KindIO, "parsing template %s: %w", tmplPath, err)
+		}
+		pragma, body, err := parsePragma(strings.TrimPrefix(string(src), utf8BOM))
+		if err != nil {
+			return parsedTemplate{}, errorf(KindTemplate, "parsing template %s: %w", tmplPath, err)
+		}
+		tmpl, err := template.New(filepath.Base(tmplPath)).Funcs(placeholderFuncs).Parse(body)
+		if err != nil {
+			return parsedTemplate{}, newTemplateError(tmplPath, "", err)
+		}
+		p = parsedTemplate{tmpl: tmpl, deprecated: deprecatedUses(tmpl), pragma: pragma, size: info.Size(), modTime: info.ModTime()}
+		c.parsed[tmplPath] = p
+	}
+
+	tmpl, err := p.tmpl.Clone()
+	if err != nil {
+		return parsedTemplate{}, errorf(KindTemplate, "parsing template %s: %w", tmplPath, err)
+	}
+	p.tmpl = tmpl
+	return p, nil
 }
 
 // resolveColorPath resolves a universal dot-notation path to a Color.
-// Supports paths like "palette.base", "theme.background", "ansi.black", "syntax.keyword".
-func resolveColorPath(path string, data templateData) (color.Color, error) {
+// Supports paths like "palette.base", "theme.background", "ansi.black", "syntax.keyword",
+// "semantic.parameter".
+func resolveColorPath(path string, data TemplateData) (color.Color, error) {
 	parts := strings.Split(path, ".")
 	if len(parts) < 2 {
 		return color.Color{}, fmt.Errorf("invalid path %q: must be block.name format", path)
@@ -110,8 +481,11 @@ func resolveColorPath(path string, data templateData) (color.Color, error) {
 		return c, nil
 
 	case "theme":
+		if len(rest) == 2 {
+			return resolveThemeSubBlockPath(rest, data)
+		}
 		if len(rest) != 1 {
-			return color.Color{}, fmt.Errorf("theme paths must be single-level: %s", path)
+			return color.Color{}, fmt.Errorf("theme paths must be single-level or a cursor/selection attribute: %s", path)
 		}
 		c, ok := data.Theme[rest[0]]
 		if !ok {
@@ -130,62 +504,223 @@ func resolveColorPath(path string, data templateData) (color.Color, error) {
 		return c, nil
 
 	case "syntax":
-		style := getStyleFromTree(data.Syntax, rest)
-		if style.Color == (color.Color{}) {
+		style, ok := getStyleFromTree(data.Syntax, rest)
+		if !ok {
 			return color.Color{}, fmt.Errorf("syntax path not found: %s", path)
 		}
 		return style.Color, nil
 
+	case "semantic":
+		if len(rest) != 1 {
+			return color.Color{}, fmt.Errorf("semantic paths must be single-level: %s", path)
+		}
+		style, ok := data.Semantic[rest[0]]
+		if !ok {
+			return color.Color{}, fmt.Errorf("semantic token not found: %s", rest[0])
+		}
+		return style.Color, nil
+
 	default:
-		return color.Color{}, fmt.Errorf("unknown block %q (valid: palette, theme, ansi, syntax)", block)
+		return color.Color{}, fmt.Errorf("unknown block %q (valid: palette, theme, ansi, syntax, semantic)", block)
 	}
 }
 
-// getStyleFromTree traverses a Tree using path segments and returns the Style.
-func getStyleFromTree(tree color.Tree, path []string) color.Style {
+// resolveThemeSubBlockPath resolves a two-segment theme path such as
+// ["cursor", "text"] against the typed cursor and selection sub-blocks.
+func resolveThemeSubBlockPath(rest []string, data TemplateData) (color.Color, error) {
+	path := "theme." + strings.Join(rest, ".")
+	switch rest[0] {
+	case "cursor":
+		if data.Cursor == nil {
+			return color.Color{}, fmt.Errorf("theme has no cursor block: %s", path)
+		}
+		switch rest[1] {
+		case "cursor":
+			return data.Cursor.Color, nil
+		case "text":
+			return data.Cursor.Text, nil
+		}
+	case "selection":
+		if data.Selection == nil {
+			return color.Color{}, fmt.Errorf("theme has no selection block: %s", path)
+		}
+		switch rest[1] {
+		case "background":
+			return data.Selection.Background, nil
+		case "foreground":
+			return data.Selection.Foreground, nil
+		}
+	}
+	return color.Color{}, fmt.Errorf("theme color not found: %s", path)
+}
+
+// getStyleFromTree traverses a Tree using path segments and returns the
+// Style, and whether the path names one. Styles are told apart from missing
+// paths by ok rather than by a zero Color, which is pure black.
+func getStyleFromTree(tree color.Tree, path []string) (color.Style, bool) {
 	if len(path) == 0 {
-		return color.Style{}
+		return color.Style{}, false
 	}
 
 	current := tree
 	for i, part := range path {
 		val, ok := current[part]
 		if !ok {
-			return color.Style{}
+			return color.Style{}, false
 		}
 
 		// Last part should be a Style
 		if i == len(path)-1 {
-			if style, ok := val.(color.Style); ok {
-				return style
-			}
-			return color.Style{}
+			style, ok := val.(color.Style)
+			return style, ok
 		}
 
 		// Intermediate parts should be Trees
 		if subtree, ok := val.(color.Tree); ok {
 			current = subtree
 		} else {
-			return color.Style{}
+			return color.Style{}, false
 		}
 	}
 
-	return color.Style{}
+	return color.Style{}, false
 }
 
-func buildTemplateData(theme *Theme) templateData {
-	data := templateData{
-		Meta:    theme.Meta,
-		Palette: theme.Palette,
-		Theme:   theme.Theme,
-		Syntax:  theme.Syntax,
-		ANSI:    theme.ANSI,
+// derefColor converts a *color.Color, such as the Color of a palette node,
+// to a color value. Any other argument is returned unchanged.
+func derefColor(arg any) any {
+	if c, ok := arg.(*color.Color); ok && c != nil {
+		return *c
+	}
+	return arg
+}
+
+// unsupportedArg returns the error for a color function argument of the
+// wrong type.
+func unsupportedArg(name string, arg any) error {
+	if c, ok := arg.(*color.Color); ok && c == nil {
+		return fmt.Errorf("%s: no color (the palette group has no color attribute)", name)
+	}
+	return fmt.Errorf("%s: unsupported type %T", name, arg)
+}
+
+// colorArg resolves a single template function argument, given as a path
+// string or a color value, to a color.
+func colorArg(name string, arg any, data TemplateData) (color.Color, error) {
+	switch v := derefColor(arg).(type) {
+	case string:
+		c, err := resolveColorPath(v, data)
+		if err != nil {
+			return color.Color{}, fmt.Errorf("%s: %w", name, err)
+		}
+		return c, nil
+	case color.Color:
+		return v, nil
+	case color.AlphaColor:
+		return v.Color, nil
+	default:
+		return color.Color{}, unsupportedArg(name, arg)
+	}
+}
+
+// channelFunc returns a template function yielding one RGB channel of a
+// color as an integer in [0, 255].
+func channelFunc(name string, data TemplateData, channel func(color.Color) uint8) func(any) (int, error) {
+	return func(arg any) (int, error) {
+		c, err := colorArg(name, arg, data)
+		if err != nil {
+			return 0, err
+		}
+		return int(channel(c)), nil
+	}
+}
+
+// channelFloatFunc is like channelFunc but scales the channel to [0, 1].
+func channelFloatFunc(name string, data TemplateData, channel func(color.Color) uint8) func(any) (float64, error) {
+	return func(arg any) (float64, error) {
+		c, err := colorArg(name, arg, data)
+		if err != nil {
+			return 0, err
+		}
+		return float64(channel(c)) / 255, nil
+	}
+}
+
+// colorMathArgs sorts the arguments of a color math template function into
+// colors and a single number. Colors may be given as a path string or a color
+// value, and the number may appear in any position, so that both
+// {{ darken "palette.base" 0.1 }} and {{ "palette.base" | darken 0.1 }} work.
+func colorMathArgs(name string, numColors int, data TemplateData, args ...any) ([]color.Color, float64, error) {
+	var colors []color.Color
+	var number float64
+	haveNumber := false
+
+	for _, arg := range args {
+		switch v := derefColor(arg).(type) {
+		case string:
+			c, err := resolveColorPath(v, data)
+			if err != nil {
+				return nil, 0, fmt.Errorf("%s: %w", name, err)
+			}
+			colors = append(colors, c)
+		case color.Color:
+			colors = append(colors, v)
+		case color.AlphaColor:
+			colors = append(colors, v.Color)
+		case float64, int:
+			if haveNumber {
+				return nil, 0, fmt.Errorf("%s: expected %d color(s) and one number", name, numColors)
+			}
+			haveNumber = true
+			if i, ok := v.(int); ok {
+				number = float64(i)
+			} else {
+				number = v.(float64)
+			}
+		default:
+			return nil, 0, unsupportedArg(name, arg)
+		}
+	}
+
+	if !haveNumber || len(colors) != numColors {
+		return nil, 0, fmt.Errorf("%s: expected %d color(s) and one number", name, numColors)
+	}
+	return colors, number, nil
+}
+
+// alphaArgs resolves the color and alpha arguments of a template function
+// that sets a color's alpha channel, in either order. The alpha replaces any
+// alpha the color already has.
+func alphaArgs(name string, data TemplateData, a, b any) (color.AlphaColor, error) {
+	colors, alpha, err := colorMathArgs(name, 1, data, a, b)
+	if err != nil {
+		return color.AlphaColor{}, err
+	}
+	if alpha < 0 || alpha > 1 {
+		return color.AlphaColor{}, fmt.Errorf("%s: alpha value must be between 0 and 1, got %g", name, alpha)
+	}
+	return colors[0].WithAlpha(alpha), nil
+}
+
+func buildTemplateData(theme *Theme) TemplateData {
+	data := TemplateData{
+		SchemaVersion:  TemplateDataVersion,
+		Meta:           theme.Meta,
+		Palette:        theme.Palette,
+		Theme:          theme.Theme,
+		Syntax:         theme.Syntax,
+		LanguageSyntax: theme.LanguageSyntax,
+		Semantic:       theme.Semantic,
+		Cursor:         theme.Cursor,
+		Selection:      theme.Selection,
+		ANSI:           theme.ANSI,
+		sources:        theme.Sources,
 	}
 
 	// Universal path-based functions
 	data.FuncMap = template.FuncMap{
 		"hex": func(arg any) (string, error) {
-			switch v := arg.(type) {
+			switch v := derefColor(arg).(type) {
 			case string:
 				c, err := resolveColorPath(v, data)
 				if err != nil {
@@ -194,12 +729,14 @@ func buildTemplateData(theme *Theme) templateData {
 				return c.Hex(), nil
 			case color.Color:
 				return v.Hex(), nil
+			case color.AlphaColor:
+				return v.Hex(), nil
 			default:
-				return "", fmt.Errorf("hex: unsupported type %T", arg)
+				return "", unsupportedArg("hex", arg)
 			}
 		},
 		"bhex": func(arg any) (string, error) {
-			switch v := arg.(type) {
+			switch v := derefColor(arg).(type) {
 			case string:
 				c, err := resolveColorPath(v, data)
 				if err != nil {
@@ -208,12 +745,14 @@ func buildTemplateData(theme *Theme) templateData {
 				return c.HexBare(), nil
 			case color.Color:
 				return v.HexBare(), nil
+			case color.AlphaColor:
+				return v.HexBare(), nil
 			default:
-				return "", fmt.Errorf("bhex: unsupported type %T", arg)
+				return "", unsupportedArg("bhex", arg)
 			}
 		},
 		"hexa": func(arg any) (string, error) {
-			switch v := arg.(type) {
+			switch v := derefColor(arg).(type) {
 			case string:
 				c, err := resolveColorPath(v, data)
 				if err != nil {
@@ -222,12 +761,14 @@ func buildTemplateData(theme *Theme) templateData {
 				return c.HexAlpha(), nil
 			case color.Color:
 				return v.HexAlpha(), nil
+			case color.AlphaColor:
+				return v.HexAlpha(), nil
 			default:
-				return "", fmt.Errorf("hexa: unsupported type %T", arg)
+				return "", unsupportedArg("hexa", arg)
 			}
 		},
 		"bhexa": func(arg any) (string, error) {
-			switch v := arg.(type) {
+			switch v := derefColor(arg).(type) {
 			case string:
 				c, err := resolveColorPath(v, data)
 				if err != nil {
@@ -236,12 +777,14 @@ func buildTemplateData(theme *Theme) templateData {
 				return c.HexBareAlpha(), nil
 			case color.Color:
 				return v.HexBareAlpha(), nil
+			case color.AlphaColor:
+				return v.HexBareAlpha(), nil
 			default:
-				return "", fmt.Errorf("bhexa: unsupported type %T", arg)
+				return "", unsupportedArg("bhexa", arg)
 			}
 		},
 		"rgb": func(arg any) (string, error) {
-			switch v := arg.(type) {
+			switch v := derefColor(arg).(type) {
 			case string:
 				c, err := resolveColorPath(v, data)
 				if err != nil {
@@ -250,12 +793,14 @@ func buildTemplateData(theme *Theme) templateData {
 				return c.RGB(), nil
 			case color.Color:
 				return v.RGB(), nil
+			case color.AlphaColor:
+				return v.RGB(), nil
 			default:
-				return "", fmt.Errorf("rgb: unsupported type %T", arg)
+				return "", unsupportedArg("rgb", arg)
 			}
 		},
 		"rgba": func(arg any) (string, error) {
-			switch v := arg.(type) {
+			switch v := derefColor(arg).(type) {
 			case string:
 				c, err := resolveColorPath(v, data)
 				if err != nil {
@@ -264,9 +809,230 @@ func buildTemplateData(theme *Theme) templateData {
 				return c.RGBA(), nil
 			case color.Color:
 				return v.RGBA(), nil
+			case color.AlphaColor:
+				return v.RGBA(), nil
 			default:
-				return "", fmt.Errorf("rgba: unsupported type %T", arg)
+				return "", unsupportedArg("rgba", arg)
+			}
+		},
+		"uhex": func(arg any) (string, error) {
+			c, err := colorArg("uhex", arg, data)
+			if err != nil {
+				return "", err
+			}
+			return strings.ToUpper(c.Hex()), nil
+		},
+		"ubhex": func(arg any) (string, error) {
+			c, err := colorArg("ubhex", arg, data)
+			if err != nil {
+				return "", err
+			}
+			return strings.ToUpper(c.HexBare()), nil
+		},
+		"oklchs": func(arg any) (string, error) {
+			c, err := colorArg("oklchs", arg, data)
+			if err != nil {
+				return "", err
+			}
+			return c.OKLCHString(), nil
+		},
+		"hsls": func(arg any) (string, error) {
+			c, err := colorArg("hsls", arg, data)
+			if err != nil {
+				return "", err
+			}
+			return c.HSLString(), nil
+		},
+		"r":  channelFunc("r", data, func(c color.Color) uint8 { return c.R }),
+		"g":  channelFunc("g", data, func(c color.Color) uint8 { return c.G }),
+		"b":  channelFunc("b", data, func(c color.Color) uint8 { return c.B }),
+		"rf": channelFloatFunc("rf", data, func(c color.Color) uint8 { return c.R }),
+		"gf": channelFloatFunc("gf", data, func(c color.Color) uint8 { return c.G }),
+		"bf": channelFloatFunc("bf", data, func(c color.Color) uint8 { return c.B }),
+		"oklch": func(arg any) (color.OKLCH, error) {
+			c, err := colorArg("oklch", arg, data)
+			if err != nil {
+				return color.OKLCH{}, err
+			}
+			return c.OKLCH(), nil
+		},
+		"oklchL": func(arg any) (float64, error) {
+			c, err := colorArg("oklchL", arg, data)
+			if err != nil {
+				return 0, err
+			}
+			return c.OKLCH().L, nil
+		},
+		"oklchC": func(arg any) (float64, error) {
+			c, err := colorArg("oklchC", arg, data)
+			if err != nil {
+				return 0, err
 			}
+			return c.OKLCH().C, nil
+		},
+		"oklchH": func(arg any) (float64, error) {
+			c, err := colorArg("oklchH", arg, data)
+			if err != nil {
+				return 0, err
+			}
+			return c.OKLCH().H, nil
+		},
+		"brighten": func(a, b any) (color.Color, error) {
+			colors, amount, err := colorMathArgs("brighten", 1, data, a, b)
+			if err != nil {
+				return color.Color{}, err
+			}
+			if amount < -1 || amount > 1 {
+				return color.Color{}, fmt.Errorf("brighten: percentage must be between -1 and 1, got %g", amount)
+			}
+			return color.Brighten(colors[0], amount), nil
+		},
+		"darken": func(a, b any) (color.Color, error) {
+			colors, amount, err := colorMathArgs("darken", 1, data, a, b)
+			if err != nil {
+				return color.Color{}, err
+			}
+			if amount < 0 || amount > 1 {
+				return color.Color{}, fmt.Errorf("darken: percentage must be between 0 and 1, got %g; use brighten to lighten", amount)
+			}
+			return color.Darken(colors[0], amount), nil
+		},
+		"lightenOk": func(a, b any) (color.Color, error) {
+			colors, amount, err := colorMathArgs("lightenOk", 1, data, a, b)
+			if err != nil {
+				return color.Color{}, err
+			}
+			if amount < -1 || amount > 1 {
+				return color.Color{}, fmt.Errorf("lightenOk: amount must be between -1 and 1, got %g", amount)
+			}
+			return color.LightenOK(colors[0], amount), nil
+		},
+		"darkenOk": func(a, b any) (color.Color, error) {
+			colors, amount, err := colorMathArgs("darkenOk", 1, data, a, b)
+			if err != nil {
+				return color.Color{}, err
+			}
+			if amount < 0 || amount > 1 {
+				return color.Color{}, fmt.Errorf("darkenOk: amount must be between 0 and 1, got %g; use lightenOk to lighten", amount)
+			}
+			return color.DarkenOK(colors[0], amount), nil
+		},
+		"saturate": func(a, b any) (color.Color, error) {
+			colors, amount, err := colorMathArgs("saturate", 1, data, a, b)
+			if err != nil {
+				return color.Color{}, err
+			}
+			if amount < -1 || amount > 1 {
+				return color.Color{}, fmt.Errorf("saturate: amount must be between -1 and 1, got %g", amount)
+			}
+			return color.Saturate(colors[0], amount), nil
+		},
+		"desaturate": func(a, b any) (color.Color, error) {
+			colors, amount, err := colorMathArgs("desaturate", 1, data, a, b)
+			if err != nil {
+				return color.Color{}, err
+			}
+			if amount < 0 || amount > 1 {
+				return color.Color{}, fmt.Errorf("desaturate: amount must be between 0 and 1, got %g; use saturate to saturate", amount)
+			}
+			return color.Desaturate(colors[0], amount), nil
+		},
+		"rotateHue": func(a, b any) (color.Color, error) {
+			colors, degrees, err := colorMathArgs("rotateHue", 1, data, a, b)
+			if err != nil {
+				return color.Color{}, err
+			}
+			return color.RotateHue(colors[0], degrees), nil
+		},
+		"complement": func(arg any) (color.Color, error) {
+			c, err := colorArg("complement", arg, data)
+			if err != nil {
+				return color.Color{}, err
+			}
+			return color.Complement(c), nil
+		},
+		"invert": func(arg any) (color.Color, error) {
+			c, err := colorArg("invert", arg, data)
+			if err != nil {
+				return color.Color{}, err
+			}
+			return color.Invert(c), nil
+		},
+		"mix": func(a, b, c any) (color.Color, error) {
+			colors, weight, err := colorMathArgs("mix", 2, data, a, b, c)
+			if err != nil {
+				return color.Color{}, err
+			}
+			if weight < 0 || weight > 1 {
+				return color.Color{}, fmt.Errorf("mix: weight must be between 0 and 1, got %g", weight)
+			}
+			return color.Mix(colors[0], colors[1], weight), nil
+		},
+		"shade": func(a, b any) (color.Color, error) {
+			colors, amount, err := colorMathArgs("shade", 1, data, a, b)
+			if err != nil {
+				return color.Color{}, err
+			}
+			if amount < 0 || amount > 1 {
+				return color.Color{}, fmt.Errorf("shade: amount must be between 0 and 1, got %g", amount)
+			}
+			return color.Shade(colors[0], amount), nil
+		},
+		"tint": func(a, b any) (color.Color, error) {
+			colors, amount, err := colorMathArgs("tint", 1, data, a, b)
+			if err != nil {
+				return color.Color{}, err
+			}
+			if amount < 0 || amount > 1 {
+				return color.Color{}, fmt.Errorf("tint: amount must be between 0 and 1, got %g", amount)
+			}
+			return color.Tint(colors[0], amount), nil
+		},
+		"gray": func(arg any) (color.Color, error) {
+			var lightness float64
+			switch v := arg.(type) {
+			case float64:
+				lightness = v
+			case int:
+				lightness = float64(v)
+			default:
+				return color.Color{}, unsupportedArg("gray", arg)
+			}
+			if !(lightness >= 0 && lightness <= 1) {
+				return color.Color{}, fmt.Errorf("gray: lightness must be between 0 and 1, got %g", lightness)
+			}
+			return color.Gray(lightness), nil
+		},
+		"alpha": func(a, b any) (color.AlphaColor, error) {
+			return alphaArgs("alpha", data, a, b)
+		},
+		"hexaWith": func(a, b any) (string, error) {
+			c, err := alphaArgs("hexaWith", data, a, b)
+			if err != nil {
+				return "", err
+			}
+			return c.HexAlpha(), nil
+		},
+		"rgbaWith": func(a, b any) (string, error) {
+			c, err := alphaArgs("rgbaWith", data, a, b)
+			if err != nil {
+				return "", err
+			}
+			return c.RGBA(), nil
+		},
+		"swatchPNG": func(a, b any) (string, error) {
+			colors, size, err := colorMathArgs("swatchPNG", 1, data, a, b)
+			if err != nil {
+				return "", err
+			}
+			return swatchPNG(colors[0], size)
+		},
+		"flatPalette": func(sep string) ([]FlatColor, error) {
+			colors, err := flatPalette(data.Palette, sep)
+			if err != nil {
+				return nil, fmt.Errorf("flatPalette: %w", err)
+			}
+			return colors, nil
 		},
 		"meta": func(key string) (string, error) {
 			switch key {
@@ -279,27 +1045,70 @@ func buildTemplateData(theme *Theme) templateData {
 			case "url":
 				return data.Meta.URL, nil
 			default:
-				return "", fmt.Errorf("meta: unknown key %q (valid: name, author, appearance, url)", key)
+				if v, ok := data.Meta.Map()[key]; ok {
+					return v, nil
+				}
+				return "", fmt.Errorf("meta: unknown key %q (valid: name, author, appearance, url, spec_version, or an extra meta attribute)", key)
 			}
 		},
-		"style": func(path string) (color.Style, error) {
-			parts := strings.Split(path, ".")
-			if len(parts) < 2 {
-				return color.Style{}, fmt.Errorf("invalid path %q", path)
+		// metaOr is meta with a fallback for fields that are absent or empty.
+		"metaOr": func(key, fallback string) string {
+			if v := data.Meta.Map()[key]; v != "" {
+				return v
 			}
-
-			block := parts[0]
-			rest := parts[1:]
-
-			switch block {
-			case "syntax":
-				return getStyleFromTree(data.Syntax, rest), nil
+			return fallback
+		},
+		"slug":       slug,
+		"quote":      quote,
+		"luaString":  luaString,
+		"jsonString": jsonString,
+		"xmlEscape":  xmlEscape,
+		"style":      data.Style,
+		"styleFlags": func(arg any, format string) (string, error) {
+			var s color.Style
+			switch v := arg.(type) {
+			case string:
+				var err error
+				if s, err = data.Style(v); err != nil {
+					return "", fmt.Errorf("styleFlags: %w", err)
+				}
+			case color.Style:
+				s = v
 			default:
-				return color.Style{}, fmt.Errorf("style only supports syntax block, got %q", block)
+				return "", unsupportedArg("styleFlags", arg)
 			}
+			return styleFlags(s, format)
+		},
+		// palette takes a path relative to the palette block. It is
+		// deprecated in favor of "palette." paths, see deprecatedFuncs.
+		"palette": func(path string) (color.Color, error) {
+			return resolveColorPath("palette."+path, data)
+		},
+		// has reports whether the theme defines a path, with the rules of
+		// the requires pragma, so optional sections need no zero-color test.
+		"has": func(path string) bool {
+			return themeHas(data, path)
+		},
+		"node":        data.Node,
+		"definedAt":   data.DefinedAt,
+		"ansiIndex":   data.ANSIIndex,
+		"ansiIndexOf": data.ANSIIndexOf,
+		"nearestAnsi": func(arg any) (string, error) {
+			c, err := colorArg("nearestAnsi", arg, data)
+			if err != nil {
+				return "", err
+			}
+			return data.NearestANSI(c)
+		},
+		"nearestAnsiIndex": func(arg any) (int, error) {
+			c, err := colorArg("nearestAnsiIndex", arg, data)
+			if err != nil {
+				return 0, err
+			}
+			return data.NearestANSIIndex(c)
 		},
 	}
+	addDeprecatedAliases(data.FuncMap)
 
 	return data
 }
-
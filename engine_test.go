@@ -1,12 +1,20 @@
 package paletteswap
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/metrics"
 )
 
 func testTheme() *Theme {
@@ -111,6 +119,70 @@ cursor={{ bhex .Theme.cursor }}`,
 	}
 }
 
+func TestRunSymlinkedTemplates(t *testing.T) {
+	realDir := setupTemplateDir(t, map[string]string{
+		"a.txt.tmpl": "a={{ .Meta.Name }}",
+	})
+	// b.txt.tmpl and c.txt.tmpl both reach a.txt.tmpl, through a link in
+	// the directory and through a link to the directory.
+	if err := os.Symlink("a.txt.tmpl", filepath.Join(realDir, "b.txt.tmpl")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	linkDir := filepath.Join(t.TempDir(), "linked")
+	if err := os.Symlink(realDir, linkDir); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(linkDir, "a.txt.tmpl"), filepath.Join(realDir, "c.txt.tmpl")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	outDir := filepath.Join(t.TempDir(), "output")
+
+	e := &Engine{
+		TemplatesDir: linkDir,
+		OutputDir:    outDir,
+	}
+	if err := e.Run(testTheme()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("reading output directory: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if !slices.Equal(names, []string{"a.txt"}) {
+		t.Errorf("outputs = %v, want [a.txt]", names)
+	}
+}
+
+func TestRunTemplatesDirHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	tmplDir := filepath.Join(home, "themes", "templates")
+	if err := os.MkdirAll(tmplDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmplDir, "test.txt.tmpl"), []byte("name={{ .Meta.Name }}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(t.TempDir(), "output")
+
+	e := &Engine{
+		TemplatesDir: "~/themes/templates",
+		OutputDir:    outDir,
+	}
+	if err := e.Run(testTheme()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "test.txt")); err != nil {
+		t.Errorf("test.txt should exist: %v", err)
+	}
+}
+
 func TestRunAppFilter(t *testing.T) {
 	tmplDir := setupTemplateDir(t, map[string]string{
 		"app1.txt.tmpl": "app1={{ .Meta.Name }}",
@@ -139,6 +211,208 @@ func TestRunAppFilter(t *testing.T) {
 	}
 }
 
+func TestMatchApp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"kitty.conf", "kitty.conf", true},
+		{"kitty", "kitty.conf", true},
+		{"KITTY", "kitty.conf", true},
+		{"kitty.conf.tmpl", "kitty.conf", true},
+		{"kit*", "kitty.conf", true},
+		{"*.json", "zed.json", true},
+		{"z?d", "zed.json", true},
+		{"kit", "kitty.conf", false},
+		{"kitty.json", "kitty.conf", false},
+		{"zed", "zed-dark.json", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.name, func(t *testing.T) {
+			if got := matchApp(tt.pattern, tt.name); got != tt.want {
+				t.Errorf("matchApp(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunAppFilterGlob(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"kitty.conf.tmpl":   "kitty",
+		"kitty-ui.tmpl":     "kitty-ui",
+		"zed.json.tmpl":     "zed",
+		"ghostty.conf.tmpl": "ghostty",
+	})
+	outDir := filepath.Join(t.TempDir(), "output")
+
+	var buf bytes.Buffer
+	e := &Engine{
+		TemplatesDir: tmplDir,
+		OutputDir:    outDir,
+		Apps:         []string{"kitty*", "Zed", "ghosty"},
+		Logger:       slog.New(slog.NewTextHandler(&buf, nil)),
+	}
+
+	if err := e.Run(testTheme()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	for name, want := range map[string]bool{
+		"kitty.conf":   true,
+		"kitty-ui":     true,
+		"zed.json":     true,
+		"ghostty.conf": false,
+	} {
+		_, err := os.Stat(filepath.Join(outDir, name))
+		if got := err == nil; got != want {
+			t.Errorf("%s exists = %v, want %v", name, got, want)
+		}
+	}
+
+	logs := buf.String()
+	if !strings.Contains(logs, `msg="app matched no templates" app=ghosty`) {
+		t.Errorf("expected warning for unmatched app, got:\n%s", logs)
+	}
+	if strings.Count(logs, "matched no templates") != 1 {
+		t.Errorf("expected exactly one unmatched-app warning, got:\n%s", logs)
+	}
+}
+
+func TestRunDeprecatedFunctions(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"old.txt.tmpl":    `bg={{ hexBare .Theme.background }}`,
+		"older.txt.tmpl":  `{{ define "bg" }}{{ hexBare "theme.background" }}{{ end }}bg={{ template "bg" }}`,
+		"new.txt.tmpl":    `bg={{ bhex .Theme.background }} {{ (style "syntax.comment").Color | hex }} {{ ("syntax.comment" | style).Color | hex }}`,
+		"legacy.txt.tmpl": `base={{ palette "highlight.low" | hex }} bold={{ (style "custom.bold").Color | hex }}`,
+		"piped.txt.tmpl":  `bold={{ ("custom.bold" | style).Color | hex }}`,
+	})
+	outDir := filepath.Join(t.TempDir(), "output")
+
+	var buf bytes.Buffer
+	e := &Engine{
+		TemplatesDir: tmplDir,
+		OutputDir:    outDir,
+		Logger:       slog.New(slog.NewTextHandler(&buf, nil)),
+	}
+	if err := e.Run(testTheme()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"old.txt":    "bg=191724",
+		"older.txt":  "bg=191724",
+		"legacy.txt": "base=#21202e bold=#ff0000",
+		"piped.txt":  "bold=#ff0000",
+	} {
+		got, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	var warnings []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if strings.Contains(line, "template function is deprecated") {
+			warnings = append(warnings, line)
+		}
+	}
+	want := []struct {
+		function  string
+		templates []string
+	}{
+		{"hexBare", []string{"old.txt.tmpl", "older.txt.tmpl"}},
+		{"palette", []string{"legacy.txt.tmpl"}},
+		{"style", []string{"legacy.txt.tmpl", "piped.txt.tmpl"}},
+	}
+	if len(warnings) != len(want) {
+		t.Fatalf("got %d deprecation warnings, want %d:\n%s", len(warnings), len(want), buf.String())
+	}
+	for i, w := range want {
+		if !strings.Contains(warnings[i], "function="+w.function) {
+			t.Errorf("warning %d = %s, want function=%s", i, warnings[i], w.function)
+		}
+		for _, tmpl := range w.templates {
+			if !strings.Contains(warnings[i], tmpl) {
+				t.Errorf("warning for %s does not list %s: %s", w.function, tmpl, warnings[i])
+			}
+		}
+		if strings.Contains(warnings[i], "new.txt.tmpl") {
+			t.Errorf("warning for %s lists new.txt.tmpl: %s", w.function, warnings[i])
+		}
+	}
+}
+
+func TestRunAppFilterInvalidPattern(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"app1.txt.tmpl": "app1",
+	})
+
+	e := &Engine{
+		TemplatesDir: tmplDir,
+		OutputDir:    filepath.Join(t.TempDir(), "output"),
+		Apps:         []string{"app["},
+	}
+
+	err := e.Run(testTheme())
+	if err == nil || !strings.Contains(err.Error(), "invalid app pattern") {
+		t.Errorf("Run() error = %v, want invalid app pattern", err)
+	}
+}
+
+func TestRunLogger(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"app1.txt.tmpl": "app1={{ .Meta.Name }}",
+		"app2.txt.tmpl": "app2={{ .Meta.Name }}",
+	})
+	outDir := filepath.Join(t.TempDir(), "output")
+
+	var buf bytes.Buffer
+	e := &Engine{
+		TemplatesDir: tmplDir,
+		OutputDir:    outDir,
+		Apps:         []string{"app1.txt"},
+		Logger:       slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+
+	if err := e.Run(testTheme()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	logs := buf.String()
+	for _, want := range []string{
+		`msg="discovered templates"`,
+		"count=2",
+		`msg="skipping template"`,
+		`msg="rendered template"`,
+		"duration=",
+	} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("logs missing %q:\n%s", want, logs)
+		}
+	}
+}
+
+func TestRunContextRecordsTimings(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{"a.txt.tmpl": "a", "b.txt.tmpl": "b"})
+	e := &Engine{TemplatesDir: tmplDir, OutputDir: t.TempDir()}
+
+	var got []string
+	ctx := metrics.WithRecorder(context.Background(), func(phase, name string, _ time.Duration) {
+		got = append(got, phase+" "+filepath.Base(name))
+	})
+	if err := e.RunContext(ctx, testTheme()); err != nil {
+		t.Fatalf("RunContext() error: %v", err)
+	}
+	if want := []string{"render a.txt.tmpl", "render b.txt.tmpl"}; !slices.Equal(got, want) {
+		t.Errorf("recorded %q, want %q", got, want)
+	}
+}
+
 func TestRunNoTemplates(t *testing.T) {
 	tmplDir := t.TempDir() // empty directory
 	outDir := filepath.Join(t.TempDir(), "output")
@@ -153,6 +427,364 @@ func TestRunNoTemplates(t *testing.T) {
 	}
 }
 
+func TestRunErrorKinds(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates map[string]string
+		want      ErrorKind
+	}{
+		{"no templates", nil, KindIO},
+		{"parse error", map[string]string{"bad.tmpl": "{{ .Meta.Name "}, KindTemplate},
+		{"execute error", map[string]string{"bad.tmpl": `{{ hex "palette.missing" }}`}, KindTemplate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Engine{
+				TemplatesDir: setupTemplateDir(t, tt.templates),
+				OutputDir:    filepath.Join(t.TempDir(), "output"),
+			}
+			err := e.Run(testTheme())
+			if got := KindOf(err); got != tt.want {
+				t.Errorf("KindOf(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunLineEndings(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"out.txt.tmpl": "name={{ .Meta.Name }}\r\nbg={{ hex .Theme.background }}\n",
+	})
+
+	tests := []struct {
+		le   LineEnding
+		want string
+	}{
+		{LineEndingPreserve, "name=Test Theme\r\nbg=#191724\n"},
+		{LineEndingLF, "name=Test Theme\nbg=#191724\n"},
+		{LineEndingCRLF, "name=Test Theme\r\nbg=#191724\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.le), func(t *testing.T) {
+			outDir := t.TempDir()
+			e := &Engine{TemplatesDir: tmplDir, OutputDir: outDir, LineEnding: tt.le}
+			if err := e.Run(testTheme()); err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+			got, err := os.ReadFile(filepath.Join(outDir, "out.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	e := &Engine{TemplatesDir: tmplDir, OutputDir: t.TempDir(), LineEnding: "cr"}
+	if err := e.Run(testTheme()); KindOf(err) != KindConfig {
+		t.Errorf("Run() with invalid line ending error = %v, want a config error", err)
+	}
+}
+
+func TestRunEncodings(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"plain.txt.tmpl": "\uFEFFbg={{ hex .Theme.background }}\n",
+		"bom.txt.tmpl":   "\uFEFF#!ps: encoding=utf8-bom\nbg={{ hex .Theme.background }}\n",
+		"reg.txt.tmpl":   "#!ps: encoding=utf16le\né={{ bhex .Theme.background }}\n",
+	})
+
+	outDir := t.TempDir()
+	e := &Engine{TemplatesDir: tmplDir, OutputDir: outDir, LineEnding: LineEndingCRLF}
+	if err := e.Run(testTheme()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	tests := map[string]string{
+		// The template's byte-order mark is dropped.
+		"plain.txt": "bg=#191724\r\n",
+		"bom.txt":   "\xef\xbb\xbfbg=#191724\r\n",
+		"reg.txt":   "\xff\xfe\xe9\x00=\x001\x009\x001\x007\x002\x004\x00\r\x00\n\x00",
+	}
+	for name, want := range tests {
+		got, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	stale, err := e.Stale(testTheme())
+	if err != nil || len(stale) != 0 {
+		t.Errorf("Stale() = %v, %v, want no stale outputs", stale, err)
+	}
+}
+
+func TestRunPatch(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"kitty.conf.tmpl": "#!ps: patch=#\nbackground {{ hex .Theme.background }}\n",
+		"new.conf.tmpl":   "#!ps: patch=#\nbackground {{ hex .Theme.background }}\n",
+	})
+	outDir := t.TempDir()
+	existing := "font_size 12\n# pstheme:start\nbackground #000000\n# pstheme:end\ncursor_blink no\n"
+	if err := os.WriteFile(filepath.Join(outDir, "kitty.conf"), []byte(existing), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &Engine{TemplatesDir: tmplDir, OutputDir: outDir}
+	if err := e.Run(testTheme()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	tests := map[string]string{
+		"kitty.conf": "font_size 12\n# pstheme:start\nbackground #191724\n# pstheme:end\ncursor_blink no\n",
+		"new.conf":   "# pstheme:start\nbackground #191724\n# pstheme:end\n",
+	}
+	for name, want := range tests {
+		got, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// Edits outside the region do not make the output stale.
+	kitty := filepath.Join(outDir, "kitty.conf")
+	if err := os.WriteFile(kitty, []byte("font_size 14\n"+tests["kitty.conf"][len("font_size 12\n"):]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stale, err := e.Stale(testTheme())
+	if err != nil || len(stale) != 0 {
+		t.Errorf("Stale() = %v, %v, want no stale outputs", stale, err)
+	}
+
+	if err := os.WriteFile(kitty, []byte("# pstheme:start\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err = e.Run(testTheme())
+	if KindOf(err) != KindIO || !strings.Contains(err.Error(), "pstheme:start marker without a pstheme:end marker") {
+		t.Errorf("Run() with an unclosed region error = %v, want an IO error about the marker", err)
+	}
+}
+
+func TestEngineOutputPath(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "output")
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory:", err)
+	}
+	abs := filepath.Join(t.TempDir(), "kitty.conf")
+
+	tests := []struct {
+		name    string
+		output  string
+		allow   bool
+		want    string
+		wantErr string
+	}{
+		{"relative", "kitty.conf", false, filepath.Join(outDir, "kitty.conf"), ""},
+		{"nested", "zed/themes/rose.json", false, filepath.Join(outDir, "zed", "themes", "rose.json"), ""},
+		{"dot-dot inside", "zed/../kitty.conf", false, filepath.Join(outDir, "kitty.conf"), ""},
+		{"dot-dot outside", "../kitty.conf", false, "", "outside the output directory"},
+		{"absolute", abs, false, "", "outside the output directory"},
+		{"home", "~/kitty.conf", false, "", "--allow-outside-out"},
+		{"output directory itself", ".", false, "", "does not name a file"},
+		{"allowed dot-dot", "../kitty.conf", true, filepath.Join(filepath.Dir(outDir), "kitty.conf"), ""},
+		{"allowed absolute", abs, true, abs, ""},
+		{"allowed home", "~/kitty.conf", true, filepath.Join(home, "kitty.conf"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Engine{OutputDir: outDir, AllowOutsideOut: tt.allow}
+			got, err := e.outputPath(tt.output)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("outputPath(%q) error = %v, want containing %q", tt.output, err, tt.wantErr)
+				}
+				if KindOf(err) != KindConfig {
+					t.Errorf("KindOf() = %v, want %v", KindOf(err), KindConfig)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("outputPath(%q) error: %v", tt.output, err)
+			}
+			if got != tt.want {
+				t.Errorf("outputPath(%q) = %q, want %q", tt.output, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineCheckCollisions(t *testing.T) {
+	outDir := t.TempDir()
+	e := &Engine{OutputDir: outDir}
+
+	user := filepath.Join("user", "kitty.conf.tmpl")
+	builtin := filepath.Join("builtin", "kitty.conf.tmpl")
+	err := e.checkCollisions([]string{user, filepath.Join("user", "zed.json.tmpl"), builtin})
+	if err == nil {
+		t.Fatal("expected a collision error")
+	}
+	want := "templates " + user + " and " + builtin + " both write to " + filepath.Join(outDir, "kitty.conf")
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+	if KindOf(err) != KindConfig {
+		t.Errorf("KindOf() = %v, want %v", KindOf(err), KindConfig)
+	}
+
+	if err := e.checkCollisions([]string{user, filepath.Join("user", "zed.json.tmpl")}); err != nil {
+		t.Errorf("distinct outputs: unexpected error %v", err)
+	}
+}
+
+func TestRunRequirements(t *testing.T) {
+	t.Run("met", func(t *testing.T) {
+		tmplDir := setupTemplateDir(t, map[string]string{
+			"a.txt.tmpl": "#!ps: requires=ansi,syntax.markup,palette.highlight,meta.author\nname={{ .Meta.Name }}\n{{ bad }}",
+		})
+		outDir := t.TempDir()
+		err := (&Engine{TemplatesDir: tmplDir, OutputDir: outDir}).Run(testTheme())
+		// The pragma line is not part of the template, and line numbers are kept.
+		if err == nil || !strings.Contains(err.Error(), "a.txt.tmpl:3:") {
+			t.Fatalf("Run() error = %v, want a parse error on line 3", err)
+		}
+	})
+
+	t.Run("output", func(t *testing.T) {
+		tmplDir := setupTemplateDir(t, map[string]string{
+			"a.txt.tmpl": "#!ps: requires=ansi\r\nname={{ .Meta.Name }}\n",
+		})
+		outDir := t.TempDir()
+		if err := (&Engine{TemplatesDir: tmplDir, OutputDir: outDir}).Run(testTheme()); err != nil {
+			t.Fatalf("Run() error: %v", err)
+		}
+		got, err := os.ReadFile(filepath.Join(outDir, "a.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "name=Test Theme\n" {
+			t.Errorf("output = %q, want %q", got, "name=Test Theme\n")
+		}
+	})
+
+	t.Run("missing fails before rendering", func(t *testing.T) {
+		tmplDir := setupTemplateDir(t, map[string]string{
+			"a.txt.tmpl": "ok\n",
+			"b.txt.tmpl": "#!ps: requires=semantic,syntax.markup.italic,ansi\nb\n",
+		})
+		outDir := t.TempDir()
+		err := (&Engine{TemplatesDir: tmplDir, OutputDir: outDir}).Run(testTheme())
+		want := "template " + filepath.Join(tmplDir, "b.txt.tmpl") + " requires semantic, syntax.markup.italic, which the theme does not define"
+		if err == nil || err.Error() != want {
+			t.Fatalf("Run() error = %v, want %q", err, want)
+		}
+		if KindOf(err) != KindValidation {
+			t.Errorf("KindOf() = %v, want %v", KindOf(err), KindValidation)
+		}
+		if _, err := os.Stat(filepath.Join(outDir, "a.txt")); !os.IsNotExist(err) {
+			t.Errorf("a.txt was written before the requirement error")
+		}
+	})
+
+	t.Run("missing skips", func(t *testing.T) {
+		tmplDir := setupTemplateDir(t, map[string]string{
+			"a.txt.tmpl": "ok\n",
+			"b.txt.tmpl": "#!ps: requires=meta.url missing=skip\nb\n",
+		})
+		outDir := t.TempDir()
+		var buf bytes.Buffer
+		e := &Engine{TemplatesDir: tmplDir, OutputDir: outDir, Logger: slog.New(slog.NewTextHandler(&buf, nil))}
+		if err := e.Run(testTheme()); err != nil {
+			t.Fatalf("Run() error: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outDir, "b.txt")); !os.IsNotExist(err) {
+			t.Errorf("b.txt was written although its requirement is missing")
+		}
+		if _, err := os.Stat(filepath.Join(outDir, "a.txt")); err != nil {
+			t.Errorf("a.txt: %v", err)
+		}
+		if !strings.Contains(buf.String(), "missing=meta.url") {
+			t.Errorf("logs missing the skipped requirement:\n%s", buf.String())
+		}
+	})
+
+	t.Run("invalid pragma", func(t *testing.T) {
+		tmplDir := setupTemplateDir(t, map[string]string{
+			"a.txt.tmpl": "#!ps: requires=colors\nok\n",
+		})
+		err := (&Engine{TemplatesDir: tmplDir, OutputDir: t.TempDir()}).Run(testTheme())
+		if err == nil || !strings.Contains(err.Error(), `invalid requirement "colors"`) {
+			t.Fatalf("Run() error = %v, want an invalid requirement error", err)
+		}
+		if KindOf(err) != KindTemplate {
+			t.Errorf("KindOf() = %v, want %v", KindOf(err), KindTemplate)
+		}
+	})
+}
+
+func TestRunTemplateErrorContext(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"a.txt.tmpl": "name={{ .Meta.Name }}\nbg={{ hex \"palette.missing\" }}\n",
+		"b.txt.tmpl": "ok={{ .Meta.Name }}\n",
+		"c.txt.tmpl": "line one\n{{ if .Meta.Name }}\n",
+	})
+	outDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outDir, "a.txt"), []byte("previous\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &Engine{TemplatesDir: tmplDir, OutputDir: outDir}
+	err := e.Run(testTheme())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	msg := err.Error()
+	for _, want := range []string{
+		"2 of 3 templates failed",
+		filepath.Join(tmplDir, "a.txt.tmpl") + ":2: executing",
+		"(rendering " + filepath.Join(outDir, "a.txt") + ")",
+		`2 | bg={{ hex "palette.missing" }}`,
+		filepath.Join(tmplDir, "c.txt.tmpl") + ":3: unexpected EOF",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error should contain %q, got:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "template: a.txt.tmpl") {
+		t.Errorf("error should not repeat the text/template location, got:\n%s", msg)
+	}
+
+	var te *TemplateError
+	if !errors.As(err, &te) || te.Line != 2 {
+		t.Errorf("errors.As(*TemplateError) = %v, want the error at line 2", te)
+	}
+	if KindOf(err) != KindTemplate {
+		t.Errorf("KindOf() = %v, want %v", KindOf(err), KindTemplate)
+	}
+
+	got, readErr := os.ReadFile(filepath.Join(outDir, "b.txt"))
+	if readErr != nil || string(got) != "ok=Test Theme\n" {
+		t.Errorf("b.txt = %q, %v; the other templates should still render", got, readErr)
+	}
+
+	got, readErr = os.ReadFile(filepath.Join(outDir, "a.txt"))
+	if readErr != nil || string(got) != "previous\n" {
+		t.Errorf("a.txt = %q, %v; a failing template should leave the previous output", got, readErr)
+	}
+	if _, statErr := os.Stat(filepath.Join(outDir, "c.txt")); !os.IsNotExist(statErr) {
+		t.Errorf("c.txt should not be created, stat error = %v", statErr)
+	}
+}
+
 func TestRunRGBFunc(t *testing.T) {
 	tmplDir := setupTemplateDir(t, map[string]string{
 		"test.txt.tmpl": `{{ rgb .Theme.cursor }}`,
@@ -205,6 +837,38 @@ func TestRunSyntaxAccess(t *testing.T) {
 	}
 }
 
+func TestRunSyntaxFor(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"test.txt.tmpl": `{{ with .SyntaxFor "go" }}{{ hex .keyword.Color }} {{ hex .comment.Color }}{{ end }}`,
+	})
+	outDir := filepath.Join(t.TempDir(), "output")
+
+	theme := testTheme()
+	theme.LanguageSyntax = map[string]color.Tree{
+		"go": {"keyword": color.Style{Color: color.Color{R: 246, G: 193, B: 119}}},
+	}
+
+	e := &Engine{
+		TemplatesDir: tmplDir,
+		OutputDir:    outDir,
+	}
+
+	if err := e.Run(theme); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outDir, "test.txt"))
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+
+	// keyword comes from the go override, comment falls through to the base syntax
+	want := "#f6c177 #6e6a86"
+	if got := string(content); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
 func TestRunStyleAccess(t *testing.T) {
 	tmplDir := setupTemplateDir(t, map[string]string{
 		"test.txt.tmpl": `{{ $c := index .Syntax "comment" }}color={{ hex $c.Color }} italic={{ $c.Italic }} bold={{ $c.Bold }}`,
@@ -282,3 +946,115 @@ func TestRunStyleFunc(t *testing.T) {
 		t.Errorf("got %q, want %q", got, want)
 	}
 }
+
+func TestRunReusesEngineAcrossThemes(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"test.txt.tmpl": `{{ hex "theme.background" }}`,
+	})
+	e := &Engine{TemplatesDir: tmplDir}
+
+	other := testTheme()
+	other.Theme["background"] = color.Color{R: 255, G: 255, B: 255}
+
+	for _, tt := range []struct {
+		theme *Theme
+		want  string
+	}{
+		{testTheme(), "#191724"},
+		{other, "#ffffff"},
+	} {
+		e.OutputDir = t.TempDir()
+		if err := e.Run(tt.theme); err != nil {
+			t.Fatalf("Run() error: %v", err)
+		}
+		content, err := os.ReadFile(filepath.Join(e.OutputDir, "test.txt"))
+		if err != nil {
+			t.Fatalf("reading output: %v", err)
+		}
+		if got := string(content); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestRunReparsesChangedTemplate(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"test.txt.tmpl": `old`,
+	})
+	outDir := t.TempDir()
+	e := &Engine{TemplatesDir: tmplDir, OutputDir: outDir}
+
+	if err := e.Run(testTheme()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmplDir, "test.txt.tmpl"), []byte(`{{ .Meta.Name }}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Run(testTheme()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outDir, "test.txt"))
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if got := string(content); got != "Test Theme" {
+		t.Errorf("got %q, want %q", got, "Test Theme")
+	}
+}
+
+func TestRunConcurrent(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"bg.txt.tmpl":   `{{ hex "theme.background" }}`,
+		"name.txt.tmpl": `{{ .Meta.Name }} {{ darken "palette.love" 0.1 }}`,
+	})
+	e := &Engine{TemplatesDir: tmplDir}
+
+	const runs = 32
+	dirs := make([]string, runs)
+	errs := make([]error, runs)
+	var wg sync.WaitGroup
+	for i := range runs {
+		dirs[i] = t.TempDir()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			theme := testTheme()
+			theme.Theme["background"] = color.Color{R: uint8(i), G: uint8(i), B: uint8(i)}
+			errs[i] = e.WithOutputDir(dirs[i]).Run(theme)
+		}()
+	}
+	wg.Wait()
+
+	for i := range runs {
+		if errs[i] != nil {
+			t.Fatalf("run %d: Run() error: %v", i, errs[i])
+		}
+		content, err := os.ReadFile(filepath.Join(dirs[i], "bg.txt"))
+		if err != nil {
+			t.Fatalf("run %d: reading output: %v", i, err)
+		}
+		want := color.Color{R: uint8(i), G: uint8(i), B: uint8(i)}.Hex()
+		if got := string(content); got != want {
+			t.Errorf("run %d: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestRunContextCanceled(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"test.txt.tmpl": `{{ .Meta.Name }}`,
+	})
+	outDir := t.TempDir()
+	e := &Engine{TemplatesDir: tmplDir, OutputDir: outDir}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := e.RunContext(ctx, testTheme())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunContext() error = %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "test.txt")); !os.IsNotExist(err) {
+		t.Errorf("output written after cancellation: %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package paletteswap
+
+import "github.com/jsvensson/paletteswap/internal/theme"
+
+// Error classifies a failure from Load or Engine.Run by its Kind, so callers
+// can branch on the class of failure with KindOf instead of matching messages.
+type Error = theme.Error
+
+// ErrorKind is the class of failure carried by an Error.
+type ErrorKind = theme.Kind
+
+const (
+	KindConfig     = theme.KindConfig     // the theme file is malformed or cannot be evaluated
+	KindValidation = theme.KindValidation // the theme evaluates but breaks a rule, e.g. missing ANSI colors
+	KindTemplate   = theme.KindTemplate   // a template failed to parse or execute
+	KindIO         = theme.KindIO         // reading or writing a file failed
+)
+
+// KindOf returns the Kind of the first Error in err's chain, or 0 if there is
+// none.
+func KindOf(err error) ErrorKind {
+	return theme.KindOf(err)
+}
+
+// errorf is like fmt.Errorf but returns an Error of the given kind.
+func errorf(kind ErrorKind, format string, args ...any) error {
+	return theme.Errorf(kind, format, args...)
+}
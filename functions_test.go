@@ -2,6 +2,8 @@ package paletteswap
 
 import (
 	"bytes"
+	"encoding/base64"
+	"image/png"
 	"strings"
 	"testing"
 	"text/template"
@@ -88,6 +90,44 @@ func TestTemplateFunctions_Bhex(t *testing.T) {
 	}
 }
 
+func TestTemplateFunctions_UpperHex(t *testing.T) {
+	theme := &Theme{
+		Theme: map[string]color.Color{
+			"background": {R: 235, G: 111, B: 146},
+		},
+	}
+
+	data := buildTemplateData(theme)
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`{{ uhex "theme.background" }}`, "#EB6F92"},
+		{`{{ ubhex "theme.background" }}`, "EB6F92"},
+		{`{{ .Theme.background | uhex }}`, "#EB6F92"},
+		{`{{ ubhex (darken "theme.background" 1) }}`, "000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(data.FuncMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestTemplateFunctions_Hexa(t *testing.T) {
 	theme := &Theme{
 		Theme: map[string]color.Color{
@@ -233,3 +273,467 @@ func TestTemplateFunctions_Style(t *testing.T) {
 		t.Errorf("got %q, want %q", got, want)
 	}
 }
+
+func TestTemplateFunctions_StyleFlags(t *testing.T) {
+	theme := &Theme{
+		Syntax: color.Tree{
+			"keyword": color.Style{Color: color.Color{R: 49, G: 116, B: 143}},
+			"comment": color.Style{Color: color.Color{R: 110, G: 106, B: 134}, Italic: true},
+			"link":    color.Style{Color: color.Color{R: 156, G: 207, B: 216}, Bold: true, Italic: true, Underline: true},
+		},
+	}
+
+	data := buildTemplateData(theme)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{"list", `{{ styleFlags "syntax.link" "list" }}`, "bold,italic,underline", ""},
+		{"list none", `{{ styleFlags "syntax.keyword" "list" }}`, "", ""},
+		{"vim", `{{ styleFlags "syntax.comment" "vim" }}`, "cterm=italic gui=italic", ""},
+		{"vim none", `{{ styleFlags "syntax.keyword" "vim" }}`, "cterm=NONE gui=NONE", ""},
+		{"css", `{{ styleFlags "syntax.link" "css" }}`, "font-weight: bold; font-style: italic; text-decoration: underline;", ""},
+		{"sgr", `{{ styleFlags "syntax.link" "sgr" }}`, "1;3;4", ""},
+		{"style value", `{{ styleFlags (style "syntax.comment") "list" }}`, "italic", ""},
+		{"unknown format", `{{ styleFlags "syntax.comment" "emacs" }}`, "", `unknown format "emacs" (valid: list, vim, css, sgr)`},
+		{"bad path", `{{ styleFlags "meta.name" "list" }}`, "", "styleFlags:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(data.FuncMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			err = tmpl.Execute(&buf, data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("execute error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateFunctions_SwatchPNG(t *testing.T) {
+	love := color.Color{R: 235, G: 111, B: 146}
+	theme := &Theme{
+		Palette: &color.Node{
+			Children: map[string]*color.Node{
+				"love": {Color: &love},
+			},
+		},
+	}
+
+	data := buildTemplateData(theme)
+
+	tests := []struct {
+		name     string
+		template string
+		wantErr  string
+	}{
+		{"path", `{{ swatchPNG "palette.love" 16 }}`, ""},
+		{"pipeline", `{{ "palette.love" | swatchPNG 16 }}`, ""},
+		{"zero size", `{{ swatchPNG "palette.love" 0 }}`, "size must be a whole number of pixels between 1 and 256, got 0"},
+		{"fractional size", `{{ swatchPNG "palette.love" 1.5 }}`, "got 1.5"},
+		{"too large", `{{ swatchPNG "palette.love" 1000 }}`, "got 1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(data.FuncMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			err = tmpl.Execute(&buf, data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("execute error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+
+			encoded, ok := strings.CutPrefix(buf.String(), "data:image/png;base64,")
+			if !ok {
+				t.Fatalf("got %q, want a PNG data URI", buf.String())
+			}
+			raw, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				t.Fatalf("decoding base64: %v", err)
+			}
+			img, err := png.Decode(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("decoding PNG: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 16 {
+				t.Errorf("size = %dx%d, want 16x16", b.Dx(), b.Dy())
+			}
+			r, g, b, a := img.At(15, 15).RGBA()
+			if got := (color.Color{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8)}); got != love || a != 0xffff {
+				t.Errorf("pixel = %v (alpha %d), want %v opaque", got, a, love)
+			}
+		})
+	}
+}
+
+func TestTemplateFunctions_FlatPalette(t *testing.T) {
+	data := buildTemplateData(testTheme())
+	tmpl, err := template.New("test").Funcs(data.FuncMap).Parse(`{{ range flatPalette "-" }}--{{ .Name }}: {{ hex .Color }};{{ end }}`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+	want := "--base: #191724;--custom-bold: #ff0000;--highlight-high: #524f67;--highlight-low: #21202e;--love: #eb6f92;"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestTemplateFunctions_Has(t *testing.T) {
+	data := buildTemplateData(testTheme())
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"defined style", `{{ if has "syntax.markup.bold" }}yes{{ end }}`, "yes"},
+		{"missing style", `{{ if has "syntax.markup.italic" }}yes{{ else }}no{{ end }}`, "no"},
+		{"black is defined", `{{ if has "ansi.black" }}{{ hex "ansi.black" }}{{ end }}`, "#000000"},
+		{"missing color", `{{ if has "ansi.white" }}yes{{ else }}no{{ end }}`, "no"},
+		{"group", `{{ has "palette.highlight" }}`, "true"},
+		{"block", `{{ has "semantic" }}`, "false"},
+		{"unknown block", `{{ has "colors.red" }}`, "false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(data.FuncMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateFunctions_Meta(t *testing.T) {
+	theme := &Theme{
+		Meta: Meta{
+			Name:        "Test Theme",
+			URL:         "https://example.com/theme",
+			SpecVersion: 2,
+			Extra:       map[string]string{"variant": "moon"},
+		},
+	}
+
+	data := buildTemplateData(theme)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"name", `{{ meta "name" }}`, "Test Theme"},
+		{"url", `{{ meta "url" }}`, "https://example.com/theme"},
+		{"extra", `{{ meta "variant" }}`, "moon"},
+		{"field access", `{{ .Meta.URL }} {{ .Meta.Extra.variant }}`, "https://example.com/theme moon"},
+		{"spec version", `{{ meta "spec_version" }}`, "2"},
+		{"metaOr set", `{{ metaOr "variant" "main" }}`, "moon"},
+		{"metaOr absent", `{{ metaOr "version" "0.0.0" }}`, "0.0.0"},
+		{"metaOr empty", `{{ metaOr "author" "unknown" }}`, "unknown"},
+		{"map access", `{{ .Meta.Map.name }}/{{ .Meta.Map.variant }}`, "Test Theme/moon"},
+		{"map range", `{{ range $k, $v := .Meta.Map }}{{ $k }}={{ $v }};{{ end }}`,
+			"name=Test Theme;spec_version=2;url=https://example.com/theme;variant=moon;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(data.FuncMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	tmpl := template.Must(template.New("test").Funcs(data.FuncMap).Parse(`{{ meta "missing" }}`))
+	if err := tmpl.Execute(&bytes.Buffer{}, data); err == nil {
+		t.Error("expected error for unknown meta key, got nil")
+	}
+}
+
+func TestTemplateFunctions_Escape(t *testing.T) {
+	theme := &Theme{
+		Meta: Meta{
+			Name:   `Rosé "Dawn" <b> & \ co`,
+			Author: "tab\there\nbell\a1",
+		},
+	}
+
+	data := buildTemplateData(theme)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"quote", `{{ quote .Meta.Name }}`, `"Rosé \"Dawn\" <b> & \\ co"`},
+		{"quote control", `{{ .Meta.Author | quote }}`, `"tab\there\nbell\u00071"`},
+		{"luaString", `{{ luaString .Meta.Name }}`, `"Rosé \"Dawn\" <b> & \\ co"`},
+		{"luaString control", `{{ .Meta.Author | luaString }}`, `"tab\there\nbell\0071"`},
+		{"jsonString", `{{ jsonString .Meta.Name }}`, `"Rosé \"Dawn\" <b> & \\ co"`},
+		{"jsonString control", `{{ .Meta.Author | jsonString }}`, `"tab\there\nbell\u00071"`},
+		{"xmlEscape", `{{ xmlEscape .Meta.Name }}`, `Rosé &#34;Dawn&#34; &lt;b&gt; &amp; \ co`},
+		{"xmlEscape quote", `{{ xmlEscape "it's" }}`, `it&#39;s`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(data.FuncMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateFunctions_ColorMath(t *testing.T) {
+	black := color.Color{R: 0, G: 0, B: 0}
+	white := color.Color{R: 255, G: 255, B: 255}
+	red := color.Color{R: 204, G: 51, B: 51}
+	theme := &Theme{
+		Palette: &color.Node{
+			Children: map[string]*color.Node{
+				"black": {Color: &black},
+				"white": {Color: &white},
+				"red":   {Color: &red},
+			},
+		},
+		Theme: map[string]color.Color{
+			"background": white,
+		},
+	}
+
+	data := buildTemplateData(theme)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"darken path", `{{ hex (darken "palette.white" 0.5) }}`, "#808080"},
+		{"darken pipeline", `{{ "palette.white" | darken 0.5 | hex }}`, "#808080"},
+		{"brighten", `{{ hex (brighten "palette.black" 0.5) }}`, "#808080"},
+		{"brighten field", `{{ hex (brighten .Theme.background -0.5) }}`, "#808080"},
+		{"lightenOk gray", `{{ hex (lightenOk "palette.black" 0.5) }}`, "#636363"},
+		{"darkenOk pipeline", `{{ "palette.white" | darkenOk 1 | hex }}`, "#000000"},
+		{"saturate", `{{ hex (saturate "palette.red" 1) }}`, "#ff0000"},
+		{"desaturate pipeline", `{{ "palette.red" | desaturate 1 | hex }}`, "#808080"},
+		{"rotateHue gray", `{{ hex (rotateHue "palette.white" 90) }}`, "#ffffff"},
+		{"rotateHue pipeline", `{{ "palette.red" | rotateHue 360 | hex }}`, "#cc3333"},
+		{"complement gray", `{{ hex (complement "palette.white") }}`, "#ffffff"},
+		{"invert", `{{ hex (invert "palette.red") }}`, "#33cccc"},
+		{"invert pipeline", `{{ "palette.black" | invert | hex }}`, "#ffffff"},
+		{"shade", `{{ hex (shade "palette.red" 0.5) }}`, "#661a1a"},
+		{"tint pipeline", `{{ "palette.red" | tint 0.5 | hex }}`, "#e69999"},
+		{"gray", `{{ hex (gray 0.5) }}`, "#636363"},
+		{"gray int", `{{ gray 1 | hex }}`, "#ffffff"},
+		{"mix", `{{ hex (mix "palette.black" "palette.white" 0.5) }}`, "#808080"},
+		{"mix int weight", `{{ hex (mix "palette.black" "palette.white" 1) }}`, "#ffffff"},
+		{"alpha hexa", `{{ hexa (alpha "palette.white" 0.5) }}`, "#ffffff80"},
+		{"alpha rgba", `{{ "palette.black" | alpha 0.25 | rgba }}`, "rgba(0, 0, 0, 0.25)"},
+		{"alpha hex", `{{ hex (alpha "palette.black" 0.25) }}`, "#000000"},
+		{"nested", `{{ hexa (alpha (darken "palette.white" 0.5) 0.5) }}`, "#80808080"},
+		{"rgbaWith", `{{ rgbaWith "palette.white" 0.8 }}`, "rgba(255, 255, 255, 0.8)"},
+		{"rgbaWith pipeline", `{{ "palette.black" | rgbaWith 0.25 }}`, "rgba(0, 0, 0, 0.25)"},
+		{"hexaWith", `{{ hexaWith "palette.white" 0.5 }}`, "#ffffff80"},
+		{"hexaWith replaces alpha", `{{ hexaWith (alpha "palette.black" 0.25) 1 }}`, "#000000ff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(data.FuncMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	errorTests := []struct {
+		name     string
+		template string
+	}{
+		{"unknown path", `{{ darken "palette.missing" 0.5 }}`},
+		{"missing number", `{{ darken "palette.white" "palette.black" }}`},
+		{"brighten percentage out of range", `{{ brighten "palette.black" 1.5 }}`},
+		{"darken percentage out of range", `{{ darken "palette.white" -0.1 }}`},
+		{"lightenOk amount out of range", `{{ lightenOk "palette.black" 1.5 }}`},
+		{"darkenOk amount out of range", `{{ darkenOk "palette.white" -0.5 }}`},
+		{"saturate amount out of range", `{{ saturate "palette.red" 2 }}`},
+		{"desaturate amount out of range", `{{ desaturate "palette.red" -0.3 }}`},
+		{"mix weight out of range", `{{ mix "palette.black" "palette.white" 2 }}`},
+		{"shade amount out of range", `{{ shade "palette.red" -0.5 }}`},
+		{"alpha out of range", `{{ alpha "palette.black" 1.5 }}`},
+		{"rgbaWith out of range", `{{ rgbaWith "palette.black" -0.1 }}`},
+		{"hexaWith without alpha", `{{ hexaWith "palette.black" "palette.white" }}`},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := template.Must(template.New("test").Funcs(data.FuncMap).Parse(tt.template))
+			if err := tmpl.Execute(&bytes.Buffer{}, data); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestTemplateFunctions_OKLCH(t *testing.T) {
+	red := color.Color{R: 255, G: 0, B: 0}
+	theme := &Theme{
+		Palette: &color.Node{
+			Children: map[string]*color.Node{
+				"red": {Color: &red},
+			},
+		},
+	}
+
+	data := buildTemplateData(theme)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"lightness", `{{ printf "%.3f" (oklchL "palette.red") }}`, "0.628"},
+		{"chroma", `{{ printf "%.3f" (oklchC "palette.red") }}`, "0.258"},
+		{"hue", `{{ printf "%.1f" (oklchH "palette.red") }}`, "29.2"},
+		{"struct", `{{ with oklch "palette.red" }}{{ printf "%.3f %.3f %.1f" .L .C .H }}{{ end }}`, "0.628 0.258 29.2"},
+		{"color value", `{{ printf "%.3f" (oklchL (darken "palette.red" 1)) }}`, "0.000"},
+		{"oklch string", `{{ oklchs "palette.red" }}`, "oklch(62.8% 0.258 29.2)"},
+		{"hsl string", `{{ "palette.red" | hsls }}`, "hsl(0, 100%, 50%)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(data.FuncMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	tmpl := template.Must(template.New("test").Funcs(data.FuncMap).Parse(`{{ oklchL "palette.missing" }}`))
+	if err := tmpl.Execute(&bytes.Buffer{}, data); err == nil {
+		t.Error("expected error for unknown path")
+	}
+}
+
+func TestTemplateFunctions_Channels(t *testing.T) {
+	theme := &Theme{
+		Theme: map[string]color.Color{
+			"background": {R: 25, G: 23, B: 36},
+			"foreground": {R: 255, G: 0, B: 51},
+		},
+	}
+
+	data := buildTemplateData(theme)
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`{{ r "theme.background" }} {{ g "theme.background" }} {{ b "theme.background" }}`, "25 23 36"},
+		{`{{ rf "theme.foreground" }} {{ gf "theme.foreground" }} {{ bf "theme.foreground" }}`, "1 0 0.2"},
+		{`{{ .Theme.background | r }}`, "25"},
+		{`{{ printf "%.4f" (rf "theme.background") }}`, "0.0980"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(data.FuncMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	tmpl := template.Must(template.New("test").Funcs(data.FuncMap).Parse(`{{ r "theme.missing" }}`))
+	if err := tmpl.Execute(&bytes.Buffer{}, data); err == nil {
+		t.Error("expected error for unknown path")
+	}
+}
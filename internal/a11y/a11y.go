@@ -0,0 +1,128 @@
+// Package a11y reports WCAG contrast ratios for the foreground and background
+// color pairs of a theme.
+package a11y
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/jsvensson/paletteswap"
+	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/theme"
+)
+
+// WCAG 2 minimum contrast ratios for normal-size text.
+const (
+	AARatio  = 4.5
+	AAARatio = 7.0
+)
+
+// Check is the contrast of one foreground color against a background.
+type Check struct {
+	Foreground string  `json:"foreground"` // color path, e.g. "syntax.comment"
+	Background string  `json:"background"`
+	Ratio      float64 `json:"ratio"` // rounded to two decimals
+	AA         bool    `json:"aa"`
+	AAA        bool    `json:"aaa"`
+}
+
+// Passes reports whether the check meets the given level: "aa" or "aaa".
+func (c Check) Passes(level string) bool {
+	if level == "aaa" {
+		return c.AAA
+	}
+	return c.AA
+}
+
+func newCheck(fgPath string, fg color.Color, bgPath string, bg color.Color) Check {
+	ratio := color.ContrastRatio(fg, bg)
+	return Check{
+		Foreground: fgPath,
+		Background: bgPath,
+		Ratio:      math.Round(ratio*100) / 100,
+		AA:         ratio >= AARatio,
+		AAA:        ratio >= AAARatio,
+	}
+}
+
+// Report returns contrast checks for the key pairs of t: theme.foreground,
+// the cursor and the selection on their backgrounds, then every syntax scope
+// and every ANSI color against theme.background.
+func Report(t *paletteswap.Theme) ([]Check, error) {
+	bg, ok := t.Theme["background"]
+	if !ok {
+		return nil, fmt.Errorf("theme.background is not defined")
+	}
+
+	var checks []Check
+	if fg, ok := t.Theme["foreground"]; ok {
+		checks = append(checks, newCheck("theme.foreground", fg, "theme.background", bg))
+	}
+	if t.Cursor != nil {
+		checks = append(checks, newCheck("theme.cursor.text", t.Cursor.Text, "theme.cursor.cursor", t.Cursor.Color))
+	}
+	if t.Selection != nil {
+		checks = append(checks, newCheck("theme.selection.foreground", t.Selection.Foreground,
+			"theme.selection.background", t.Selection.Background))
+	}
+
+	walkSyntax(t.Syntax, "syntax", func(path string, style color.Style) {
+		checks = append(checks, newCheck(path, style.Color, "theme.background", bg))
+	})
+
+	for _, name := range theme.RequiredANSIColors {
+		if c, ok := t.ANSI[name]; ok {
+			checks = append(checks, newCheck("ansi."+name, c, "theme.background", bg))
+		}
+	}
+	return checks, nil
+}
+
+// walkSyntax calls fn for every style in tree, in sorted path order.
+func walkSyntax(tree color.Tree, prefix string, fn func(path string, style color.Style)) {
+	keys := make([]string, 0, len(tree))
+	for k := range tree {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch v := tree[k].(type) {
+		case color.Style:
+			fn(prefix+"."+k, v)
+		case color.Tree:
+			walkSyntax(v, prefix+"."+k, fn)
+		}
+	}
+}
+
+// WriteTable writes the checks as an aligned text table.
+func WriteTable(w io.Writer, checks []Check) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FOREGROUND\tBACKGROUND\tRATIO\tAA\tAAA")
+	for _, c := range checks {
+		fmt.Fprintf(tw, "%s\t%s\t%.2f:1\t%s\t%s\n", c.Foreground, c.Background, c.Ratio, passFail(c.AA), passFail(c.AAA))
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes the checks as an indented JSON array.
+func WriteJSON(w io.Writer, checks []Check) error {
+	if checks == nil {
+		checks = []Check{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(checks)
+}
+
+func passFail(ok bool) string {
+	if ok {
+		return "pass"
+	}
+	return "fail"
+}
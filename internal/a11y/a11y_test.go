@@ -0,0 +1,118 @@
+package a11y
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jsvensson/paletteswap"
+	"github.com/jsvensson/paletteswap/internal/color"
+)
+
+func testTheme() *paletteswap.Theme {
+	return &paletteswap.Theme{
+		Theme: map[string]color.Color{
+			"background": {R: 25, G: 23, B: 36},
+			"foreground": {R: 224, G: 222, B: 244},
+		},
+		Selection: &paletteswap.Selection{
+			Background: color.Color{R: 64, G: 61, B: 82},
+			Foreground: color.Color{R: 224, G: 222, B: 244},
+		},
+		Syntax: color.Tree{
+			"keyword": color.Style{Color: color.Color{R: 49, G: 116, B: 143}},
+			"markup": color.Tree{
+				"heading": color.Style{Color: color.Color{R: 235, G: 111, B: 146}},
+			},
+			"comment": color.Style{Color: color.Color{R: 110, G: 106, B: 134}},
+		},
+		ANSI: map[string]color.Color{
+			"red":   {R: 235, G: 111, B: 146},
+			"black": {R: 0, G: 0, B: 0},
+		},
+	}
+}
+
+func TestReport(t *testing.T) {
+	checks, err := Report(testTheme())
+	if err != nil {
+		t.Fatalf("Report() error: %v", err)
+	}
+
+	var got []string
+	for _, c := range checks {
+		got = append(got, c.Foreground+" on "+c.Background)
+	}
+	want := []string{
+		"theme.foreground on theme.background",
+		"theme.selection.foreground on theme.selection.background",
+		"syntax.comment on theme.background",
+		"syntax.keyword on theme.background",
+		"syntax.markup.heading on theme.background",
+		"ansi.black on theme.background",
+		"ansi.red on theme.background",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("pairs:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	fg := checks[0]
+	if fg.Ratio != 13.39 || !fg.AA || !fg.AAA {
+		t.Errorf("foreground check = %+v, want ratio 13.39 passing AA and AAA", fg)
+	}
+	keyword := checks[3]
+	if keyword.AA || keyword.AAA {
+		t.Errorf("keyword check = %+v, want failing AA and AAA", keyword)
+	}
+	if !fg.Passes("aaa") || keyword.Passes("aa") {
+		t.Error("Passes does not match the AA and AAA results")
+	}
+}
+
+func TestReportRequiresBackground(t *testing.T) {
+	if _, err := Report(&paletteswap.Theme{}); err == nil {
+		t.Error("expected error for theme without background")
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	var buf bytes.Buffer
+	checks := []Check{newCheck("theme.foreground", color.Color{}, "theme.background", color.Color{R: 255, G: 255, B: 255})}
+	if err := WriteTable(&buf, checks); err != nil {
+		t.Fatal(err)
+	}
+	want := "FOREGROUND        BACKGROUND        RATIO    AA    AAA\n" +
+		"theme.foreground  theme.background  21.00:1  pass  pass\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	checks, err := Report(testTheme())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, checks); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded []Check
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(decoded) != len(checks) || decoded[0] != checks[0] {
+		t.Errorf("decoded %+v, want %+v", decoded, checks)
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "[]" {
+		t.Errorf("empty report = %q, want []", got)
+	}
+}
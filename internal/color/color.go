@@ -2,6 +2,9 @@ package color
 
 import (
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -23,28 +26,135 @@ type Style struct {
 // Values are either Style or Tree.
 type Tree map[string]any
 
+// MergeTrees returns a new Tree with override deep-merged over base.
+// Nested trees are merged recursively; any other value in override replaces
+// the value at the same key in base. Neither input is modified.
+func MergeTrees(base, override Tree) Tree {
+	result := make(Tree, len(base)+len(override))
+	for k, v := range base {
+		if sub, ok := v.(Tree); ok {
+			result[k] = MergeTrees(sub, nil)
+		} else {
+			result[k] = v
+		}
+	}
+	for k, v := range override {
+		sub, ok := v.(Tree)
+		if !ok {
+			result[k] = v
+			continue
+		}
+		if baseSub, ok := result[k].(Tree); ok {
+			result[k] = MergeTrees(baseSub, sub)
+		} else {
+			result[k] = MergeTrees(sub, nil)
+		}
+	}
+	return result
+}
+
+// MergeNodes returns a new Node with override deep-merged over base. A
+// color in override replaces the color at the same path in base, and
+// children are merged recursively, in the order of base followed by the
+// children new in override. Either argument may be nil; the result
+// shares no Nodes with them, and is nil only if both are nil.
+func MergeNodes(base, override *Node) *Node {
+	if base == nil && override == nil {
+		return nil
+	}
+	result := &Node{}
+	for _, n := range []*Node{base, override} {
+		if n == nil {
+			continue
+		}
+		if n.Color != nil {
+			c := *n.Color
+			result.Color = &c
+		}
+		for _, name := range n.Names() {
+			result.SetChild(name, MergeNodes(result.Children[name], n.Children[name]))
+		}
+	}
+	return result
+}
+
+// ColorKey is the reserved attribute name that sets a group's own color.
+// It never names a child: "palette.highlight.color" is the same entry as
+// "palette.highlight".
+const ColorKey = "color"
+
 // Node represents a palette entry that can be both a color and a namespace.
 // Color is nil for namespace-only nodes (groups without a color attribute).
 // Children is nil for leaf nodes (flat color attributes).
+// Order lists the names of Children in the order they were declared; use
+// SetChild to keep it in step with Children, and Names to iterate.
 type Node struct {
 	Color    *Color
 	Children map[string]*Node
+	Order    []string
 }
 
-// Lookup resolves a dot-path (as segments) to a Color.
-// Returns an error if the path is not found or the target node has no color.
-func (n *Node) Lookup(path []string) (Color, error) {
+// SetChild sets the named child, appending the name to Order if it is new.
+func (n *Node) SetChild(name string, child *Node) {
+	if n.Children == nil {
+		n.Children = make(map[string]*Node)
+	}
+	if _, ok := n.Children[name]; !ok {
+		n.Order = append(n.Order, name)
+	}
+	n.Children[name] = child
+}
+
+// Names returns the names of the children in declaration order. Children
+// missing from Order, such as those of a Node built without SetChild, follow
+// in sorted order.
+func (n *Node) Names() []string {
+	names := make([]string, 0, len(n.Children))
+	seen := make(map[string]bool, len(n.Order))
+	for _, name := range n.Order {
+		if _, ok := n.Children[name]; ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	var rest []string
+	for name := range n.Children {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+// Find resolves a dot-path (as segments) to a Node, which may be a group.
+// An empty path returns n itself. A trailing ColorKey on a group with its own
+// color resolves to the group.
+func (n *Node) Find(path []string) (*Node, error) {
 	current := n
-	for _, part := range path {
+	for i, part := range path {
+		if part == ColorKey && i == len(path)-1 && current.Children != nil && current.Color != nil {
+			return current, nil
+		}
 		if current.Children == nil {
-			return Color{}, fmt.Errorf("path not found: %s is a leaf, cannot traverse further", part)
+			return nil, fmt.Errorf("path not found: %s is a leaf, cannot traverse further", part)
 		}
 		child, ok := current.Children[part]
 		if !ok {
-			return Color{}, fmt.Errorf("path not found: %q does not exist", part)
+			return nil, fmt.Errorf("path not found: %q does not exist", part)
 		}
 		current = child
 	}
+	return current, nil
+}
+
+// Lookup resolves a dot-path (as segments) to a Color.
+// Returns an error if the path is not found or the target node has no color.
+func (n *Node) Lookup(path []string) (Color, error) {
+	current, err := n.Find(path)
+	if err != nil {
+		return Color{}, err
+	}
 	if current.Color == nil {
 		return Color{}, fmt.Errorf("path is a group, not a color; add a color attribute or reference a specific child")
 	}
@@ -75,6 +185,7 @@ func applyLightnessStepsRecursive(node *Node, low, high float64, steps int) {
 	}
 
 	node.Children = make(map[string]*Node, steps)
+	node.Order = nil
 	for i := range steps {
 		var lightness float64
 		if steps == 1 {
@@ -84,7 +195,7 @@ func applyLightnessStepsRecursive(node *Node, low, high float64, steps int) {
 		}
 		stepped := StepLightness(*node.Color, lightness)
 		name := fmt.Sprintf("l%d", i+1)
-		node.Children[name] = &Node{Color: &stepped}
+		node.SetChild(name, &Node{Color: &stepped})
 	}
 }
 
@@ -94,12 +205,21 @@ func ParseHex(s string) (Color, error) {
 	if len(s) != 6 {
 		return Color{}, fmt.Errorf("invalid hex color %q: must be 6 hex digits", s)
 	}
-	var r, g, b uint8
-	_, err := fmt.Sscanf(s, "%02x%02x%02x", &r, &g, &b)
+	// Check digits explicitly: Sscanf's %x would accept signs and spaces.
+	for i := 0; i < len(s); i++ {
+		if !isHexDigit(s[i]) {
+			return Color{}, fmt.Errorf("invalid hex color %q: %q is not a hex digit", s, s[i])
+		}
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
 	if err != nil {
 		return Color{}, fmt.Errorf("invalid hex color %q: %w", s, err)
 	}
-	return Color{R: r, G: g, B: b}, nil
+	return Color{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v)}, nil
+}
+
+func isHexDigit(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
 }
 
 // Hex returns the color as a hex string with leading #, e.g. "#eb6f92".
@@ -127,7 +247,51 @@ func (c Color) RGB() string {
 	return fmt.Sprintf("rgb(%d, %d, %d)", c.R, c.G, c.B)
 }
 
+// HSLString returns the color as an hsl() string with whole-number
+// components, e.g. "hsl(343, 76%, 68%)".
+func (c Color) HSLString() string {
+	h, s, l := rgbToHSL(c)
+	hue := int(math.Round(h*360)) % 360
+	return fmt.Sprintf("hsl(%d, %d%%, %d%%)", hue, int(math.Round(s*100)), int(math.Round(l*100)))
+}
+
 // RGBA returns the color in rgba() function format with full opacity
 func (c Color) RGBA() string {
 	return fmt.Sprintf("rgba(%d, %d, %d, 1.0)", c.R, c.G, c.B)
 }
+
+// AlphaColor is a Color with an alpha channel in [0, 1]. Non-alpha output
+// formats (Hex, RGB, ...) are promoted from the embedded Color and ignore alpha.
+type AlphaColor struct {
+	Color
+	A float64
+}
+
+// WithAlpha returns the color with the given alpha, clamped to [0, 1].
+func (c Color) WithAlpha(a float64) AlphaColor {
+	return AlphaColor{Color: c, A: clamp01(a)}
+}
+
+// alphaByte returns the alpha channel scaled to 0-255.
+func (c AlphaColor) alphaByte() uint8 {
+	return uint8(math.Round(c.A * 255))
+}
+
+// HexAlpha returns the color in hex format with alpha channel (#rrggbbaa).
+func (c AlphaColor) HexAlpha() string {
+	return fmt.Sprintf("%s%02x", c.Hex(), c.alphaByte())
+}
+
+// HexBareAlpha returns the color in hex format without # prefix and with alpha channel (rrggbbaa).
+func (c AlphaColor) HexBareAlpha() string {
+	return fmt.Sprintf("%s%02x", c.HexBare(), c.alphaByte())
+}
+
+// RGBA returns the color in rgba() function format, e.g. "rgba(235, 111, 146, 0.5)".
+func (c AlphaColor) RGBA() string {
+	a := strconv.FormatFloat(c.A, 'f', -1, 64)
+	if !strings.Contains(a, ".") {
+		a += ".0"
+	}
+	return fmt.Sprintf("rgba(%d, %d, %d, %s)", c.R, c.G, c.B, a)
+}
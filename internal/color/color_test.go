@@ -1,6 +1,9 @@
 package color
 
 import (
+	"math"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -20,6 +23,8 @@ func TestParseHex(t *testing.T) {
 		{"too long", "#aabbccdd", Color{}, true},
 		{"invalid chars", "#zzzzzz", Color{}, true},
 		{"empty", "", Color{}, true},
+		{"leading space", "# 00000", Color{}, true},
+		{"signs", "#+1+1+1", Color{}, true},
 	}
 
 	for _, tt := range tests {
@@ -79,7 +84,7 @@ func TestBrighten(t *testing.T) {
 			name:       "brighten red by 10%",
 			color:      Color{255, 0, 0},
 			percentage: 0.1,
-			want:       Color{255, 50, 50},
+			want:       Color{255, 51, 51},
 		},
 		{
 			name:       "brighten gray by 20%",
@@ -97,7 +102,7 @@ func TestBrighten(t *testing.T) {
 			name:       "brighten black by 50%",
 			color:      Color{0, 0, 0},
 			percentage: 0.5,
-			want:       Color{127, 127, 127},
+			want:       Color{128, 128, 128},
 		},
 	}
 
@@ -146,7 +151,7 @@ func TestDarken(t *testing.T) {
 			name:       "darken white by 50%",
 			color:      Color{255, 255, 255},
 			percentage: 0.5,
-			want:       Color{127, 127, 127},
+			want:       Color{128, 128, 128},
 		},
 	}
 
@@ -248,6 +253,9 @@ func TestNode_Lookup(t *testing.T) {
 		{"flat leaf", []string{"black"}, "#000000", false},
 		{"nested block with color", []string{"highlight"}, "#c0c0c0", false},
 		{"nested child", []string{"highlight", "low"}, "#21202e", false},
+		{"explicit color key", []string{"highlight", "color"}, "#c0c0c0", false},
+		{"color key on leaf", []string{"black", "color"}, "", true},
+		{"color key on namespace", []string{"nocolor", "color"}, "", true},
 		{"not found", []string{"missing"}, "", true},
 		{"namespace only", []string{"nocolor"}, "", true},
 	}
@@ -273,6 +281,135 @@ func TestNode_Lookup(t *testing.T) {
 	}
 }
 
+func TestMergeNodes(t *testing.T) {
+	red, green, blue := Color{R: 255}, Color{G: 255}, Color{B: 255}
+	base := &Node{Children: map[string]*Node{
+		"base": {Color: &red},
+		"highlight": {Color: &red, Children: map[string]*Node{
+			"low":  {Color: &red},
+			"high": {Color: &red},
+		}},
+	}}
+	override := &Node{Children: map[string]*Node{
+		"accent": {Color: &blue},
+		"highlight": {Children: map[string]*Node{
+			"low": {Color: &green},
+		}},
+	}}
+
+	got := MergeNodes(base, override)
+
+	for path, want := range map[string]Color{
+		"base":           red,
+		"accent":         blue,
+		"highlight":      red,
+		"highlight.low":  green,
+		"highlight.high": red,
+	} {
+		c, err := got.Lookup(strings.Split(path, "."))
+		if err != nil || c != want {
+			t.Errorf("%s = %v (%v), want %v", path, c, err, want)
+		}
+	}
+
+	// Inputs are not modified, and the result does not alias them.
+	if *base.Children["highlight"].Children["low"].Color != red {
+		t.Error("base was modified")
+	}
+	*got.Children["base"].Color = blue
+	if *base.Children["base"].Color != red {
+		t.Error("result shares colors with base")
+	}
+
+	if MergeNodes(nil, nil) != nil {
+		t.Error("MergeNodes(nil, nil) should be nil")
+	}
+}
+
+func TestMergeNodes_Order(t *testing.T) {
+	red := Color{R: 255}
+	base, override := &Node{}, &Node{}
+	for _, name := range []string{"text", "base", "love"} {
+		base.SetChild(name, &Node{Color: &red})
+	}
+	for _, name := range []string{"accent", "base"} {
+		override.SetChild(name, &Node{Color: &red})
+	}
+
+	got := MergeNodes(base, override).Names()
+	want := []string{"text", "base", "love", "accent"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+}
+
+func TestNode_Names(t *testing.T) {
+	red := Color{R: 255}
+	n := &Node{}
+	n.SetChild("text", &Node{Color: &red})
+	n.SetChild("base", &Node{Color: &red})
+	n.SetChild("text", &Node{Color: &red}) // replacing keeps the position
+
+	if got, want := n.Names(), []string{"text", "base"}; !slices.Equal(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+
+	// Children added without SetChild follow, sorted.
+	n.Children["zed"] = &Node{Color: &red}
+	n.Children["alpha"] = &Node{Color: &red}
+	if got, want := n.Names(), []string{"text", "base", "alpha", "zed"}; !slices.Equal(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+
+	if got := (&Node{Color: &red}).Names(); len(got) != 0 {
+		t.Errorf("leaf Names() = %v, want none", got)
+	}
+}
+
+func TestMergeTrees(t *testing.T) {
+	red := Style{Color: Color{R: 255}}
+	green := Style{Color: Color{G: 255}}
+	blue := Style{Color: Color{B: 255}}
+
+	base := Tree{
+		"keyword": red,
+		"string":  red,
+		"markup": Tree{
+			"heading": red,
+		},
+	}
+	override := Tree{
+		"string": green,
+		"markup": Tree{
+			"bold": blue,
+		},
+	}
+
+	got := MergeTrees(base, override)
+
+	if got["keyword"] != red {
+		t.Errorf("keyword = %v, want %v", got["keyword"], red)
+	}
+	if got["string"] != green {
+		t.Errorf("string = %v, want %v", got["string"], green)
+	}
+	markup, ok := got["markup"].(Tree)
+	if !ok {
+		t.Fatal("markup is not a Tree")
+	}
+	if markup["heading"] != red || markup["bold"] != blue {
+		t.Errorf("markup = %v, want heading and bold merged", markup)
+	}
+
+	// Inputs are not modified
+	if base["string"] != red {
+		t.Error("base was modified")
+	}
+	if _, ok := base["markup"].(Tree)["bold"]; ok {
+		t.Error("base nested tree was modified")
+	}
+}
+
 func TestApplyLightnessSteps_FlatLeaf(t *testing.T) {
 	c, _ := ParseHex("#808080")
 	root := &Node{
@@ -402,3 +539,195 @@ func TestColor_HexBareAlpha(t *testing.T) {
 		})
 	}
 }
+
+func TestAlphaColor(t *testing.T) {
+	c := Color{235, 111, 146}.WithAlpha(0.5)
+
+	if got, want := c.Hex(), "#eb6f92"; got != want {
+		t.Errorf("Hex() = %v, want %v", got, want)
+	}
+	if got, want := c.HexAlpha(), "#eb6f9280"; got != want {
+		t.Errorf("HexAlpha() = %v, want %v", got, want)
+	}
+	if got, want := c.HexBareAlpha(), "eb6f9280"; got != want {
+		t.Errorf("HexBareAlpha() = %v, want %v", got, want)
+	}
+	if got, want := c.RGBA(), "rgba(235, 111, 146, 0.5)"; got != want {
+		t.Errorf("RGBA() = %v, want %v", got, want)
+	}
+
+	opaque := Color{25, 23, 36}.WithAlpha(1)
+	if got, want := opaque.RGBA(), "rgba(25, 23, 36, 1.0)"; got != want {
+		t.Errorf("RGBA() = %v, want %v", got, want)
+	}
+
+	clamped := Color{}.WithAlpha(2)
+	if clamped.A != 1 {
+		t.Errorf("WithAlpha(2).A = %v, want 1", clamped.A)
+	}
+}
+
+func TestMix(t *testing.T) {
+	black := Color{0, 0, 0}
+	white := Color{255, 255, 255}
+
+	tests := []struct {
+		name   string
+		weight float64
+		want   Color
+	}{
+		{"all a", 0, black},
+		{"all b", 1, white},
+		{"halfway", 0.5, Color{128, 128, 128}},
+		{"clamped", 1.5, white},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Mix(black, white, tt.weight)
+			if got != tt.want {
+				t.Errorf("Mix(%v, %v, %v) = %v, want %v", black, white, tt.weight, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaturate(t *testing.T) {
+	red := Color{204, 51, 51} // hsl(0, 60%, 50%)
+	gray := Color{128, 128, 128}
+
+	tests := []struct {
+		name string
+		fn   func(Color, float64) Color
+		c    Color
+		amt  float64
+		want Color
+	}{
+		{"saturate", Saturate, red, 0.2, Color{230, 25, 25}},
+		{"saturate clamped", Saturate, red, 1, Color{255, 0, 0}},
+		{"saturate negative", Saturate, red, -0.2, Color{179, 76, 76}},
+		{"desaturate", Desaturate, red, 0.2, Color{179, 76, 76}},
+		{"desaturate fully", Desaturate, red, 1, Color{128, 128, 128}},
+		{"desaturate gray", Desaturate, gray, 0.5, gray},
+		{"saturate gray tints red", Saturate, gray, 0.5, Color{192, 65, 65}},
+		{"NaN", Saturate, red, math.NaN(), red},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fn(tt.c, tt.amt); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvert(t *testing.T) {
+	tests := []struct {
+		c    Color
+		want Color
+	}{
+		{Color{0, 0, 0}, Color{255, 255, 255}},
+		{Color{204, 51, 51}, Color{51, 204, 204}},
+		{Color{0x19, 0x17, 0x24}, Color{0xe6, 0xe8, 0xdb}},
+	}
+	for _, tt := range tests {
+		if got := Invert(tt.c); got != tt.want {
+			t.Errorf("Invert(%v) = %v, want %v", tt.c, got, tt.want)
+		}
+		if got := Invert(Invert(tt.c)); got != tt.c {
+			t.Errorf("Invert(Invert(%v)) = %v, want the color back", tt.c, got)
+		}
+	}
+}
+
+func TestHSL(t *testing.T) {
+	tests := []struct {
+		h, s, l float64
+		want    Color
+	}{
+		{0, 1, 0.5, Color{255, 0, 0}},
+		{120, 1, 0.5, Color{0, 255, 0}},
+		{480, 1, 0.5, Color{0, 255, 0}},
+		{-120, 1, 0.5, Color{0, 0, 255}},
+		{0, 0, 0.5, Color{128, 128, 128}},
+		{343, 0.76, 0.68, Color{235, 111, 147}},
+	}
+	for _, tt := range tests {
+		if got := HSL(tt.h, tt.s, tt.l); got != tt.want {
+			t.Errorf("HSL(%v, %v, %v) = %v, want %v", tt.h, tt.s, tt.l, got, tt.want)
+		}
+	}
+
+	for _, c := range []Color{{255, 0, 0}, {0, 0, 255}, {128, 128, 128}, {235, 111, 147}} {
+		if got := HSL(HSLOf(c)); got != c {
+			t.Errorf("HSL(HSLOf(%v)) = %v", c, got)
+		}
+	}
+}
+
+func TestShadeTint(t *testing.T) {
+	red := Color{204, 51, 51}
+	tests := []struct {
+		name string
+		got  Color
+		want Color
+	}{
+		{"shade none", Shade(red, 0), red},
+		{"shade half", Shade(red, 0.5), Color{102, 26, 26}},
+		{"shade full", Shade(red, 1), Color{0, 0, 0}},
+		{"tint half", Tint(red, 0.5), Color{230, 153, 153}},
+		{"tint full", Tint(red, 1), Color{255, 255, 255}},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	black := Color{0, 0, 0}
+	white := Color{255, 255, 255}
+
+	tests := []struct {
+		name  string
+		steps int
+		want  []Color
+	}{
+		{"endpoints only", 2, []Color{black, white}},
+		{"three steps", 3, []Color{black, {128, 128, 128}, white}},
+		{"five steps", 5, []Color{black, {64, 64, 64}, {128, 128, 128}, {191, 191, 191}, white}},
+		{"single step", 1, []Color{black}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Scale(black, white, tt.steps)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Scale(%v, %v, %d) = %v, want %v", black, white, tt.steps, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColor_HSLString(t *testing.T) {
+	tests := []struct {
+		c    Color
+		want string
+	}{
+		{Color{235, 111, 146}, "hsl(343, 76%, 68%)"},
+		{Color{255, 0, 0}, "hsl(0, 100%, 50%)"},
+		{Color{0, 0, 255}, "hsl(240, 100%, 50%)"},
+		{Color{255, 0, 1}, "hsl(0, 100%, 50%)"},
+		{Color{128, 128, 128}, "hsl(0, 0%, 50%)"},
+		{Color{0, 0, 0}, "hsl(0, 0%, 0%)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.c.Hex(), func(t *testing.T) {
+			if got := tt.c.HSLString(); got != tt.want {
+				t.Errorf("HSLString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
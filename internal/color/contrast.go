@@ -0,0 +1,21 @@
+package color
+
+// RelativeLuminance returns the WCAG 2 relative luminance of c, from 0 for
+// black to 1 for white.
+func RelativeLuminance(c Color) float64 {
+	r := srgbToLinear(float64(c.R) / 255.0)
+	g := srgbToLinear(float64(c.G) / 255.0)
+	b := srgbToLinear(float64(c.B) / 255.0)
+	return 0.2126*r + 0.7152*g + 0.0722*b
+}
+
+// ContrastRatio returns the WCAG 2 contrast ratio between two colors, from 1
+// for identical colors to 21 for black on white. The order of a and b does
+// not matter.
+func ContrastRatio(a, b Color) float64 {
+	la, lb := RelativeLuminance(a), RelativeLuminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
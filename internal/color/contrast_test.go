@@ -0,0 +1,28 @@
+package color
+
+import (
+	"math"
+	"testing"
+)
+
+func TestContrastRatio(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Color
+		want float64
+	}{
+		{"black on white", Color{0, 0, 0}, Color{255, 255, 255}, 21},
+		{"white on black", Color{255, 255, 255}, Color{0, 0, 0}, 21},
+		{"identical", Color{25, 23, 36}, Color{25, 23, 36}, 1},
+		{"gray on white", Color{118, 118, 118}, Color{255, 255, 255}, 4.54},
+		{"text on base", Color{224, 222, 244}, Color{25, 23, 36}, 13.39},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ContrastRatio(tt.a, tt.b)
+			if math.Abs(got-tt.want) > 0.01 {
+				t.Errorf("ContrastRatio(%s, %s) = %.3f, want %.2f", tt.a.Hex(), tt.b.Hex(), got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package color
+
+import (
+	"strings"
+	"unicode"
+)
+
+// FlatColor is a palette color under its flattened name.
+type FlatColor struct {
+	Name  string // flattened name, e.g. "highlight-low"
+	Path  string // path below the palette, e.g. "highlight.low"
+	Color Color
+}
+
+// FlatCollision is a flattened name that more than one palette path
+// flattens to.
+type FlatCollision struct {
+	Name  string
+	Paths []string // in declaration order
+}
+
+// FlatName flattens a path below the palette, such as "highlight.low", to a
+// single name for exports without nesting, such as CSS custom properties:
+// the path is lowercased, and every run of characters other than letters
+// and digits, including the dots between groups, is replaced with sep. With
+// sep "-", both "highlight.low" and "highlight_low" become "highlight-low".
+func FlatName(path, sep string) string {
+	var b strings.Builder
+	pending := false
+	for _, r := range strings.ToLower(path) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if pending && b.Len() > 0 {
+				b.WriteString(sep)
+			}
+			pending = false
+			b.WriteRune(r)
+			continue
+		}
+		pending = true
+	}
+	return b.String()
+}
+
+// Flatten returns the colors of the palette tree rooted at node, including
+// groups with their own color and the steps of transforms, under their
+// flattened names in declaration order. It also returns the names more
+// than one path flattens to, in the order of their first path; the color
+// under such a name is that of the first path.
+func Flatten(node *Node, sep string) ([]FlatColor, []FlatCollision) {
+	var colors []FlatColor
+	paths := make(map[string][]string)
+	var names []string
+	var walk func(node *Node, path string)
+	walk = func(node *Node, path string) {
+		if node.Color != nil && path != "" {
+			name := FlatName(path, sep)
+			if _, ok := paths[name]; !ok {
+				names = append(names, name)
+				colors = append(colors, FlatColor{Name: name, Path: path, Color: *node.Color})
+			}
+			paths[name] = append(paths[name], path)
+		}
+		for _, name := range node.Names() {
+			child := name
+			if path != "" {
+				child = path + "." + name
+			}
+			walk(node.Children[name], child)
+		}
+	}
+	if node != nil {
+		walk(node, "")
+	}
+
+	var collisions []FlatCollision
+	for _, name := range names {
+		if len(paths[name]) > 1 {
+			collisions = append(collisions, FlatCollision{Name: name, Paths: paths[name]})
+		}
+	}
+	return colors, collisions
+}
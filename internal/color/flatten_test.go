@@ -0,0 +1,58 @@
+package color
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFlatName(t *testing.T) {
+	tests := []struct {
+		path, sep, want string
+	}{
+		{"highlight.low", "-", "highlight-low"},
+		{"highlight_low", "-", "highlight-low"},
+		{"Highlight.Low", "_", "highlight_low"},
+		{"base.l1", "", "basel1"},
+		{"a..b__c", "-", "a-b-c"},
+	}
+	for _, tt := range tests {
+		if got := FlatName(tt.path, tt.sep); got != tt.want {
+			t.Errorf("FlatName(%q, %q) = %q, want %q", tt.path, tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	c := func(hex string) *Color {
+		color, err := ParseHex(hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &color
+	}
+	root := &Node{}
+	root.SetChild("base", &Node{Color: c("#191724")})
+	highlight := &Node{Color: c("#21202e")}
+	highlight.SetChild("low", &Node{Color: c("#403d52")})
+	root.SetChild("highlight", highlight)
+	root.SetChild("highlight_low", &Node{Color: c("#524f67")})
+	root.SetChild("text", &Node{Color: c("#e0def4")})
+
+	colors, collisions := Flatten(root, "-")
+	var names []string
+	for _, fc := range colors {
+		names = append(names, fc.Name+"="+fc.Path)
+	}
+	want := []string{"base=base", "highlight=highlight", "highlight-low=highlight.low", "text=text"}
+	if !slices.Equal(names, want) {
+		t.Errorf("Flatten() names = %v, want %v", names, want)
+	}
+	if len(collisions) != 1 || collisions[0].Name != "highlight-low" ||
+		!slices.Equal(collisions[0].Paths, []string{"highlight.low", "highlight_low"}) {
+		t.Errorf("Flatten() collisions = %+v, want highlight.low and highlight_low", collisions)
+	}
+
+	if colors, collisions := Flatten(nil, "-"); colors != nil || collisions != nil {
+		t.Errorf("Flatten(nil) = %v, %v, want nothing", colors, collisions)
+	}
+}
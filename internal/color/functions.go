@@ -2,53 +2,147 @@ package color
 
 import "math"
 
-// Brighten returns a brighter version of the given color.
+// Brighten returns a brighter version of the given color, adding percentage
+// to its HSL lightness. Channels are rounded to the nearest value, so
+// brightening black by 0.5 gives #808080. A NaN percentage returns the color
+// unchanged.
 func Brighten(color Color, percentage float64) Color {
-	// Normalize RGB to 0-1 range
-	r, g, b := float64(color.R)/255.0, float64(color.G)/255.0, float64(color.B)/255.0
+	if math.IsNaN(percentage) {
+		return color
+	}
+	h, s, l := rgbToHSL(color)
+	return hslToRGB(h, s, clamp01(l+percentage))
+}
+
+// Darken returns a darker version of the given color.
+func Darken(color Color, percentage float64) Color {
+	return Brighten(color, percentage*-1)
+}
+
+// Saturate returns a more saturated version of the given color, adding
+// amount to its HSL saturation. Grays have no hue, so saturating them tints
+// them red. A NaN amount returns the color unchanged.
+func Saturate(color Color, amount float64) Color {
+	if math.IsNaN(amount) {
+		return color
+	}
+	h, s, l := rgbToHSL(color)
+	return hslToRGB(h, clamp01(s+amount), l)
+}
+
+// Desaturate returns a less saturated version of the given color.
+func Desaturate(color Color, amount float64) Color {
+	return Saturate(color, amount*-1)
+}
 
-	// Convert RGB to HSL internally
-	var h, s, l float64
+// Invert returns the RGB inverse of the given color, each channel
+// subtracted from 255. Unlike InvertLightness it also turns the hue around.
+func Invert(color Color) Color {
+	return Color{R: 255 - color.R, G: 255 - color.G, B: 255 - color.B}
+}
+
+// HSL returns the color with the given HSL hue in degrees, which wraps
+// around, and saturation and lightness in [0, 1], which are clamped.
+// Channels are rounded to the nearest value. A NaN hue is taken as 0.
+func HSL(hue, saturation, lightness float64) Color {
+	h := math.Mod(hue/360, 1)
+	if h < 0 {
+		h++
+	}
+	if math.IsNaN(h) {
+		h = 0
+	}
+	return hslToRGB(h, clamp01(saturation), clamp01(lightness))
+}
+
+// HSLOf returns the HSL hue in degrees, from 0 up to 360, and saturation
+// and lightness in [0, 1] of color, the inverse of HSL.
+func HSLOf(color Color) (hue, saturation, lightness float64) {
+	h, s, l := rgbToHSL(color)
+	return h * 360, s, l
+}
+
+// Mix blends two colors in sRGB space. Weight is the proportion of b in the
+// result and is clamped to [0, 1]: 0 returns a, 1 returns b.
+func Mix(a, b Color, weight float64) Color {
+	w := clamp01(weight)
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x)*(1-w) + float64(y)*w))
+	}
+	return Color{
+		R: mix(a.R, b.R),
+		G: mix(a.G, b.G),
+		B: mix(a.B, b.B),
+	}
+}
+
+// Shade returns the color mixed toward black by amount, in [0, 1] and
+// clamped as in Mix. Unlike Darken it keeps the ratio of the channels, as
+// shades in design systems do.
+func Shade(color Color, amount float64) Color {
+	return Mix(color, Color{}, amount)
+}
+
+// Tint returns the color mixed toward white by amount, in [0, 1] and
+// clamped as in Mix.
+func Tint(color Color, amount float64) Color {
+	return Mix(color, Color{R: 255, G: 255, B: 255}, amount)
+}
+
+// Scale returns steps colors evenly interpolated from a to b with Mix,
+// including both endpoints. Steps below 2 return just a.
+func Scale(a, b Color, steps int) []Color {
+	if steps < 2 {
+		return []Color{a}
+	}
+	colors := make([]Color, steps)
+	for i := range colors {
+		colors[i] = Mix(a, b, float64(i)/float64(steps-1))
+	}
+	return colors
+}
+
+// rgbToHSL converts a color to HSL. Hue, saturation and lightness are all
+// in [0, 1].
+func rgbToHSL(color Color) (h, s, l float64) {
+	r, g, b := float64(color.R)/255.0, float64(color.G)/255.0, float64(color.B)/255.0
 
 	min := math.Min(math.Min(r, g), b)
 	max := math.Max(math.Max(r, g), b)
 	l = (max + min) / 2.0
 
 	if max == min {
-		h = 0
-		s = 0 // Achromatic
+		return 0, 0, l // Achromatic
+	}
+
+	d := max - min
+	if l > 0.5 {
+		s = d / (2.0 - max - min)
 	} else {
-		d := max - min
-		if l > 0.5 {
-			s = d / (2.0 - max - min)
-		} else {
-			s = d / (max + min)
-		}
+		s = d / (max + min)
+	}
 
-		switch max {
-		case r:
-			h = (g - b) / d
-			if g < b {
-				h += 6.0
-			}
-			h /= 6.0
-		case g:
-			h = ((b-r)/d + 2.0) / 6.0
-		case b:
-			h = ((r-g)/d + 4.0) / 6.0
+	switch max {
+	case r:
+		h = (g - b) / d
+		if g < b {
+			h += 6.0
 		}
-
+		h /= 6.0
+	case g:
+		h = ((b-r)/d + 2.0) / 6.0
+	case b:
+		h = ((r-g)/d + 4.0) / 6.0
 	}
+	return h, s, l
+}
 
-	// Increase lightness, clamp at 0.0/1.0
-	l = math.Min(1.0, l+(percentage))
-	l = math.Max(0.0, l)
-
-	// Convert back to RGB
-	var r1, g1, b1 float64
-
+// hslToRGB converts hue, saturation and lightness in [0, 1] to a color,
+// rounding channels to the nearest value.
+func hslToRGB(h, s, l float64) Color {
+	var r, g, b float64
 	if s == 0 { // Achromatic
-		r1, g1, b1 = l, l, l
+		r, g, b = l, l, l
 	} else {
 		var q float64
 		if l < 0.5 {
@@ -58,23 +152,18 @@ func Brighten(color Color, percentage float64) Color {
 		}
 		p := 2.0*l - q
 
-		r1 = hueToRGB(p, q, h+1.0/3.0)
-		g1 = hueToRGB(p, q, h)
-		b1 = hueToRGB(p, q, h-1.0/3.0)
+		r = hueToRGB(p, q, h+1.0/3.0)
+		g = hueToRGB(p, q, h)
+		b = hueToRGB(p, q, h-1.0/3.0)
 	}
 
 	return Color{
-		R: uint8(r1 * 255),
-		G: uint8(g1 * 255),
-		B: uint8(b1 * 255),
+		R: uint8(math.Round(clamp01(r) * 255)),
+		G: uint8(math.Round(clamp01(g) * 255)),
+		B: uint8(math.Round(clamp01(b) * 255)),
 	}
 }
 
-// Darken returns a darker version of the given color.
-func Darken(color Color, percentage float64) Color {
-	return Brighten(color, percentage*-1)
-}
-
 func hueToRGB(p, q, t float64) float64 {
 	if t < 0 {
 		t += 1.0
@@ -0,0 +1,23 @@
+package color
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzParseHex(f *testing.F) {
+	for _, seed := range []string{"#191724", "eb6f92", "#FFFFFF", "", "#", "#12345", "+1+1+1", "#gg0000"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		c, err := ParseHex(s)
+		if err != nil {
+			return
+		}
+		// A successful parse must round-trip to the same digits.
+		if got, want := c.HexBare(), strings.ToLower(strings.TrimPrefix(s, "#")); got != want {
+			t.Errorf("ParseHex(%q) = %s, which does not round-trip", s, got)
+		}
+	})
+}
@@ -1,6 +1,9 @@
 package color
 
-import "math"
+import (
+	"fmt"
+	"math"
+)
 
 // RGBToOKLCH converts an sRGB Color to OKLCH components.
 // L is lightness [0, 1], chroma is colorfulness [0, ~0.37], hue is in degrees [0, 360).
@@ -23,9 +26,36 @@ func RGBToOKLCH(c Color) (l, chroma, hue float64) {
 	return L, chroma, hue
 }
 
+// OKLCH holds the OKLCH components of a color: L is lightness [0, 1], C is
+// chroma [0, ~0.37], and H is hue in degrees [0, 360).
+type OKLCH struct {
+	L, C, H float64
+}
+
+// OKLCH returns the color's OKLCH components.
+func (c Color) OKLCH() OKLCH {
+	l, chroma, hue := RGBToOKLCH(c)
+	return OKLCH{L: l, C: chroma, H: hue}
+}
+
+// OKLCHString returns the color as a CSS oklch() string, e.g.
+// "oklch(62.8% 0.258 29.2)". The hue of an achromatic color is 0.
+func (c Color) OKLCHString() string {
+	o := c.OKLCH()
+	chroma := math.Round(o.C*1000) / 1000
+	hue := 0.0
+	if chroma > 0 {
+		hue = math.Mod(math.Round(o.H*10)/10, 360)
+	}
+	return fmt.Sprintf("oklch(%.1f%% %.3f %.1f)", o.L*100, chroma, hue)
+}
+
 // OKLCHToRGB converts OKLCH components to an sRGB Color.
 // L is lightness [0, 1], chroma is colorfulness, hue is in degrees [0, 360).
+// Colors outside sRGB are clamped; NaN and infinite components count as 0.
 func OKLCHToRGB(l, chroma, hue float64) Color {
+	l, chroma, hue = finite(l), finite(chroma), finite(hue)
+
 	// OKLCH → OKLAB
 	hRad := hue * (math.Pi / 180.0)
 	a := chroma * math.Cos(hRad)
@@ -109,9 +139,49 @@ func StepLightness(c Color, lightness float64) Color {
 	return OKLCHToRGB(lightness, chroma, hue)
 }
 
-// clamp01 clamps a value to the [0, 1] range.
+// LightenOK returns the color with amount added to its OKLCH lightness,
+// clamped to [0, 1], preserving hue and as much chroma as sRGB can show at
+// the new lightness. Unlike Brighten, which moves HSL lightness, it does not
+// visibly shift the hue of saturated colors, and equal amounts look like
+// equal steps. Unlike StepLightness it reduces chroma rather than clipping
+// the channels, which would shift the hue of saturated colors near white
+// and black. A negative amount darkens, and a NaN amount returns the color
+// unchanged.
+func LightenOK(c Color, amount float64) Color {
+	if math.IsNaN(amount) {
+		return c
+	}
+	l, chroma, hue := RGBToOKLCH(c)
+	l = clamp01(l + amount)
+	if l == 0 || l == 1 {
+		// Only black and white have these lightnesses; fitChroma's
+		// tolerance would leave a trace of the hue.
+		return Gray(l)
+	}
+	return OKLCHToRGB(l, fitChroma(l, chroma, hue), hue)
+}
+
+// DarkenOK returns the color with amount subtracted from its OKLCH
+// lightness, like LightenOK.
+func DarkenOK(c Color, amount float64) Color {
+	return LightenOK(c, -amount)
+}
+
+// Gray returns the neutral color with the given OKLCH lightness, clamped to
+// [0, 1]: 0 is black and 1 white. Grays at evenly spaced lightness look
+// evenly spaced, unlike grays evenly spaced in sRGB. The channels are
+// always equal, so the result has no tint.
+func Gray(lightness float64) Color {
+	// Without chroma, OKLAB's cone responses all equal the lightness, and
+	// the linear RGB channels their cube.
+	l := clamp01(lightness)
+	v := uint8(math.Round(linearToSRGB(l*l*l) * 255.0))
+	return Color{R: v, G: v, B: v}
+}
+
+// clamp01 clamps a value to the [0, 1] range. NaN becomes 0.
 func clamp01(v float64) float64 {
-	if v < 0 {
+	if !(v > 0) {
 		return 0
 	}
 	if v > 1 {
@@ -119,3 +189,80 @@ func clamp01(v float64) float64 {
 	}
 	return v
 }
+
+// finite returns v, or 0 if v is NaN or infinite.
+func finite(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return v
+}
+
+// InvertLightness returns the color with its OKLCH lightness L replaced by
+// 1 - L, preserving hue and as much chroma as sRGB can show at the new
+// lightness. Dark colors become light and vice versa, so their order by
+// lightness is reversed.
+func InvertLightness(c Color) Color {
+	l, chroma, hue := RGBToOKLCH(c)
+	l = 1 - l
+	return OKLCHToRGB(l, fitChroma(l, chroma, hue), hue)
+}
+
+// RotateHue returns the color with its OKLCH hue rotated by degrees,
+// preserving lightness and as much chroma as sRGB can show at the new hue.
+// Rotating in OKLCH keeps the perceived lightness of the result, so
+// analogous (±30) and triadic (±120) accents derived from one color look
+// balanced. Grays have no hue and are returned unchanged, as is the color
+// for a NaN or infinite rotation.
+func RotateHue(c Color, degrees float64) Color {
+	if math.IsNaN(degrees) || math.IsInf(degrees, 0) {
+		return c
+	}
+	l, chroma, hue := RGBToOKLCH(c)
+	if chroma < 1e-4 {
+		return c
+	}
+	hue = math.Mod(hue+degrees, 360)
+	if hue < 0 {
+		hue += 360
+	}
+	return OKLCHToRGB(l, fitChroma(l, chroma, hue), hue)
+}
+
+// Complement returns the complementary color, with its OKLCH hue rotated by
+// 180 degrees. Grays are returned unchanged.
+func Complement(c Color) Color {
+	return RotateHue(c, 180)
+}
+
+// fitChroma returns chroma, reduced if needed until the OKLCH color is
+// within the sRGB gamut. Clipping an out-of-gamut color instead would shift
+// its hue.
+func fitChroma(l, chroma, hue float64) float64 {
+	if inGamut(l, chroma, hue) {
+		return chroma
+	}
+	low, high := 0.0, chroma
+	for range 20 {
+		mid := (low + high) / 2
+		if inGamut(l, mid, hue) {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return low
+}
+
+// inGamut reports whether the OKLCH color is within the sRGB gamut.
+func inGamut(l, chroma, hue float64) bool {
+	const eps = 1e-4
+	hRad := hue * (math.Pi / 180.0)
+	r, g, b := oklabToLinearRGB(l, chroma*math.Cos(hRad), chroma*math.Sin(hRad))
+	for _, v := range []float64{r, g, b} {
+		if v < -eps || v > 1+eps {
+			return false
+		}
+	}
+	return true
+}
@@ -150,6 +150,42 @@ func TestStepLightness(t *testing.T) {
 	}
 }
 
+func TestLightenOK(t *testing.T) {
+	love := Color{0xeb, 0x6f, 0x92}
+	origL, _, origH := RGBToOKLCH(love)
+
+	tests := []struct {
+		name  string
+		got   Color
+		wantL float64
+	}{
+		{"lighten", LightenOK(love, 0.1), origL + 0.1},
+		{"lighten negative", LightenOK(love, -0.1), origL - 0.1},
+		{"darken", DarkenOK(love, 0.2), origL - 0.2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotL, _, gotH := RGBToOKLCH(tt.got)
+			if math.Abs(gotL-tt.wantL) > 0.01 {
+				t.Errorf("L = %f, want %f", gotL, tt.wantL)
+			}
+			if math.Abs(gotH-origH) > 2 {
+				t.Errorf("hue shifted: orig=%f, got=%f", origH, gotH)
+			}
+		})
+	}
+
+	if got := LightenOK(Color{255, 255, 255}, 0.5); got != (Color{255, 255, 255}) {
+		t.Errorf("LightenOK(white, 0.5) = %v, want white", got)
+	}
+	if got := DarkenOK(love, 1); got != (Color{0, 0, 0}) {
+		t.Errorf("DarkenOK(love, 1) = %v, want black", got)
+	}
+	if got := LightenOK(love, math.NaN()); got != love {
+		t.Errorf("LightenOK(love, NaN) = %v, want the color unchanged", got)
+	}
+}
+
 func TestStepLightness_PreservesHueChroma(t *testing.T) {
 	red := Color{255, 0, 0}
 	_, origC, origH := RGBToOKLCH(red)
@@ -193,3 +229,143 @@ func TestRGBToOKLCH_Roundtrip(t *testing.T) {
 		})
 	}
 }
+
+func TestColor_OKLCHString(t *testing.T) {
+	tests := []struct {
+		c    Color
+		want string
+	}{
+		{Color{255, 0, 0}, "oklch(62.8% 0.258 29.2)"},
+		{Color{255, 255, 255}, "oklch(100.0% 0.000 0.0)"},
+		{Color{0, 0, 0}, "oklch(0.0% 0.000 0.0)"},
+		{Color{128, 128, 128}, "oklch(60.0% 0.000 0.0)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.c.Hex(), func(t *testing.T) {
+			if got := tt.c.OKLCHString(); got != tt.want {
+				t.Errorf("OKLCHString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvertLightness(t *testing.T) {
+	tests := []struct {
+		name  string
+		color Color
+	}{
+		{"black", Color{0, 0, 0}},
+		{"white", Color{255, 255, 255}},
+		{"dark background", Color{0x19, 0x17, 0x24}},
+		{"muted accent", Color{0x9c, 0xcf, 0xd8}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, _, wantH := RGBToOKLCH(tt.color)
+			got := InvertLightness(tt.color)
+			gotL, gotC, gotH := RGBToOKLCH(got)
+			if math.Abs(gotL-(1-want)) > 0.02 {
+				t.Errorf("InvertLightness(%v) produced L=%f, want L≈%f", tt.color, gotL, 1-want)
+			}
+			if gotC > 0.02 && math.Abs(gotH-wantH) > 2.0 {
+				t.Errorf("hue shifted: orig=%f, got=%f", wantH, gotH)
+			}
+		})
+	}
+}
+
+func TestRotateHue(t *testing.T) {
+	love := Color{0xeb, 0x6f, 0x92}
+	tests := []struct {
+		name    string
+		color   Color
+		degrees float64
+	}{
+		{"analogous", love, 30},
+		{"analogous backwards", love, -30},
+		{"triadic", love, 120},
+		{"complement", love, 180},
+		{"wraps past 360", love, 400},
+		{"muted accent", Color{0x9c, 0xcf, 0xd8}, 120},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantL, _, h := RGBToOKLCH(tt.color)
+			wantH := math.Mod(h+tt.degrees+720, 360)
+			got := RotateHue(tt.color, tt.degrees)
+			gotL, _, gotH := RGBToOKLCH(got)
+			if math.Abs(gotL-wantL) > 0.01 {
+				t.Errorf("RotateHue(%v, %v) produced L=%f, want L≈%f", tt.color, tt.degrees, gotL, wantL)
+			}
+			if d := math.Abs(gotH - wantH); math.Min(d, 360-d) > 2.0 {
+				t.Errorf("RotateHue(%v, %v) produced H=%f, want H≈%f", tt.color, tt.degrees, gotH, wantH)
+			}
+		})
+	}
+
+	if got := RotateHue(love, 360); got != love {
+		t.Errorf("RotateHue(%v, 360) = %v, want it unchanged", love, got)
+	}
+	gray := Color{0x80, 0x80, 0x80}
+	if got := RotateHue(gray, 90); got != gray {
+		t.Errorf("RotateHue(%v, 90) = %v, want grays unchanged", gray, got)
+	}
+	if got, want := Complement(love), RotateHue(love, 180); got != want {
+		t.Errorf("Complement(%v) = %v, want %v", love, got, want)
+	}
+}
+
+func TestGray(t *testing.T) {
+	if got := Gray(0); got != (Color{0, 0, 0}) {
+		t.Errorf("Gray(0) = %v, want black", got)
+	}
+	if got := Gray(1); got != (Color{255, 255, 255}) {
+		t.Errorf("Gray(1) = %v, want white", got)
+	}
+	if got := Gray(-0.5); got != (Color{0, 0, 0}) {
+		t.Errorf("Gray(-0.5) = %v, want it clamped to black", got)
+	}
+
+	for _, l := range []float64{0.1, 0.2, 0.25, 0.5, 0.75, 0.9} {
+		got := Gray(l)
+		if got.R != got.G || got.G != got.B {
+			t.Errorf("Gray(%v) = %v, want equal channels", l, got)
+		}
+		if gotL, _, _ := RGBToOKLCH(got); math.Abs(gotL-l) > 0.005 {
+			t.Errorf("Gray(%v) has L=%f, want L≈%v", l, gotL, l)
+		}
+	}
+}
+
+func TestNonFiniteInputs(t *testing.T) {
+	c := Color{R: 0x19, G: 0x17, B: 0x24}
+	nan, inf := math.NaN(), math.Inf(1)
+
+	tests := []struct {
+		name string
+		got  Color
+		want Color
+	}{
+		{"Brighten NaN", Brighten(c, nan), c},
+		{"Brighten +Inf", Brighten(c, inf), Color{255, 255, 255}},
+		{"Brighten -Inf", Brighten(c, -inf), Color{0, 0, 0}},
+		{"Darken NaN", Darken(c, nan), c},
+		{"Darken +Inf", Darken(c, inf), Color{0, 0, 0}},
+		{"Mix NaN", Mix(c, Color{255, 255, 255}, nan), c},
+		{"OKLCHToRGB NaN lightness", OKLCHToRGB(nan, 0.1, 120), OKLCHToRGB(0, 0.1, 120)},
+		{"OKLCHToRGB Inf lightness", OKLCHToRGB(inf, 0, 0), Color{0, 0, 0}},
+		{"OKLCHToRGB NaN chroma, Inf hue", OKLCHToRGB(0.5, nan, inf), OKLCHToRGB(0.5, 0, 0)},
+		{"StepLightness NaN", StepLightness(c, nan), OKLCHToRGB(0, c.OKLCH().C, c.OKLCH().H)},
+		{"RotateHue NaN", RotateHue(c, nan), c},
+		{"RotateHue Inf", RotateHue(c, inf), c},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
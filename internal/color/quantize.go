@@ -0,0 +1,32 @@
+package color
+
+import "math"
+
+// Distance returns the perceptual distance between two colors, measured as
+// the Euclidean distance in OKLAB.
+func Distance(a, b Color) float64 {
+	l1, a1, b1 := toOKLAB(a)
+	l2, a2, b2 := toOKLAB(b)
+	return math.Sqrt((l1-l2)*(l1-l2) + (a1-a2)*(a1-a2) + (b1-b2)*(b1-b2))
+}
+
+// Nearest returns the index of the candidate perceptually closest to c, or
+// -1 if there are no candidates. Ties go to the lower index.
+func Nearest(c Color, candidates []Color) int {
+	best, bestDist := -1, math.Inf(1)
+	for i, candidate := range candidates {
+		if d := Distance(c, candidate); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// toOKLAB converts an sRGB color to OKLAB.
+func toOKLAB(c Color) (float64, float64, float64) {
+	return linearRGBToOKLAB(
+		srgbToLinear(float64(c.R)/255.0),
+		srgbToLinear(float64(c.G)/255.0),
+		srgbToLinear(float64(c.B)/255.0),
+	)
+}
@@ -0,0 +1,52 @@
+package color
+
+import "testing"
+
+func TestDistance(t *testing.T) {
+	black := Color{0, 0, 0}
+	white := Color{255, 255, 255}
+	gray := Color{128, 128, 128}
+
+	if d := Distance(black, black); d != 0 {
+		t.Errorf("Distance(black, black) = %v, want 0", d)
+	}
+	if d1, d2 := Distance(black, white), Distance(white, black); d1 != d2 {
+		t.Errorf("Distance is not symmetric: %v != %v", d1, d2)
+	}
+	if Distance(gray, white) >= Distance(black, white) {
+		t.Error("gray should be closer to white than black is")
+	}
+}
+
+func TestNearest(t *testing.T) {
+	candidates := []Color{
+		{0, 0, 0},       // black
+		{205, 49, 49},   // red
+		{13, 188, 121},  // green
+		{229, 229, 229}, // white
+		{205, 49, 49},   // duplicate red
+	}
+
+	tests := []struct {
+		name string
+		c    Color
+		want int
+	}{
+		{"exact", Color{13, 188, 121}, 2},
+		{"dark gray", Color{30, 30, 30}, 0},
+		{"light gray", Color{200, 200, 200}, 3},
+		{"pink", Color{235, 111, 146}, 1},
+		{"tie goes to lower index", Color{205, 49, 49}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Nearest(tt.c, candidates); got != tt.want {
+				t.Errorf("Nearest(%s) = %d, want %d", tt.c.Hex(), got, tt.want)
+			}
+		})
+	}
+
+	if got := Nearest(Color{}, nil); got != -1 {
+		t.Errorf("Nearest with no candidates = %d, want -1", got)
+	}
+}
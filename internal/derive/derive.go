@@ -0,0 +1,363 @@
+// Package derive bootstraps a variant of a theme with the opposite
+// appearance, e.g. a light theme from a dark one, by inverting the lightness
+// of its colors. The result is a starting point for the author to tweak, not
+// a finished theme.
+package derive
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/format"
+	"github.com/jsvensson/paletteswap/internal/theme"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Appearances lists the appearances a theme can be derived as.
+var Appearances = []string{"dark", "light"}
+
+// colorBlocks are the top-level blocks whose color literals are rewritten.
+var colorBlocks = []string{"palette", "locals", "theme", "ansi", "syntax", "semantic"}
+
+// opposites maps each lightness function to the one with the opposite effect.
+var opposites = map[string]string{
+	"brighten":   "darken",
+	"darken":     "brighten",
+	"lighten_ok": "darken_ok",
+	"darken_ok":  "lighten_ok",
+	"shade":      "tint",
+	"tint":       "shade",
+}
+
+// edit replaces src[start:end] with text.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// Derive returns a copy of the theme file src with the given appearance:
+//
+//   - Every hex color, [r, g, b] literal and rgb() or hsl() call with
+//     constant arguments gets the inverse OKLCH lightness, keeping its hue
+//     and chroma, so a dark background becomes a light one and light
+//     foreground text becomes dark.
+//   - brighten() and darken() calls are swapped, as are lighten_ok() and
+//     darken_ok(), and shade() and tint(), so derived colors keep their
+//     direction relative to the colors they derive from. A brighten() or
+//     lighten_ok() by a negative literal amount becomes one by the positive
+//     amount.
+//   - gray(l) becomes gray(1 - l), computed for a constant l.
+//   - Blocks with a when attribute are left as they are.
+//   - meta.appearance is set, and the appearance is appended to meta.name.
+//
+// References, comments and layout are kept, and the result is formatted.
+// Lightness transform ranges are left as they are. It is an error if the
+// theme already declares the requested appearance.
+func Derive(filename string, src []byte, appearance string) ([]byte, error) {
+	if !slices.Contains(Appearances, appearance) {
+		return nil, fmt.Errorf("unknown appearance %q (valid: %s)", appearance, strings.Join(Appearances, ", "))
+	}
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parsing %s: %s", filename, diags.Error())
+	}
+	body := file.Body.(*hclsyntax.Body)
+
+	var edits []edit
+	var meta *hclsyntax.Block
+	for _, block := range body.Blocks {
+		if block.Type == "meta" {
+			meta = block
+			continue
+		}
+		if slices.Contains(colorBlocks, block.Type) {
+			edits = append(edits, invertBody(block.Body)...)
+		}
+	}
+
+	metaEdits, err := metaEdits(meta, appearance)
+	if err != nil {
+		return nil, err
+	}
+	edits = append(edits, metaEdits...)
+
+	out := applyEdits(src, edits)
+	formatted, err := format.Format(string(out))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(formatted), nil
+}
+
+// invertBody returns the edits inverting the colors in body. Blocks with a
+// when attribute are left as they are: those for the derived appearance are
+// already written for it, and those for the other one do not apply to it.
+func invertBody(body *hclsyntax.Body) []edit {
+	if _, ok := body.Attributes[theme.WhenAttr]; ok {
+		return nil
+	}
+	var edits []edit
+	for _, attr := range body.Attributes {
+		edits = append(edits, invertExpr(attr.Expr)...)
+	}
+	for _, block := range body.Blocks {
+		edits = append(edits, invertBody(block.Body)...)
+	}
+	return edits
+}
+
+// invertExpr returns the edits inverting the colors in expr.
+func invertExpr(expr hclsyntax.Expression) []edit {
+	var edits []edit
+	_ = hclsyntax.VisitAll(expr, func(node hclsyntax.Node) hcl.Diagnostics {
+		switch n := node.(type) {
+		case *hclsyntax.FunctionCallExpr:
+			if n.Name == "gray" && len(n.Args) == 1 {
+				edits = append(edits, complementEdits(n.Args[0])...)
+				break
+			}
+			if c, ok := constructorLiteral(n); ok {
+				rng := n.Range()
+				edits = append(edits, edit{rng.Start.Byte, rng.End.Byte, constructorCall(n.Name, color.InvertLightness(c))})
+				break
+			}
+			opposite, ok := opposites[n.Name]
+			if !ok {
+				break
+			}
+			// brighten(c, -x) darkens, and darken only takes a positive
+			// amount, so brighten(c, x) is its opposite; likewise for
+			// lighten_ok.
+			if (n.Name == "brighten" || n.Name == "lighten_ok") && len(n.Args) == 2 {
+				if amount, ok := negativeLiteral(n.Args[1]); ok {
+					rng := n.Args[1].Range()
+					edits = append(edits, edit{rng.Start.Byte, rng.End.Byte, amount})
+					break
+				}
+			}
+			edits = append(edits, edit{n.NameRange.Start.Byte, n.NameRange.End.Byte, opposite})
+		case *hclsyntax.TemplateExpr:
+			if c, ok := hexLiteral(n); ok {
+				edits = append(edits, edit{n.SrcRange.Start.Byte, n.SrcRange.End.Byte, strconv.Quote(color.InvertLightness(c).Hex())})
+			}
+		case *hclsyntax.TupleConsExpr:
+			if c, ok := rgbLiteral(n); ok {
+				inv := color.InvertLightness(c)
+				edits = append(edits, edit{n.SrcRange.Start.Byte, n.SrcRange.End.Byte, fmt.Sprintf("[%d, %d, %d]", inv.R, inv.G, inv.B)})
+			}
+		}
+		return nil
+	})
+	return edits
+}
+
+// hexLiteral returns the color of a string literal holding a hex color.
+func hexLiteral(expr *hclsyntax.TemplateExpr) (color.Color, bool) {
+	if !expr.IsStringLiteral() {
+		return color.Color{}, false
+	}
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() || val.IsNull() || val.Type() != cty.String {
+		return color.Color{}, false
+	}
+	s := strings.TrimSpace(val.AsString())
+	if !strings.HasPrefix(s, "#") {
+		return color.Color{}, false
+	}
+	c, err := color.ParseHex(s)
+	return c, err == nil
+}
+
+// negativeLiteral reports whether expr is a negative number literal such as
+// -0.1, and returns its absolute value formatted for HCL.
+func negativeLiteral(expr hclsyntax.Expression) (string, bool) {
+	f, ok := numberConstant(expr)
+	if !ok || f.Sign() >= 0 {
+		return "", false
+	}
+	return f.Neg(f).Text('g', -1), true
+}
+
+// complementEdits returns the edits turning expr, a lightness from 0 to 1,
+// into 1 minus it: a constant is replaced by its complement, and any other
+// expression is subtracted from 1.
+func complementEdits(expr hclsyntax.Expression) []edit {
+	rng := expr.Range()
+	if f, ok := numberConstant(expr); ok {
+		one := new(big.Float).SetPrec(f.Prec()).SetInt64(1)
+		return []edit{{rng.Start.Byte, rng.End.Byte, one.Sub(one, f).Text('g', 10)}}
+	}
+	switch expr.(type) {
+	case *hclsyntax.ScopeTraversalExpr, *hclsyntax.FunctionCallExpr, *hclsyntax.ParenthesesExpr:
+		return []edit{{rng.Start.Byte, rng.Start.Byte, "1 - "}}
+	}
+	return []edit{{rng.Start.Byte, rng.Start.Byte, "1 - ("}, {rng.End.Byte, rng.End.Byte, ")"}}
+}
+
+// numberConstant returns the value of expr if it is a number that does not
+// depend on any variable, such as 0.2 or -0.1.
+func numberConstant(expr hclsyntax.Expression) (*big.Float, bool) {
+	if len(expr.Variables()) > 0 {
+		return nil, false
+	}
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() || val.IsNull() || !val.IsKnown() || val.Type() != cty.Number {
+		return nil, false
+	}
+	return val.AsBigFloat(), true
+}
+
+// rgbLiteral returns the color of an [r, g, b] tuple of number literals.
+func rgbLiteral(expr *hclsyntax.TupleConsExpr) (color.Color, bool) {
+	if len(expr.Exprs) != 3 {
+		return color.Color{}, false
+	}
+	var rgb [3]uint8
+	for i, e := range expr.Exprs {
+		lit, ok := e.(*hclsyntax.LiteralValueExpr)
+		if !ok || lit.Val.Type() != cty.Number {
+			return color.Color{}, false
+		}
+		f := lit.Val.AsBigFloat()
+		n, _ := f.Int64()
+		if !f.IsInt() || n < 0 || n > 255 {
+			return color.Color{}, false
+		}
+		rgb[i] = uint8(n)
+	}
+	return color.Color{R: rgb[0], G: rgb[1], B: rgb[2]}, true
+}
+
+// constructorLiteral returns the color of an rgb() or hsl() call whose
+// arguments are all constants.
+func constructorLiteral(call *hclsyntax.FunctionCallExpr) (color.Color, bool) {
+	if (call.Name != "rgb" && call.Name != "hsl") || len(call.Args) != 3 {
+		return color.Color{}, false
+	}
+	var args [3]*big.Float
+	for i, arg := range call.Args {
+		f, ok := numberConstant(arg)
+		if !ok {
+			return color.Color{}, false
+		}
+		args[i] = f
+	}
+	if call.Name == "rgb" {
+		var rgb [3]uint8
+		for i, f := range args {
+			n, _ := f.Int64()
+			if !f.IsInt() || n < 0 || n > 255 {
+				return color.Color{}, false
+			}
+			rgb[i] = uint8(n)
+		}
+		return color.Color{R: rgb[0], G: rgb[1], B: rgb[2]}, true
+	}
+	h, _ := args[0].Float64()
+	sat, _ := args[1].Float64()
+	l, _ := args[2].Float64()
+	if math.IsInf(h, 0) || sat < 0 || sat > 1 || l < 0 || l > 1 {
+		return color.Color{}, false
+	}
+	return color.HSL(h, sat, l), true
+}
+
+// constructorCall returns the call of the rgb() or hsl() function name that
+// gives c. hsl() components are rounded to the fewest decimals that still
+// give c.
+func constructorCall(name string, c color.Color) string {
+	if name == "rgb" {
+		return fmt.Sprintf("rgb(%d, %d, %d)", c.R, c.G, c.B)
+	}
+	h, s, l := color.HSLOf(c)
+	round := func(x float64, decimals int) float64 {
+		p := math.Pow(10, float64(decimals))
+		return math.Round(x*p) / p
+	}
+	decimals := 2
+	for ; decimals < 6; decimals++ {
+		if color.HSL(round(h, decimals-2), round(s, decimals), round(l, decimals)) == c {
+			break
+		}
+	}
+	return fmt.Sprintf("hsl(%s, %s, %s)", formatFloat(round(h, decimals-2)), formatFloat(round(s, decimals)), formatFloat(round(l, decimals)))
+}
+
+// formatFloat formats f for HCL with as few digits as needed.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// metaEdits returns the edits setting the appearance in the meta block,
+// adding the block if there is none.
+func metaEdits(meta *hclsyntax.Block, appearance string) ([]edit, error) {
+	line := "appearance = " + strconv.Quote(appearance) + "\n"
+	if meta == nil {
+		return []edit{{0, 0, "meta {\n" + line + "}\n\n"}}, nil
+	}
+
+	var edits []edit
+	from := ""
+	if attr, ok := meta.Body.Attributes["appearance"]; ok {
+		from = stringValue(attr.Expr)
+		if from == appearance {
+			return nil, fmt.Errorf("theme is already %s", appearance)
+		}
+		rng := attr.Expr.Range()
+		edits = append(edits, edit{rng.Start.Byte, rng.End.Byte, strconv.Quote(appearance)})
+	} else {
+		at := meta.CloseBraceRange.Start.Byte
+		edits = append(edits, edit{at, at, line})
+	}
+
+	if attr, ok := meta.Body.Attributes["name"]; ok {
+		if name := stringValue(attr.Expr); name != "" {
+			name = strings.TrimSuffix(name, " "+title(from))
+			rng := attr.Expr.Range()
+			edits = append(edits, edit{rng.Start.Byte, rng.End.Byte, strconv.Quote(name + " " + title(appearance))})
+		}
+	}
+	return edits, nil
+}
+
+// stringValue returns the value of a string literal, or "" for any other
+// expression.
+func stringValue(expr hclsyntax.Expression) string {
+	t, ok := expr.(*hclsyntax.TemplateExpr)
+	if !ok || !t.IsStringLiteral() {
+		return ""
+	}
+	val, diags := t.Value(nil)
+	if diags.HasErrors() || val.IsNull() || val.Type() != cty.String {
+		return ""
+	}
+	return val.AsString()
+}
+
+// title capitalizes the first letter of an appearance name.
+func title(s string) string {
+	if s == "" {
+		return ""
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// applyEdits applies non-overlapping edits to src.
+func applyEdits(src []byte, edits []edit) []byte {
+	slices.SortFunc(edits, func(a, b edit) int { return a.start - b.start })
+	var out []byte
+	pos := 0
+	for _, e := range edits {
+		out = append(out, src[pos:e.start]...)
+		out = append(out, e.text...)
+		pos = e.end
+	}
+	return append(out, src[pos:]...)
+}
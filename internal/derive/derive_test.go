@@ -0,0 +1,242 @@
+package derive
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jsvensson/paletteswap/internal/color"
+)
+
+const darkTheme = `meta {
+  name       = "Rosé Dark"
+  appearance = "dark"
+}
+
+# Base colors
+palette {
+  base    = "#191724"
+  text    = "#e0def4"
+  love    = [235, 111, 146]
+  surface = brighten(palette.base, 0.1)
+  overlay = brighten(palette.base, -0.2)
+}
+
+theme {
+  background = palette.base
+  foreground = palette.text
+  selection  = darken(palette.text, 0.2)
+  border     = shade(palette.text, 0.3)
+  muted      = lighten_ok(palette.text, -0.15)
+  accent     = darken_ok(palette.love, 0.1)
+}
+`
+
+func TestDerive(t *testing.T) {
+	out, err := Derive("dark.pstheme", []byte(darkTheme), "light")
+	if err != nil {
+		t.Fatalf("Derive() error: %v", err)
+	}
+	got := string(out)
+
+	invert := func(hex string) string {
+		c, err := color.ParseHex(hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return color.InvertLightness(c).Hex()
+	}
+	love := color.InvertLightness(color.Color{R: 235, G: 111, B: 146})
+
+	for _, want := range []string{
+		`name       = "Rosé Light"`,
+		`appearance = "light"`,
+		`# Base colors`,
+		`base    = "` + invert("#191724") + `"`,
+		`text    = "` + invert("#e0def4") + `"`,
+		fmt.Sprintf("love    = [%d, %d, %d]", love.R, love.G, love.B),
+		`surface = darken(palette.base, 0.1)`,
+		`overlay = brighten(palette.base, 0.2)`,
+		`background = palette.base`,
+		`selection  = brighten(palette.text, 0.2)`,
+		`border     = tint(palette.text, 0.3)`,
+		`muted      = lighten_ok(palette.text, 0.15)`,
+		`accent     = lighten_ok(palette.love, 0.1)`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestDerive_SwapsBackgroundAndForegroundLightness(t *testing.T) {
+	out, err := Derive("dark.pstheme", []byte(darkTheme), "light")
+	if err != nil {
+		t.Fatalf("Derive() error: %v", err)
+	}
+	base := color.InvertLightness(color.Color{R: 0x19, G: 0x17, B: 0x24})
+	text := color.InvertLightness(color.Color{R: 0xe0, G: 0xde, B: 0xf4})
+	if base.OKLCH().L <= text.OKLCH().L {
+		t.Errorf("background %s is not lighter than foreground %s", base.Hex(), text.Hex())
+	}
+	if !strings.Contains(string(out), base.Hex()) {
+		t.Errorf("output missing background %s:\n%s", base.Hex(), out)
+	}
+}
+
+func TestDerive_Meta(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "no meta block",
+			src:  "palette {\n  base = \"#000000\"\n}\n",
+			want: []string{"meta {\n  appearance = \"dark\"\n}\n\npalette {\n  base = \"#ffffff\"\n}\n"},
+		},
+		{
+			name: "meta without appearance",
+			src:  "meta {\n  name = \"Paper\"\n}\n",
+			want: []string{"meta {\n  name       = \"Paper Dark\"\n  appearance = \"dark\"\n}\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Derive("test.pstheme", []byte(tt.src), "dark")
+			if err != nil {
+				t.Fatalf("Derive() error: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(out), want) {
+					t.Errorf("output missing %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestDerive_Gray(t *testing.T) {
+	src := `locals {
+  l = 0.25
+}
+
+palette {
+  base    = gray(0.2)
+  surface = gray(0.7)
+  overlay = gray(local.l)
+  muted   = gray(local.l * 2)
+}
+`
+	out, err := Derive("test.pstheme", []byte(src), "light")
+	if err != nil {
+		t.Fatalf("Derive() error: %v", err)
+	}
+	for _, want := range []string{
+		`base    = gray(0.8)`,
+		`surface = gray(0.3)`,
+		`overlay = gray(1 - local.l)`,
+		`muted   = gray(1 - (local.l * 2))`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestDerive_Constructors(t *testing.T) {
+	src := `palette {
+  love = rgb(235, 111, 146)
+  rose = hsl(343, 0.76, 0.68)
+  iris = rgb(local.red, 0, 0)
+}
+`
+	out, err := Derive("test.pstheme", []byte(src), "light")
+	if err != nil {
+		t.Fatalf("Derive() error: %v", err)
+	}
+	got := string(out)
+
+	love := color.InvertLightness(color.Color{R: 235, G: 111, B: 146})
+	if want := fmt.Sprintf("love = rgb(%d, %d, %d)", love.R, love.G, love.B); !strings.Contains(got, want) {
+		t.Errorf("output missing %q:\n%s", want, got)
+	}
+	if want := "iris = rgb(local.red, 0, 0)"; !strings.Contains(got, want) {
+		t.Errorf("output missing %q:\n%s", want, got)
+	}
+
+	var h, sat, l float64
+	i := strings.Index(got, "rose = hsl(")
+	if i < 0 {
+		t.Fatalf("output missing rose = hsl(...):\n%s", got)
+	}
+	if _, err := fmt.Sscanf(got[i:], "rose = hsl(%g, %g, %g)", &h, &sat, &l); err != nil {
+		t.Fatalf("parsing rose: %v\n%s", err, got)
+	}
+	want := color.InvertLightness(color.HSL(343, 0.76, 0.68))
+	if rose := color.HSL(h, sat, l); rose != want {
+		t.Errorf("rose = hsl(%g, %g, %g) = %s, want %s", h, sat, l, rose.Hex(), want.Hex())
+	}
+}
+
+func TestDerive_When(t *testing.T) {
+	src := `meta {
+  appearance = "dark"
+}
+
+palette {
+  base = "#191724"
+}
+
+theme {
+  when       = "light"
+  background = "#faf4ed"
+  selection  = darken(palette.base, 0.2)
+}
+
+syntax {
+  keyword = "#31748f"
+  markup {
+    when    = "dark"
+    heading = "#ebbcba"
+  }
+}
+`
+	out, err := Derive("test.pstheme", []byte(src), "light")
+	if err != nil {
+		t.Fatalf("Derive() error: %v", err)
+	}
+	keyword, _ := color.ParseHex("#31748f")
+	for _, want := range []string{
+		`base = "` + color.InvertLightness(color.Color{R: 0x19, G: 0x17, B: 0x24}).Hex() + `"`,
+		`background = "#faf4ed"`,
+		`selection  = darken(palette.base, 0.2)`,
+		`keyword = "` + color.InvertLightness(keyword).Hex() + `"`,
+		`heading = "#ebbcba"`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestDerive_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		src        string
+		appearance string
+		wantErr    string
+	}{
+		{"same appearance", darkTheme, "dark", "theme is already dark"},
+		{"unknown appearance", darkTheme, "dim", `unknown appearance "dim"`},
+		{"syntax error", "palette {", "light", "parsing test.pstheme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Derive("test.pstheme", []byte(tt.src), tt.appearance)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Derive() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
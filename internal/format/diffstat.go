@@ -0,0 +1,63 @@
+package format
+
+import "strings"
+
+// DiffStat returns the number of lines added and removed by changing before
+// into after, counted over a shortest line diff as in git diff --stat. A
+// line whose line ending changed counts as removed and added.
+func DiffStat(before, after string) (added, removed int) {
+	a, b := splitLines(before), splitLines(after)
+
+	// Lines kept at either end are not part of any edit.
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	d := editDistance(a, b)
+	// d = added + removed, and added - removed = len(b) - len(a).
+	added = (d + len(b) - len(a)) / 2
+	return added, d - added
+}
+
+// splitLines splits s into lines that keep their line endings.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// editDistance returns the least number of lines to insert into or delete
+// from a to get b, using Myers' O(ND) algorithm.
+func editDistance(a, b []string) int {
+	n, m := len(a), len(b)
+	total := n + m
+	if total == 0 {
+		return 0
+	}
+	// v[k+total] is the furthest x reached on diagonal k = x - y.
+	v := make([]int, 2*total+2)
+	for d := 0; d <= total; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+total] < v[k+1+total]) {
+				x = v[k+1+total] // insertion: move down from diagonal k+1
+			} else {
+				x = v[k-1+total] + 1 // deletion: move right from diagonal k-1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[k+total] = x
+			if x >= n && y >= m {
+				return d
+			}
+		}
+	}
+	return total
+}
@@ -0,0 +1,31 @@
+package format
+
+import "testing"
+
+func TestDiffStat(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		added         int
+		removed       int
+	}{
+		{"unchanged", "a\nb\n", "a\nb\n", 0, 0},
+		{"empty", "", "", 0, 0},
+		{"changed line", "a\nb\nc\n", "a\nB\nc\n", 1, 1},
+		{"inserted", "a\nc\n", "a\nb\nc\n", 1, 0},
+		{"deleted", "a\nb\n\nc\n", "a\nb\nc\n", 0, 1},
+		{"from empty", "", "a\nb\n", 2, 0},
+		{"missing final newline", "a\nb", "a\nb\n", 1, 1},
+		{"line endings", "a\r\nb\r\n", "a\nb\n", 2, 2},
+		{"realigned", "x = 1\nlong = 2\ny = 3\n", "x    = 1\nlong = 2\ny    = 3\n", 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := DiffStat(tt.before, tt.after)
+			if added != tt.added || removed != tt.removed {
+				t.Errorf("DiffStat() = +%d -%d, want +%d -%d", added, removed, tt.added, tt.removed)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package format
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EditorConfigIndent returns the indent size the .editorconfig files
+// governing file set for it, or 0 if they set none. Files are read from the
+// file's directory upwards until one declares root = true; closer files and
+// later sections take precedence. indent_size = tab uses tab_width.
+//
+// Section globs support *, ?, [...] and {a,b}; ** matches like *.
+func EditorConfigIndent(file string) (int, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return 0, err
+	}
+
+	var props []map[string]string // closest .editorconfig first
+	for dir := filepath.Dir(abs); ; {
+		name := filepath.Join(dir, ".editorconfig")
+		p, root, err := readEditorConfig(name, abs)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return 0, fmt.Errorf("reading %s: %w", name, err)
+		}
+		props = append(props, p)
+		parent := filepath.Dir(dir)
+		if root || parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	merged := make(map[string]string)
+	for i := len(props) - 1; i >= 0; i-- {
+		for k, v := range props[i] {
+			merged[k] = v
+		}
+	}
+
+	size := merged["indent_size"]
+	if size == "tab" {
+		size = merged["tab_width"]
+	}
+	if size == "" || size == "unset" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(size)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid .editorconfig indent_size %q", size)
+	}
+	return n, nil
+}
+
+// readEditorConfig returns the properties the .editorconfig at name sets for
+// the file at abs, and whether it declares root = true.
+func readEditorConfig(name, abs string) (map[string]string, bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	rel, err := filepath.Rel(filepath.Dir(name), abs)
+	if err != nil {
+		return nil, false, err
+	}
+	rel = filepath.ToSlash(rel)
+
+	props := make(map[string]string)
+	root := false
+	inPreamble, matched := true, false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inPreamble = false
+			matched = editorConfigMatch(line[1:len(line)-1], rel)
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.ToLower(strings.TrimSpace(value))
+		switch {
+		case inPreamble && key == "root":
+			root = value == "true"
+		case matched:
+			props[key] = value
+		}
+	}
+	return props, root, scanner.Err()
+}
+
+// editorConfigMatch reports whether the section glob matches rel, the
+// slash-separated path of the file relative to the .editorconfig. A glob
+// without a slash matches the file name in any directory.
+func editorConfigMatch(glob, rel string) bool {
+	target := rel
+	if !strings.Contains(glob, "/") {
+		target = path.Base(rel)
+	} else {
+		glob = strings.TrimPrefix(glob, "/")
+	}
+	for _, g := range expandBraces(glob) {
+		g = strings.ReplaceAll(g, "**", "*")
+		if ok, _ := path.Match(g, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// expandBraces expands the first {a,b,...} group in glob, recursively, into
+// one glob per alternative.
+func expandBraces(glob string) []string {
+	open := strings.IndexByte(glob, '{')
+	if open < 0 {
+		return []string{glob}
+	}
+	end := strings.IndexByte(glob[open:], '}')
+	if end < 0 {
+		return []string{glob}
+	}
+	end += open
+	var out []string
+	for _, alt := range strings.Split(glob[open+1:end], ",") {
+		out = append(out, expandBraces(glob[:open]+alt+glob[end+1:])...)
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEditorConfigIndent(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".editorconfig", `root = true
+
+[*]
+indent_size = 8
+
+[*.{pstheme,hcl}]
+indent_size = 4
+
+[legacy/*.pstheme]
+indent_size = tab
+tab_width = 3
+`)
+	write("nested/.editorconfig", "[*.pstheme]\nindent_size = 2\n")
+	write("other/.editorconfig", "[*.pstheme]\nindent_size = unset\n")
+
+	tests := []struct {
+		file string
+		want int
+	}{
+		{"theme.pstheme", 4},
+		{"theme.hcl", 4},
+		{"README.md", 8},
+		{"legacy/old.pstheme", 3},
+		{"nested/theme.pstheme", 2},
+		{"other/theme.pstheme", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := EditorConfigIndent(filepath.Join(dir, tt.file))
+			if err != nil {
+				t.Fatalf("EditorConfigIndent() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("EditorConfigIndent() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditorConfigIndent_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".editorconfig"), []byte("root = true\n[*]\nindent_size = wide\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EditorConfigIndent(filepath.Join(dir, "theme.pstheme")); err == nil {
+		t.Error("EditorConfigIndent() succeeded, want an error for indent_size = wide")
+	}
+}
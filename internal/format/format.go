@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/jsvensson/paletteswap/internal/theme"
 )
@@ -15,21 +16,76 @@ var blankLineBeforeCloseBrace = regexp.MustCompile(`\n\s*\n(\s*\})`)
 
 // Format takes HCL source content and returns it formatted according to
 // HCL canonical style rules. It uses hclwrite.Format which handles
-// indentation, spacing, and newline normalization.
+// indentation, spacing, and newline normalization. Line endings follow the
+// input, see FormatWithOptions.
 //
 // The formatter works even on partial/invalid HCL, making it suitable
 // for use while the user is still typing.
 func Format(content string) (string, error) {
-	formatted := hclwrite.Format([]byte(content))
+	return FormatWithOptions(content, Options{})
+}
+
+// DefaultIndentSize is the number of spaces per indentation level when
+// Options.IndentSize is zero.
+const DefaultIndentSize = 2
+
+// Options configures FormatWithOptions. The zero value formats like Format.
+type Options struct {
+	// LineEnding selects the output line endings. The zero value preserves
+	// the style of the input's first line break for the whole file.
+	LineEnding LineEnding
+	// IndentSize is the number of spaces per indentation level. Zero means
+	// DefaultIndentSize.
+	IndentSize int
+	// NoAlign puts a single space before each attribute's '=' instead of
+	// aligning the '=' signs of consecutive attributes.
+	NoAlign bool
+	// HexCase selects the case of hex color strings. The zero value leaves
+	// them as written.
+	HexCase HexCase
+	// KeepANSIOrder leaves the attributes of the ansi block in the order
+	// they are written instead of sorting them into the canonical order.
+	KeepANSIOrder bool
+}
+
+// FormatWithOptions is like Format but applies opts.
+func FormatWithOptions(content string, opts Options) (string, error) {
+	le := opts.LineEnding
+	if le == LineEndingPreserve {
+		le = DetectLineEnding(content)
+	}
+	// The rules below match "\n" only, so format with LF line endings.
+	formatted := hclwrite.Format([]byte(ConvertLineEndings(content, LineEndingLF)))
 	// Reorder ANSI block attributes to canonical order.
-	formatted = reorderANSIBlock(formatted)
+	if !opts.KeepANSIOrder {
+		formatted = reorderANSIBlock(formatted)
+	}
 	// Collapse multiple consecutive blank lines into a single blank line.
 	collapsed := multipleBlankLines.ReplaceAllString(string(formatted), "\n\n")
 	// Remove blank lines immediately after opening braces.
 	collapsed = blankLineAfterOpenBrace.ReplaceAllString(collapsed, "{\n")
 	// Remove blank lines immediately before closing braces.
 	collapsed = blankLineBeforeCloseBrace.ReplaceAllString(collapsed, "\n${1}")
-	return collapsed, nil
+	collapsed = applyHexCase(collapsed, opts.HexCase)
+	if opts.NoAlign {
+		collapsed = unalignAttributes(collapsed)
+	}
+	if opts.IndentSize > 0 && opts.IndentSize != DefaultIndentSize {
+		collapsed = reindent(collapsed, opts.IndentSize)
+	}
+	return ConvertLineEndings(collapsed, le), nil
+}
+
+// Validate reports whether content is syntactically valid HCL. Format itself
+// tolerates partial input, so callers that must not rewrite a broken file
+// (such as editor format-on-save) check Validate first. The filename is only
+// used in diagnostic messages.
+func Validate(filename, content string) error {
+	_, diags := hclsyntax.ParseConfig([]byte(content), filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return diags
+	}
+	return nil
 }
 
 // ansiBlockPattern matches the "ansi {" opening and captures everything
@@ -38,7 +94,7 @@ var ansiBlockPattern = regexp.MustCompile(`(?s)(ansi\s*\{)\n(.*?)\n(\})`)
 
 // reorderANSIBlock finds the "ansi" block in the formatted HCL source and
 // reorders its attributes to match the canonical order defined in
-// theme.RequiredANSIColors. Comments and blank lines immediately preceding
+// theme.ANSIColorNames. Comments and blank lines immediately preceding
 // an attribute travel with that attribute.
 func reorderANSIBlock(src []byte) []byte {
 	// First verify this is valid HCL with an ansi block using the parser.
@@ -69,7 +125,7 @@ func reorderANSIBlock(src []byte) []byte {
 		closer := match[loc[6]:loc[7]]
 
 		lines := strings.Split(inner, "\n")
-		reordered := reorderEntries(lines, theme.RequiredANSIColors)
+		reordered := reorderEntries(lines, theme.ANSIColorNames)
 		newInner := strings.Join(reordered, "\n")
 
 		var result []byte
@@ -351,3 +351,179 @@ func TestFormatInvalidHCL(t *testing.T) {
 		t.Errorf("Format() on incomplete HCL should not error, got: %v", err)
 	}
 }
+
+func TestFormatLineEndings(t *testing.T) {
+	crlf := "palette {\r\n\r\n\r\n  a = \"#000000\"\r\n   bb= \"#111111\"\r\n}\r\n"
+	lf := "palette {\n  a  = \"#000000\"\n  bb = \"#111111\"\n}\n"
+	wantCRLF := strings.ReplaceAll(lf, "\n", "\r\n")
+
+	tests := []struct {
+		name  string
+		input string
+		opts  Options
+		want  string
+	}{
+		{"preserve CRLF", crlf, Options{}, wantCRLF},
+		{"preserve LF", lf, Options{}, lf},
+		{"CRLF to LF", crlf, Options{LineEnding: LineEndingLF}, lf},
+		{"LF to CRLF", lf, Options{LineEnding: LineEndingCRLF}, wantCRLF},
+		{"mixed follows first line", strings.Replace(crlf, "\r\n", "\n", 2), Options{}, lf},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FormatWithOptions(tt.input, tt.opts)
+			if err != nil {
+				t.Fatalf("FormatWithOptions() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FormatWithOptions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatStyleOptions(t *testing.T) {
+	input := `palette {
+  base = "#191724"
+  love_pink = "#EB6F92"
+  # "#ABCDEF" stays in comments
+  block {
+    nested = "#AbC"
+  }
+  doc = <<EOT
+  kept = "#ABCDEF"
+EOT
+}
+
+ansi {
+  red   = "#eb6f92"
+  black = "#000000"
+}
+`
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"indent size", Options{IndentSize: 4}, `palette {
+    base      = "#191724"
+    love_pink = "#EB6F92"
+    # "#ABCDEF" stays in comments
+    block {
+        nested = "#AbC"
+    }
+    doc = <<EOT
+  kept = "#ABCDEF"
+EOT
+}
+
+ansi {
+    black = "#000000"
+    red   = "#eb6f92"
+}
+`},
+		{"no align", Options{NoAlign: true}, `palette {
+  base = "#191724"
+  love_pink = "#EB6F92"
+  # "#ABCDEF" stays in comments
+  block {
+    nested = "#AbC"
+  }
+  doc = <<EOT
+  kept = "#ABCDEF"
+EOT
+}
+
+ansi {
+  black = "#000000"
+  red = "#eb6f92"
+}
+`},
+		{"lower hex", Options{HexCase: HexCaseLower}, `palette {
+  base      = "#191724"
+  love_pink = "#eb6f92"
+  # "#ABCDEF" stays in comments
+  block {
+    nested = "#abc"
+  }
+  doc = <<EOT
+  kept = "#ABCDEF"
+EOT
+}
+
+ansi {
+  black = "#000000"
+  red   = "#eb6f92"
+}
+`},
+		{"upper hex, ANSI order kept", Options{HexCase: HexCaseUpper, KeepANSIOrder: true}, `palette {
+  base      = "#191724"
+  love_pink = "#EB6F92"
+  # "#ABCDEF" stays in comments
+  block {
+    nested = "#ABC"
+  }
+  doc = <<EOT
+  kept = "#ABCDEF"
+EOT
+}
+
+ansi {
+  red   = "#EB6F92"
+  black = "#000000"
+}
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FormatWithOptions(input, tt.opts)
+			if err != nil {
+				t.Fatalf("FormatWithOptions() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FormatWithOptions() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHexCase(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    HexCase
+		wantErr bool
+	}{
+		{"", HexCasePreserve, false},
+		{"preserve", HexCasePreserve, false},
+		{"Lower", HexCaseLower, false},
+		{"upper", HexCaseUpper, false},
+		{"title", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseHexCase(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseHexCase(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseHexCase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate("theme.pstheme", "meta {\n  name = \"Test\"\n}\n"); err != nil {
+		t.Errorf("Validate() on valid HCL returned error: %v", err)
+	}
+
+	err := Validate("theme.pstheme", `meta { name = "Test"`)
+	if err == nil {
+		t.Fatal("Validate() on incomplete HCL should error")
+	}
+	if !strings.Contains(err.Error(), "theme.pstheme") {
+		t.Errorf("error should mention the filename, got: %v", err)
+	}
+}
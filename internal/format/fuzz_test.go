@@ -0,0 +1,41 @@
+package format
+
+import "testing"
+
+var fuzzSeeds = []string{
+	`meta { name = "Test" }`,
+	"palette {\n  base = \"#191724\"\n  highlight {\n    low = \"#21202e\"\n  }\n}\n",
+	"ansi {\n  red = \"#ff0000\"\n  # comment\n  black = \"#000000\"\n}\n",
+	"ansi {\n}\n",
+	"ansi {}",
+	`meta { name = "Test"`,
+}
+
+func FuzzFormat(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, content string) {
+		formatted, err := Format(content)
+		if err != nil {
+			return
+		}
+		// Valid input must stay valid after formatting.
+		if Validate("fuzz.pstheme", content) == nil {
+			if err := Validate("fuzz.pstheme", formatted); err != nil {
+				t.Errorf("Format() turned valid input into invalid output: %v\ninput:\n%s\noutput:\n%s", err, content, formatted)
+			}
+		}
+	})
+}
+
+func FuzzReorderANSIBlock(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, content string) {
+		reorderANSIBlock([]byte(content))
+	})
+}
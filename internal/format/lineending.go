@@ -0,0 +1,68 @@
+package format
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// LineEnding selects the line endings written by Format and the Engine.
+type LineEnding string
+
+const (
+	// LineEndingPreserve keeps the line endings of the input: for Format the
+	// style of the first line break, for generated output whatever the
+	// template itself uses.
+	LineEndingPreserve LineEnding = ""
+	LineEndingLF       LineEnding = "lf"
+	LineEndingCRLF     LineEnding = "crlf"
+	// LineEndingNative is CRLF on Windows and LF everywhere else.
+	LineEndingNative LineEnding = "native"
+)
+
+// ParseLineEnding parses a --line-endings flag value. "preserve" and the
+// empty string both mean LineEndingPreserve.
+func ParseLineEnding(s string) (LineEnding, error) {
+	switch le := LineEnding(strings.ToLower(s)); le {
+	case LineEndingPreserve, "preserve":
+		return LineEndingPreserve, nil
+	case LineEndingLF, LineEndingCRLF, LineEndingNative:
+		return le, nil
+	default:
+		return "", fmt.Errorf("unknown line ending %q (valid: preserve, lf, crlf, native)", s)
+	}
+}
+
+// DetectLineEnding returns LineEndingCRLF if the first line break in s is
+// "\r\n", and LineEndingLF otherwise.
+func DetectLineEnding(s string) LineEnding {
+	if i := strings.IndexByte(s, '\n'); i > 0 && s[i-1] == '\r' {
+		return LineEndingCRLF
+	}
+	return LineEndingLF
+}
+
+// ConvertLineEndings rewrites every line break in s to le. Mixed input is
+// made consistent. LineEndingPreserve returns s unchanged.
+func ConvertLineEndings(s string, le LineEnding) string {
+	switch resolveNative(le, runtime.GOOS) {
+	case LineEndingLF:
+		return strings.ReplaceAll(s, "\r\n", "\n")
+	case LineEndingCRLF:
+		return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+	default:
+		return s
+	}
+}
+
+// resolveNative returns the concrete line ending LineEndingNative stands for
+// on goos, and any other value unchanged.
+func resolveNative(le LineEnding, goos string) LineEnding {
+	if le != LineEndingNative {
+		return le
+	}
+	if goos == "windows" {
+		return LineEndingCRLF
+	}
+	return LineEndingLF
+}
@@ -0,0 +1,87 @@
+package format
+
+import "testing"
+
+func TestParseLineEnding(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    LineEnding
+		wantErr bool
+	}{
+		{"", LineEndingPreserve, false},
+		{"preserve", LineEndingPreserve, false},
+		{"LF", LineEndingLF, false},
+		{"crlf", LineEndingCRLF, false},
+		{"native", LineEndingNative, false},
+		{"cr", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLineEnding(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLineEnding(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLineEnding(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectLineEnding(t *testing.T) {
+	tests := []struct {
+		in   string
+		want LineEnding
+	}{
+		{"", LineEndingLF},
+		{"a\nb\r\n", LineEndingLF},
+		{"a\r\nb\n", LineEndingCRLF},
+		{"\n", LineEndingLF},
+	}
+
+	for _, tt := range tests {
+		if got := DetectLineEnding(tt.in); got != tt.want {
+			t.Errorf("DetectLineEnding(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestConvertLineEndings(t *testing.T) {
+	mixed := "a\r\nb\nc"
+	tests := []struct {
+		le   LineEnding
+		want string
+	}{
+		{LineEndingPreserve, mixed},
+		{LineEndingLF, "a\nb\nc"},
+		{LineEndingCRLF, "a\r\nb\r\nc"},
+	}
+
+	for _, tt := range tests {
+		if got := ConvertLineEndings(mixed, tt.le); got != tt.want {
+			t.Errorf("ConvertLineEndings(%q, %q) = %q, want %q", mixed, tt.le, got, tt.want)
+		}
+	}
+}
+
+// TestResolveNative covers Windows behavior on any CI platform.
+func TestResolveNative(t *testing.T) {
+	tests := []struct {
+		le   LineEnding
+		goos string
+		want LineEnding
+	}{
+		{LineEndingNative, "windows", LineEndingCRLF},
+		{LineEndingNative, "linux", LineEndingLF},
+		{LineEndingNative, "darwin", LineEndingLF},
+		{LineEndingLF, "windows", LineEndingLF},
+		{LineEndingPreserve, "windows", LineEndingPreserve},
+	}
+
+	for _, tt := range tests {
+		if got := resolveNative(tt.le, tt.goos); got != tt.want {
+			t.Errorf("resolveNative(%q, %q) = %q, want %q", tt.le, tt.goos, got, tt.want)
+		}
+	}
+}
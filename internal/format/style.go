@@ -0,0 +1,112 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// HexCase selects the case FormatWithOptions writes hex color strings in.
+type HexCase string
+
+const (
+	// HexCasePreserve leaves hex color strings as written.
+	HexCasePreserve HexCase = ""
+	HexCaseLower    HexCase = "lower"
+	HexCaseUpper    HexCase = "upper"
+)
+
+// ParseHexCase parses a --hex-case flag value. "preserve" and the empty
+// string both mean HexCasePreserve.
+func ParseHexCase(s string) (HexCase, error) {
+	switch hc := HexCase(strings.ToLower(s)); hc {
+	case HexCasePreserve, "preserve":
+		return HexCasePreserve, nil
+	case HexCaseLower, HexCaseUpper:
+		return hc, nil
+	default:
+		return "", fmt.Errorf("unknown hex case %q (valid: preserve, lower, upper)", s)
+	}
+}
+
+// hexColor matches the content of a string holding only a hex color.
+var hexColor = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// applyHexCase rewrites every quoted string holding a hex color, such as
+// "#EB6F92", to hc. Comments and heredocs are left alone.
+func applyHexCase(src string, hc HexCase) string {
+	if hc == HexCasePreserve {
+		return src
+	}
+	tokens, _ := hclsyntax.LexConfig([]byte(src), "", hcl.InitialPos)
+	out := []byte(src)
+	for i, tok := range tokens {
+		if tok.Type != hclsyntax.TokenQuotedLit || i == 0 || tokens[i-1].Type != hclsyntax.TokenOQuote ||
+			i+1 == len(tokens) || tokens[i+1].Type != hclsyntax.TokenCQuote || !hexColor.Match(tok.Bytes) {
+			continue
+		}
+		s := string(tok.Bytes)
+		if hc == HexCaseUpper {
+			s = strings.ToUpper(s)
+		} else {
+			s = strings.ToLower(s)
+		}
+		// Changing the case keeps the length, so byte offsets stay valid.
+		copy(out[tok.Range.Start.Byte:], s)
+	}
+	return string(out)
+}
+
+// alignedEquals matches the padding hclwrite inserts before the '=' of an
+// attribute to align it with its neighbors.
+var alignedEquals = regexp.MustCompile(`^(\s*[\p{L}_][\p{L}\p{N}_-]*)  +=`)
+
+// unalignAttributes reduces the padding before each attribute's '=' to a
+// single space. Heredoc content is left alone.
+func unalignAttributes(src string) string {
+	lines := strings.Split(src, "\n")
+	skip := heredocLines(src)
+	for i, line := range lines {
+		if !skip[i+1] {
+			lines[i] = alignedEquals.ReplaceAllString(line, "$1 =")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reindent rewrites the two-space indentation hclwrite produces to size
+// spaces per level. Heredoc content is left alone.
+func reindent(src string, size int) string {
+	lines := strings.Split(src, "\n")
+	skip := heredocLines(src)
+	for i, line := range lines {
+		if skip[i+1] {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " "))
+		lines[i] = strings.Repeat(" ", n/DefaultIndentSize*size+n%DefaultIndentSize) + line[n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// heredocLines returns the 1-based numbers of the lines inside heredocs,
+// including the closing marker, whose leading whitespace is significant.
+func heredocLines(src string) map[int]bool {
+	tokens, _ := hclsyntax.LexConfig([]byte(src), "", hcl.InitialPos)
+	lines := make(map[int]bool)
+	start := 0
+	for _, tok := range tokens {
+		switch tok.Type {
+		case hclsyntax.TokenOHeredoc:
+			start = tok.Range.End.Line
+		case hclsyntax.TokenCHeredoc:
+			for l := start; l <= tok.Range.Start.Line; l++ {
+				lines[l] = true
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,202 @@
+// Package graph builds the reference graph of a theme file: which palette
+// colors and locals feed which theme, syntax, ansi and semantic entries.
+package graph
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/parser"
+	"github.com/jsvensson/paletteswap/internal/theme"
+)
+
+// Edge is a reference from one theme entry to another. Func names the
+// innermost function the reference is passed through, e.g. "brighten", or
+// "scale" for a scale block endpoint. It is empty for a direct reference.
+type Edge struct {
+	From string
+	To   string
+	Func string
+}
+
+// Graph holds the entries of a theme file in source order and the references
+// between them. Entries are named by their reference path, such as
+// "palette.highlight.low", "local.accent" or "syntax.comment"; labeled syntax
+// blocks use "syntax[go].keyword".
+type Graph struct {
+	Nodes []string
+	Edges []Edge
+}
+
+// Build parses a theme file and returns its reference graph. The file only
+// needs to be syntactically valid; references are not resolved.
+func Build(filename string, src []byte) (*Graph, error) {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parsing %s: %s", filename, diags.Error())
+	}
+
+	g := &Graph{}
+	seen := make(map[string]bool)
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		prefix := block.Type
+		switch block.Type {
+		case "meta":
+			continue
+		case "locals":
+			prefix = "local"
+		case "syntax":
+			if len(block.Labels) > 0 {
+				prefix = fmt.Sprintf("syntax[%s]", block.Labels[0])
+			}
+		}
+		g.addBody(block.Body, prefix, block.Type == "palette", seen)
+	}
+	return g, nil
+}
+
+// addBody adds the attributes and nested blocks of body under prefix. A
+// "color" attribute is the entry of its enclosing block itself, and a
+// "when" attribute is no entry.
+func (g *Graph) addBody(body *hclsyntax.Body, prefix string, inPalette bool, seen map[string]bool) {
+	for _, attr := range sortedAttributes(body) {
+		if attr.Name == theme.WhenAttr {
+			continue
+		}
+		name := prefix + "." + attr.Name
+		if attr.Name == color.ColorKey {
+			name = prefix
+		}
+		g.addNode(name, seen)
+		g.addReferences(attr.Expr, name, "", seen)
+	}
+	for _, block := range body.Blocks {
+		switch {
+		case block.Type == "transform":
+			continue
+		case inPalette && parser.IsScaleBlock(block):
+			name := prefix + "." + block.Labels[0]
+			g.addNode(name, seen)
+			for _, attr := range sortedAttributes(block.Body) {
+				if attr.Name == "from" || attr.Name == "to" {
+					g.addReferences(attr.Expr, name, "scale", seen)
+				}
+			}
+		default:
+			g.addBody(block.Body, prefix+"."+block.Type, inPalette, seen)
+		}
+	}
+}
+
+func (g *Graph) addNode(name string, seen map[string]bool) {
+	if !seen[name] {
+		seen[name] = true
+		g.Nodes = append(g.Nodes, name)
+	}
+}
+
+// addReferences adds an edge to target for every reference in expr. Edges
+// are labeled with the innermost enclosing function, or with via if the
+// reference is not inside a function call. References are taken as
+// written: a bare name such as `base` is not a palette entry, even inside
+// the palette, because the loader rejects it as an unknown variable.
+func (g *Graph) addReferences(expr hclsyntax.Expression, target, via string, seen map[string]bool) {
+	funcs := []string{via}
+	_ = hclsyntax.Walk(expr, walker{
+		enter: func(node hclsyntax.Node) {
+			switch n := node.(type) {
+			case *hclsyntax.FunctionCallExpr:
+				funcs = append(funcs, n.Name)
+			case *hclsyntax.ScopeTraversalExpr:
+				from := traversalPath(n.Traversal)
+				g.addNode(from, seen)
+				g.Edges = append(g.Edges, Edge{From: from, To: target, Func: funcs[len(funcs)-1]})
+			}
+		},
+		exit: func(node hclsyntax.Node) {
+			if _, ok := node.(*hclsyntax.FunctionCallExpr); ok {
+				funcs = funcs[:len(funcs)-1]
+			}
+		},
+	})
+}
+
+// traversalPath joins the attribute steps of a traversal with dots. A
+// trailing color key is dropped, since it names its enclosing entry.
+func traversalPath(t hcl.Traversal) string {
+	parts := []string{t.RootName()}
+	for _, step := range t[1:] {
+		if attr, ok := step.(hcl.TraverseAttr); ok {
+			parts = append(parts, attr.Name)
+		}
+	}
+	if len(parts) > 1 && parts[len(parts)-1] == color.ColorKey {
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, ".")
+}
+
+// sortedAttributes returns the attributes of body in source order.
+func sortedAttributes(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte
+	})
+	return attrs
+}
+
+// walker adapts a pair of funcs to hclsyntax.Walker.
+type walker struct {
+	enter func(hclsyntax.Node)
+	exit  func(hclsyntax.Node)
+}
+
+func (w walker) Enter(node hclsyntax.Node) hcl.Diagnostics { w.enter(node); return nil }
+func (w walker) Exit(node hclsyntax.Node) hcl.Diagnostics  { w.exit(node); return nil }
+
+// WriteDOT writes the graph in Graphviz DOT format.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph paletteswap {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, node := range g.Nodes {
+		fmt.Fprintf(&b, "\t%q;\n", node)
+	}
+	for _, e := range g.Edges {
+		if e.Func != "" {
+			fmt.Fprintf(&b, "\t%q -> %q [label=%q];\n", e.From, e.To, e.Func)
+		} else {
+			fmt.Fprintf(&b, "\t%q -> %q;\n", e.From, e.To)
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	ids := make(map[string]string, len(g.Nodes))
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for i, node := range g.Nodes {
+		ids[node] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(&b, "\t%s[%q]\n", ids[node], node)
+	}
+	for _, e := range g.Edges {
+		if e.Func != "" {
+			fmt.Fprintf(&b, "\t%s -->|%s| %s\n", ids[e.From], e.Func, ids[e.To])
+		} else {
+			fmt.Fprintf(&b, "\t%s --> %s\n", ids[e.From], ids[e.To])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,194 @@
+package graph
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testTheme = `meta {
+  name = "Test"
+}
+
+palette {
+  base    = "#191724"
+  surface = brighten(palette.base, 0.1)
+  highlight {
+    color = "#524f67"
+    low   = "#21202e"
+  }
+  scale "ramp" {
+    from  = palette.base
+    to    = palette.highlight.low
+    steps = 3
+  }
+}
+
+locals {
+  accent = palette.highlight.low
+}
+
+theme {
+  background = palette.base
+  foreground = palette.highlight.color
+  selection  = darken(local.accent, 0.1)
+}
+
+syntax {
+  comment {
+    color  = palette.surface
+    italic = true
+  }
+}
+
+syntax "go" {
+  keyword = mix(palette.base, palette.surface, 0.5)
+}
+`
+
+func TestBuild(t *testing.T) {
+	g, err := Build("test.pstheme", []byte(testTheme))
+	if err != nil {
+		t.Fatalf("Build() error: %v", err)
+	}
+
+	wantNodes := []string{
+		"palette.base", "palette.surface", "palette.highlight", "palette.highlight.low", "palette.ramp",
+		"local.accent", "theme.background", "theme.selection",
+		"syntax.comment", "syntax[go].keyword",
+	}
+	for _, node := range wantNodes {
+		if !slices.Contains(g.Nodes, node) {
+			t.Errorf("Nodes missing %q: %v", node, g.Nodes)
+		}
+	}
+	if slices.Contains(g.Nodes, "palette.highlight.color") {
+		t.Error("the reserved color key should not be a graph node")
+	}
+	if slices.Contains(g.Nodes, "meta.name") {
+		t.Error("meta attributes should not be graph nodes")
+	}
+
+	wantEdges := []Edge{
+		{From: "palette.base", To: "palette.surface", Func: "brighten"},
+		{From: "palette.base", To: "palette.ramp", Func: "scale"},
+		{From: "palette.highlight.low", To: "palette.ramp", Func: "scale"},
+		{From: "palette.highlight.low", To: "local.accent"},
+		{From: "palette.base", To: "theme.background"},
+		{From: "palette.highlight", To: "theme.foreground"},
+		{From: "local.accent", To: "theme.selection", Func: "darken"},
+		{From: "palette.surface", To: "syntax.comment"},
+		{From: "palette.base", To: "syntax[go].keyword", Func: "mix"},
+		{From: "palette.surface", To: "syntax[go].keyword", Func: "mix"},
+	}
+	if !slices.Equal(g.Edges, wantEdges) {
+		t.Errorf("Edges =\n%v\nwant\n%v", g.Edges, wantEdges)
+	}
+}
+
+func TestBuildSyntaxError(t *testing.T) {
+	if _, err := Build("test.pstheme", []byte("palette {")); err == nil {
+		t.Error("Build() should fail on invalid HCL")
+	}
+}
+
+func TestBuildBareName(t *testing.T) {
+	src := `palette {
+  base    = "#191724"
+  surface = brighten(base, 0.1)
+}
+`
+	g, err := Build("test.pstheme", []byte(src))
+	if err != nil {
+		t.Fatalf("Build() error: %v", err)
+	}
+
+	want := []Edge{{From: "base", To: "palette.surface", Func: "brighten"}}
+	if !slices.Equal(g.Edges, want) {
+		t.Errorf("Edges = %v, want %v", g.Edges, want)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	g := &Graph{
+		Nodes: []string{"palette.base", "theme.background", "theme.surface"},
+		Edges: []Edge{
+			{From: "palette.base", To: "theme.background"},
+			{From: "palette.base", To: "theme.surface", Func: "brighten"},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		write func(*Graph, *bytes.Buffer) error
+		want  []string
+	}{
+		{
+			name:  "dot",
+			write: func(g *Graph, b *bytes.Buffer) error { return g.WriteDOT(b) },
+			want: []string{
+				"digraph paletteswap {",
+				`"palette.base" -> "theme.background";`,
+				`"palette.base" -> "theme.surface" [label="brighten"];`,
+			},
+		},
+		{
+			name:  "mermaid",
+			write: func(g *Graph, b *bytes.Buffer) error { return g.WriteMermaid(b) },
+			want: []string{
+				"graph LR",
+				`n0["palette.base"]`,
+				"n0 --> n1",
+				"n0 -->|brighten| n2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.write(g, &buf); err != nil {
+				t.Fatalf("write error: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestUsage(t *testing.T) {
+	g, err := Build("test.pstheme", []byte(testTheme))
+	if err != nil {
+		t.Fatalf("Build() error: %v", err)
+	}
+
+	want := []Usage{
+		{Path: "palette.base", Uses: 3}, // directly, and through palette.surface
+		{Path: "palette.surface", Uses: 2},
+		{Path: "palette.highlight", Uses: 1},
+		{Path: "palette.highlight.low", Uses: 1}, // through local.accent
+		{Path: "palette.ramp", Uses: 0},
+	}
+	if got := g.Usage(); !slices.Equal(got, want) {
+		t.Errorf("Usage() =\n%v\nwant\n%v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := g.WriteUsage(&buf); err != nil {
+		t.Fatalf("WriteUsage() error: %v", err)
+	}
+	for _, line := range []string{
+		"| Palette entry | Uses | |",
+		"| `palette.base` | 3 | " + strings.Repeat("█", 20) + " |",
+		"| `palette.highlight` | 1 | " + strings.Repeat("█", 6) + " |",
+		"| `palette.ramp` | 0 |  |",
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("output missing %q:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// usageBarWidth is the length of the heat bar of the most used entry in
+// WriteUsage.
+const usageBarWidth = 20
+
+// Usage is how many theme, ansi, syntax and semantic entries use a palette
+// entry.
+type Usage struct {
+	Path string
+	Uses int
+}
+
+// Usage returns the usage of every palette entry, in source order. An entry
+// counts as using a palette entry if it references it directly, or through
+// locals, other palette entries or other entries, so that a color used only
+// through local.accent is not reported as unused. Each entry counts once
+// per palette entry, however many paths lead there.
+func (g *Graph) Usage() []Usage {
+	sources := make(map[string][]string)
+	for _, e := range g.Edges {
+		sources[e.To] = append(sources[e.To], e.From)
+	}
+
+	uses := make(map[string]int)
+	for _, node := range g.Nodes {
+		if !isConsumer(node) {
+			continue
+		}
+		seen := make(map[string]bool)
+		var visit func(string)
+		visit = func(to string) {
+			for _, from := range sources[to] {
+				if !seen[from] {
+					seen[from] = true
+					visit(from)
+				}
+			}
+		}
+		visit(node)
+		for from := range seen {
+			if strings.HasPrefix(from, "palette.") {
+				uses[from]++
+			}
+		}
+	}
+
+	var usage []Usage
+	for _, node := range g.Nodes {
+		if strings.HasPrefix(node, "palette.") {
+			usage = append(usage, Usage{Path: node, Uses: uses[node]})
+		}
+	}
+	return usage
+}
+
+// isConsumer reports whether node is an entry of a block that uses palette
+// colors rather than defining them.
+func isConsumer(node string) bool {
+	block, _, _ := strings.Cut(node, ".")
+	switch block {
+	case "theme", "ansi", "syntax", "semantic":
+		return true
+	}
+	return strings.HasPrefix(block, "syntax[")
+}
+
+// WriteUsage writes the palette usage as a Markdown table, with a bar
+// scaled to the most used entry as a heatmap.
+func (g *Graph) WriteUsage(w io.Writer) error {
+	usage := g.Usage()
+	most := 0
+	for _, u := range usage {
+		most = max(most, u.Uses)
+	}
+
+	var b strings.Builder
+	b.WriteString("| Palette entry | Uses | |\n|---|---:|---|\n")
+	for _, u := range usage {
+		bar := ""
+		if u.Uses > 0 {
+			bar = strings.Repeat("█", max(1, u.Uses*usageBarWidth/most))
+		}
+		fmt.Fprintf(&b, "| `%s` | %d | %s |\n", u.Path, u.Uses, bar)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
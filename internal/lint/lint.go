@@ -0,0 +1,277 @@
+// Package lint implements the theme lint rules shared by the check command
+// and the language server. Each rule has a stable ID (PS001) and a name
+// (missing-ansi); its severity can be overridden, or the rule turned off,
+// in the lint block of a theme's settings block, and its messages can be
+// translated with a Catalog for the locale setting:
+//
+//	settings {
+//	  ansi_profile = "basic8"
+//	  locale       = "de"
+//	  lint {
+//	    PS003        = "off"
+//	    low-contrast = "error"
+//	  }
+//	}
+package lint
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/theme"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Severity is how a finding is reported. SeverityOff disables a rule.
+type Severity int
+
+const (
+	SeverityOff Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = []string{"off", "info", "warning", "error"}
+
+// String returns the lowercase name of the severity, as used in settings.
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return "unknown"
+	}
+	return severityNames[s]
+}
+
+// MarshalText encodes the severity by name, e.g. in JSON output.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity parses a severity name: off, info, warning or error.
+func ParseSeverity(s string) (Severity, error) {
+	i := slices.Index(severityNames, strings.ToLower(s))
+	if i < 0 {
+		return 0, fmt.Errorf("unknown severity %q (valid: %s)", s, strings.Join(severityNames, ", "))
+	}
+	return Severity(i), nil
+}
+
+// Rule is a single lint check.
+type Rule struct {
+	ID       string   // stable identifier, e.g. "PS001"
+	Name     string   // short kebab-case name, e.g. "missing-ansi"
+	Severity Severity // default severity
+	Doc      string   // one-line description
+	// Message is the English fmt format of the rule's findings, taking
+	// their Args. Catalogs translate it for other locales; see Localize. If
+	// it is empty, findings keep the Message Check gives them.
+	Message string
+	// Check returns the rule's findings for in. Run fills in their rule,
+	// severity and, from Message, their message.
+	Check func(in *Input) []Finding
+}
+
+// Input is the theme a rule inspects: the parsed file, plus colors the
+// caller managed to resolve. Rules skip what is missing, so a partial Input
+// from a file with errors still yields the findings that can be made.
+type Input struct {
+	Body    *hclsyntax.Body
+	Theme   map[string]color.Color // resolved top-level theme colors
+	Palette *color.Node            // resolved palette, with the steps of transforms
+}
+
+// Finding is a problem reported by a rule.
+type Finding struct {
+	RuleID   string
+	RuleName string
+	Severity Severity
+	Range    hcl.Range
+	Message  string // in English; see Localize
+	Args     []any  // arguments of the rule's Message format
+}
+
+// Config overrides rule severities, keyed by rule ID.
+type Config map[string]Severity
+
+// Severity returns the severity of the rule with the given ID: the one cfg
+// gives it, or else the rule's default. Unknown rules are off.
+func (cfg Config) Severity(id string) Severity {
+	if s, ok := cfg[id]; ok {
+		return s
+	}
+	if r, ok := Lookup(id); ok {
+		return r.Severity
+	}
+	return SeverityOff
+}
+
+// rulesMu guards Rules against RegisterRule.
+var rulesMu sync.RWMutex
+
+// RegisterRule adds a rule to Rules, so that Run, and with it the check
+// command and the language server, apply it like the built-in rules, and
+// the lint block of a theme's settings can configure it. Its ID and name
+// must be unique; project rules should not use the PS prefix of the
+// built-in ones.
+func RegisterRule(r Rule) error {
+	switch {
+	case r.ID == "" || r.Name == "":
+		return fmt.Errorf("lint rule must have an ID and a name")
+	case r.Check == nil:
+		return fmt.Errorf("lint rule %s has no Check function", r.ID)
+	case r.Severity < SeverityOff || r.Severity > SeverityError:
+		return fmt.Errorf("lint rule %s has an invalid severity %d", r.ID, r.Severity)
+	}
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	for _, other := range Rules {
+		if strings.EqualFold(other.ID, r.ID) || other.Name == r.Name || strings.EqualFold(other.ID, r.Name) || other.Name == r.ID {
+			return fmt.Errorf("lint rule %s (%s) conflicts with rule %s (%s)", r.ID, r.Name, other.ID, other.Name)
+		}
+	}
+	Rules = append(Rules, r)
+	return nil
+}
+
+// Lookup returns the rule with the given ID or name.
+func Lookup(idOrName string) (Rule, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	for _, r := range Rules {
+		if strings.EqualFold(r.ID, idOrName) || r.Name == idOrName {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Run checks in against every rule that cfg does not turn off and returns
+// the findings in source order.
+func Run(in *Input, cfg Config) []Finding {
+	rulesMu.RLock()
+	rules := Rules
+	rulesMu.RUnlock()
+
+	var findings []Finding
+	for _, rule := range rules {
+		sev := rule.Severity
+		if s, ok := cfg[rule.ID]; ok {
+			sev = s
+		}
+		if sev == SeverityOff {
+			continue
+		}
+		for _, f := range rule.Check(in) {
+			f.RuleID = rule.ID
+			f.RuleName = rule.Name
+			f.Severity = sev
+			if rule.Message != "" {
+				f.Message = fmt.Sprintf(rule.Message, f.Args...)
+			}
+			findings = append(findings, f)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Range.Start.Byte < findings[j].Range.Start.Byte
+	})
+	return findings
+}
+
+// ParseConfig reads the rule severities from the lint block of the
+// settings block in body. Attribute names may be a rule ID or name, and
+// values are severity names. no_literals_outside_palette = true turns on
+// the literal-color rule as a warning, unless the lint block sets its
+// severity. Invalid entries are reported as diagnostics and otherwise
+// ignored.
+func ParseConfig(body *hclsyntax.Body) (Config, hcl.Diagnostics) {
+	cfg := make(Config)
+	var diags hcl.Diagnostics
+	noLiterals := false
+	for _, settings := range body.Blocks {
+		if settings.Type != "settings" {
+			continue
+		}
+		for name, attr := range settings.Body.Attributes {
+			switch name {
+			case theme.ANSIProfileAttr:
+				continue // validated by theme.ANSIProfileSetting
+			case theme.SchemaVersionAttr:
+				continue // validated by theme.SchemaVersionSetting
+			case LocaleAttr:
+				if d := checkLocale(attr); d != nil {
+					diags = append(diags, d)
+				}
+				continue
+			case NoLiteralsAttr:
+				on, d := noLiteralsValue(attr)
+				if d != nil {
+					diags = append(diags, d)
+				}
+				noLiterals = noLiterals || on
+				continue
+			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("unknown setting %q (valid: %s, %s, %s, %s, or a lint block)", name, theme.ANSIProfileAttr, LocaleAttr, NoLiteralsAttr, theme.SchemaVersionAttr),
+				Subject:  attr.NameRange.Ptr(),
+			})
+		}
+		for _, block := range settings.Body.Blocks {
+			if block.Type != "lint" {
+				rng := block.DefRange()
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  fmt.Sprintf("unknown settings block %q (valid: lint)", block.Type),
+					Subject:  &rng,
+				})
+				continue
+			}
+			diags = append(diags, parseLintBlock(block.Body, cfg)...)
+		}
+	}
+	if _, ok := cfg[LiteralColorRule]; noLiterals && !ok {
+		cfg[LiteralColorRule] = SeverityWarning
+	}
+	return cfg, diags
+}
+
+func parseLintBlock(body *hclsyntax.Body, cfg Config) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for name, attr := range body.Attributes {
+		rule, ok := Lookup(name)
+		if !ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("unknown lint rule %q", name),
+				Subject:  attr.NameRange.Ptr(),
+			})
+			continue
+		}
+		val, valDiags := attr.Expr.Value(nil)
+		if valDiags.HasErrors() || !val.IsKnown() || val.IsNull() || !val.Type().Equals(cty.String) {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("lint.%s must be a severity string (valid: %s)", name, strings.Join(severityNames, ", ")),
+				Subject:  attr.Expr.Range().Ptr(),
+			})
+			continue
+		}
+		sev, err := ParseSeverity(val.AsString())
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("lint.%s: %s", name, err),
+				Subject:  attr.Expr.Range().Ptr(),
+			})
+			continue
+		}
+		cfg[rule.ID] = sev
+	}
+	return diags
+}
@@ -0,0 +1,445 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/jsvensson/paletteswap/internal/color"
+)
+
+func parseBody(t *testing.T, content string) *hclsyntax.Body {
+	t.Helper()
+	file, diags := hclsyntax.ParseConfig([]byte(content), "test.pstheme", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatalf("parse error: %s", diags.Error())
+	}
+	return file.Body.(*hclsyntax.Body)
+}
+
+func ruleIDs(findings []Finding) []string {
+	var ids []string
+	for _, f := range findings {
+		ids = append(ids, f.RuleID)
+	}
+	return ids
+}
+
+func TestRun(t *testing.T) {
+	content := `
+palette {
+  base   = "#191724"
+  text   = "#e0def4"
+  unused = "#ff0000"
+  muted  = "#6e6a86"
+  highlight {
+    low  = "#21202e"
+    high = "#524f67"
+  }
+  scale "gray" {
+    from  = palette.base
+    to    = palette.text
+    steps = 3
+  }
+}
+
+theme {
+  background = palette.base
+  foreground = palette.text
+  selection  = palette.highlight.low
+  border     = palette.gray.2
+  comment    = palette.muted.l1
+}
+
+ansi {
+  black = palette.base
+}
+`
+	in := &Input{
+		Body: parseBody(t, content),
+		Theme: map[string]color.Color{
+			"background": {R: 25, G: 23, B: 36},
+			"foreground": {R: 224, G: 222, B: 244},
+		},
+	}
+
+	findings := Run(in, nil)
+	want := []string{"PS003", "PS003", "PS001"}
+	if got := ruleIDs(findings); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("rules = %v, want %v", got, want)
+	}
+	if findings[0].Message != "palette.unused is never referenced" {
+		t.Errorf("message = %q", findings[0].Message)
+	}
+	if findings[1].Message != "palette.highlight.high is never referenced" {
+		t.Errorf("message = %q", findings[1].Message)
+	}
+	if findings[0].Severity != SeverityInfo || findings[2].Severity != SeverityError {
+		t.Errorf("severities = %v, %v; want info, error", findings[0].Severity, findings[2].Severity)
+	}
+	if findings[2].RuleName != "missing-ansi" || findings[2].Range.Start.Line != 26 {
+		t.Errorf("missing-ansi finding = %+v", findings[2])
+	}
+}
+
+func TestRunLowContrast(t *testing.T) {
+	body := parseBody(t, "theme {\n  background = \"#191724\"\n  foreground = \"#26233a\"\n}\n")
+	in := &Input{
+		Body: body,
+		Theme: map[string]color.Color{
+			"background": {R: 25, G: 23, B: 36},
+			"foreground": {R: 38, G: 35, B: 58},
+		},
+	}
+
+	findings := Run(in, nil)
+	if len(findings) != 1 || findings[0].RuleID != "PS002" {
+		t.Fatalf("findings = %+v, want one PS002", findings)
+	}
+	if findings[0].Range.Start.Line != 3 {
+		t.Errorf("range starts on line %d, want 3", findings[0].Range.Start.Line)
+	}
+
+	if findings := Run(in, Config{"PS002": SeverityOff}); len(findings) != 0 {
+		t.Errorf("findings with PS002 off = %+v, want none", findings)
+	}
+}
+
+func TestRunMissingANSIProfile(t *testing.T) {
+	content := `
+settings {
+  ansi_profile = "%s"
+}
+
+ansi {
+  black   = "#000000"
+  red     = "#ff0000"
+  green   = "#00ff00"
+  yellow  = "#ffff00"
+  blue    = "#0000ff"
+  magenta = "#ff00ff"
+  cyan    = "#00ffff"
+  white   = "#ffffff"
+}
+`
+	if findings := Run(&Input{Body: parseBody(t, strings.Replace(content, "%s", "basic8", 1))}, nil); len(findings) != 0 {
+		t.Errorf("basic8 findings = %+v, want none", findings)
+	}
+	findings := Run(&Input{Body: parseBody(t, strings.Replace(content, "%s", "extended", 1))}, nil)
+	if len(findings) != 1 || !strings.HasSuffix(findings[0].Message, "bright_white, foreground, background, cursor") {
+		t.Errorf("extended findings = %+v, want the bright and extended colors missing", findings)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	content := `
+settings {
+  ansi_profile = "basic8"
+  colors       = 8
+  locale       = 7
+  lint {
+    PS001          = "error"
+    unused-palette = "off"
+    PS002          = "loud"
+    PS999          = "off"
+  }
+}
+`
+	cfg, diags := ParseConfig(parseBody(t, content))
+
+	if cfg["PS001"] != SeverityError {
+		t.Errorf("PS001 = %v, want error", cfg["PS001"])
+	}
+	if sev, ok := cfg["PS003"]; !ok || sev != SeverityOff {
+		t.Errorf("PS003 = %v (set %v), want off", sev, ok)
+	}
+	if _, ok := cfg["PS002"]; ok {
+		t.Error("PS002 should not be set from an invalid severity")
+	}
+
+	var msgs []string
+	for _, d := range diags {
+		msgs = append(msgs, d.Summary)
+	}
+	joined := strings.Join(msgs, "\n")
+	for _, want := range []string{`unknown severity "loud"`, `unknown lint rule "PS999"`, `unknown setting "colors"`, "locale must be a string"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("diagnostics %q missing %q", joined, want)
+		}
+	}
+	if strings.Contains(joined, "ansi_profile\"") {
+		t.Errorf("diagnostics %q should not report ansi_profile", joined)
+	}
+}
+
+func TestRunLiteralColors(t *testing.T) {
+	content := `
+settings {
+  no_literals_outside_palette = %s
+%s}
+palette {
+  base = "#191724"
+}
+theme {
+  background = palette.base
+  foreground = brighten("#e0def4", 0.1)
+  border     = [49, 116, 143]
+  accent     = hsl(343, 0.76, 0.68)
+  selection  = rgb(local.red, 0, 0)
+}
+syntax {
+  markup {
+    heading {
+      color = "#ebbcba"
+      bold  = true
+    }
+  }
+}
+`
+	tests := []struct {
+		name      string
+		setting   string
+		lintBlock string
+		want      Severity // SeverityOff for no findings
+	}{
+		{"enabled", "true", "", SeverityWarning},
+		{"disabled", "false", "", SeverityOff},
+		{"severity from lint block", "true", "  lint {\n    literal-color = \"error\"\n  }\n", SeverityError},
+		{"lint block alone", "false", "  lint {\n    PS004 = \"info\"\n  }\n", SeverityInfo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := parseBody(t, fmt.Sprintf(content, tt.setting, tt.lintBlock))
+			cfg, diags := ParseConfig(body)
+			if diags.HasErrors() {
+				t.Fatalf("ParseConfig() error: %s", diags.Error())
+			}
+			var got []string
+			for _, f := range Run(&Input{Body: body}, cfg) {
+				if f.RuleID != "PS004" {
+					continue
+				}
+				if f.Severity != tt.want {
+					t.Errorf("severity = %v, want %v", f.Severity, tt.want)
+				}
+				got = append(got, f.Message)
+			}
+			var want []string
+			if tt.want != SeverityOff {
+				want = []string{
+					"theme.foreground uses the color literal #e0def4; define it in the palette",
+					"theme.border uses the color literal #31748f; define it in the palette",
+					"theme.accent uses the color literal #eb6f93; define it in the palette",
+					"syntax.markup.heading uses the color literal #ebbcba; define it in the palette",
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("messages = %q, want %q", got, want)
+			}
+		})
+	}
+
+	_, diags := ParseConfig(parseBody(t, "settings {\n  no_literals_outside_palette = \"yes\"\n}\n"))
+	if !strings.Contains(diags.Error(), "no_literals_outside_palette must be true or false") {
+		t.Errorf("diagnostics = %v, want a bool error", diags)
+	}
+}
+
+func TestRunFlatNameCollisions(t *testing.T) {
+	body := parseBody(t, `
+palette {
+  highlight {
+    low = "#21202e"
+  }
+  highlight_low = "#403d52"
+  base          = "#191724"
+}
+`)
+	highlight := &color.Node{}
+	highlight.SetChild("low", &color.Node{Color: &color.Color{R: 0x21, G: 0x20, B: 0x2e}})
+	palette := &color.Node{}
+	palette.SetChild("highlight", highlight)
+	palette.SetChild("highlight_low", &color.Node{Color: &color.Color{R: 0x40, G: 0x3d, B: 0x52}})
+	palette.SetChild("base", &color.Node{Color: &color.Color{R: 0x19, G: 0x17, B: 0x24}})
+
+	var got []Finding
+	for _, f := range Run(&Input{Body: body, Palette: palette}, nil) {
+		if f.RuleID == "PS005" {
+			got = append(got, f)
+		}
+	}
+	if len(got) != 1 {
+		t.Fatalf("PS005 findings = %+v, want one", got)
+	}
+	want := `palette.highlight_low flattens to "highlight-low", like palette.highlight.low; flat exports cannot tell them apart`
+	if got[0].Message != want || got[0].Severity != SeverityWarning {
+		t.Errorf("finding = %v %q, want warning %q", got[0].Severity, got[0].Message, want)
+	}
+	if got[0].Range.Start.Line != 6 {
+		t.Errorf("finding line = %d, want 6, the highlight_low attribute", got[0].Range.Start.Line)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	RegisterCatalog("x-test", Catalog{
+		"PS003": "%s wird nie verwendet",
+	})
+	RegisterCatalog("x-test-RE", Catalog{
+		"PS002": "Kontrast %.1[2]f:1 verfehlt, nur %.2[1]f:1",
+	})
+
+	body := parseBody(t, `
+settings {
+  locale = "x-test_re"
+}
+palette {
+  extra = "#ffffff"
+}
+theme {
+  background = "#191724"
+  foreground = "#1f1d2e"
+}
+`)
+	findings := Run(&Input{
+		Body: body,
+		Theme: map[string]color.Color{
+			"background": {R: 25, G: 23, B: 36},
+			"foreground": {R: 31, G: 29, B: 46},
+		},
+	}, nil)
+	locale := LocaleSetting(body)
+	if locale != "x-test_re" {
+		t.Fatalf("LocaleSetting() = %q, want x-test_re", locale)
+	}
+
+	got := make(map[string][2]string)
+	for _, f := range findings {
+		got[f.RuleID] = [2]string{f.Message, f.Localize(locale)}
+	}
+	tests := map[string][2]string{
+		// The region's catalog, with reordered arguments.
+		"PS002": {"theme.foreground has contrast 1.07:1 on theme.background, below WCAG AA (4.5:1)", "Kontrast 4.5:1 verfehlt, nur 1.07:1"},
+		// Falls back to the language's catalog.
+		"PS003": {"palette.extra is never referenced", "palette.extra wird nie verwendet"},
+	}
+	for id, want := range tests {
+		if got[id] != want {
+			t.Errorf("%s message, localized = %q, want %q", id, got[id], want)
+		}
+	}
+
+	if f := findings[0]; f.Localize("fr") != f.Message {
+		t.Errorf("Localize(fr) = %q, want the English message %q", f.Localize("fr"), f.Message)
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Severity
+		wantErr bool
+	}{
+		{"off", SeverityOff, false},
+		{"info", SeverityInfo, false},
+		{"Warning", SeverityWarning, false},
+		{"error", SeverityError, false},
+		{"fatal", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSeverity(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSeverity(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSeverity(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterRule(t *testing.T) {
+	// A project rule, off unless the lint block enables it, so that it does
+	// not affect the other tests.
+	var walk func(body *hclsyntax.Body, findings *[]Finding)
+	walk = func(body *hclsyntax.Body, findings *[]Finding) {
+		for _, attr := range body.Attributes {
+			if _, ok := attr.Expr.(*hclsyntax.TemplateExpr); ok {
+				*findings = append(*findings, Finding{Range: attr.Expr.Range(), Args: []any{attr.Name}})
+			}
+		}
+		for _, block := range body.Blocks {
+			walk(block.Body, findings)
+		}
+	}
+	rule := Rule{
+		ID:       "XT001",
+		Name:     "no-syntax-literals",
+		Severity: SeverityOff,
+		Doc:      "Syntax colors must come from the palette.",
+		Message:  "%s uses a color literal",
+		Check: func(in *Input) []Finding {
+			var findings []Finding
+			for _, block := range in.Body.Blocks {
+				if block.Type == "syntax" {
+					walk(block.Body, &findings)
+				}
+			}
+			return findings
+		},
+	}
+	if err := RegisterRule(rule); err != nil {
+		t.Fatalf("RegisterRule() error: %v", err)
+	}
+	if got, ok := Lookup("no-syntax-literals"); !ok || got.ID != "XT001" {
+		t.Errorf("Lookup(no-syntax-literals) = %+v, %v, want XT001", got, ok)
+	}
+
+	body := parseBody(t, `
+settings {
+  lint {
+    no-syntax-literals = "error"
+  }
+}
+palette {
+  base = "#191724"
+}
+syntax {
+  keyword = palette.base
+  markup {
+    heading = "#ebbcba"
+  }
+}
+`)
+	cfg, diags := ParseConfig(body)
+	if diags.HasErrors() {
+		t.Fatalf("ParseConfig() error: %s", diags.Error())
+	}
+	var custom []Finding
+	for _, f := range Run(&Input{Body: body}, cfg) {
+		if f.RuleID == "XT001" {
+			custom = append(custom, f)
+		}
+	}
+	if len(custom) != 1 || custom[0].Severity != SeverityError || custom[0].Message != "heading uses a color literal" {
+		t.Fatalf("XT001 findings = %+v, want one error for heading", custom)
+	}
+	if custom[0].Range.Start.Line != 13 {
+		t.Errorf("range starts on line %d, want 13", custom[0].Range.Start.Line)
+	}
+
+	invalid := map[string]Rule{
+		"duplicate ID":  {ID: "xt001", Name: "other", Check: rule.Check},
+		"built-in name": {ID: "XT002", Name: "missing-ansi", Check: rule.Check},
+		"no name":       {ID: "XT003", Check: rule.Check},
+		"no check":      {ID: "XT004", Name: "no-check"},
+		"bad severity":  {ID: "XT005", Name: "bad-severity", Severity: 7, Check: rule.Check},
+	}
+	for name, r := range invalid {
+		if err := RegisterRule(r); err == nil {
+			t.Errorf("RegisterRule(%s) succeeded, want an error", name)
+		}
+	}
+}
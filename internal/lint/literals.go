@@ -0,0 +1,145 @@
+package lint
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/theme"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// NoLiteralsAttr is the settings attribute that turns on the literal-color
+// rule, at warning severity unless the lint block sets another.
+const NoLiteralsAttr = "no_literals_outside_palette"
+
+// LiteralColorRule is the ID of the rule reporting color literals outside
+// the palette.
+const LiteralColorRule = "PS004"
+
+// literalBlocks are the top-level blocks whose colors the literal-color
+// rule expects to come from the palette.
+var literalBlocks = []string{"theme", "ansi", "syntax", "semantic"}
+
+// ColorLiteral is a color written outside the palette: a hex string, an
+// [r, g, b] tuple, or an rgb() or hsl() call with constant arguments.
+type ColorLiteral struct {
+	Path  string // color path of the attribute holding it, e.g. "theme.background"
+	Name  string // name of the attribute, or of the style block for its color
+	Value string // the color: a hex string as written, other literals in hex
+	Expr  hclsyntax.Expression
+}
+
+// ColorLiterals returns the color literals in the theme, ansi, syntax and
+// semantic blocks of body, including those passed to functions such as
+// brighten(), in no particular order.
+func ColorLiterals(body *hclsyntax.Body) []ColorLiteral {
+	var literals []ColorLiteral
+	var walk func(prefix, name string, body *hclsyntax.Body)
+	walk = func(prefix, name string, body *hclsyntax.Body) {
+		for attrName, attr := range body.Attributes {
+			path, litName := prefix+"."+attrName, attrName
+			if attrName == color.ColorKey {
+				path, litName = prefix, name
+			}
+			_ = hclsyntax.VisitAll(attr.Expr, func(node hclsyntax.Node) hcl.Diagnostics {
+				if expr, ok := node.(hclsyntax.Expression); ok {
+					if value, ok := literalValue(expr); ok {
+						literals = append(literals, ColorLiteral{Path: path, Name: litName, Value: value, Expr: expr})
+					}
+				}
+				return nil
+			})
+		}
+		for _, block := range body.Blocks {
+			walk(prefix+"."+block.Type, block.Type, block.Body)
+		}
+	}
+	for _, block := range body.Blocks {
+		for _, typ := range literalBlocks {
+			if block.Type == typ {
+				walk(block.Type, block.Type, block.Body)
+			}
+		}
+	}
+	return literals
+}
+
+// literalContext evaluates the color constructors, and nothing else.
+var literalContext = &hcl.EvalContext{
+	Functions: map[string]function.Function{
+		"rgb": theme.MakeRGBFunc(),
+		"hsl": theme.MakeHSLFunc(),
+	},
+}
+
+// literalValue returns the color of expr if it is a color literal: the text
+// of a hex string, or the hex of a tuple or constructor call.
+func literalValue(expr hclsyntax.Expression) (string, bool) {
+	switch e := expr.(type) {
+	case *hclsyntax.TemplateExpr:
+		return hexLiteral(e)
+	case *hclsyntax.FunctionCallExpr:
+		if e.Name != "rgb" && e.Name != "hsl" {
+			return "", false
+		}
+	case *hclsyntax.TupleConsExpr:
+		if len(e.Exprs) != 3 {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	if len(expr.Variables()) > 0 {
+		return "", false
+	}
+	val, diags := expr.Value(literalContext)
+	if diags.HasErrors() {
+		return "", false
+	}
+	hex, err := theme.ResolveColor(val)
+	return hex, err == nil
+}
+
+// hexLiteral returns the text of tmpl if it is a string literal holding a
+// hex color.
+func hexLiteral(tmpl *hclsyntax.TemplateExpr) (string, bool) {
+	if !tmpl.IsStringLiteral() {
+		return "", false
+	}
+	val, diags := tmpl.Value(nil)
+	if diags.HasErrors() || !val.Type().Equals(cty.String) {
+		return "", false
+	}
+	if _, err := color.ParseHex(val.AsString()); err != nil {
+		return "", false
+	}
+	return val.AsString(), true
+}
+
+func checkLiteralColors(in *Input) []Finding {
+	var findings []Finding
+	for _, lit := range ColorLiterals(in.Body) {
+		findings = append(findings, Finding{
+			Range: lit.Expr.Range(),
+			Args:  []any{lit.Path, lit.Value},
+		})
+	}
+	return findings
+}
+
+// noLiteralsValue returns the value of a no_literals_outside_palette
+// attribute, or a diagnostic if it is not a bool.
+func noLiteralsValue(attr *hclsyntax.Attribute) (bool, *hcl.Diagnostic) {
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || !val.IsKnown() || val.IsNull() || !val.Type().Equals(cty.Bool) {
+		return false, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  fmt.Sprintf("%s must be true or false", NoLiteralsAttr),
+			Subject:  attr.Expr.Range().Ptr(),
+		}
+	}
+	return val.True(), nil
+}
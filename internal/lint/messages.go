@@ -0,0 +1,102 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// LocaleAttr is the settings attribute selecting the locale of lint
+// messages, such as "de" or "pt-BR". Only lint findings are localized; the
+// parser's and the language server's own diagnostics are always in English.
+const LocaleAttr = "locale"
+
+// Catalog holds translated finding messages, keyed by rule ID. Each message
+// is a fmt format taking the finding's Args, which a translation may
+// reorder with explicit argument indexes such as %[2]s.
+type Catalog map[string]string
+
+var (
+	catalogsMu sync.RWMutex
+	catalogs   = make(map[string]Catalog) // keyed by lowercase locale
+)
+
+// RegisterCatalog adds the messages of c to the catalog of locale,
+// replacing any registered earlier for the same rules.
+func RegisterCatalog(locale string, c Catalog) {
+	catalogsMu.Lock()
+	defer catalogsMu.Unlock()
+	key := normalizeLocale(locale)
+	if catalogs[key] == nil {
+		catalogs[key] = make(Catalog, len(c))
+	}
+	for id, msg := range c {
+		catalogs[key][id] = msg
+	}
+}
+
+// Localize returns the message of f in locale. It uses the catalog of the
+// full locale, then those of the locale with its last subtags removed ("pt"
+// for "pt-BR"), and falls back to the English Message.
+func (f Finding) Localize(locale string) string {
+	catalogsMu.RLock()
+	defer catalogsMu.RUnlock()
+	for key := normalizeLocale(locale); key != ""; {
+		if msg, ok := catalogs[key][f.RuleID]; ok {
+			return fmt.Sprintf(msg, f.Args...)
+		}
+		i := strings.LastIndexByte(key, '-')
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+	return f.Message
+}
+
+// normalizeLocale lowercases locale and writes "pt_BR" as "pt-br".
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
+
+// LocaleSetting returns the locale setting of the settings block in body,
+// or "" if there is none or it is not a string. ParseConfig reports a value
+// that is not a string.
+func LocaleSetting(body *hclsyntax.Body) string {
+	for _, block := range body.Blocks {
+		if block.Type != "settings" {
+			continue
+		}
+		if attr, ok := block.Body.Attributes[LocaleAttr]; ok {
+			if val, ok := localeValue(attr); ok {
+				return val
+			}
+		}
+	}
+	return ""
+}
+
+// localeValue returns the string value of a locale attribute.
+func localeValue(attr *hclsyntax.Attribute) (string, bool) {
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || !val.IsKnown() || val.IsNull() || !val.Type().Equals(cty.String) {
+		return "", false
+	}
+	return val.AsString(), true
+}
+
+// checkLocale returns a diagnostic if the locale attribute is not a string.
+func checkLocale(attr *hclsyntax.Attribute) *hcl.Diagnostic {
+	if _, ok := localeValue(attr); ok {
+		return nil
+	}
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  fmt.Sprintf("%s must be a string, such as \"de\" or \"pt-BR\"", LocaleAttr),
+		Subject:  attr.Expr.Range().Ptr(),
+	}
+}
@@ -0,0 +1,263 @@
+package lint
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/theme"
+)
+
+// MinContrast is the WCAG AA contrast ratio for normal-size text, which
+// theme.foreground must reach on theme.background.
+const MinContrast = 4.5
+
+// MissingANSIRule is the ID of the rule reporting an incomplete ansi block.
+// Themes load only while it is at error severity, its default, so that
+// check and generate agree; lowering it lets themes with an incomplete
+// ansi block load, leaving templates to fail on the colors they miss.
+const MissingANSIRule = "PS001"
+
+// Rules lists every lint rule: the built-in ones in ID order, then those
+// added with RegisterRule.
+var Rules = []Rule{
+	{
+		ID:       MissingANSIRule,
+		Name:     "missing-ansi",
+		Severity: SeverityError,
+		Doc:      "the ansi block must define every color its ansi_profile setting requires",
+		Message:  "ANSI block missing colors: %s",
+		Check:    checkMissingANSI,
+	},
+	{
+		ID:       "PS002",
+		Name:     "low-contrast",
+		Severity: SeverityWarning,
+		Doc:      "theme.foreground should reach WCAG AA contrast on theme.background",
+		Message:  "theme.foreground has contrast %.2f:1 on theme.background, below WCAG AA (%.1f:1)",
+		Check:    checkLowContrast,
+	},
+	{
+		ID:       "PS003",
+		Name:     "unused-palette",
+		Severity: SeverityInfo,
+		Doc:      "palette colors should be referenced somewhere in the theme",
+		Message:  "%s is never referenced",
+		Check:    checkUnusedPalette,
+	},
+	{
+		ID:       LiteralColorRule,
+		Name:     "literal-color",
+		Severity: SeverityOff,
+		Doc:      "colors outside the palette should reference it rather than use hex, [r, g, b], rgb() or hsl() literals",
+		Message:  "%s uses the color literal %s; define it in the palette",
+		Check:    checkLiteralColors,
+	},
+	{
+		ID:       "PS005",
+		Name:     "flat-name-collision",
+		Severity: SeverityWarning,
+		Doc:      "palette paths should stay distinct when flattened for exports such as CSS custom properties",
+		Message:  "%s flattens to %q, like %s; flat exports cannot tell them apart",
+		Check:    checkFlatNameCollisions,
+	},
+}
+
+// topLevelBlock returns the first unlabeled top-level block of the given type.
+func topLevelBlock(body *hclsyntax.Body, typ string) *hclsyntax.Block {
+	for _, block := range body.Blocks {
+		if block.Type == typ && len(block.Labels) == 0 {
+			return block
+		}
+	}
+	return nil
+}
+
+func checkMissingANSI(in *Input) []Finding {
+	ansi := topLevelBlock(in.Body, "ansi")
+	if ansi == nil {
+		return nil
+	}
+	// An invalid ansi_profile is reported by the caller; check against the
+	// default then.
+	profile, _, err := theme.ANSIProfileSetting(in.Body)
+	if err != nil {
+		profile = theme.ANSIStandard16
+	}
+	var missing []string
+	for _, name := range profile.Required() {
+		if _, ok := ansi.Body.Attributes[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return []Finding{{
+		Range: ansi.DefRange(),
+		Args:  []any{strings.Join(missing, ", ")},
+	}}
+}
+
+func checkLowContrast(in *Input) []Finding {
+	fg, okFg := in.Theme["foreground"]
+	bg, okBg := in.Theme["background"]
+	block := topLevelBlock(in.Body, "theme")
+	if !okFg || !okBg || block == nil {
+		return nil
+	}
+	attr, ok := block.Body.Attributes["foreground"]
+	if !ok {
+		return nil
+	}
+	ratio := color.ContrastRatio(fg, bg)
+	if ratio >= MinContrast {
+		return nil
+	}
+	return []Finding{{
+		Range: attr.SrcRange,
+		Args:  []any{ratio, MinContrast},
+	}}
+}
+
+func checkUnusedPalette(in *Input) []Finding {
+	palette := topLevelBlock(in.Body, "palette")
+	if palette == nil {
+		return nil
+	}
+
+	// A reference to palette.a.b uses palette.a.b and everything under it.
+	used := make(map[string]bool)
+	_ = hclsyntax.VisitAll(in.Body, func(node hclsyntax.Node) hcl.Diagnostics {
+		expr, ok := node.(*hclsyntax.ScopeTraversalExpr)
+		if !ok || expr.Traversal.RootName() != "palette" {
+			return nil
+		}
+		path := "palette"
+		for _, step := range expr.Traversal[1:] {
+			attr, ok := step.(hcl.TraverseAttr)
+			if !ok {
+				break
+			}
+			path += "." + attr.Name
+		}
+		used[path] = true
+		return nil
+	})
+	isUsed := func(path string) bool {
+		for p := path; p != ""; {
+			if used[p] {
+				return true
+			}
+			i := strings.LastIndexByte(p, '.')
+			if i < 0 {
+				break
+			}
+			p = p[:i]
+		}
+		return false
+	}
+
+	type entry struct {
+		path string
+		rng  hcl.Range
+	}
+	var entries []entry
+	declared := make(map[string]bool)
+	var walk func(prefix string, body *hclsyntax.Body)
+	walk = func(prefix string, body *hclsyntax.Body) {
+		for name, attr := range body.Attributes {
+			if name != color.ColorKey {
+				entries = append(entries, entry{prefix + "." + name, attr.NameRange})
+			}
+		}
+		for _, block := range body.Blocks {
+			switch {
+			case block.Type == "transform":
+			case block.Type == "scale" && len(block.Labels) == 1:
+				entries = append(entries, entry{prefix + "." + block.Labels[0], block.DefRange()})
+			case len(block.Labels) == 0:
+				declared[prefix+"."+block.Type] = true
+				walk(prefix+"."+block.Type, block.Body)
+			}
+		}
+	}
+	walk("palette", palette.Body)
+	for _, e := range entries {
+		declared[e.path] = true
+	}
+
+	// A reference to a step the transform block generates, such as
+	// palette.base.l1, uses the color it is generated from, but not the
+	// other colors in a group it is generated for.
+	stepUsed := make(map[string]bool)
+	for path := range used {
+		i := strings.LastIndexByte(path, '.')
+		if i < 0 || declared[path] || !declared[path[:i]] || path[i+1:] == color.ColorKey {
+			continue
+		}
+		if in.Palette != nil {
+			if _, err := in.Palette.Find(strings.Split(path, ".")[1:]); err != nil {
+				continue
+			}
+		}
+		stepUsed[path[:i]] = true
+	}
+
+	var findings []Finding
+	for _, e := range entries {
+		if !isUsed(e.path) && !stepUsed[e.path] {
+			findings = append(findings, Finding{
+				Range: e.rng,
+				Args:  []any{e.path},
+			})
+		}
+	}
+	return findings
+}
+
+func checkFlatNameCollisions(in *Input) []Finding {
+	palette := topLevelBlock(in.Body, "palette")
+	if palette == nil {
+		return nil
+	}
+	_, collisions := color.Flatten(in.Palette, "-")
+	var findings []Finding
+	for _, c := range collisions {
+		for _, path := range c.Paths[1:] {
+			findings = append(findings, Finding{
+				Range: paletteEntryRange(palette, path),
+				Args:  []any{"palette." + path, c.Name, "palette." + c.Paths[0]},
+			})
+		}
+	}
+	return findings
+}
+
+// paletteEntryRange returns the range of the name of the palette entry at
+// path, below the palette. A path the file does not declare, such as a
+// step a transform adds, gets the range of its nearest declared ancestor.
+func paletteEntryRange(palette *hclsyntax.Block, path string) hcl.Range {
+	rng := palette.DefRange()
+	body := palette.Body
+	for _, name := range strings.Split(path, ".") {
+		if attr, ok := body.Attributes[name]; ok {
+			return attr.NameRange
+		}
+		var next *hclsyntax.Block
+		for _, block := range body.Blocks {
+			switch {
+			case block.Type == "scale" && len(block.Labels) == 1 && block.Labels[0] == name:
+				return block.DefRange()
+			case block.Type == name && len(block.Labels) == 0:
+				next = block
+			}
+		}
+		if next == nil {
+			break
+		}
+		rng, body = next.DefRange(), next.Body
+	}
+	return rng
+}
@@ -1,13 +1,19 @@
 package lsp
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/lint"
+	"github.com/jsvensson/paletteswap/internal/metrics"
 	"github.com/jsvensson/paletteswap/internal/parser"
 	"github.com/jsvensson/paletteswap/internal/theme"
 	protocol "github.com/tliron/glsp/protocol_3_16"
@@ -27,6 +33,27 @@ type BlockType struct {
 	SupportsNesting bool     // theme, syntax, palette = true; ansi = false
 	SelfReferencing bool     // Can reference earlier items in same block
 	StrictNames     []string // For ANSI: only these names allowed
+	StrictNameKind  string   // Describes StrictNames in diagnostics
+	Variable        string   // Root name used in references, if different from Name
+}
+
+// RefName returns the root name expressions use to reference the block.
+func (b BlockType) RefName() string {
+	if b.Variable != "" {
+		return b.Variable
+	}
+	return b.Name
+}
+
+// blockTypeForRef returns the block type referenced by a traversal root name
+// (e.g. "palette" or "local").
+func blockTypeForRef(name string) (BlockType, bool) {
+	for _, bt := range BlockTypes {
+		if bt.RefName() == name {
+			return bt, true
+		}
+	}
+	return BlockType{}, false
 }
 
 // BlockTypes defines the configuration for each referenceable block
@@ -36,6 +63,12 @@ var BlockTypes = map[string]BlockType{
 		SupportsNesting: true,
 		SelfReferencing: true,
 	},
+	"locals": {
+		Name:            "locals",
+		SupportsNesting: false,
+		SelfReferencing: true,
+		Variable:        "local",
+	},
 	"theme": {
 		Name:            "theme",
 		SupportsNesting: true,
@@ -50,16 +83,43 @@ var BlockTypes = map[string]BlockType{
 		Name:            "ansi",
 		SupportsNesting: false,
 		SelfReferencing: false,
-		StrictNames:     theme.RequiredANSIColors,
+		StrictNames:     theme.ANSIColorNames,
+		StrictNameKind:  "ANSI color name",
+	},
+	"semantic": {
+		Name:            "semantic",
+		SupportsNesting: true, // style blocks only
+		SelfReferencing: false,
+		StrictNames:     theme.SemanticTokenTypes,
+		StrictNameKind:  "semantic token type",
 	},
 }
 
 // AnalysisResult holds all information produced by analyzing a theme file.
 type AnalysisResult struct {
 	Diagnostics []protocol.Diagnostic
+	Findings    []lint.Finding // lint findings, also reported in Diagnostics
 	Palette     *color.Node
 	Symbols     map[string]protocol.Range // "palette.base", "palette.highlight.low" -> definition range
+	Generated   map[string]bool           // symbols generated by the transform block, such as "palette.base.l1"
 	Colors      []ColorLocation
+	Locals      map[string]cty.Value // successfully evaluated locals, keyed by name
+	Blocks      map[string]bool      // top-level block types present in the file
+	// ANSIProfile is the ansi_profile setting, which selects the ANSI
+	// colors that are required.
+	ANSIProfile theme.ANSIProfile
+	// Transform is the palette's valid lightness transform, if any, and
+	// TransformRange the range of its transform block.
+	Transform      *parser.LightnessTransform
+	TransformRange protocol.Range
+	// Timings are the durations of the phases of the analysis: parsing,
+	// evaluating each top-level block, and linting.
+	Timings []metrics.Timing
+
+	limits theme.Limits
+	mapper *PositionMapper
+	body   *hclsyntax.Body                 // parsed file body, for the document outline and code actions
+	exprs  map[string]hclsyntax.Expression // value expression of each color symbol; groups by their color key
 }
 
 // ColorLocation records a resolved color at a specific source position.
@@ -69,37 +129,88 @@ type ColorLocation struct {
 	IsRef bool // true if this is a palette reference (not a hex literal)
 }
 
-// hclPosToLSP converts an HCL position to an LSP position.
-// HCL positions are 1-based; LSP positions are 0-based.
-func hclPosToLSP(pos hcl.Pos) protocol.Position {
-	return protocol.Position{
-		Line:      uint32(pos.Line - 1),
-		Character: uint32(pos.Column - 1),
-	}
+// recordSince adds the time elapsed since start to the timings of the
+// analysis.
+func (r *AnalysisResult) recordSince(phase, name string, start time.Time) {
+	r.Timings = append(r.Timings, metrics.Timing{Phase: phase, Name: name, Duration: time.Since(start)})
+}
+
+// lspRange converts an HCL range to an LSP range with UTF-16 columns.
+func (r *AnalysisResult) lspRange(rng hcl.Range) protocol.Range {
+	return r.mapper.Range(rng)
 }
 
-// hclRangeToLSP converts an HCL range to an LSP range.
-func hclRangeToLSP(r hcl.Range) protocol.Range {
+// colorRange returns the range a color location is reported at for expr.
+// A heredoc is narrowed to the color text inside it, so that editors put
+// the swatch on the value rather than on the <<EOT marker, and a color
+// picked there replaces only the value. Other expressions, including
+// function calls spanning several lines, keep their full range.
+func (r *AnalysisResult) colorRange(expr hclsyntax.Expression) protocol.Range {
+	rng := expr.Range()
+	tmpl, ok := expr.(*hclsyntax.TemplateExpr)
+	if !ok || !tmpl.IsStringLiteral() || rng.End.Byte > len(r.mapper.content) {
+		return r.lspRange(rng)
+	}
+	src := r.mapper.content[rng.Start.Byte:rng.End.Byte]
+	if !strings.HasPrefix(src, "<<") {
+		return r.lspRange(rng)
+	}
+	val, diags := tmpl.Value(nil)
+	if diags.HasErrors() {
+		return r.lspRange(rng)
+	}
+	text := strings.TrimSpace(val.AsString())
+	body := strings.IndexByte(src, '\n') + 1 // the value starts after the marker line
+	i := strings.Index(src[body:], text)
+	if text == "" || i < 0 {
+		return r.lspRange(rng)
+	}
+	start := rng.Start.Byte + body + i
 	return protocol.Range{
-		Start: hclPosToLSP(r.Start),
-		End:   hclPosToLSP(r.End),
+		Start: r.mapper.Position(start),
+		End:   r.mapper.Position(start + len(text)),
 	}
 }
 
 // Analyze parses HCL content from memory and produces diagnostics, a symbol table,
 // and color locations. It collects ALL errors rather than short-circuiting on the first.
+// It enforces theme.DefaultLimits.
 func Analyze(filename, content string) *AnalysisResult {
+	return AnalyzeWithLimits(filename, content, theme.DefaultLimits)
+}
+
+// AnalyzeWithLimits is like Analyze but enforces the given limits. Input that
+// exceeds a limit is reported as a diagnostic and analysis stops there.
+func AnalyzeWithLimits(filename, content string, limits theme.Limits) *AnalysisResult {
 	result := &AnalysisResult{
 		Symbols:     make(map[string]protocol.Range),
+		Generated:   make(map[string]bool),
+		Blocks:      make(map[string]bool),
+		ANSIProfile: theme.ANSIStandard16,
 		Diagnostics: []protocol.Diagnostic{}, // Initialize to empty slice, not nil
+		exprs:       make(map[string]hclsyntax.Expression),
+		limits:      limits,
+		mapper:      NewPositionMapper(content),
+	}
+
+	fileStart := hcl.Range{
+		Filename: filename,
+		Start:    hcl.Pos{Line: 1, Column: 1},
+		End:      hcl.Pos{Line: 1, Column: 1},
+	}
+	if err := limits.CheckFileSize(int64(len(content))); err != nil {
+		result.addError(fileStart, err.Error()+"; analysis skipped")
+		return result
 	}
 
 	// Parse HCL from string content
+	start := time.Now()
 	file, diags := hclsyntax.ParseConfig([]byte(content), filename, hcl.Pos{Line: 1, Column: 1})
+	result.recordSince(metrics.PhaseParse, filename, start)
 
 	// Convert HCL diagnostics, filtering out unhelpful ones during editing
 	for _, d := range diags {
-		if lspDiag := hclDiagToLSP(d); lspDiag != nil {
+		if lspDiag := result.hclDiagToLSP(d); lspDiag != nil {
 			result.Diagnostics = append(result.Diagnostics, *lspDiag)
 		}
 	}
@@ -114,79 +225,359 @@ func Analyze(filename, content string) *AnalysisResult {
 		result.addError(hcl.Range{}, "internal error: parsed body is not *hclsyntax.Body")
 		return result
 	}
+	result.body = body
+
+	// Blocks for the other appearance are left out, as the loader does.
+	// The outline and code actions still see the whole file.
+	body, diags = theme.ApplyWhen(body, theme.MetaAppearance(body))
+	for _, d := range diags {
+		if lspDiag := result.hclDiagToLSP(d); lspDiag != nil {
+			result.Diagnostics = append(result.Diagnostics, *lspDiag)
+		}
+	}
 
-	// Track blocks for processing
+	// Track blocks for processing. Syntax may be declared multiple times
+	// and is merged, so its bodies are collected separately.
 	blockBodies := make(map[string]*hclsyntax.Body)
 	blockRanges := make(map[string]hcl.Range)
+	var syntaxBodies []*hclsyntax.Body
+	var languageSyntaxBlocks []*hclsyntax.Block
 
-	// First pass: collect all blocks and store their locations
+	// First pass: collect all blocks and store their locations.
+	// Duplicates of unique blocks are reported on the later block and ignored.
+	seen := make(map[string]hcl.Range)
 	for _, block := range body.Blocks {
+		result.Blocks[block.Type] = true
+		if slices.Contains(theme.UniqueBlocks, block.Type) {
+			if first, dup := seen[block.Type]; dup {
+				result.addError(block.DefRange(), fmt.Sprintf("duplicate %s block; only one is allowed (first defined at line %d)",
+					block.Type, first.Start.Line))
+				continue
+			}
+			seen[block.Type] = block.DefRange()
+		}
+		if block.Type == "syntax" && len(block.Labels) > 0 {
+			if len(block.Labels) > 1 {
+				result.addError(block.DefRange(), "syntax block accepts at most one language label")
+				continue
+			}
+			languageSyntaxBlocks = append(languageSyntaxBlocks, block)
+			continue
+		}
+		if block.Type == "syntax" {
+			syntaxBodies = append(syntaxBodies, block.Body)
+			if len(syntaxBodies) > 1 {
+				continue
+			}
+		}
 		if _, exists := BlockTypes[block.Type]; exists {
 			blockBodies[block.Type] = block.Body
 			blockRanges[block.Type] = block.DefRange()
 			// Store block location in symbols
-			result.Symbols[block.Type] = hclRangeToLSP(block.DefRange())
+			result.Symbols[block.Type] = result.lspRange(block.DefRange())
 		}
 	}
 
+	if profile, rng, err := theme.ANSIProfileSetting(body); err != nil {
+		result.addError(rng, err.Error())
+	} else {
+		result.ANSIProfile = profile
+	}
+	if _, rng, err := theme.SchemaVersionSetting(body); err != nil {
+		result.addError(rng, err.Error())
+	}
+
 	// Check for required palette block
 	if _, hasPalette := blockBodies["palette"]; !hasPalette {
-		result.addError(hcl.Range{
-			Filename: filename,
-			Start:    hcl.Pos{Line: 1, Column: 1},
-			End:      hcl.Pos{Line: 1, Column: 1},
-		}, "missing required palette block")
+		result.addError(fileStart, "missing required palette block")
 		return result
 	}
+	if _, hasANSI := blockBodies["ansi"]; !hasANSI {
+		result.addError(fileStart, "missing required ansi block")
+	}
 
 	// Build initial eval context with functions
 	ctx := &hcl.EvalContext{
 		Variables: make(map[string]cty.Value),
 		Functions: map[string]function.Function{
-			"brighten": theme.MakeBrightenFunc(),
-			"darken":   theme.MakeDarkenFunc(),
+			"brighten":   theme.MakeBrightenFunc(),
+			"darken":     theme.MakeDarkenFunc(),
+			"lighten_ok": theme.MakeLightenOKFunc(),
+			"darken_ok":  theme.MakeDarkenOKFunc(),
+			"saturate":   theme.MakeSaturateFunc(),
+			"desaturate": theme.MakeDesaturateFunc(),
+			"rotate_hue": theme.MakeRotateHueFunc(),
+			"complement": theme.MakeComplementFunc(),
+			"invert":     theme.MakeInvertFunc(),
+			"mix":        theme.MakeMixFunc(),
+			"shade":      theme.MakeShadeFunc(),
+			"tint":       theme.MakeTintFunc(),
+			"gray":       theme.MakeGrayFunc(),
+			"hsl":        theme.MakeHSLFunc(),
+			"rgb":        theme.MakeRGBFunc(),
 		},
 	}
 
 	// Process palette first (required and may be referenced by others)
 	if paletteBody, ok := blockBodies["palette"]; ok {
+		if err := limits.CheckPaletteEntries(paletteBody); err != nil {
+			result.addError(blockRanges["palette"], err.Error()+"; analysis skipped")
+			return result
+		}
+
+		start := time.Now()
 		palette, _ := result.analyzeBlock(paletteBody, BlockTypes["palette"], ctx, "palette", nil)
 		result.Palette = palette
 
 		// Apply lightness transform if present
-		transform, err := parser.ParseTransformBlock(paletteBody)
-		if err != nil {
-			result.addError(hcl.Range{Filename: filename}, err.Error())
-		} else if transform != nil {
-			color.ApplyLightnessSteps(palette, transform.Low, transform.High, transform.Steps)
-		}
+		result.analyzeTransform(paletteBody, palette)
 
 		ctx.Variables["palette"] = theme.NodeToCty(palette)
+		result.recordSince(metrics.PhaseEval, "palette", start)
+	}
+
+	// Process locals (can reference palette and earlier locals)
+	result.Locals = make(map[string]cty.Value)
+	if localsBody, ok := blockBodies["locals"]; ok {
+		start := time.Now()
+		result.analyzeLocals(localsBody, ctx)
+		result.recordSince(metrics.PhaseEval, "locals", start)
 	}
+	ctx.Variables["local"] = cty.ObjectVal(result.Locals)
 
 	// Process theme (self-referencing, can reference palette)
+	themeColors := make(map[string]color.Color)
 	if themeBody, ok := blockBodies["theme"]; ok {
+		start := time.Now()
 		themeNode, _ := result.analyzeBlock(themeBody, BlockTypes["theme"], ctx, "theme", nil)
+		result.validateThemeSubBlocks(themeBody)
 		ctx.Variables["theme"] = theme.NodeToCty(themeNode)
+		for name, child := range themeNode.Children {
+			if child.Color != nil {
+				themeColors[name] = *child.Color
+			}
+		}
+		result.recordSince(metrics.PhaseEval, "theme", start)
 	}
 
-	// Process ansi (strict names, can reference palette/theme)
+	// Process ansi (strict names, can reference palette/theme). Missing
+	// colors are reported by the missing-ansi lint rule, whose severity
+	// also decides whether the theme loads.
 	if ansiBody, ok := blockBodies["ansi"]; ok {
-		_, ansiResolved := result.analyzeBlock(ansiBody, BlockTypes["ansi"], ctx, "ansi", nil)
-		result.validateANSICompleteness(ansiResolved, blockRanges["ansi"], filename)
+		start := time.Now()
+		ansiNode, _ := result.analyzeBlock(ansiBody, BlockTypes["ansi"], ctx, "ansi", nil)
+		ctx.Variables["ansi"] = theme.NodeToCty(ansiNode)
+		result.recordSince(metrics.PhaseEval, "ansi", start)
 	}
 
-	// Process syntax (self-referencing, can reference all others)
-	if syntaxBody, ok := blockBodies["syntax"]; ok {
-		_, _ = result.analyzeBlock(syntaxBody, BlockTypes["syntax"], ctx, "syntax", nil)
+	// Process syntax (self-referencing, can reference all others).
+	// All syntax blocks populate the same root node so they merge.
+	start = time.Now()
+	syntaxNode := &color.Node{}
+	for _, syntaxBody := range syntaxBodies {
+		_, _ = result.analyzeBlock(syntaxBody, BlockTypes["syntax"], ctx, "syntax", &blockNesting{
+			RootName:   "syntax",
+			RootNode:   syntaxNode,
+			TargetNode: syntaxNode,
+		})
+	}
+	ctx.Variables["syntax"] = theme.NodeToCty(syntaxNode)
+
+	// Process language-scoped syntax blocks. They may reference the merged
+	// base syntax but not each other, so they are not self-referencing.
+	languageType := BlockTypes["syntax"]
+	languageType.SelfReferencing = false
+	languageNodes := make(map[string]*color.Node)
+	for _, block := range languageSyntaxBlocks {
+		lang := block.Labels[0]
+		node, ok := languageNodes[lang]
+		if !ok {
+			node = &color.Node{}
+			languageNodes[lang] = node
+		}
+		_, _ = result.analyzeBlock(block.Body, languageType, ctx, fmt.Sprintf("syntax[%q]", lang), &blockNesting{
+			RootName:   "syntax",
+			RootNode:   node,
+			TargetNode: node,
+		})
 	}
+	if len(syntaxBodies) > 0 || len(languageSyntaxBlocks) > 0 {
+		result.recordSince(metrics.PhaseEval, "syntax", start)
+	}
+
+	// Process semantic (strict token type names, can reference all others)
+	if semanticBody, ok := blockBodies["semantic"]; ok {
+		start := time.Now()
+		_, _ = result.analyzeBlock(semanticBody, BlockTypes["semantic"], ctx, "semantic", nil)
+		result.recordSince(metrics.PhaseEval, "semantic", start)
+	}
+
+	if localsBody, ok := blockBodies["locals"]; ok {
+		result.checkUnusedLocals(body, localsBody)
+	}
+
+	result.checkSpec(body)
+	start = time.Now()
+	result.lint(body, themeColors)
+	result.recordSince(metrics.PhaseLint, "", start)
 
 	return result
 }
 
+// checkSpec checks the file against its spec_version. Constructs the
+// declared version rejects are errors; those only a later version rejects
+// are warnings carrying the migration hint.
+func (r *AnalysisResult) checkSpec(body *hclsyntax.Body) {
+	version, rng, err := theme.SpecVersion(body)
+	if err != nil {
+		r.addError(rng, err.Error())
+		return
+	}
+	for _, issue := range theme.CheckSpec(body) {
+		if version >= issue.Removed {
+			r.addError(issue.Range, issue.String())
+		} else {
+			r.addWarning(issue.Range, issue.String())
+		}
+	}
+}
+
+// lint runs the lint rules with the severities configured in the settings
+// block, reporting each finding with its rule ID as the diagnostic code and
+// its message in the locale setting. The message arguments are sent as the
+// diagnostic data, {"args": [...]}, so that editor extensions can translate
+// messages themselves.
+func (r *AnalysisResult) lint(body *hclsyntax.Body, themeColors map[string]color.Color) {
+	cfg, diags := lint.ParseConfig(body)
+	locale := lint.LocaleSetting(body)
+	for _, d := range diags {
+		if diag := r.hclDiagToLSP(d); diag != nil {
+			r.Diagnostics = append(r.Diagnostics, *diag)
+		}
+	}
+
+	r.Findings = lint.Run(&lint.Input{Body: body, Theme: themeColors, Palette: r.Palette}, cfg)
+	for _, f := range r.Findings {
+		sev := DiagInfo
+		switch f.Severity {
+		case lint.SeverityWarning:
+			sev = DiagWarning
+		case lint.SeverityError:
+			sev = DiagError
+		}
+		r.Diagnostics = append(r.Diagnostics, protocol.Diagnostic{
+			Range:    r.lspRange(f.Range),
+			Severity: &sev,
+			Code:     &protocol.IntegerOrString{Value: f.RuleID},
+			Source:   strPtr("pstheme"),
+			Message:  f.Localize(locale),
+			Data:     map[string]any{"args": f.Args},
+		})
+	}
+}
+
+// analyzeLocals evaluates the locals block in source order, recording a symbol
+// for each local and a color location for locals that resolve to colors.
+func (r *AnalysisResult) analyzeLocals(body *hclsyntax.Body, parentCtx *hcl.EvalContext) {
+	for _, block := range body.Blocks {
+		r.addError(block.DefRange(), "locals block does not support nesting")
+	}
+
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte
+	})
+
+	for _, attr := range attrs {
+		symbolName := "local." + attr.Name
+		r.Symbols[symbolName] = r.lspRange(attr.SrcRange)
+
+		if rng, err := theme.CheckReferenceOrder("locals", attr.Expr); err != nil {
+			r.addError(rng, err.Error())
+			continue
+		}
+
+		ctx := parentCtx.NewChild()
+		ctx.Variables = map[string]cty.Value{"local": cty.ObjectVal(r.Locals)}
+
+		val, diags := attr.Expr.Value(ctx)
+		if diags.HasErrors() {
+			errStr := diags.Error()
+			if strings.Contains(errStr, "Invalid attribute name") {
+				continue
+			}
+			r.addError(evalErrorRange(diags, attr.Expr, attr.SrcRange), fmt.Sprintf("%s: %s", symbolName, errStr))
+			continue
+		}
+		r.Locals[attr.Name] = val
+
+		// Locals may hold any value; only colors get a swatch.
+		hexStr, err := theme.ResolveColor(val)
+		if err != nil {
+			continue
+		}
+		c, err := color.ParseHex(hexStr)
+		if err != nil {
+			continue
+		}
+		r.addColor(attr.Expr, c)
+	}
+}
+
+// checkUnusedLocals warns about locals that are never referenced anywhere in
+// the file, including from other locals.
+func (r *AnalysisResult) checkUnusedLocals(file *hclsyntax.Body, localsBody *hclsyntax.Body) {
+	used := make(map[string]bool)
+	_ = hclsyntax.VisitAll(file, func(node hclsyntax.Node) hcl.Diagnostics {
+		expr, ok := node.(*hclsyntax.ScopeTraversalExpr)
+		if !ok || len(expr.Traversal) < 2 || expr.Traversal.RootName() != "local" {
+			return nil
+		}
+		if attr, ok := expr.Traversal[1].(hcl.TraverseAttr); ok {
+			used[attr.Name] = true
+		}
+		return nil
+	})
+
+	for name, attr := range localsBody.Attributes {
+		if !used[name] {
+			r.addWarning(attr.NameRange, fmt.Sprintf("local.%s is declared but never used", name))
+		}
+	}
+}
+
+// evalErrorRange returns the range to report evaluation errors of expr at:
+// the whole argument a function rejected, such as the percentage of
+// brighten(), or fallback for any other error.
+func evalErrorRange(diags hcl.Diagnostics, expr hclsyntax.Expression, fallback hcl.Range) hcl.Range {
+	for _, d := range diags {
+		if d.Severity != hcl.DiagError || d.Summary != "Invalid function argument" || d.Subject == nil {
+			continue
+		}
+		// HCL reports where the argument starts, e.g. just the minus sign
+		// of -0.2; widen that to the argument expression.
+		rng := *d.Subject
+		_ = hclsyntax.VisitAll(expr, func(node hclsyntax.Node) hcl.Diagnostics {
+			if call, ok := node.(*hclsyntax.FunctionCallExpr); ok {
+				for _, arg := range call.Args {
+					if arg.StartRange().Start.Byte == d.Subject.Start.Byte {
+						rng = arg.Range()
+					}
+				}
+			}
+			return nil
+		})
+		return rng
+	}
+	return fallback
+}
+
 // hclDiagToLSP converts an HCL diagnostic to an LSP diagnostic.
 // Returns nil if the diagnostic should be filtered out (e.g., unhelpful editing errors).
-func hclDiagToLSP(d *hcl.Diagnostic) *protocol.Diagnostic {
+func (r *AnalysisResult) hclDiagToLSP(d *hcl.Diagnostic) *protocol.Diagnostic {
 	// Filter out "Invalid attribute name" errors during editing
 	// These occur when user types "palette." and hasn't typed the attribute yet
 	if d.Summary == "Invalid attribute name" && strings.Contains(d.Detail, "required after a dot") {
@@ -209,7 +600,7 @@ func hclDiagToLSP(d *hcl.Diagnostic) *protocol.Diagnostic {
 	}
 
 	if d.Subject != nil {
-		diag.Range = hclRangeToLSP(*d.Subject)
+		diag.Range = r.lspRange(*d.Subject)
 	}
 
 	return &diag
@@ -218,7 +609,7 @@ func hclDiagToLSP(d *hcl.Diagnostic) *protocol.Diagnostic {
 // addError adds an error-level diagnostic at the given range.
 func (r *AnalysisResult) addError(rng hcl.Range, msg string) {
 	r.Diagnostics = append(r.Diagnostics, protocol.Diagnostic{
-		Range:    hclRangeToLSP(rng),
+		Range:    r.lspRange(rng),
 		Severity: &DiagError,
 		Source:   strPtr("pstheme"),
 		Message:  msg,
@@ -228,7 +619,7 @@ func (r *AnalysisResult) addError(rng hcl.Range, msg string) {
 // addWarning adds a warning-level diagnostic at the given range.
 func (r *AnalysisResult) addWarning(rng hcl.Range, msg string) {
 	r.Diagnostics = append(r.Diagnostics, protocol.Diagnostic{
-		Range:    hclRangeToLSP(rng),
+		Range:    r.lspRange(rng),
 		Severity: &DiagWarning,
 		Source:   strPtr("pstheme"),
 		Message:  msg,
@@ -274,17 +665,17 @@ func (r *AnalysisResult) analyzePaletteBody(body *hclsyntax.Body, paletteRoot *c
 			symbolName := prefix + "." + attrName
 
 			// Record symbol for non-"color" attributes
-			if attrName != "color" {
-				r.Symbols[symbolName] = hclRangeToLSP(item.attr.SrcRange)
+			if attrName != color.ColorKey {
+				r.Symbols[symbolName] = r.lspRange(item.attr.SrcRange)
 			}
 
 			val, diags := item.attr.Expr.Value(ctx)
 			if diags.HasErrors() {
-				r.addError(item.attr.SrcRange, fmt.Sprintf("evaluating %s: %s", symbolName, diags.Error()))
+				r.addError(evalErrorRange(diags, item.attr.Expr, item.attr.SrcRange), fmt.Sprintf("evaluating %s: %s", symbolName, diags.Error()))
 				continue
 			}
 
-			hexStr, err := theme.ResolveColor(val)
+			hexStr, err := theme.ResolveColorExpr(item.attr.Expr, val)
 			if err != nil {
 				r.addError(item.attr.SrcRange, fmt.Sprintf("%s: %s", symbolName, err.Error()))
 				continue
@@ -297,28 +688,17 @@ func (r *AnalysisResult) analyzePaletteBody(body *hclsyntax.Body, paletteRoot *c
 			}
 
 			// Record color location
-			isRef := isReferenceExpr(item.attr.Expr)
-			r.Colors = append(r.Colors, ColorLocation{
-				Range: hclRangeToLSP(item.attr.Expr.Range()),
-				Color: c,
-				IsRef: isRef,
-			})
-
-			if attrName == "color" {
+			r.addColor(item.attr.Expr, c)
+
+			if attrName == color.ColorKey {
 				node.Color = &c
 			} else {
-				if node.Children == nil {
-					node.Children = make(map[string]*color.Node)
-				}
-				node.Children[attrName] = &color.Node{Color: &c}
+				node.SetChild(attrName, &color.Node{Color: &c})
 			}
 		} else {
 			// Block: recurse
-			if node.Children == nil {
-				node.Children = make(map[string]*color.Node)
-			}
 			child := &color.Node{}
-			node.Children[item.block.Type] = child
+			node.SetChild(item.block.Type, child)
 			r.analyzePaletteBody(item.block.Body, paletteRoot, child, prefix+"."+item.block.Type)
 		}
 	}
@@ -338,11 +718,11 @@ func (r *AnalysisResult) analyzeColorBlock(body *hclsyntax.Body, ctx *hcl.EvalCo
 			if strings.Contains(errStr, "Invalid attribute name") {
 				continue
 			}
-			r.addError(attr.SrcRange, fmt.Sprintf("%s.%s: %s", blockName, attr.Name, errStr))
+			r.addError(evalErrorRange(diags, attr.Expr, attr.SrcRange), fmt.Sprintf("%s.%s: %s", blockName, attr.Name, errStr))
 			continue
 		}
 
-		hexStr, err := theme.ResolveColor(val)
+		hexStr, err := theme.ResolveColorExpr(attr.Expr, val)
 		if err != nil {
 			r.addError(attr.SrcRange, fmt.Sprintf("%s.%s: %s", blockName, attr.Name, err.Error()))
 			continue
@@ -354,12 +734,7 @@ func (r *AnalysisResult) analyzeColorBlock(body *hclsyntax.Body, ctx *hcl.EvalCo
 			continue
 		}
 
-		isRef := isReferenceExpr(attr.Expr)
-		r.Colors = append(r.Colors, ColorLocation{
-			Range: hclRangeToLSP(attr.Expr.Range()),
-			Color: c,
-			IsRef: isRef,
-		})
+		r.addColor(attr.Expr, c)
 
 		resolved[attr.Name] = true
 	}
@@ -374,7 +749,7 @@ func (r *AnalysisResult) analyzeSyntaxBody(body *hclsyntax.Body, ctx *hcl.EvalCo
 	for _, attr := range body.Attributes {
 		val, diags := attr.Expr.Value(ctx)
 		if diags.HasErrors() {
-			r.addError(attr.SrcRange, fmt.Sprintf("%s.%s: %s", prefix, attr.Name, diags.Error()))
+			r.addError(evalErrorRange(diags, attr.Expr, attr.SrcRange), fmt.Sprintf("%s.%s: %s", prefix, attr.Name, diags.Error()))
 			continue
 		}
 
@@ -384,7 +759,7 @@ func (r *AnalysisResult) analyzeSyntaxBody(body *hclsyntax.Body, ctx *hcl.EvalCo
 			continue
 		}
 
-		hexStr, err := theme.ResolveColor(val)
+		hexStr, err := theme.ResolveColorExpr(attr.Expr, val)
 		if err != nil {
 			r.addError(attr.SrcRange, fmt.Sprintf("%s.%s: %s", prefix, attr.Name, err.Error()))
 			continue
@@ -396,12 +771,7 @@ func (r *AnalysisResult) analyzeSyntaxBody(body *hclsyntax.Body, ctx *hcl.EvalCo
 			continue
 		}
 
-		isRef := isReferenceExpr(attr.Expr)
-		r.Colors = append(r.Colors, ColorLocation{
-			Range: hclRangeToLSP(attr.Expr.Range()),
-			Color: c,
-			IsRef: isRef,
-		})
+		r.addColor(attr.Expr, c)
 	}
 
 	// Recurse into nested blocks
@@ -410,33 +780,148 @@ func (r *AnalysisResult) analyzeSyntaxBody(body *hclsyntax.Body, ctx *hcl.EvalCo
 	}
 }
 
-// validateANSICompleteness checks that all 16 required ANSI colors are present
-// and emits warning diagnostics for any missing ones.
-func (r *AnalysisResult) validateANSICompleteness(resolved map[string]bool, blockRange hcl.Range, filename string) {
-	var missing []string
-	for _, name := range theme.RequiredANSIColors {
-		if !resolved[name] {
-			missing = append(missing, name)
+// validateThemeSubBlocks checks the typed theme sub-blocks (cursor, selection)
+// for unknown, missing and conflicting attributes.
+func (r *AnalysisResult) validateThemeSubBlocks(body *hclsyntax.Body) {
+	for _, block := range body.Blocks {
+		required, ok := theme.ThemeSubBlocks[block.Type]
+		if !ok {
+			continue
 		}
+
+		if _, conflict := body.Attributes[block.Type]; conflict {
+			r.addError(block.DefRange(), fmt.Sprintf("theme.%s is defined both as an attribute and as a block", block.Type))
+		}
+
+		for name, attr := range block.Body.Attributes {
+			if !slices.Contains(required, name) {
+				r.addError(attr.SrcRange, fmt.Sprintf("theme.%s.%s is not a valid attribute (valid: %s)",
+					block.Type, name, strings.Join(required, ", ")))
+			}
+		}
+		for _, nested := range block.Body.Blocks {
+			r.addError(nested.DefRange(), fmt.Sprintf("theme.%s block does not support nesting", block.Type))
+		}
+
+		var missing []string
+		for _, name := range required {
+			if _, ok := block.Body.Attributes[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			r.addError(block.DefRange(), fmt.Sprintf("theme.%s block missing attributes: %s", block.Type, strings.Join(missing, ", ")))
+		}
+	}
+}
+
+// analyzeTransform validates the palette's transform block, reporting errors
+// at the attribute or block at fault, and applies its lightness steps to
+// palette.
+func (r *AnalysisResult) analyzeTransform(body *hclsyntax.Body, palette *color.Node) {
+	transform, err := parser.ParseTransformBlock(body)
+	var te *parser.TransformError
+	switch {
+	case errors.As(err, &te):
+		r.addError(te.Range, te.Msg)
+		return
+	case err != nil:
+		r.addError(body.SrcRange, err.Error())
+		return
+	case transform == nil:
+		return
+	}
+
+	var block *hclsyntax.Block
+	for _, b := range body.Blocks {
+		if b.Type == "transform" {
+			block = b
+			break
+		}
+	}
+	if err := r.limits.CheckTransformSteps(transform.Steps); err != nil {
+		r.addError(block.DefRange(), err.Error())
+		return
+	}
+	leaves := steppedPaths(palette, "palette", nil)
+	color.ApplyLightnessSteps(palette, transform.Low, transform.High, transform.Steps)
+	r.Transform = transform
+	r.TransformRange = r.lspRange(block.Range())
+
+	// The generated steps are defined by the transform block.
+	defRange := r.lspRange(block.DefRange())
+	for _, path := range leaves {
+		for i := range transform.Steps {
+			step := fmt.Sprintf("%s.l%d", path, i+1)
+			r.Symbols[step] = defRange
+			r.Generated[step] = true
+		}
+	}
+}
+
+// steppedPaths appends to paths the path of every color below node that a
+// lightness transform gives steps to: those without children.
+func steppedPaths(node *color.Node, path string, paths []string) []string {
+	if node.Children == nil {
+		if node.Color != nil {
+			paths = append(paths, path)
+		}
+		return paths
+	}
+	for _, name := range node.Names() {
+		paths = steppedPaths(node.Children[name], path+"."+name, paths)
 	}
+	return paths
+}
 
-	if len(missing) > 0 {
-		rng := blockRange
-		if rng.Filename == "" {
-			rng = hcl.Range{
-				Filename: filename,
-				Start:    hcl.Pos{Line: 1, Column: 1},
-				End:      hcl.Pos{Line: 1, Column: 1},
+// addColor records a swatch for the color c of expr, and one for every hex
+// literal passed to a function within expr, such as the
+// "#403d52" of darken("#403d52", 0.2), so editors can show and pick the
+// argument as well as the computed color.
+func (r *AnalysisResult) addColor(expr hclsyntax.Expression, c color.Color) {
+	r.Colors = append(r.Colors, ColorLocation{
+		Range: r.colorRange(expr),
+		Color: c,
+		IsRef: isReferenceExpr(expr),
+	})
+	_ = hclsyntax.VisitAll(expr, func(node hclsyntax.Node) hcl.Diagnostics {
+		call, ok := node.(*hclsyntax.FunctionCallExpr)
+		if !ok {
+			return nil
+		}
+		for _, arg := range call.Args {
+			if c, ok := literalColor(arg); ok {
+				r.Colors = append(r.Colors, ColorLocation{Range: r.colorRange(arg), Color: c})
 			}
 		}
-		r.addWarning(rng, fmt.Sprintf("ANSI block missing colors: %s", strings.Join(missing, ", ")))
+		return nil
+	})
+}
+
+// literalColor returns the color of a hex string literal.
+func literalColor(expr hclsyntax.Expression) (color.Color, bool) {
+	tmpl, ok := expr.(*hclsyntax.TemplateExpr)
+	if !ok || !tmpl.IsStringLiteral() {
+		return color.Color{}, false
+	}
+	val, diags := tmpl.Value(nil)
+	if diags.HasErrors() {
+		return color.Color{}, false
 	}
+	hexStr, err := theme.ResolveColor(val)
+	if err != nil {
+		return color.Color{}, false
+	}
+	c, err := color.ParseHex(hexStr)
+	return c, err == nil
 }
 
 // isReferenceExpr returns true if the expression is a scope traversal
 // (e.g. palette.base) rather than a literal value.
 func isReferenceExpr(expr hclsyntax.Expression) bool {
-	switch expr.(type) {
+	switch e := expr.(type) {
+	case *hclsyntax.ParenthesesExpr:
+		return isReferenceExpr(e.Expression)
 	case *hclsyntax.ScopeTraversalExpr:
 		return true
 	case *hclsyntax.RelativeTraversalExpr:
@@ -446,27 +931,6 @@ func isReferenceExpr(expr hclsyntax.Expression) bool {
 	}
 }
 
-// checkExplicitPaletteColor warns when an expression explicitly references .color
-// on a palette path, since the color is implicit on the parent node.
-func (r *AnalysisResult) checkExplicitPaletteColor(expr hclsyntax.Expression) {
-	st, ok := expr.(*hclsyntax.ScopeTraversalExpr)
-	if !ok || len(st.Traversal) < 3 {
-		return
-	}
-
-	root, ok := st.Traversal[0].(hcl.TraverseRoot)
-	if !ok || root.Name != "palette" {
-		return
-	}
-
-	last, ok := st.Traversal[len(st.Traversal)-1].(hcl.TraverseAttr)
-	if !ok || last.Name != "color" {
-		return
-	}
-
-	r.addWarning(last.SrcRange, "color is implicit; use palette path without .color")
-}
-
 // blockItem represents an attribute or block in source order.
 type blockItem struct {
 	pos   hcl.Pos
@@ -494,14 +958,10 @@ type BlockContext struct {
 	Items     []blockItem
 }
 
-// isValidANSIName checks if a name is in the list of valid ANSI colors
-func isValidANSIName(name string) bool {
-	for _, valid := range theme.RequiredANSIColors {
-		if name == valid {
-			return true
-		}
-	}
-	return false
+// canonicalPath strips a trailing reserved color key from a dotted reference,
+// so "palette.highlight.color" resolves to the "palette.highlight" entry.
+func canonicalPath(path string) string {
+	return strings.TrimSuffix(path, "."+color.ColorKey)
 }
 
 // hasCircularReference checks if an expression references something not yet defined
@@ -518,7 +978,7 @@ func (r *AnalysisResult) hasCircularReference(expr hclsyntax.Expression, current
 						parts = append(parts, attr.Name)
 					}
 				}
-				refPath := strings.Join(parts, ".")
+				refPath := canonicalPath(strings.Join(parts, "."))
 
 				// Check if referencing current block with path not yet defined
 				if strings.HasPrefix(refPath, currentPrefix+".") {
@@ -570,15 +1030,16 @@ func (r *AnalysisResult) analyzeBlock(body *hclsyntax.Body, blockType BlockType,
 		Items:     []blockItem{},
 	}
 
+	// Strict names apply to the top level of the block only; nested blocks
+	// are style blocks whose attributes are validated elsewhere.
+	strict := blockType.StrictNames != nil && nesting == nil
+
 	// Collect items
 	for _, attr := range body.Attributes {
-		// Validate ANSI names if strict
-		if blockType.StrictNames != nil {
-			if !isValidANSIName(attr.Name) {
-				r.addError(attr.SrcRange,
-					fmt.Sprintf("ansi.%s is not a valid ANSI color name", attr.Name))
-				continue
-			}
+		if strict && !slices.Contains(blockType.StrictNames, attr.Name) {
+			r.addError(attr.SrcRange,
+				fmt.Sprintf("%s.%s is not a valid %s", blockType.Name, attr.Name, blockType.StrictNameKind))
+			continue
 		}
 		ctx.Items = append(ctx.Items, blockItem{pos: attr.SrcRange.Start, attr: attr})
 	}
@@ -592,6 +1053,11 @@ func (r *AnalysisResult) analyzeBlock(body *hclsyntax.Body, blockType BlockType,
 				fmt.Sprintf("%s block does not support nesting", blockType.Name))
 			continue
 		}
+		if strict && !slices.Contains(blockType.StrictNames, block.Type) {
+			r.addError(block.DefRange(),
+				fmt.Sprintf("%s.%s is not a valid %s", blockType.Name, block.Type, blockType.StrictNameKind))
+			continue
+		}
 		ctx.Items = append(ctx.Items, blockItem{pos: block.DefRange().Start, block: block})
 	}
 
@@ -618,6 +1084,8 @@ func (r *AnalysisResult) analyzeBlock(body *hclsyntax.Body, blockType BlockType,
 
 		if item.attr != nil {
 			r.processBlockAttribute(item.attr, ctx, currentCtx, prefix, resolved)
+		} else if blockType.Name == "palette" && parser.IsScaleBlock(item.block) {
+			r.processScaleBlock(item.block, ctx, currentCtx, prefix, resolved)
 		} else {
 			r.processBlockNestedBlock(item.block, ctx, currentCtx, prefix, resolved)
 		}
@@ -638,6 +1106,11 @@ func (r *AnalysisResult) processBlockAttribute(attr *hclsyntax.Attribute,
 		return
 	}
 
+	if rng, err := theme.CheckReferenceOrder(ctx.BlockType.Name, attr.Expr); err != nil {
+		r.addError(rng, err.Error())
+		return
+	}
+
 	val, diags := attr.Expr.Value(evalCtx)
 	if diags.HasErrors() {
 		errStr := diags.Error()
@@ -646,19 +1119,19 @@ func (r *AnalysisResult) processBlockAttribute(attr *hclsyntax.Attribute,
 		if strings.Contains(errStr, "Invalid attribute name") {
 			return
 		}
-		r.addError(attr.SrcRange, fmt.Sprintf("%s: %s", symbolName, errStr))
+		r.addError(evalErrorRange(diags, attr.Expr, attr.SrcRange), fmt.Sprintf("%s: %s", symbolName, errStr))
 		return
 	}
 
 	// Handle boolean attributes (bold, italic, underline in syntax)
 	if val.Type() == cty.Bool {
-		ctx.Symbols[symbolName] = hclRangeToLSP(attr.SrcRange)
-		r.Symbols[symbolName] = hclRangeToLSP(attr.SrcRange)
+		ctx.Symbols[symbolName] = r.lspRange(attr.SrcRange)
+		r.Symbols[symbolName] = r.lspRange(attr.SrcRange)
 		resolved[attr.Name] = true
 		return
 	}
 
-	hexStr, err := theme.ResolveColor(val)
+	hexStr, err := theme.ResolveColorExpr(attr.Expr, val)
 	if err != nil {
 		r.addError(attr.SrcRange, fmt.Sprintf("%s: %s", symbolName, err.Error()))
 		return
@@ -671,29 +1144,19 @@ func (r *AnalysisResult) processBlockAttribute(attr *hclsyntax.Attribute,
 	}
 
 	// Record color location
-	isRef := isReferenceExpr(attr.Expr)
-	r.Colors = append(r.Colors, ColorLocation{
-		Range: hclRangeToLSP(attr.Expr.Range()),
-		Color: c,
-		IsRef: isRef,
-	})
-
-	// Warn when explicitly referencing .color on a palette path — the color is implicit
-	r.checkExplicitPaletteColor(attr.Expr)
-
-	// Store symbol
-	ctx.Symbols[symbolName] = hclRangeToLSP(attr.SrcRange)
-	r.Symbols[symbolName] = hclRangeToLSP(attr.SrcRange)
+	r.addColor(attr.Expr, c)
 
 	// Update node tree — "color" is a reserved keyword that sets the node's
-	// own color rather than creating a child entry.
-	if attr.Name == "color" && ctx.BlockType.SupportsNesting {
+	// own color rather than creating a child entry, so it gets no symbol of
+	// its own: the enclosing block's symbol stands for it.
+	if attr.Name == color.ColorKey && ctx.BlockType.SupportsNesting {
 		ctx.Node.Color = &c
+		r.exprs[prefix] = attr.Expr
 	} else {
-		if ctx.Node.Children == nil {
-			ctx.Node.Children = make(map[string]*color.Node)
-		}
-		ctx.Node.Children[attr.Name] = &color.Node{Color: &c}
+		r.exprs[symbolName] = attr.Expr
+		ctx.Symbols[symbolName] = r.lspRange(attr.SrcRange)
+		r.Symbols[symbolName] = r.lspRange(attr.SrcRange)
+		ctx.Node.SetChild(attr.Name, &color.Node{Color: &c})
 	}
 
 	resolved[attr.Name] = true
@@ -706,17 +1169,18 @@ func (r *AnalysisResult) processBlockNestedBlock(block *hclsyntax.Block,
 	childPrefix := prefix + "." + block.Type
 
 	// Store nested block symbol
-	ctx.Symbols[childPrefix] = hclRangeToLSP(block.DefRange())
-	r.Symbols[childPrefix] = hclRangeToLSP(block.DefRange())
+	ctx.Symbols[childPrefix] = r.lspRange(block.DefRange())
+	r.Symbols[childPrefix] = r.lspRange(block.DefRange())
 
 	// Pre-attach child node to parent so the root tree includes it
 	// during recursive analysis. This allows self-references like
 	// palette.highlight.mid to resolve when building the eval context.
-	if ctx.Node.Children == nil {
-		ctx.Node.Children = make(map[string]*color.Node)
+	// Reuse an existing child so repeated blocks merge.
+	childNode, ok := ctx.Node.Children[block.Type]
+	if !ok || childNode.Children == nil {
+		childNode = &color.Node{}
+		ctx.Node.SetChild(block.Type, childNode)
 	}
-	childNode := &color.Node{}
-	ctx.Node.Children[block.Type] = childNode
 
 	// Recursively analyze nested block, using the pre-attached childNode
 	// and threading root context so buildBlockEvalContext updates the
@@ -728,6 +1192,43 @@ func (r *AnalysisResult) processBlockNestedBlock(block *hclsyntax.Block,
 	})
 }
 
+// processScaleBlock evaluates a palette scale "name" { ... } block, adding
+// the generated name.1..name.N entries to the palette.
+func (r *AnalysisResult) processScaleBlock(block *hclsyntax.Block,
+	ctx *BlockContext, evalCtx *hcl.EvalContext, prefix string, resolved map[string]bool) {
+
+	name := block.Labels[0]
+	symbolName := prefix + "." + name
+	defRange := r.lspRange(block.DefRange())
+	ctx.Symbols[symbolName] = defRange
+	r.Symbols[symbolName] = defRange
+
+	node, err := parser.ParseScaleBlock(block, evalCtx, r.limits)
+	if err != nil {
+		r.addError(block.DefRange(), err.Error())
+		return
+	}
+
+	for step, child := range node.Children {
+		ctx.Symbols[symbolName+"."+step] = defRange
+		r.Symbols[symbolName+"."+step] = defRange
+		// Swatches for the endpoints
+		var attr *hclsyntax.Attribute
+		switch step {
+		case "1":
+			attr = block.Body.Attributes["from"]
+		case strconv.Itoa(len(node.Children)):
+			attr = block.Body.Attributes["to"]
+		}
+		if attr != nil {
+			r.addColor(attr.Expr, *child.Color)
+		}
+	}
+
+	ctx.Node.SetChild(name, node)
+	resolved[name] = true
+}
+
 // buildBlockEvalContext rebuilds eval context with current block state
 func (r *AnalysisResult) buildBlockEvalContext(parentCtx *hcl.EvalContext,
 	blockName string, node *color.Node) *hcl.EvalContext {
@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/jsvensson/paletteswap/internal/theme"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
@@ -58,6 +59,29 @@ ansi {
 }
 `
 
+// completeANSI is an ansi block with every color the default profile
+// requires, for tests whose themes would otherwise miss the required block.
+const completeANSI = `
+ansi {
+  black   = "#000000"
+  red     = "#ff0000"
+  green   = "#00ff00"
+  yellow  = "#ffff00"
+  blue    = "#0000ff"
+  magenta = "#ff00ff"
+  cyan    = "#00ffff"
+  white   = "#ffffff"
+  bright_black   = "#808080"
+  bright_red     = "#ff8080"
+  bright_green   = "#80ff80"
+  bright_yellow  = "#ffff80"
+  bright_blue    = "#8080ff"
+  bright_magenta = "#ff80ff"
+  bright_cyan    = "#80ffff"
+  bright_white   = "#ffffff"
+}
+`
+
 func TestAnalyze_ValidTheme(t *testing.T) {
 	result := Analyze("test.pstheme", validTheme)
 
@@ -229,7 +253,7 @@ ansi {
 
 	found := false
 	for _, d := range result.Diagnostics {
-		if d.Severity != nil && *d.Severity == protocol.DiagnosticSeverityWarning {
+		if d.Severity != nil && *d.Severity == protocol.DiagnosticSeverityError {
 			if strings.Contains(d.Message, "missing") || strings.Contains(d.Message, "Missing") {
 				found = true
 				break
@@ -237,13 +261,159 @@ ansi {
 		}
 	}
 	if !found {
-		t.Error("expected warning diagnostic for missing ANSI colors")
+		t.Error("expected error diagnostic for missing ANSI colors")
 		for _, d := range result.Diagnostics {
 			t.Logf("  diagnostic: [%v] %s", *d.Severity, d.Message)
 		}
 	}
 }
 
+func TestAnalyze_ANSIProfile(t *testing.T) {
+	content := `
+settings {
+  ansi_profile = "%s"
+}
+
+palette {
+  base = "#191724"
+}
+
+ansi {
+  black      = palette.base
+  red        = "#ff0000"
+  green      = "#00ff00"
+  yellow     = "#ffff00"
+  blue       = "#0000ff"
+  magenta    = "#ff00ff"
+  cyan       = "#00ffff"
+  white      = "#ffffff"
+  foreground = "#e0def4"
+  orange     = "#ffa500"
+}
+`
+	messages := func(profile string) string {
+		result := Analyze("test.pstheme", strings.Replace(content, "%s", profile, 1))
+		var msgs []string
+		for _, d := range result.Diagnostics {
+			msgs = append(msgs, d.Message)
+		}
+		return strings.Join(msgs, "\n")
+	}
+
+	if got := messages("basic8"); got != "ansi.orange is not a valid ANSI color name" {
+		t.Errorf("basic8 diagnostics:\n%s\nwant only orange rejected", got)
+	}
+	if got := messages("extended"); strings.Contains(got, "ansi.foreground") || !strings.Contains(got, "bright_white, background, cursor") {
+		t.Errorf("extended diagnostics:\n%s\nwant foreground accepted and the rest reported missing", got)
+	}
+	if got := messages("256"); !strings.Contains(got, "ansi_profile must be one of") {
+		t.Errorf("diagnostics:\n%s\nwant an invalid ansi_profile error", got)
+	}
+}
+
+func TestAnalyze_LintRules(t *testing.T) {
+	content := `
+settings {
+  lint {
+    PS001          = "off"
+    unused-palette = "error"
+    PS002          = "nope"
+  }
+}
+
+palette {
+  base  = "#191724"
+  extra = "#ffffff"
+}
+
+theme {
+  background = palette.base
+  foreground = palette.base
+}
+
+ansi {
+  black = palette.base
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	codes := make(map[string]protocol.DiagnosticSeverity)
+	settingsError := false
+	for _, d := range result.Diagnostics {
+		if d.Code != nil {
+			codes[d.Code.Value.(string)] = *d.Severity
+		} else if strings.Contains(d.Message, `unknown severity "nope"`) {
+			settingsError = true
+		}
+	}
+
+	if _, ok := codes["PS001"]; ok {
+		t.Error("PS001 is turned off but was reported")
+	}
+	if sev, ok := codes["PS002"]; !ok || sev != DiagWarning {
+		t.Errorf("PS002 severity = %v (reported %v), want default warning", sev, ok)
+	}
+	if sev, ok := codes["PS003"]; !ok || sev != DiagError {
+		t.Errorf("PS003 severity = %v (reported %v), want error from settings", sev, ok)
+	}
+	if !settingsError {
+		t.Error("expected a diagnostic for the invalid severity")
+	}
+
+	for _, d := range result.Diagnostics {
+		if d.Code != nil && d.Code.Value == "PS003" {
+			data, _ := d.Data.(map[string]any)
+			if args, _ := data["args"].([]any); len(args) != 1 || args[0] != "palette.extra" {
+				t.Errorf("PS003 data = %v, want the message arguments", d.Data)
+			}
+		}
+	}
+}
+
+func TestAnalyze_FlatNameCollisionTransform(t *testing.T) {
+	content := `palette {
+  base    = "#191724"
+  base_l1 = "#ffffff"
+
+  transform {
+    lightness {
+      range = [0.2, 0.8]
+      steps = 2
+    }
+  }
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	var found []protocol.Diagnostic
+	for _, d := range result.Diagnostics {
+		if d.Code != nil && d.Code.Value == "PS005" {
+			found = append(found, d)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("PS005 diagnostics = %+v, want one", found)
+	}
+	// base.l1 comes from the transform; base_l1 collides with it.
+	if !strings.Contains(found[0].Message, `palette.base_l1 flattens to "base-l1", like palette.base.l1`) || found[0].Range.Start.Line != 2 {
+		t.Errorf("diagnostic = %q at line %d, want base_l1 at line 2", found[0].Message, found[0].Range.Start.Line)
+	}
+}
+
+func TestAnalyze_MissingANSIBlock(t *testing.T) {
+	result := Analyze("test.pstheme", "palette {\n  base = \"#191724\"\n}\n")
+
+	var errs []string
+	for _, d := range result.Diagnostics {
+		if *d.Severity == DiagError {
+			errs = append(errs, d.Message)
+		}
+	}
+	if len(errs) != 1 || errs[0] != "missing required ansi block" {
+		t.Errorf("errors = %v, want the missing ansi block", errs)
+	}
+}
+
 func TestAnalyze_MissingPalette(t *testing.T) {
 	content := `
 meta {
@@ -273,6 +443,388 @@ theme {
 	}
 }
 
+func TestAnalyze_DuplicateBlock(t *testing.T) {
+	content := `
+palette {
+  base = "#191724"
+}
+
+palette {
+  base = "#ffffff"
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	var dupDiag *protocol.Diagnostic
+	for i, d := range result.Diagnostics {
+		if strings.Contains(d.Message, "duplicate palette block") {
+			dupDiag = &result.Diagnostics[i]
+			break
+		}
+	}
+	if dupDiag == nil {
+		t.Fatal("expected error diagnostic for duplicate palette block")
+	}
+	if *dupDiag.Severity != protocol.DiagnosticSeverityError {
+		t.Errorf("severity = %v, want error", *dupDiag.Severity)
+	}
+	// Reported on the second block (line 6, 0-based 5)
+	if dupDiag.Range.Start.Line != 5 {
+		t.Errorf("diagnostic line = %d, want 5", dupDiag.Range.Start.Line)
+	}
+
+	// The first block wins
+	base, err := result.Palette.Lookup([]string{"base"})
+	if err != nil {
+		t.Fatalf("Lookup(base) error: %v", err)
+	}
+	if base.Hex() != "#191724" {
+		t.Errorf("palette.base = %q, want %q", base.Hex(), "#191724")
+	}
+}
+
+func TestAnalyze_When(t *testing.T) {
+	content := `
+meta {
+  appearance = "dark"
+}
+
+palette {
+  base {
+    when  = "dark"
+    color = "#191724"
+  }
+  base {
+    when  = "light"
+    color = "#faf4ed"
+  }
+  love {
+    when  = "dim"
+    color = "#eb6f92"
+  }
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	base, err := result.Palette.Lookup([]string{"base"})
+	if err != nil {
+		t.Fatalf("Lookup(base) error: %v", err)
+	}
+	if base.Hex() != "#191724" {
+		t.Errorf("palette.base = %q, want %q", base.Hex(), "#191724")
+	}
+	for _, d := range result.Diagnostics {
+		if strings.Contains(d.Message, "duplicate") {
+			t.Errorf("unexpected diagnostic: %s", d.Message)
+		}
+	}
+
+	var whenDiag *protocol.Diagnostic
+	for i, d := range result.Diagnostics {
+		if strings.Contains(d.Message, `when must be "dark" or "light"`) {
+			whenDiag = &result.Diagnostics[i]
+			break
+		}
+	}
+	if whenDiag == nil {
+		t.Fatal("expected error diagnostic for invalid when value")
+	}
+	// Reported on the value (line 16, 0-based 15)
+	if whenDiag.Range.Start.Line != 15 {
+		t.Errorf("diagnostic line = %d, want 15", whenDiag.Range.Start.Line)
+	}
+}
+
+func TestAnalyze_MultipleSyntaxBlocks(t *testing.T) {
+	content := `
+palette {
+  pine = "#31748f"
+  gold = "#f6c177"
+}
+
+syntax {
+  keyword = palette.pine
+  markup {
+    heading = palette.gold
+  }
+}
+
+syntax {
+  string = syntax.keyword
+  markup {
+    bold = syntax.markup.heading
+  }
+}
+`
+	result := Analyze("test.pstheme", content+completeANSI)
+
+	for _, d := range result.Diagnostics {
+		if d.Severity != nil && *d.Severity == protocol.DiagnosticSeverityError {
+			t.Errorf("unexpected error diagnostic: %s", d.Message)
+		}
+	}
+
+	for _, sym := range []string{"syntax.keyword", "syntax.string", "syntax.markup.heading", "syntax.markup.bold"} {
+		if _, ok := result.Symbols[sym]; !ok {
+			t.Errorf("expected symbol %q in symbol table", sym)
+		}
+	}
+}
+
+func TestAnalyze_LanguageSyntaxBlock(t *testing.T) {
+	content := `
+palette {
+  pine = "#31748f"
+  gold = "#f6c177"
+}
+
+syntax {
+  keyword = palette.pine
+}
+
+syntax "go" {
+  keyword = palette.gold
+  string  = syntax.keyword
+}
+`
+	result := Analyze("test.pstheme", content+completeANSI)
+
+	for _, d := range result.Diagnostics {
+		if d.Severity != nil && *d.Severity == protocol.DiagnosticSeverityError {
+			t.Errorf("unexpected error diagnostic: %s", d.Message)
+		}
+	}
+
+	// The language block must not clobber the base symbol
+	rng, ok := result.Symbols["syntax.keyword"]
+	if !ok {
+		t.Fatal("expected symbol syntax.keyword")
+	}
+	if rng.Start.Line != 7 {
+		t.Errorf("syntax.keyword defined at line %d, want 7", rng.Start.Line)
+	}
+
+	// Both language block values produce color locations
+	count := 0
+	for _, cl := range result.Colors {
+		if cl.Range.Start.Line == 11 || cl.Range.Start.Line == 12 {
+			count++
+		}
+	}
+	if count != 2 {
+		t.Errorf("got %d color locations in language block, want 2", count)
+	}
+}
+
+func TestAnalyze_SemanticBlock(t *testing.T) {
+	content := `
+palette {
+  pine = "#31748f"
+  gold = "#f6c177"
+}
+
+semantic {
+  parameter = palette.pine
+  keywrod   = palette.gold
+  decorator {
+    color  = palette.gold
+    italic = true
+  }
+}
+`
+	result := Analyze("test.pstheme", content+completeANSI)
+
+	var errs []string
+	for _, d := range result.Diagnostics {
+		if d.Severity != nil && *d.Severity == protocol.DiagnosticSeverityError {
+			errs = append(errs, d.Message)
+		}
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0], "semantic.keywrod is not a valid semantic token type") {
+		t.Errorf("errors = %v, want single unknown token type error for keywrod", errs)
+	}
+
+	for _, sym := range []string{"semantic.parameter", "semantic.decorator"} {
+		if _, ok := result.Symbols[sym]; !ok {
+			t.Errorf("expected symbol %q in symbol table", sym)
+		}
+	}
+}
+
+func TestAnalyze_ThemeSubBlocks(t *testing.T) {
+	content := `
+palette {
+  base = "#191724"
+  love = "#eb6f92"
+}
+
+theme {
+  cursor {
+    cursor = palette.love
+  }
+  selection {
+    background = palette.base
+    foreground = palette.love
+    border     = palette.love
+  }
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	wantErrors := []string{
+		"theme.cursor block missing attributes: text",
+		"theme.selection.border is not a valid attribute",
+	}
+	for _, want := range wantErrors {
+		found := false
+		for _, d := range result.Diagnostics {
+			if strings.Contains(d.Message, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected diagnostic containing %q", want)
+		}
+	}
+
+	if _, ok := result.Symbols["theme.selection.background"]; !ok {
+		t.Error("expected symbol theme.selection.background")
+	}
+}
+
+func TestAnalyze_Locals(t *testing.T) {
+	content := `
+palette {
+  base = "#191724"
+  love = "#eb6f92"
+}
+
+locals {
+  step   = 0.1
+  accent = palette.love
+  unused = "#ffffff"
+}
+
+theme {
+  background = brighten(palette.base, local.step)
+  foreground = local.accent
+}
+`
+	result := Analyze("test.pstheme", content+completeANSI)
+
+	for _, d := range result.Diagnostics {
+		if *d.Severity == DiagError {
+			t.Errorf("unexpected error diagnostic: %s", d.Message)
+		}
+	}
+
+	for _, sym := range []string{"local.step", "local.accent", "local.unused"} {
+		if _, ok := result.Symbols[sym]; !ok {
+			t.Errorf("expected symbol %s", sym)
+		}
+	}
+
+	var warnings []string
+	for _, d := range result.Diagnostics {
+		if *d.Severity == DiagWarning {
+			warnings = append(warnings, d.Message)
+		}
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "local.unused is declared but never used") {
+		t.Errorf("warnings = %v, want single unused warning for local.unused", warnings)
+	}
+
+	// Color swatches for the two color-valued locals, none for the number
+	swatches := 0
+	for _, loc := range result.Colors {
+		if loc.Range.Start.Line >= 7 && loc.Range.Start.Line <= 10 {
+			swatches++
+		}
+	}
+	if swatches != 2 {
+		t.Errorf("got %d color locations in locals block, want 2", swatches)
+	}
+}
+
+func TestAnalyze_LocalsErrors(t *testing.T) {
+	content := `
+palette {
+  base = "#191724"
+}
+
+locals {
+  a = local.b
+  b = palette.base
+  group {
+    c = palette.base
+  }
+}
+
+theme {
+  background = local.a
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	wantErrors := []string{
+		"local.a:",
+		"locals block does not support nesting",
+	}
+	for _, want := range wantErrors {
+		found := false
+		for _, d := range result.Diagnostics {
+			if strings.Contains(d.Message, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected diagnostic containing %q", want)
+		}
+	}
+}
+
+func TestAnalyzeWithLimits(t *testing.T) {
+	content := `
+palette {
+  base = "#191724"
+  love = "#eb6f92"
+  transform {
+    lightness {
+      range = [0.1, 0.9]
+      steps = 10
+    }
+  }
+}
+`
+	tests := []struct {
+		name   string
+		limits theme.Limits
+		want   string
+	}{
+		{"file size", theme.Limits{MaxFileSize: 16}, "exceeding the limit of 16 bytes"},
+		{"palette entries", theme.Limits{MaxPaletteEntries: 1}, "palette has more than 1 entries"},
+		{"transform steps", theme.Limits{MaxTransformSteps: 5}, "lightness steps is 10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := AnalyzeWithLimits("test.pstheme", content, tt.limits)
+			found := false
+			for _, d := range result.Diagnostics {
+				if strings.Contains(d.Message, tt.want) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Errorf("expected diagnostic containing %q, got %v", tt.want, result.Diagnostics)
+			}
+		})
+	}
+}
+
 func TestAnalyze_InvalidHex(t *testing.T) {
 	content := `
 palette {
@@ -564,6 +1116,48 @@ ansi {
 	}
 }
 
+func TestAnalyze_PaletteTransformErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform string
+		want      string
+		line      uint32 // 0-based line of the diagnostic
+		text      string // source text the diagnostic covers
+	}{
+		{"range type", "lightness {\n      range = [0.1, \"x\"]\n      steps = 3\n    }", "lightness range must be a list of two numbers", 4, `[0.1, "x"]`},
+		{"range bounds", "lightness {\n      range = [0.1, 1.5]\n      steps = 3\n    }", "lightness range values must be between 0 and 1, got 1.5", 4, "[0.1, 1.5]"},
+		{"fractional steps", "lightness {\n      range = [0.1, 0.9]\n      steps = 2.5\n    }", "lightness steps must be a whole number", 5, "2.5"},
+		{"zero steps", "lightness {\n      range = [0.1, 0.9]\n      steps = 0\n    }", "lightness steps must be >= 1, got 0", 5, "0"},
+		{"unknown attribute", "lightness {\n      range = [0.1, 0.9]\n      steps = 3\n      step = 3\n    }", `lightness: unexpected attribute "step"`, 6, "step"},
+		{"unknown block", "chroma {\n    }", `transform: unexpected block "chroma"`, 3, "chroma"},
+		{"missing lightness", "", "transform block has no lightness block", 2, "transform"},
+		{"missing steps", "lightness {\n      range = [0.1, 0.9]\n    }", "lightness block missing 'steps' attribute", 3, "lightness"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "palette {\n  base = \"#808080\"\n  transform {\n    " + tt.transform + "\n  }\n}\n"
+			result := Analyze("test.pstheme", content)
+
+			var found bool
+			for _, d := range result.Diagnostics {
+				if !strings.Contains(d.Message, tt.want) {
+					continue
+				}
+				found = true
+				if d.Range.Start.Line != tt.line || !strings.HasPrefix(extractText(content, d.Range), tt.text) {
+					t.Errorf("diagnostic at %v covering %q, want line %d starting %q", d.Range, extractText(content, d.Range), tt.line, tt.text)
+				}
+			}
+			if !found {
+				t.Errorf("missing diagnostic %q in %v", tt.want, result.Diagnostics)
+			}
+			if result.Transform != nil {
+				t.Errorf("Transform = %+v, want nil for an invalid transform", result.Transform)
+			}
+		})
+	}
+}
+
 func TestAnalyze_ExplicitPaletteColorWarning(t *testing.T) {
 	content := `
 palette {
@@ -627,3 +1221,344 @@ ansi {
 		}
 	}
 }
+
+func TestAnalyze_Scale(t *testing.T) {
+	content := `
+palette {
+  base    = "#000000"
+  overlay = "#ffffff"
+  scale "surface" {
+    from  = palette.base
+    to    = palette.overlay
+    steps = 3
+  }
+  raised = palette.surface.2
+}
+
+theme {
+  background = palette.surface.3
+}
+`
+	result := Analyze("test.pstheme", content+completeANSI)
+
+	for _, d := range result.Diagnostics {
+		if *d.Severity == DiagError {
+			t.Errorf("unexpected error diagnostic: %s", d.Message)
+		}
+	}
+
+	for _, sym := range []string{"palette.surface", "palette.surface.1", "palette.surface.3", "palette.raised"} {
+		if _, ok := result.Symbols[sym]; !ok {
+			t.Errorf("expected symbol %s", sym)
+		}
+	}
+
+	c, err := result.Palette.Lookup([]string{"surface", "2"})
+	if err != nil || c.Hex() != "#808080" {
+		t.Errorf("palette.surface.2 = %v, %v, want #808080", c.Hex(), err)
+	}
+}
+
+func TestAnalyze_ScaleErrors(t *testing.T) {
+	content := `
+palette {
+  base = "#000000"
+  scale "surface" {
+    from  = palette.base
+    steps = 3
+  }
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	found := false
+	for _, d := range result.Diagnostics {
+		if *d.Severity == DiagError && strings.Contains(d.Message, "missing 'to'") {
+			found = true
+			if d.Range.Start.Line != 3 {
+				t.Errorf("diagnostic on line %d, want 3 (the scale block)", d.Range.Start.Line)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected missing 'to' error, got %v", result.Diagnostics)
+	}
+}
+
+func TestAnalyze_ReferenceOrder(t *testing.T) {
+	blocks := []string{"palette", "locals", "theme", "ansi", "syntax", "semantic"}
+	refs := map[string]string{
+		"palette":  "palette.base",
+		"locals":   "local.accent",
+		"theme":    "theme.background",
+		"ansi":     "ansi.black",
+		"syntax":   "syntax.keyword",
+		"semantic": "semantic.keyword",
+	}
+	selfReferencing := map[string]bool{"palette": true, "locals": true, "theme": true, "syntax": true}
+
+	template := `
+palette {
+  base = "#111111"
+  ref  = PALETTE
+}
+
+locals {
+  accent = "#222222"
+  ref    = LOCALS
+}
+
+theme {
+  background = "#333333"
+  ref        = THEME
+}
+
+ansi {
+  black          = "#444444"
+  red            = "#000000"
+  green          = "#000000"
+  yellow         = "#000000"
+  blue           = "#000000"
+  magenta        = "#000000"
+  cyan           = "#000000"
+  white          = ANSI
+  bright_black   = "#000000"
+  bright_red     = "#000000"
+  bright_green   = "#000000"
+  bright_yellow  = "#000000"
+  bright_blue    = "#000000"
+  bright_magenta = "#000000"
+  bright_cyan    = "#000000"
+  bright_white   = "#000000"
+}
+
+syntax {
+  keyword = "#555555"
+  ref     = SYNTAX
+}
+
+semantic {
+  keyword = "#666666"
+  comment = SEMANTIC
+}
+`
+
+	for i, from := range blocks {
+		for j, to := range blocks {
+			t.Run(from+" to "+to, func(t *testing.T) {
+				slots := make([]string, 0, 2*len(blocks))
+				for _, b := range blocks {
+					value := `"#999999"`
+					if b == from {
+						value = refs[to]
+					}
+					slots = append(slots, strings.ToUpper(b), value)
+				}
+				result := Analyze("test.pstheme", strings.NewReplacer(slots...).Replace(template))
+
+				var errs []string
+				for _, d := range result.Diagnostics {
+					if d.Severity != nil && *d.Severity == DiagError {
+						errs = append(errs, d.Message)
+					}
+				}
+
+				allowed := j < i || (j == i && selfReferencing[from])
+				if allowed {
+					if len(errs) > 0 {
+						t.Errorf("unexpected errors: %v", errs)
+					}
+					return
+				}
+				if len(errs) != 1 || !strings.Contains(errs[0], "cannot reference") {
+					t.Errorf("expected one reference order error, got %v", errs)
+				}
+			})
+		}
+	}
+}
+
+func TestAnalyze_SpecVersion(t *testing.T) {
+	const body = `
+palette {
+  base = "#191724"
+  scale {
+    a = "#000000"
+  }
+}
+`
+	tests := []struct {
+		name     string
+		meta     string
+		wantSev  protocol.DiagnosticSeverity
+		wantText string
+	}{
+		{"v1 hint", `meta { name = "x" }`, DiagWarning, "palette.scale: a palette group named scale"},
+		{"v2 error", `meta { spec_version = 2 }`, DiagError, "palette.scale: a palette group named scale"},
+		{"unsupported", `meta { spec_version = 7 }`, DiagError, "unsupported spec_version 7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Analyze("test.pstheme", tt.meta+"\n"+body)
+			for _, d := range result.Diagnostics {
+				if strings.Contains(d.Message, tt.wantText) {
+					if *d.Severity != tt.wantSev {
+						t.Errorf("severity = %v, want %v", *d.Severity, tt.wantSev)
+					}
+					return
+				}
+			}
+			t.Errorf("no diagnostic containing %q in %v", tt.wantText, result.Diagnostics)
+		})
+	}
+}
+
+func TestAnalyze_HeredocAndMultilineColors(t *testing.T) {
+	content := `palette {
+  base = "#191724"
+  love = <<EOT
+#eb6f92
+EOT
+  pine = <<-EOT
+    #31748f
+    EOT
+  dim = darken(
+    palette.base,
+    0.1,
+  )
+}
+
+theme {
+  background = (
+    palette.base
+  )
+  foreground = brighten(
+    palette.love,
+    0.2
+  )
+  cursor = palette.pine
+  accent = palette.dim
+}
+`
+	result := Analyze("test.pstheme", content+completeANSI)
+	for _, d := range result.Diagnostics {
+		t.Errorf("unexpected diagnostic: %s", d.Message)
+	}
+
+	rng := func(sl, sc, el, ec uint32) protocol.Range {
+		return protocol.Range{
+			Start: protocol.Position{Line: sl, Character: sc},
+			End:   protocol.Position{Line: el, Character: ec},
+		}
+	}
+	tests := []struct {
+		name  string
+		hex   string
+		rng   protocol.Range
+		isRef bool
+	}{
+		{"heredoc", "#eb6f92", rng(3, 0, 3, 7), false},
+		{"indented heredoc", "#31748f", rng(6, 4, 6, 11), false},
+		{"multi-line call", "", rng(8, 8, 11, 3), false},
+		{"parenthesized reference", "#191724", rng(15, 15, 17, 3), true},
+		{"multi-line call in theme", "", rng(18, 15, 21, 3), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, c := range result.Colors {
+				if c.Range != tt.rng {
+					continue
+				}
+				if tt.hex != "" && c.Color.Hex() != tt.hex {
+					t.Errorf("color = %s, want %s", c.Color.Hex(), tt.hex)
+				}
+				if c.IsRef != tt.isRef {
+					t.Errorf("IsRef = %v, want %v", c.IsRef, tt.isRef)
+				}
+				return
+			}
+			t.Errorf("no color location at %v; have %v", tt.rng, result.Colors)
+		})
+	}
+}
+
+func TestAnalyze_InterpolatedColor(t *testing.T) {
+	content := `
+palette {
+  base = "#191724"
+  bad  = "#${palette.base}"
+}
+
+locals {
+  hue = "eb"
+}
+
+theme {
+  foreground = "#${local.hue}6f92"
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	found := false
+	for _, d := range result.Diagnostics {
+		if strings.Contains(d.Message, "foreground") {
+			t.Errorf("unexpected diagnostic for valid interpolation: %s", d.Message)
+		}
+		if strings.Contains(d.Message, `palette.bad: interpolation produced "##191724"`) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected interpolation diagnostic for palette.bad, got %v", result.Diagnostics)
+	}
+
+	for _, c := range result.Colors {
+		if c.Range.Start.Line == 11 && c.Color.Hex() == "#eb6f92" {
+			return
+		}
+	}
+	t.Errorf("no color location for the interpolated foreground in %v", result.Colors)
+}
+
+func TestAnalyze_LightnessPercentageRange(t *testing.T) {
+	content := `palette {
+  base    = "#191724"
+  surface = brighten(palette.base, 1.5)
+  overlay = mix(palette.base, "#ffffff", 2)
+}
+
+theme {
+  background = darken(palette.base, -0.25)
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	want := map[string]protocol.Range{
+		"percentage must be between -1 and 1, got 1.5": {
+			Start: protocol.Position{Line: 2, Character: 35},
+			End:   protocol.Position{Line: 2, Character: 38},
+		},
+		"weight must be between 0 and 1, got 2": {
+			Start: protocol.Position{Line: 3, Character: 41},
+			End:   protocol.Position{Line: 3, Character: 42},
+		},
+		"percentage must be between 0 and 1, got -0.25": {
+			Start: protocol.Position{Line: 7, Character: 36},
+			End:   protocol.Position{Line: 7, Character: 41},
+		},
+	}
+	for _, d := range result.Diagnostics {
+		for msg, rng := range want {
+			if strings.Contains(d.Message, msg) {
+				if d.Range != rng {
+					t.Errorf("%q range = %v, want %v", msg, d.Range, rng)
+				}
+				delete(want, msg)
+			}
+		}
+	}
+	for msg := range want {
+		t.Errorf("missing diagnostic %q in %v", msg, result.Diagnostics)
+	}
+}
@@ -0,0 +1,163 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/protocol")
+
+// testClient drives a Server over an in-memory pipe, speaking JSON-RPC with
+// the same framing as an editor does over stdio. Requests return the raw
+// JSON result so tests can check the exact wire encoding.
+type testClient struct {
+	t     *testing.T
+	conn  *jsonrpc2.Conn
+	notes chan *jsonrpc2.Request // notifications sent by the server
+	done  chan error             // result of Server.serve
+}
+
+// newTestClient starts a Server and connects a client to it. The session is
+// shut down when the test ends, and the test fails unless the server then
+// exits cleanly.
+func newTestClient(t *testing.T) *testClient {
+	t.Helper()
+	serverSide, clientSide := net.Pipe()
+	c := &testClient{
+		t:     t,
+		notes: make(chan *jsonrpc2.Request, 64),
+		done:  make(chan error, 1),
+	}
+
+	s := NewServer("test")
+	go func() {
+		c.done <- s.serve(context.Background(), jsonrpc2.NewBufferedStream(serverSide, jsonrpc2.VSCodeObjectCodec{}))
+	}()
+
+	c.conn = jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(clientSide, jsonrpc2.VSCodeObjectCodec{}),
+		jsonrpc2.HandlerWithError(func(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			if req.Notif {
+				c.notes <- req
+			}
+			return nil, nil
+		}))
+	t.Cleanup(c.shutdown)
+	return c
+}
+
+// call sends a request and returns its raw result.
+func (c *testClient) call(method string, params any) json.RawMessage {
+	c.t.Helper()
+	var result json.RawMessage
+	if err := c.conn.Call(context.Background(), method, params, &result); err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+	return result
+}
+
+// notify sends a notification.
+func (c *testClient) notify(method string, params any) {
+	c.t.Helper()
+	if err := c.conn.Notify(context.Background(), method, params); err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+}
+
+// initialize performs the initialize handshake and returns the raw result.
+func (c *testClient) initialize() json.RawMessage {
+	c.t.Helper()
+	result := c.call(protocol.MethodInitialize, protocol.InitializeParams{})
+	c.notify(protocol.MethodInitialized, protocol.InitializedParams{})
+	return result
+}
+
+// open opens a document and returns the raw diagnostics published for it.
+func (c *testClient) open(uri, text string) json.RawMessage {
+	c.t.Helper()
+	c.notify(protocol.MethodTextDocumentDidOpen, protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: uri, LanguageID: "pstheme", Text: text},
+	})
+	return c.diagnostics(uri)
+}
+
+// diagnostics waits for the next diagnostics notification for uri.
+func (c *testClient) diagnostics(uri string) json.RawMessage {
+	c.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case req := <-c.notes:
+			if req.Method != protocol.ServerTextDocumentPublishDiagnostics || req.Params == nil {
+				continue
+			}
+			var params protocol.PublishDiagnosticsParams
+			if err := json.Unmarshal(*req.Params, &params); err != nil {
+				c.t.Fatalf("decoding diagnostics: %v", err)
+			}
+			if params.URI == uri {
+				return *req.Params
+			}
+		case <-timeout:
+			c.t.Fatalf("no diagnostics published for %s", uri)
+		}
+	}
+}
+
+// shutdown sends shutdown and exit, and checks that the server exits cleanly.
+func (c *testClient) shutdown() {
+	c.t.Helper()
+	defer c.conn.Close()
+	if err := c.conn.Call(context.Background(), protocol.MethodShutdown, nil, nil); err != nil {
+		c.t.Errorf("shutdown: %v", err)
+		return
+	}
+	_ = c.conn.Notify(context.Background(), protocol.MethodExit, nil)
+	select {
+	case err := <-c.done:
+		if err != nil {
+			c.t.Errorf("server exited with error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		c.t.Error("server did not exit after shutdown and exit")
+	}
+}
+
+// checkGolden compares got, a JSON document, to testdata/protocol/name.json.
+// With -update, the golden file is rewritten instead.
+func checkGolden(t *testing.T, name string, got json.RawMessage) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, got, "", "  "); err != nil {
+		t.Fatalf("indenting %s: %v", name, err)
+	}
+	buf.WriteByte('\n')
+
+	path := filepath.Join("testdata", "protocol", name+".json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("%s does not match %s (run with -update to accept):\n%s", name, path, buf.String())
+	}
+}
@@ -0,0 +1,163 @@
+package lsp
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/format"
+	"github.com/jsvensson/paletteswap/internal/lint"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// codeActions returns a quick fix for each literal-color diagnostic in
+// diags. A literal whose color the palette already has is replaced with a
+// reference to it; any other is moved, as written, to a new palette entry
+// named after its attribute, such as palette.background, indented like the
+// palette's entries, and referenced from there.
+func codeActions(result *AnalysisResult, uri protocol.DocumentUri, diags []protocol.Diagnostic) []protocol.CodeAction {
+	actions := []protocol.CodeAction{}
+	if result == nil || result.body == nil {
+		return actions
+	}
+	var palette *hclsyntax.Block
+	for _, b := range result.body.Blocks {
+		if b.Type == "palette" && len(b.Labels) == 0 {
+			palette = b
+			break
+		}
+	}
+	if palette == nil {
+		return actions
+	}
+
+	literals := make(map[protocol.Range]lint.ColorLiteral)
+	for _, lit := range lint.ColorLiterals(result.body) {
+		literals[result.lspRange(lit.Expr.Range())] = lit
+	}
+	kind := protocol.CodeActionKindQuickFix
+	for _, d := range diags {
+		if d.Code == nil || d.Code.Value != lint.LiteralColorRule {
+			continue
+		}
+		lit, ok := literals[d.Range]
+		if !ok {
+			continue
+		}
+		c, err := color.ParseHex(lit.Value)
+		if err != nil {
+			continue
+		}
+
+		var title string
+		var edits []protocol.TextEdit
+		if path, ok := palettePathOf(result.Palette, c); ok {
+			title = "Replace with " + path
+			edits = []protocol.TextEdit{{Range: d.Range, NewText: path}}
+		} else {
+			// A palette block on one line has no line to add the entry on.
+			if palette.OpenBraceRange.Start.Line == palette.CloseBraceRange.Start.Line {
+				continue
+			}
+			name := newPaletteName(palette.Body, lit.Name)
+			line := result.lspRange(palette.CloseBraceRange).Start.Line
+			rng := lit.Expr.Range()
+			title = "Extract to palette." + name
+			edits = []protocol.TextEdit{
+				{
+					Range:   protocol.Range{Start: protocol.Position{Line: line}, End: protocol.Position{Line: line}},
+					NewText: paletteIndent(result, palette, uri) + name + " = " + result.mapper.content[rng.Start.Byte:rng.End.Byte] + "\n",
+				},
+				{Range: d.Range, NewText: "palette." + name},
+			}
+		}
+		actions = append(actions, protocol.CodeAction{
+			Title:       title,
+			Kind:        &kind,
+			Diagnostics: []protocol.Diagnostic{d},
+			Edit: &protocol.WorkspaceEdit{
+				Changes: map[protocol.DocumentUri][]protocol.TextEdit{uri: edits},
+			},
+		})
+	}
+	return actions
+}
+
+// paletteIndent returns the indentation of a new entry of the palette
+// block: that of its first entry, or else one level of the indent size the
+// document is formatted with.
+func paletteIndent(result *AnalysisResult, palette *hclsyntax.Block, uri protocol.DocumentUri) string {
+	first := -1
+	for _, attr := range palette.Body.Attributes {
+		if first < 0 || attr.SrcRange.Start.Byte < first {
+			first = attr.SrcRange.Start.Byte
+		}
+	}
+	for _, block := range palette.Body.Blocks {
+		if first < 0 || block.TypeRange.Start.Byte < first {
+			first = block.TypeRange.Start.Byte
+		}
+	}
+	content := result.mapper.content
+	if first >= 0 {
+		start := strings.LastIndexByte(content[:first], '\n') + 1
+		if indent := content[start:first]; strings.TrimLeft(indent, " \t") == "" {
+			return indent
+		}
+	}
+	size := formatOptions(string(uri), nil).IndentSize
+	if size == 0 {
+		size = format.DefaultIndentSize
+	}
+	return strings.Repeat(" ", size)
+}
+
+// palettePathOf returns the path of the first palette color, in declaration
+// order, that is c.
+func palettePathOf(node *color.Node, c color.Color) (string, bool) {
+	if node == nil {
+		return "", false
+	}
+	var find func(node *color.Node, path string) (string, bool)
+	find = func(node *color.Node, path string) (string, bool) {
+		if node.Color != nil && *node.Color == c && path != "palette" {
+			return path, true
+		}
+		for _, name := range node.Names() {
+			if p, ok := find(node.Children[name], path+"."+name); ok {
+				return p, true
+			}
+		}
+		return "", false
+	}
+	return find(node, "palette")
+}
+
+// newPaletteName returns name, or name followed by the lowest number from 2
+// that makes it, if it is taken by an entry or group of the palette body.
+func newPaletteName(body *hclsyntax.Body, name string) string {
+	taken := func(n string) bool {
+		if _, ok := body.Attributes[n]; ok {
+			return true
+		}
+		for _, b := range body.Blocks {
+			if b.Type == n || (len(b.Labels) == 1 && b.Labels[0] == n) {
+				return true
+			}
+		}
+		return false
+	}
+	candidate := name
+	for i := 2; taken(candidate); i++ {
+		candidate = name + strconv.Itoa(i)
+	}
+	return candidate
+}
+
+// textDocumentCodeAction handles textDocument/codeAction requests.
+func (s *Server) textDocumentCodeAction(_ *glsp.Context, params *protocol.CodeActionParams) (any, error) {
+	uri := params.TextDocument.URI
+	return codeActions(s.getResult(string(uri)), uri, params.Context.Diagnostics), nil
+}
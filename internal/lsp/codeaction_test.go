@@ -0,0 +1,136 @@
+package lsp
+
+import (
+	"slices"
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestCodeActions_LiteralColor(t *testing.T) {
+	content := `settings {
+  no_literals_outside_palette = true
+}
+
+palette {
+  base       = "#191724"
+  background = "#1f1d2e"
+}
+
+theme {
+  background = "#1f1d2e"
+  foreground = "#e0def4"
+}
+`
+	result := Analyze("test.pstheme", content)
+	var diags []protocol.Diagnostic
+	for _, d := range result.Diagnostics {
+		if d.Code != nil && d.Code.Value == "PS004" {
+			diags = append(diags, d)
+		}
+	}
+	if len(diags) != 2 {
+		t.Fatalf("PS004 diagnostics = %v, want 2", diags)
+	}
+
+	const uri = "file:///test.pstheme"
+	actions := codeActions(result, uri, diags)
+	if len(actions) != 2 {
+		t.Fatalf("actions = %+v, want 2", actions)
+	}
+
+	// The palette already has the background color.
+	reuse := actions[0]
+	if reuse.Title != "Replace with palette.background" {
+		t.Errorf("title = %q, want Replace with palette.background", reuse.Title)
+	}
+	wantReuse := []protocol.TextEdit{{
+		Range:   protocol.Range{Start: protocol.Position{Line: 10, Character: 15}, End: protocol.Position{Line: 10, Character: 24}},
+		NewText: "palette.background",
+	}}
+	if got := reuse.Edit.Changes[uri]; !slices.Equal(got, wantReuse) {
+		t.Errorf("edits = %+v, want %+v", got, wantReuse)
+	}
+
+	extract := actions[1]
+	if extract.Title != "Extract to palette.foreground" {
+		t.Errorf("title = %q, want Extract to palette.foreground", extract.Title)
+	}
+	if extract.Kind == nil || *extract.Kind != protocol.CodeActionKindQuickFix {
+		t.Errorf("kind = %v, want quickfix", extract.Kind)
+	}
+	wantExtract := []protocol.TextEdit{
+		{
+			Range:   protocol.Range{Start: protocol.Position{Line: 7}, End: protocol.Position{Line: 7}},
+			NewText: "  foreground = \"#e0def4\"\n",
+		},
+		{
+			Range:   protocol.Range{Start: protocol.Position{Line: 11, Character: 15}, End: protocol.Position{Line: 11, Character: 24}},
+			NewText: "palette.foreground",
+		},
+	}
+	if got := extract.Edit.Changes[uri]; !slices.Equal(got, wantExtract) {
+		t.Errorf("edits = %+v, want %+v", got, wantExtract)
+	}
+
+	if got := codeActions(result, uri, result.Diagnostics[:0]); len(got) != 0 {
+		t.Errorf("actions without diagnostics = %+v, want none", got)
+	}
+}
+
+func TestCodeActions_ExtractKeepsLiteralAndIndent(t *testing.T) {
+	content := `settings {
+  no_literals_outside_palette = true
+}
+
+palette {
+    base = "#191724"
+}
+
+theme {
+  background = [49, 116, 143]
+  foreground = rgb(224, 222, 244)
+}
+`
+	result := Analyze("test.pstheme", content)
+	var diags []protocol.Diagnostic
+	for _, d := range result.Diagnostics {
+		if d.Code != nil && d.Code.Value == "PS004" {
+			diags = append(diags, d)
+		}
+	}
+	const uri = "file:///test.pstheme"
+	actions := codeActions(result, uri, diags)
+	if len(actions) != 2 {
+		t.Fatalf("actions = %+v, want 2", actions)
+	}
+	want := []string{"    background = [49, 116, 143]\n", "    foreground = rgb(224, 222, 244)\n"}
+	for i, action := range actions {
+		edits := action.Edit.Changes[uri]
+		if len(edits) != 2 || edits[0].NewText != want[i] {
+			t.Errorf("%s edits = %+v, want a palette entry %q", action.Title, edits, want[i])
+		}
+	}
+}
+
+func TestNewPaletteName(t *testing.T) {
+	result := Analyze("test.pstheme", `palette {
+  accent  = "#eb6f92"
+  accent2 = "#f6c177"
+  surface {
+    low = "#21202e"
+  }
+}
+`)
+	tests := map[string]string{
+		"accent":  "accent3",
+		"surface": "surface2",
+		"cursor":  "cursor",
+	}
+	palette := result.body.Blocks[0].Body
+	for name, want := range tests {
+		if got := newPaletteName(palette, name); got != want {
+			t.Errorf("newPaletteName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package lsp
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/jsvensson/paletteswap/internal/color"
@@ -252,6 +253,49 @@ func TestColorPresentation_HashWithoutQuotes(t *testing.T) {
 	}
 }
 
+func TestDocumentColors_FunctionArguments(t *testing.T) {
+	content := `palette {
+  overlay = darken("#403d52", 0.2)
+  muted   = brighten("#6e6a86", 0.1)
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	var got []string
+	for _, cl := range result.Colors {
+		got = append(got, extractText(content, cl.Range))
+	}
+	want := []string{
+		`darken("#403d52", 0.2)`,
+		`"#403d52"`,
+		`brighten("#6e6a86", 0.1)`,
+		`"#6e6a86"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("color ranges = %q, want %q", got, want)
+	}
+	if hex := result.Colors[1].Color.Hex(); hex != "#403d52" {
+		t.Errorf("argument color = %s, want #403d52", hex)
+	}
+	if hex := result.Colors[3].Color.Hex(); hex != "#6e6a86" {
+		t.Errorf("argument color = %s, want #6e6a86", hex)
+	}
+	if result.Colors[0].Color == result.Colors[1].Color {
+		t.Errorf("outer color should be the computed color, got the argument %s", result.Colors[1].Color.Hex())
+	}
+
+	// The argument literals can be edited in place; the computed colors cannot.
+	for i, cl := range result.Colors {
+		presentations := colorPresentation(content, &protocol.ColorPresentationParams{
+			Color: protocol.Color{Red: 1.0, Alpha: 1.0},
+			Range: cl.Range,
+		})
+		if wantEdit := i%2 == 1; (len(presentations) == 1) != wantEdit {
+			t.Errorf("%s: presentations = %v, want an edit: %v", got[i], presentations, wantEdit)
+		}
+	}
+}
+
 func TestColorPresentation_Integration(t *testing.T) {
 	// Use the analyzer to produce real color locations, then test color presentation
 	content := `palette {
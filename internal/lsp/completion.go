@@ -1,37 +1,57 @@
 package lsp
 
 import (
+	"fmt"
+	"slices"
 	"strings"
 
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/parser"
 	"github.com/jsvensson/paletteswap/internal/theme"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
 // splitLines splits content into lines, preserving empty trailing lines.
+// A "\r" before each "\n" is dropped, so CRLF files index like LF files.
 func splitLines(content string) []string {
-	return strings.Split(content, "\n")
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
 }
 
 // blockContext represents the kind of block the cursor is in.
 type blockContext int
 
 const (
-	contextRoot    blockContext = iota
-	contextMeta                 // inside meta {}
-	contextPalette              // inside palette {}
-	contextTheme                // inside theme {}
-	contextAnsi                 // inside ansi {}
-	contextSyntax               // inside syntax {} (top level)
-	contextStyle                // inside a sub-block of syntax {} (style block)
+	contextRoot          blockContext = iota
+	contextMeta                       // inside meta {}
+	contextPalette                    // inside palette {}
+	contextTheme                      // inside theme {}
+	contextAnsi                       // inside ansi {}
+	contextSyntax                     // inside syntax {} (top level)
+	contextStyle                      // inside a sub-block of syntax {} or semantic {} (style block)
+	contextSemantic                   // inside semantic {} (top level)
+	contextThemeSubBlock              // inside a typed sub-block of theme {} (cursor, selection)
+	contextLocals                     // inside locals {} (free-form names)
+	contextScale                      // inside a scale "name" {} block in the palette
+	contextPaletteGroup               // inside a nested group block in the palette
+	contextTransform                  // inside the palette's transform {} block
+	contextLightness                  // inside transform { lightness {} }
 )
 
 // styleAttributes are the valid attributes inside a syntax style block.
 var styleAttributes = []string{"color", "bold", "italic", "underline"}
 
-// topLevelBlocks are the valid top-level block names.
-var topLevelBlocks = []string{"meta", "palette", "theme", "syntax", "ansi"}
+// scaleAttributes are the attributes of a palette scale block.
+var scaleAttributes = []string{"from", "to", "steps"}
+
+// topLevelBlocks are the valid top-level block names, in the order they are
+// offered: meta first, then the blocks in evaluation order, then settings.
+var topLevelBlocks = []string{"meta", "palette", "locals", "theme", "ansi", "syntax", "semantic", "settings"}
 
 // complete produces completion items given an analysis result, document content,
 // and cursor position. This is the core logic, decoupled from the LSP protocol
@@ -43,17 +63,22 @@ func complete(result *AnalysisResult, content string, pos protocol.Position) []p
 	}
 
 	line := lines[pos.Line]
-	charPos := min(int(pos.Character), len(line))
+	charPos := byteColumn(line, pos.Character)
 	textBeforeCursor := line[:charPos]
 
 	// Check for palette path completion: look for "palette." or "palette.xxx."
-	if paletteItems := tryPaletteCompletion(result, textBeforeCursor); paletteItems != nil {
+	if paletteItems := tryPaletteCompletion(result, content, textBeforeCursor); paletteItems != nil {
 		return paletteItems
 	}
 
-	// Check for value position (after "=") — offer functions and palette
+	// Check for local completion: "local." or "local.xxx"
+	if localItems := tryLocalCompletion(result, textBeforeCursor); localItems != nil {
+		return localItems
+	}
+
+	// Check for value position (after "=") — offer functions, palette and locals
 	if isValuePosition(textBeforeCursor) {
-		return valueCompletions()
+		return valueCompletions(result)
 	}
 
 	// Determine which block the cursor is in by scanning backwards
@@ -61,11 +86,27 @@ func complete(result *AnalysisResult, content string, pos protocol.Position) []p
 
 	switch ctx {
 	case contextAnsi:
-		return ansiCompletions(lines, int(pos.Line))
+		return ansiCompletions(result.ANSIProfile, lines, int(pos.Line))
+	case contextSemantic:
+		return semanticCompletions(lines, int(pos.Line))
+	case contextThemeSubBlock:
+		return themeSubBlockCompletions(lines, int(pos.Line))
 	case contextStyle:
 		return styleCompletions(lines, int(pos.Line))
+	case contextScale:
+		return remainingAttributeCompletions(scaleAttributes, lines, int(pos.Line))
+	case contextPalette:
+		return paletteBlockCompletions()
+	case contextTransform:
+		return transformBlockCompletions(lines, int(pos.Line))
+	case contextLightness:
+		return remainingAttributeCompletions(parser.TransformAttributes, lines, int(pos.Line))
+	case contextPaletteGroup:
+		// The reserved color key is offered only here, where it sets the
+		// group's own color; palette paths never list it as a child.
+		return remainingAttributeCompletions([]string{color.ColorKey}, lines, int(pos.Line))
 	case contextRoot:
-		return topLevelCompletions()
+		return topLevelCompletions(result)
 	}
 
 	return nil
@@ -74,7 +115,7 @@ func complete(result *AnalysisResult, content string, pos protocol.Position) []p
 // tryPaletteCompletion checks if the text before the cursor ends with a palette
 // path prefix (e.g., "palette." or "palette.highlight.") and returns completion
 // items for the children at that node in the palette tree.
-func tryPaletteCompletion(result *AnalysisResult, textBeforeCursor string) []protocol.CompletionItem {
+func tryPaletteCompletion(result *AnalysisResult, content, textBeforeCursor string) []protocol.CompletionItem {
 	if result == nil || result.Palette == nil {
 		return nil
 	}
@@ -123,23 +164,71 @@ func tryPaletteCompletion(result *AnalysisResult, textBeforeCursor string) []pro
 		return nil
 	}
 
-	return nodeChildrenToCompletionItems(node)
+	return nodeChildrenToCompletionItems(result, content, strings.Join(append([]string{"palette"}, segments...), "."), node)
+}
+
+// tryLocalCompletion checks if the text before the cursor ends with "local."
+// followed by a partial name and returns completion items for the defined
+// locals. Locals that resolve to colors show their hex value in Detail.
+func tryLocalCompletion(result *AnalysisResult, textBeforeCursor string) []protocol.CompletionItem {
+	if result == nil || len(result.Locals) == 0 {
+		return nil
+	}
+
+	idx := strings.LastIndex(textBeforeCursor, "local.")
+	if idx == -1 || (idx > 0 && isIdentChar(textBeforeCursor[idx-1])) {
+		return nil
+	}
+	if strings.Contains(textBeforeCursor[idx+len("local."):], ".") {
+		return nil // locals are flat
+	}
+
+	var items []protocol.CompletionItem
+	for name, val := range result.Locals {
+		item := protocol.CompletionItem{
+			Label: name,
+			Kind:  completionKindPtr(protocol.CompletionItemKindVariable),
+		}
+		if hexStr, err := theme.ResolveColor(val); err == nil {
+			if c, err := color.ParseHex(hexStr); err == nil {
+				hex := c.Hex()
+				item.Kind = completionKindPtr(protocol.CompletionItemKindColor)
+				item.Detail = &hex
+			}
+		} else {
+			detail := val.Type().FriendlyName()
+			item.Detail = &detail
+		}
+		items = append(items, item)
+	}
+
+	return items
 }
 
-// nodeChildrenToCompletionItems converts a node's children into completion items.
-func nodeChildrenToCompletionItems(node *color.Node) []protocol.CompletionItem {
+// nodeChildrenToCompletionItems converts the children of the node at path
+// into completion items, documenting where each child is defined.
+func nodeChildrenToCompletionItems(result *AnalysisResult, content, path string, node *color.Node) []protocol.CompletionItem {
 	var items []protocol.CompletionItem
 	kind := protocol.CompletionItemKindColor
 
-	for name, child := range node.Children {
+	// Sorted so that clients which keep the server's order, and tests, see
+	// a stable list.
+	for _, name := range sortedChildNames(node) {
+		child := node.Children[name]
 		item := protocol.CompletionItem{
 			Label: name,
 			Kind:  &kind,
 		}
+		if doc := result.definitionDoc(content, path+"."+name); doc != "" {
+			item.Documentation = protocol.MarkupContent{Kind: protocol.MarkupKindMarkdown, Value: doc}
+		}
 
 		// If the child has a direct color, show it in Detail
 		if child.Color != nil {
 			hex := child.Color.Hex()
+			if result.Generated[path+"."+name] {
+				hex += " (generated)"
+			}
 			item.Detail = &hex
 		} else if child.Children != nil {
 			// It's a group/namespace — still offer it but with a different detail
@@ -155,6 +244,48 @@ func nodeChildrenToCompletionItems(node *color.Node) []protocol.CompletionItem {
 	return items
 }
 
+// definitionDoc describes where the symbol at path is defined: its line and
+// whether its value is a literal, a reference or the result of a function.
+// It returns "" for paths without a symbol.
+func (r *AnalysisResult) definitionDoc(content, path string) string {
+	rng, ok := r.Symbols[path]
+	if !ok {
+		return ""
+	}
+	doc := fmt.Sprintf("Defined on line %d", rng.Start.Line+1)
+
+	expr, ok := r.exprs[path]
+	if !ok {
+		if r.Generated[path] {
+			_, step, _ := cutLast(path)
+			return fmt.Sprintf("Generated by the transform block on line %d as lightness step `%s`", rng.Start.Line+1, step)
+		}
+		// Scale steps share the range of their scale block.
+		if parent, step, ok := cutLast(path); ok && r.Symbols[parent] == rng {
+			return fmt.Sprintf("%s as step %s of scale `%s`", doc, step, parent)
+		}
+		return doc
+	}
+	src := extractText(content, r.lspRange(expr.Range()))
+	switch expr.(type) {
+	case *hclsyntax.ScopeTraversalExpr:
+		return fmt.Sprintf("%s as a reference to `%s`", doc, src)
+	case *hclsyntax.FunctionCallExpr:
+		return fmt.Sprintf("%s as the result of `%s`", doc, src)
+	default:
+		return fmt.Sprintf("%s as `%s`", doc, src)
+	}
+}
+
+// cutLast splits a dotted path before its last segment.
+func cutLast(path string) (parent, last string, ok bool) {
+	i := strings.LastIndexByte(path, '.')
+	if i < 0 {
+		return "", "", false
+	}
+	return path[:i], path[i+1:], true
+}
+
 // isValuePosition returns true if the text before the cursor indicates we are
 // at a value position (after an "=" sign with nothing meaningful following it).
 func isValuePosition(textBeforeCursor string) bool {
@@ -168,15 +299,29 @@ func isValuePosition(textBeforeCursor string) bool {
 }
 
 // valueCompletions returns completion items for a value position, including
-// function snippets and a palette reference trigger.
-func valueCompletions() []protocol.CompletionItem {
+// function snippets and palette and local reference triggers.
+func valueCompletions(result *AnalysisResult) []protocol.CompletionItem {
 	snippetFormat := protocol.InsertTextFormatSnippet
 
 	brightenSnippet := "brighten(${1:color}, ${2:0.1})"
 	darkenSnippet := "darken(${1:color}, ${2:0.1})"
+	lightenOKSnippet := "lighten_ok(${1:color}, ${2:0.1})"
+	darkenOKSnippet := "darken_ok(${1:color}, ${2:0.1})"
+	saturateSnippet := "saturate(${1:color}, ${2:0.1})"
+	desaturateSnippet := "desaturate(${1:color}, ${2:0.1})"
+	rotateHueSnippet := "rotate_hue(${1:color}, ${2:30})"
+	complementSnippet := "complement(${1:color})"
+	invertSnippet := "invert(${1:color})"
+	mixSnippet := "mix(${1:color1}, ${2:color2}, ${3:0.5})"
+	shadeSnippet := "shade(${1:color}, ${2:0.2})"
+	tintSnippet := "tint(${1:color}, ${2:0.2})"
+	graySnippet := "gray(${1:0.2})"
+	hslSnippet := "hsl(${1:hue}, ${2:0.5}, ${3:0.5})"
+	rgbSnippet := "rgb(${1:red}, ${2:green}, ${3:blue})"
 	paletteSnippet := "palette."
+	localSnippet := "local."
 
-	return []protocol.CompletionItem{
+	items := []protocol.CompletionItem{
 		{
 			Label:            "brighten",
 			Kind:             completionKindPtr(protocol.CompletionItemKindFunction),
@@ -191,6 +336,97 @@ func valueCompletions() []protocol.CompletionItem {
 			InsertText:       &darkenSnippet,
 			InsertTextFormat: &snippetFormat,
 		},
+		{
+			Label:            "lighten_ok",
+			Kind:             completionKindPtr(protocol.CompletionItemKindFunction),
+			Detail:           strPtr("lighten_ok(color, amount)"),
+			InsertText:       &lightenOKSnippet,
+			InsertTextFormat: &snippetFormat,
+		},
+		{
+			Label:            "darken_ok",
+			Kind:             completionKindPtr(protocol.CompletionItemKindFunction),
+			Detail:           strPtr("darken_ok(color, amount)"),
+			InsertText:       &darkenOKSnippet,
+			InsertTextFormat: &snippetFormat,
+		},
+		{
+			Label:            "saturate",
+			Kind:             completionKindPtr(protocol.CompletionItemKindFunction),
+			Detail:           strPtr("saturate(color, amount)"),
+			InsertText:       &saturateSnippet,
+			InsertTextFormat: &snippetFormat,
+		},
+		{
+			Label:            "desaturate",
+			Kind:             completionKindPtr(protocol.CompletionItemKindFunction),
+			Detail:           strPtr("desaturate(color, amount)"),
+			InsertText:       &desaturateSnippet,
+			InsertTextFormat: &snippetFormat,
+		},
+		{
+			Label:            "rotate_hue",
+			Kind:             completionKindPtr(protocol.CompletionItemKindFunction),
+			Detail:           strPtr("rotate_hue(color, degrees)"),
+			InsertText:       &rotateHueSnippet,
+			InsertTextFormat: &snippetFormat,
+		},
+		{
+			Label:            "complement",
+			Kind:             completionKindPtr(protocol.CompletionItemKindFunction),
+			Detail:           strPtr("complement(color)"),
+			InsertText:       &complementSnippet,
+			InsertTextFormat: &snippetFormat,
+		},
+		{
+			Label:            "invert",
+			Kind:             completionKindPtr(protocol.CompletionItemKindFunction),
+			Detail:           strPtr("invert(color)"),
+			InsertText:       &invertSnippet,
+			InsertTextFormat: &snippetFormat,
+		},
+		{
+			Label:            "mix",
+			Kind:             completionKindPtr(protocol.CompletionItemKindFunction),
+			Detail:           strPtr("mix(color1, color2, weight)"),
+			InsertText:       &mixSnippet,
+			InsertTextFormat: &snippetFormat,
+		},
+		{
+			Label:            "shade",
+			Kind:             completionKindPtr(protocol.CompletionItemKindFunction),
+			Detail:           strPtr("shade(color, amount)"),
+			InsertText:       &shadeSnippet,
+			InsertTextFormat: &snippetFormat,
+		},
+		{
+			Label:            "tint",
+			Kind:             completionKindPtr(protocol.CompletionItemKindFunction),
+			Detail:           strPtr("tint(color, amount)"),
+			InsertText:       &tintSnippet,
+			InsertTextFormat: &snippetFormat,
+		},
+		{
+			Label:            "gray",
+			Kind:             completionKindPtr(protocol.CompletionItemKindFunction),
+			Detail:           strPtr("gray(lightness)"),
+			InsertText:       &graySnippet,
+			InsertTextFormat: &snippetFormat,
+		},
+		{
+			Label:            "hsl",
+			Kind:             completionKindPtr(protocol.CompletionItemKindFunction),
+			Detail:           strPtr("hsl(hue, saturation, lightness)"),
+			InsertText:       &hslSnippet,
+			InsertTextFormat: &snippetFormat,
+		},
+		{
+			Label:            "rgb",
+			Kind:             completionKindPtr(protocol.CompletionItemKindFunction),
+			Detail:           strPtr("rgb(red, green, blue)"),
+			InsertText:       &rgbSnippet,
+			InsertTextFormat: &snippetFormat,
+		},
 		{
 			Label:      "palette",
 			Kind:       completionKindPtr(protocol.CompletionItemKindVariable),
@@ -198,6 +434,17 @@ func valueCompletions() []protocol.CompletionItem {
 			InsertText: &paletteSnippet,
 		},
 	}
+
+	if result != nil && len(result.Locals) > 0 {
+		items = append(items, protocol.CompletionItem{
+			Label:      "local",
+			Kind:       completionKindPtr(protocol.CompletionItemKindVariable),
+			Detail:     strPtr("local reference"),
+			InsertText: &localSnippet,
+		})
+	}
+
+	return items
 }
 
 // determineBlockContext scans from the top of the file down to the cursor line
@@ -253,26 +500,95 @@ func determineBlockContext(lines []string, cursorLine int) blockContext {
 		return contextAnsi
 	case "syntax":
 		return contextSyntax
+	case "semantic":
+		return contextSemantic
+	case "locals":
+		return contextLocals
 	default:
-		// If the parent is "syntax", we're in a style sub-block
+		// If the parent is "syntax" or "semantic", we're in a style sub-block
 		if len(stack) >= 2 {
 			parent := stack[len(stack)-2]
-			if parent.name == "syntax" {
+			if parent.name == "syntax" || parent.name == "semantic" {
 				return contextStyle
 			}
+			if current.name == "scale" && parent.name == "palette" {
+				return contextScale
+			}
+			if current.name == "transform" && parent.name == "palette" && len(stack) == 2 {
+				return contextTransform
+			}
+			if current.name == "lightness" && parent.name == "transform" && len(stack) == 3 && stack[0].name == "palette" {
+				return contextLightness
+			}
+			if _, ok := theme.ThemeSubBlocks[current.name]; ok && parent.name == "theme" {
+				return contextThemeSubBlock
+			}
+			if stack[0].name == "palette" {
+				return contextPaletteGroup
+			}
 		}
 		return contextRoot
 	}
 }
 
 // ansiCompletions returns ANSI color name completions, excluding names that are
-// already defined in the ansi block surrounding the cursor.
-func ansiCompletions(lines []string, cursorLine int) []protocol.CompletionItem {
+// already defined in the ansi block surrounding the cursor. Names that profile
+// does not require are marked optional.
+func ansiCompletions(profile theme.ANSIProfile, lines []string, cursorLine int) []protocol.CompletionItem {
 	defined := findDefinedAttributes(lines, cursorLine)
 	kind := protocol.CompletionItemKindConstant
+	required := profile.Required()
 
 	var items []protocol.CompletionItem
-	for _, name := range theme.RequiredANSIColors {
+	for _, name := range theme.ANSIColorNames {
+		if !defined[name] {
+			item := protocol.CompletionItem{
+				Label: name,
+				Kind:  &kind,
+			}
+			if !slices.Contains(required, name) {
+				item.Detail = strPtr("optional")
+			}
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// semanticCompletions returns standard semantic token type completions,
+// excluding types already defined in the semantic block.
+func semanticCompletions(lines []string, cursorLine int) []protocol.CompletionItem {
+	defined := findDefinedAttributes(lines, cursorLine)
+	kind := protocol.CompletionItemKindConstant
+
+	var items []protocol.CompletionItem
+	for _, name := range theme.SemanticTokenTypes {
+		if !defined[name] {
+			items = append(items, protocol.CompletionItem{
+				Label: name,
+				Kind:  &kind,
+			})
+		}
+	}
+
+	return items
+}
+
+// themeSubBlockCompletions returns the attributes of the typed theme sub-block
+// surrounding the cursor, excluding attributes already defined.
+func themeSubBlockCompletions(lines []string, cursorLine int) []protocol.CompletionItem {
+	start := findBlockStart(lines, cursorLine)
+	fields := strings.Fields(strings.TrimSpace(lines[start]))
+	if len(fields) == 0 {
+		return nil
+	}
+
+	defined := findDefinedAttributes(lines, cursorLine)
+	kind := protocol.CompletionItemKindProperty
+
+	var items []protocol.CompletionItem
+	for _, name := range theme.ThemeSubBlocks[fields[0]] {
 		if !defined[name] {
 			items = append(items, protocol.CompletionItem{
 				Label: name,
@@ -287,11 +603,17 @@ func ansiCompletions(lines []string, cursorLine int) []protocol.CompletionItem {
 // styleCompletions returns style attribute completions, excluding attributes
 // already defined in the current style block.
 func styleCompletions(lines []string, cursorLine int) []protocol.CompletionItem {
+	return remainingAttributeCompletions(styleAttributes, lines, cursorLine)
+}
+
+// remainingAttributeCompletions returns keyword completions for the given
+// attribute names, excluding those already defined in the current block.
+func remainingAttributeCompletions(names []string, lines []string, cursorLine int) []protocol.CompletionItem {
 	defined := findDefinedAttributes(lines, cursorLine)
 	kind := protocol.CompletionItemKindKeyword
 
 	var items []protocol.CompletionItem
-	for _, name := range styleAttributes {
+	for _, name := range names {
 		if !defined[name] {
 			items = append(items, protocol.CompletionItem{
 				Label: name,
@@ -303,25 +625,60 @@ func styleCompletions(lines []string, cursorLine int) []protocol.CompletionItem
 	return items
 }
 
+// paletteBlockCompletions returns the block snippets available directly
+// inside the palette block.
+func paletteBlockCompletions() []protocol.CompletionItem {
+	snippetFormat := protocol.InsertTextFormatSnippet
+	scaleSnippet := "scale \"${1:name}\" {\n  from  = ${2}\n  to    = ${3}\n  steps = ${4:5}\n}"
+	transformSnippet := "transform {\n  lightness {\n    range = [${1:0.2}, ${2:0.8}]\n    steps = ${3:5}\n  }\n}"
+
+	return []protocol.CompletionItem{
+		{
+			Label:            "scale",
+			Kind:             completionKindPtr(protocol.CompletionItemKindSnippet),
+			Detail:           strPtr("interpolate name.1..name.N between two colors"),
+			InsertText:       &scaleSnippet,
+			InsertTextFormat: &snippetFormat,
+		},
+		{
+			Label:            "transform",
+			Kind:             completionKindPtr(protocol.CompletionItemKindSnippet),
+			Detail:           strPtr("give every color lightness steps l1..lN"),
+			InsertText:       &transformSnippet,
+			InsertTextFormat: &snippetFormat,
+		},
+	}
+}
+
+// transformBlockCompletions offers the lightness block inside a transform
+// block that does not have one yet.
+func transformBlockCompletions(lines []string, cursorLine int) []protocol.CompletionItem {
+	start := findBlockStart(lines, cursorLine)
+	for _, line := range lines[start+1 : cursorLine] {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "lightness" {
+			return nil
+		}
+	}
+
+	snippetFormat := protocol.InsertTextFormatSnippet
+	snippet := "lightness {\n  range = [${1:0.2}, ${2:0.8}]\n  steps = ${3:5}\n}"
+	return []protocol.CompletionItem{
+		{
+			Label:            "lightness",
+			Kind:             completionKindPtr(protocol.CompletionItemKindSnippet),
+			Detail:           strPtr("OKLCH lightness range and number of steps"),
+			InsertText:       &snippet,
+			InsertTextFormat: &snippetFormat,
+		},
+	}
+}
+
 // findDefinedAttributes scans the current block (from the nearest opening brace
 // before cursorLine to cursorLine) and returns attribute names already defined
 // (lines containing "name = ...").
 func findDefinedAttributes(lines []string, cursorLine int) map[string]bool {
 	defined := make(map[string]bool)
-
-	// Scan backwards to find the opening brace of the current block
-	startLine := 0
-	depth := 0
-	for i := cursorLine; i >= 0; i-- {
-		line := strings.TrimSpace(lines[i])
-		closes := strings.Count(line, "}")
-		opens := strings.Count(line, "{")
-		depth += closes - opens
-		if depth < 0 {
-			startLine = i
-			break
-		}
-	}
+	startLine := findBlockStart(lines, cursorLine)
 
 	// Scan forward from startLine to cursorLine, collecting attribute names
 	for i := startLine; i <= cursorLine; i++ {
@@ -337,17 +694,40 @@ func findDefinedAttributes(lines []string, cursorLine int) map[string]bool {
 	return defined
 }
 
-// topLevelCompletions returns completion items for top-level block names.
-func topLevelCompletions() []protocol.CompletionItem {
+// findBlockStart scans backwards from cursorLine and returns the line holding
+// the opening brace of the block surrounding the cursor, or 0 if none.
+func findBlockStart(lines []string, cursorLine int) int {
+	depth := 0
+	for i := cursorLine; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		closes := strings.Count(line, "}")
+		opens := strings.Count(line, "{")
+		depth += closes - opens
+		if depth < 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// topLevelCompletions returns completion items for top-level block names,
+// skipping blocks that may appear only once and are already in the file.
+// SortText keeps the topLevelBlocks order in clients that sort by label.
+func topLevelCompletions(result *AnalysisResult) []protocol.CompletionItem {
 	snippetFormat := protocol.InsertTextFormatSnippet
 	kind := protocol.CompletionItemKindSnippet
 
 	var items []protocol.CompletionItem
-	for _, name := range topLevelBlocks {
+	for i, name := range topLevelBlocks {
+		if result != nil && result.Blocks[name] && slices.Contains(theme.UniqueBlocks, name) {
+			continue
+		}
 		snippet := name + " {\n  $0\n}"
+		sortText := fmt.Sprintf("%d", i)
 		items = append(items, protocol.CompletionItem{
 			Label:            name,
 			Kind:             &kind,
+			SortText:         &sortText,
 			InsertText:       &snippet,
 			InsertTextFormat: &snippetFormat,
 		})
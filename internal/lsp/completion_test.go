@@ -1,6 +1,7 @@
 package lsp
 
 import (
+	"slices"
 	"sort"
 	"strings"
 	"testing"
@@ -380,12 +381,249 @@ palette {
 		t.Fatal("expected top-level block completion items, got none")
 	}
 
-	expectedBlocks := []string{"meta", "palette", "theme", "syntax", "ansi"}
-	for _, block := range expectedBlocks {
-		if !hasLabel(items, block) {
-			t.Errorf("expected top-level block completion %q", block)
+	var got []string
+	for _, item := range items {
+		got = append(got, item.Label)
+	}
+	want := []string{"meta", "locals", "theme", "ansi", "syntax", "semantic", "settings"}
+	if !slices.Equal(got, want) {
+		t.Errorf("top-level completions = %v, want %v", got, want)
+	}
+	for i := 1; i < len(items); i++ {
+		if *items[i-1].SortText >= *items[i].SortText {
+			t.Errorf("SortText of %q should sort before %q", items[i-1].Label, items[i].Label)
+		}
+	}
+}
+
+func TestCompletion_TopLevelBlocksPresent(t *testing.T) {
+	content := `
+palette {
+  base = "#191724"
+}
+
+syntax {
+  keyword = palette.base
+}
+
+
+meta {
+  name = "Late Meta"
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	items := complete(result, content, protocol.Position{Line: 8, Character: 0})
+	for _, block := range []string{"meta", "palette"} {
+		if hasLabel(items, block) {
+			t.Errorf("%q is already present and should not be offered, got %v", block, items)
+		}
+	}
+	if !hasLabel(items, "syntax") {
+		t.Errorf("syntax may be repeated and should still be offered, got %v", items)
+	}
+
+	if !result.Blocks["meta"] || !result.Blocks["syntax"] || result.Blocks["theme"] {
+		t.Errorf("Blocks = %v, want meta, palette and syntax", result.Blocks)
+	}
+}
+
+func TestCompletion_SemanticTokenTypes(t *testing.T) {
+	content := `
+palette {
+  base = "#191724"
+}
+
+semantic {
+  parameter = palette.base
+
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	pos := protocol.Position{Line: 7, Character: 2}
+	items := complete(result, content, pos)
+
+	if !hasLabel(items, "decorator") {
+		t.Error("expected semantic token type completion \"decorator\"")
+	}
+	if hasLabel(items, "parameter") {
+		t.Error("already defined \"parameter\" should not be offered")
+	}
+}
+
+func TestCompletion_ThemeSubBlock(t *testing.T) {
+	content := `
+palette {
+  base = "#191724"
+}
+
+theme {
+  cursor {
+    cursor = palette.base
+
+  }
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	pos := protocol.Position{Line: 8, Character: 4}
+	items := complete(result, content, pos)
+
+	if !hasLabel(items, "text") {
+		t.Error("expected cursor sub-block completion \"text\"")
+	}
+	if hasLabel(items, "cursor") {
+		t.Error("already defined \"cursor\" should not be offered")
+	}
+}
+
+func TestCompletion_Locals(t *testing.T) {
+	content := `
+palette {
+  base = "#191724"
+}
+
+locals {
+  step   = 0.1
+  accent = palette.base
+}
+
+theme {
+  background = local.
+  foreground = 
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	items := complete(result, content, protocol.Position{Line: 11, Character: 21})
+	if !hasLabel(items, "step") || !hasLabel(items, "accent") {
+		t.Fatalf("expected local completions step and accent, got %v", items)
+	}
+	for _, item := range items {
+		if item.Label == "accent" && (item.Detail == nil || *item.Detail != "#191724") {
+			t.Errorf("accent detail = %v, want #191724", item.Detail)
+		}
+	}
+
+	items = complete(result, content, protocol.Position{Line: 12, Character: 15})
+	if !hasLabel(items, "local") {
+		t.Error("expected \"local\" in value position completions")
+	}
+}
+
+func TestCompletion_Scale(t *testing.T) {
+	content := `
+palette {
+  base = "#000000"
+  scale "surface" {
+    from  = palette.base
+    to    = "#ffffff"
+    steps = 3
+  }
+
+  scale "ramp" {
+    from = palette.base
+
+  }
+}
+
+theme {
+  background = palette.surface.
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	items := complete(result, content, protocol.Position{Line: 16, Character: 32})
+	for _, label := range []string{"1", "2", "3"} {
+		if !hasLabel(items, label) {
+			t.Errorf("expected scale step %q in completions, got %v", label, items)
 		}
 	}
+
+	items = complete(result, content, protocol.Position{Line: 11, Character: 4})
+	if hasLabel(items, "from") || !hasLabel(items, "to") || !hasLabel(items, "steps") {
+		t.Errorf("scale block completions = %v, want to and steps", items)
+	}
+
+	items = complete(result, content, protocol.Position{Line: 8, Character: 2})
+	if !hasLabel(items, "scale") {
+		t.Errorf("expected scale snippet inside palette, got %v", items)
+	}
+}
+
+func TestCompletion_Transform(t *testing.T) {
+	content := `
+palette {
+  base = "#000000"
+  transform {
+
+    lightness {
+      range = [0.1, 0.9]
+
+    }
+  }
+
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	items := complete(result, content, protocol.Position{Line: 10, Character: 2})
+	if !hasLabel(items, "transform") {
+		t.Errorf("expected transform snippet inside palette, got %v", items)
+	}
+
+	items = complete(result, content, protocol.Position{Line: 4, Character: 4})
+	if !hasLabel(items, "lightness") || hasLabel(items, "color") {
+		t.Errorf("transform block completions = %v, want lightness", items)
+	}
+
+	items = complete(result, content, protocol.Position{Line: 7, Character: 6})
+	if hasLabel(items, "range") || !hasLabel(items, "steps") || hasLabel(items, "color") {
+		t.Errorf("lightness block completions = %v, want steps", items)
+	}
+}
+
+func TestCompletion_ReservedColorKey(t *testing.T) {
+	content := `
+palette {
+  highlight {
+    low = "#21202e"
+
+  }
+  accent {
+    color = "#eb6f92"
+    dim   = "#8a4058"
+
+  }
+}
+
+theme {
+  background = palette.accent.
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	items := complete(result, content, protocol.Position{Line: 4, Character: 4})
+	if !hasLabel(items, "color") {
+		t.Errorf("expected color inside a palette group, got %v", items)
+	}
+	if hasLabel(items, "palette") {
+		t.Errorf("top-level blocks should not be offered inside a palette group, got %v", items)
+	}
+
+	items = complete(result, content, protocol.Position{Line: 9, Character: 4})
+	if hasLabel(items, "color") {
+		t.Errorf("color should not be offered once defined, got %v", items)
+	}
+
+	items = complete(result, content, protocol.Position{Line: 14, Character: 30})
+	if !hasLabel(items, "dim") {
+		t.Errorf("expected dim in palette.accent. completions, got %v", items)
+	}
+	if hasLabel(items, "color") {
+		t.Errorf("palette paths should not offer the reserved color key, got %v", items)
+	}
 }
 
 func TestCompletion_StyleAttributes(t *testing.T) {
@@ -538,6 +776,14 @@ ansi {
 	if !hasLabel(items, "darken") {
 		t.Error("expected 'darken' function completion")
 	}
+	if !hasLabel(items, "mix") {
+		t.Error("expected 'mix' function completion")
+	}
+	for _, name := range []string{"lighten_ok", "darken_ok", "saturate", "desaturate", "rotate_hue", "complement", "invert", "shade", "tint", "gray", "hsl", "rgb"} {
+		if !hasLabel(items, name) {
+			t.Errorf("expected '%s' function completion", name)
+		}
+	}
 
 	// Functions should also include palette. as a trigger
 	if !hasLabel(items, "palette") {
@@ -714,3 +960,146 @@ ansi {
 		t.Error("should not suggest reserved keyword 'color' as palette completion")
 	}
 }
+
+func TestCompletion_PaletteDefinitionDoc(t *testing.T) {
+	content := `palette {
+  base    = "#191724"
+  surface = brighten(palette.base, 0.1)
+  accent  = palette.base
+  scale "gray" {
+    from  = "#000000"
+    to    = "#ffffff"
+    steps = 3
+  }
+}
+
+theme {
+  background = palette.
+}
+`
+	result := Analyze("test.pstheme", content)
+	items := complete(result, content, protocol.Position{Line: 12, Character: 23})
+
+	want := map[string]string{
+		"base":    "Defined on line 2 as `\"#191724\"`",
+		"surface": "Defined on line 3 as the result of `brighten(palette.base, 0.1)`",
+		"accent":  "Defined on line 4 as a reference to `palette.base`",
+		"gray":    "Defined on line 5",
+	}
+	for _, item := range items {
+		w, ok := want[item.Label]
+		if !ok {
+			continue
+		}
+		delete(want, item.Label)
+		doc, ok := item.Documentation.(protocol.MarkupContent)
+		if !ok {
+			t.Errorf("%s: Documentation = %#v, want MarkupContent", item.Label, item.Documentation)
+			continue
+		}
+		if doc.Value != w {
+			t.Errorf("%s: Documentation = %q, want %q", item.Label, doc.Value, w)
+		}
+	}
+	for label := range want {
+		t.Errorf("missing completion item %q", label)
+	}
+
+	steps := complete(result, strings.Replace(content, "palette.\n", "palette.gray.\n", 1), protocol.Position{Line: 12, Character: 28})
+	for _, item := range steps {
+		if item.Label != "2" {
+			continue
+		}
+		doc, _ := item.Documentation.(protocol.MarkupContent)
+		if want := "Defined on line 5 as step 2 of scale `palette.gray`"; doc.Value != want {
+			t.Errorf("step 2: Documentation = %q, want %q", doc.Value, want)
+		}
+		return
+	}
+	t.Error("missing completion item for scale step 2")
+}
+
+func TestCompletion_TransformSteps(t *testing.T) {
+	content := `palette {
+  base = "#191724"
+  transform {
+    lightness {
+      range = [0.2, 0.8]
+      steps = 3
+    }
+  }
+}
+
+theme {
+  background = palette.base.
+}
+`
+	result := Analyze("test.pstheme", content)
+	items := complete(result, content, protocol.Position{Line: 11, Character: 28})
+
+	for _, label := range []string{"l1", "l2", "l3"} {
+		if !hasLabel(items, label) {
+			t.Errorf("expected transform step %q in completions, got %v", label, items)
+		}
+	}
+	for _, item := range items {
+		if item.Label != "l2" {
+			continue
+		}
+		if item.Detail == nil || !strings.HasSuffix(*item.Detail, " (generated)") {
+			t.Errorf("l2: Detail = %v, want a hex marked as generated", item.Detail)
+		}
+		doc, _ := item.Documentation.(protocol.MarkupContent)
+		if want := "Generated by the transform block on line 3 as lightness step `l2`"; doc.Value != want {
+			t.Errorf("l2: Documentation = %q, want %q", doc.Value, want)
+		}
+	}
+}
+
+func TestCompletion_ANSIProfile(t *testing.T) {
+	content := `
+settings {
+  ansi_profile = "extended"
+}
+
+palette {
+  base = "#191724"
+}
+
+ansi {
+  black = palette.base
+
+}
+`
+	detail := func(profile, label string) string {
+		src := strings.Replace(content, "extended", profile, 1)
+		items := complete(Analyze("test.pstheme", src), src, protocol.Position{Line: 11, Character: 2})
+		if hasLabel(items, "black") {
+			t.Errorf("ansi completions = %v, want black excluded once defined", items)
+		}
+		for _, item := range items {
+			if item.Label != label {
+				continue
+			}
+			if item.Detail == nil {
+				return ""
+			}
+			return *item.Detail
+		}
+		t.Errorf("%s: missing completion item %q", profile, label)
+		return ""
+	}
+
+	if got := detail("extended", "cursor"); got != "" {
+		t.Errorf("extended: cursor detail = %q, want none for a required color", got)
+	}
+	if got := detail("standard16", "cursor"); got != "optional" {
+		t.Errorf("standard16: cursor detail = %q, want optional", got)
+	}
+	if got := detail("basic8", "bright_white"); got != "optional" {
+		t.Errorf("basic8: bright_white detail = %q, want optional", got)
+	}
+	if got := detail("standard16", "selection_foreground"); got != "optional" {
+		t.Errorf("standard16: selection_foreground detail = %q, want optional", got)
+	}
+}
@@ -0,0 +1,185 @@
+package lsp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jsvensson/paletteswap/internal/parser"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// The corpus in testdata/corpus pins the behavior of theme files reported
+// in issues. Each NAME.pstheme fixture has a NAME.diagnostics sidecar
+// listing, one per line, the diagnostics Analyze must report:
+//
+//	LINE SEVERITY MESSAGE
+//
+// LINE is 1-based, SEVERITY is error, warning or info, and MESSAGE is a
+// substring of the diagnostic's message. The list must be complete. A line
+//
+//	parse error MESSAGE
+//
+// says parser.Parse fails with an error containing MESSAGE; without one,
+// Parse must succeed. Blank lines and lines starting with # are ignored.
+//
+// To add a case, write the fixture and run the test with -update, which
+// writes the sidecar from the current diagnostics. Review it, and cut
+// messages that include the fixture path down to the part after it.
+
+// corpusExpectation is one line of a .diagnostics sidecar.
+type corpusExpectation struct {
+	line     int
+	severity string
+	message  string
+}
+
+func (e corpusExpectation) String() string {
+	return fmt.Sprintf("%d %s %s", e.line, e.severity, e.message)
+}
+
+// corpusCase is a parsed .diagnostics sidecar.
+type corpusCase struct {
+	diagnostics []corpusExpectation
+	parseError  string // expected substring of the Parse error, "" if Parse succeeds
+}
+
+func TestCorpus(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "corpus", "*.pstheme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures in testdata/corpus")
+	}
+
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".pstheme")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sidecar := strings.TrimSuffix(fixture, ".pstheme") + ".diagnostics"
+			diags := Analyze(fixture, string(src)).Diagnostics
+			_, parseErr := parser.Parse(fixture)
+
+			if *update {
+				writeCorpusSidecar(t, sidecar, diags, parseErr)
+				return
+			}
+
+			want, err := readCorpusSidecar(sidecar)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			checkCorpusDiagnostics(t, diags, want.diagnostics)
+
+			switch {
+			case want.parseError == "" && parseErr != nil:
+				t.Errorf("Parse() error: %v, want success", parseErr)
+			case want.parseError != "" && parseErr == nil:
+				t.Errorf("Parse() succeeded, want error containing %q", want.parseError)
+			case want.parseError != "" && !strings.Contains(parseErr.Error(), want.parseError):
+				t.Errorf("Parse() error = %v, want containing %q", parseErr, want.parseError)
+			}
+		})
+	}
+}
+
+// checkCorpusDiagnostics matches each expectation to a distinct diagnostic,
+// and reports expectations left unmatched and diagnostics not expected.
+func checkCorpusDiagnostics(t *testing.T, diags []protocol.Diagnostic, want []corpusExpectation) {
+	t.Helper()
+	matched := make([]bool, len(diags))
+	for _, w := range want {
+		found := false
+		for i, d := range diags {
+			if !matched[i] && int(d.Range.Start.Line)+1 == w.line && severityName(d.Severity) == w.severity &&
+				strings.Contains(d.Message, w.message) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("missing diagnostic: %s", w)
+		}
+	}
+	for i, d := range diags {
+		if !matched[i] {
+			t.Errorf("unexpected diagnostic: %d %s %s", d.Range.Start.Line+1, severityName(d.Severity), d.Message)
+		}
+	}
+}
+
+func severityName(s *protocol.DiagnosticSeverity) string {
+	if s == nil {
+		return "info"
+	}
+	switch *s {
+	case DiagError:
+		return "error"
+	case DiagWarning:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+func readCorpusSidecar(path string) (corpusCase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return corpusCase{}, err
+	}
+	defer f.Close()
+
+	var c corpusCase
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 3 {
+			return corpusCase{}, fmt.Errorf("%s:%d: want LINE SEVERITY MESSAGE or parse error MESSAGE", path, n)
+		}
+		if fields[0] == "parse" && fields[1] == "error" {
+			c.parseError = fields[2]
+			continue
+		}
+		lineNum, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return corpusCase{}, fmt.Errorf("%s:%d: bad line number %q", path, n, fields[0])
+		}
+		switch fields[1] {
+		case "error", "warning", "info":
+		default:
+			return corpusCase{}, fmt.Errorf("%s:%d: bad severity %q", path, n, fields[1])
+		}
+		c.diagnostics = append(c.diagnostics, corpusExpectation{line: lineNum, severity: fields[1], message: fields[2]})
+	}
+	return c, scanner.Err()
+}
+
+// writeCorpusSidecar writes the sidecar matching diags and parseErr, with
+// each message in full. Multi-line messages are cut at the first line break.
+func writeCorpusSidecar(t *testing.T, path string, diags []protocol.Diagnostic, parseErr error) {
+	t.Helper()
+	var b strings.Builder
+	for _, d := range diags {
+		msg, _, _ := strings.Cut(d.Message, "\n")
+		fmt.Fprintf(&b, "%d %s %s\n", d.Range.Start.Line+1, severityName(d.Severity), msg)
+	}
+	if parseErr != nil {
+		msg, _, _ := strings.Cut(parseErr.Error(), "\n")
+		fmt.Fprintf(&b, "parse error %s\n", msg)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -39,7 +39,7 @@ func blockRefAtCursor(line string, character uint32) string {
 	}
 
 	// Check if first part is a valid block name
-	if _, exists := BlockTypes[parts[0]]; !exists {
+	if _, exists := blockTypeForRef(parts[0]); !exists {
 		return ""
 	}
 
@@ -85,19 +85,19 @@ func definition(result *AnalysisResult, content string, uri string, pos protocol
 		return nil
 	}
 
-	lines := strings.Split(content, "\n")
+	lines := splitLines(content)
 	lineIdx := int(pos.Line)
 	if lineIdx >= len(lines) {
 		return nil
 	}
 
 	line := lines[lineIdx]
-	ref := blockRefAtCursor(line, pos.Character)
+	ref := blockRefAtCursor(line, uint32(byteColumn(line, pos.Character)))
 	if ref == "" {
 		return nil
 	}
 
-	symRange, ok := result.Symbols[ref]
+	symRange, ok := result.Symbols[canonicalPath(ref)]
 	if !ok {
 		return nil
 	}
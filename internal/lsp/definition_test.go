@@ -83,6 +83,68 @@ theme {
 	}
 }
 
+func TestDefinition_ExplicitColorKey(t *testing.T) {
+	content := `palette {
+  highlight {
+    color = "#524f67"
+    low   = "#21202e"
+  }
+}
+
+theme {
+  background = palette.highlight.color
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	if _, ok := result.Symbols["palette.highlight.color"]; ok {
+		t.Error("the reserved color key should not have a symbol of its own")
+	}
+	groupRange, ok := result.Symbols["palette.highlight"]
+	if !ok {
+		t.Fatal("expected palette.highlight in symbol table")
+	}
+
+	// Line 8 is "  background = palette.highlight.color", "color" starts at character 33
+	loc := definition(result, content, "file:///test.pstheme", protocol.Position{Line: 8, Character: 35})
+	if loc == nil {
+		t.Fatal("expected non-nil definition location for palette.highlight.color")
+	}
+	if loc.Range != groupRange {
+		t.Errorf("Range = %v, want the group's range %v", loc.Range, groupRange)
+	}
+}
+
+func TestDefinition_Local(t *testing.T) {
+	content := `palette {
+  base = "#191724"
+}
+
+locals {
+  accent = palette.base
+}
+
+theme {
+  background = local.accent
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	symRange, ok := result.Symbols["local.accent"]
+	if !ok {
+		t.Fatal("expected local.accent in symbol table")
+	}
+
+	// Line 9 is "  background = local.accent"
+	loc := definition(result, content, "file:///test.pstheme", protocol.Position{Line: 9, Character: 22})
+	if loc == nil {
+		t.Fatal("expected non-nil definition location for local.accent reference")
+	}
+	if loc.Range != symRange {
+		t.Errorf("Range = %v, want %v", loc.Range, symRange)
+	}
+}
+
 func TestDefinition_HexLiteral(t *testing.T) {
 	// Cursor on a hex literal should return nil
 	content := `palette {
@@ -137,3 +199,60 @@ func TestDefinition_NilResult(t *testing.T) {
 		t.Errorf("expected nil for nil result, got %+v", loc)
 	}
 }
+
+func TestDefinition_CRLF(t *testing.T) {
+	content := "palette {\r\n  highlight {\r\n    low = \"#21202e\"\r\n  }\r\n}\r\n\r\ntheme {\r\n  background = palette.highlight.low\r\n}\r\n"
+	result := Analyze("test.pstheme", content)
+
+	symRange, ok := result.Symbols["palette.highlight.low"]
+	if !ok {
+		t.Fatal("expected palette.highlight.low in symbol table")
+	}
+	if symRange.Start.Line != 2 || symRange.Start.Character != 4 {
+		t.Errorf("symbol range start = %v, want line 2 character 4", symRange.Start)
+	}
+
+	// The cursor is on the last character of "low", just before the "\r"
+	loc := definition(result, content, "file:///test.pstheme", protocol.Position{Line: 7, Character: 35})
+	if loc == nil || loc.Range != symRange {
+		t.Errorf("definition() = %v, want %v", loc, symRange)
+	}
+
+	items := complete(result, content, protocol.Position{Line: 7, Character: 33})
+	if !hasLabel(items, "low") {
+		t.Errorf("expected low in completions in a CRLF document, got %v", items)
+	}
+}
+
+func TestDefinition_TransformStep(t *testing.T) {
+	content := `palette {
+  base = "#191724"
+  transform {
+    lightness {
+      range = [0.2, 0.8]
+      steps = 3
+    }
+  }
+}
+
+theme {
+  background = palette.base.l2
+}
+`
+	result := Analyze("test.pstheme", content)
+	if !result.Generated["palette.base.l2"] || result.Generated["palette.base"] {
+		t.Errorf("Generated = %v, want the steps of palette.base only", result.Generated)
+	}
+
+	loc := definition(result, content, "file:///test.pstheme", protocol.Position{Line: 11, Character: 29})
+	if loc == nil {
+		t.Fatal("expected a definition for palette.base.l2")
+	}
+	want := protocol.Range{
+		Start: protocol.Position{Line: 2, Character: 2},
+		End:   protocol.Position{Line: 2, Character: 11},
+	}
+	if loc.Range != want {
+		t.Errorf("Range = %v, want the transform block header %v", loc.Range, want)
+	}
+}
@@ -1,27 +1,36 @@
 package lsp
 
-import "sync"
+import (
+	"sort"
+	"sync"
+)
 
 // DocumentStore holds open document contents keyed by URI.
 type DocumentStore struct {
 	mu   sync.RWMutex
-	docs map[string]string
+	docs map[string]document
+}
+
+// document is an open document with the position mapper for its content.
+type document struct {
+	content string
+	mapper  *PositionMapper
 }
 
 func NewDocumentStore() *DocumentStore {
-	return &DocumentStore{docs: make(map[string]string)}
+	return &DocumentStore{docs: make(map[string]document)}
 }
 
 func (s *DocumentStore) Open(uri, content string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.docs[uri] = content
+	s.docs[uri] = document{content: content, mapper: NewPositionMapper(content)}
 }
 
 func (s *DocumentStore) Update(uri, content string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.docs[uri] = content
+	s.docs[uri] = document{content: content, mapper: NewPositionMapper(content)}
 }
 
 func (s *DocumentStore) Close(uri string) {
@@ -33,6 +42,26 @@ func (s *DocumentStore) Close(uri string) {
 func (s *DocumentStore) Get(uri string) (string, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	content, ok := s.docs[uri]
-	return content, ok
+	doc, ok := s.docs[uri]
+	return doc.content, ok
+}
+
+// Mapper returns the position mapper for the current content of a document.
+func (s *DocumentStore) Mapper(uri string) (*PositionMapper, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	doc, ok := s.docs[uri]
+	return doc.mapper, ok
+}
+
+// URIs returns the URIs of all open documents, sorted.
+func (s *DocumentStore) URIs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	uris := make([]string, 0, len(s.docs))
+	for uri := range s.docs {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	return uris
 }
@@ -0,0 +1,15 @@
+package lsp
+
+import "testing"
+
+func FuzzAnalyze(f *testing.F) {
+	f.Add(validTheme)
+	f.Add("palette {\n  base = \"#191724\"\n}\nsyntax {\n  keyword = 5\n}\n")
+	f.Add("palette {\n  base = \"#191724\"\n  transform {\n    lightness {\n      range = 1\n      steps = \"x\"\n    }\n  }\n}\n")
+	f.Add("palette {\n  base = null\n}\n")
+
+	f.Fuzz(func(t *testing.T, content string) {
+		// Diagnostics are expected; panics are not.
+		Analyze("fuzz.pstheme", content)
+	})
+}
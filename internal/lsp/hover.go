@@ -2,8 +2,11 @@ package lsp
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/jsvensson/paletteswap/internal/color"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -23,9 +26,10 @@ func posInRange(pos protocol.Position, r protocol.Range) bool {
 	}
 }
 
-// extractText extracts the source text at a given LSP range from document content.
+// extractText extracts the source text at a given LSP range, whose columns
+// are UTF-16 code units, from document content.
 func extractText(content string, r protocol.Range) string {
-	lines := strings.Split(content, "\n")
+	lines := splitLines(content)
 
 	startLine := int(r.Start.Line)
 	endLine := int(r.End.Line)
@@ -39,14 +43,8 @@ func extractText(content string, r protocol.Range) string {
 
 	if startLine == endLine {
 		line := lines[startLine]
-		startChar := int(r.Start.Character)
-		endChar := int(r.End.Character)
-		if startChar > len(line) {
-			startChar = len(line)
-		}
-		if endChar > len(line) {
-			endChar = len(line)
-		}
+		startChar := byteColumn(line, r.Start.Character)
+		endChar := max(startChar, byteColumn(line, r.End.Character))
 		return line[startChar:endChar]
 	}
 
@@ -56,10 +54,10 @@ func extractText(content string, r protocol.Range) string {
 		line := lines[i]
 		switch i {
 		case startLine:
-			startChar := min(int(r.Start.Character), len(line))
+			startChar := byteColumn(line, r.Start.Character)
 			parts = append(parts, line[startChar:])
 		case endLine:
-			endChar := min(int(r.End.Character), len(line))
+			endChar := byteColumn(line, r.End.Character)
 			parts = append(parts, line[:endChar])
 		default:
 			parts = append(parts, line)
@@ -72,6 +70,7 @@ func extractText(content string, r protocol.Range) string {
 // It checks whether the position falls within any ColorLocation from the analysis result.
 // For palette references (IsRef=true), the hover shows the source text, hex, and RGB.
 // For hex literals, it shows hex and RGB.
+// Over the header of a palette group it shows a summary of the group.
 // Returns nil if no color is found at the position.
 func hover(result *AnalysisResult, content string, pos protocol.Position) *protocol.Hover {
 	if result == nil {
@@ -100,9 +99,145 @@ func hover(result *AnalysisResult, content string, pos protocol.Position) *proto
 		}
 	}
 
+	if h := groupHover(result, pos); h != nil {
+		return h
+	}
+	return transformHover(result, pos)
+}
+
+// transformHover returns a hover listing the children the palette's
+// lightness transform generates, when pos is inside its transform block.
+func transformHover(result *AnalysisResult, pos protocol.Position) *protocol.Hover {
+	t := result.Transform
+	if t == nil || !posInRange(pos, result.TransformRange) {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("**transform lightness**\n\n")
+	fmt.Fprintf(&b, "Every palette color gets %d children with these OKLCH lightness values", t.Steps)
+	if example := firstSteppedPath(result.Palette, "palette"); example != "" {
+		fmt.Fprintf(&b, ", e.g. `%s.l1`", example)
+	}
+	b.WriteString(":\n\n| Name | Lightness |\n|------|-----------|\n")
+	for i, l := range t.Lightness() {
+		fmt.Fprintf(&b, "| `l%d` | %s |\n", i+1, strconv.FormatFloat(l, 'g', 4, 64))
+	}
+
+	rng := result.TransformRange
+	return &protocol.Hover{
+		Contents: protocol.MarkupContent{
+			Kind:  protocol.MarkupKindMarkdown,
+			Value: b.String(),
+		},
+		Range: &rng,
+	}
+}
+
+// firstSteppedPath returns the path of the first color in declaration order
+// that has lightness steps, or "" if there is none.
+func firstSteppedPath(node *color.Node, path string) string {
+	if node == nil {
+		return ""
+	}
+	if _, ok := node.Children["l1"]; ok && node.Color != nil {
+		return path
+	}
+	for _, name := range node.Names() {
+		if p := firstSteppedPath(node.Children[name], path+"."+name); p != "" {
+			return p
+		}
+	}
+	return ""
+}
+
+// groupHover returns a hover summarizing the palette group, or scale, whose
+// block header is at pos: its own color if set, and a table of its children.
+func groupHover(result *AnalysisResult, pos protocol.Position) *protocol.Hover {
+	if result.Palette == nil {
+		return nil
+	}
+	for path, rng := range result.Symbols {
+		rest, ok := strings.CutPrefix(path, "palette.")
+		if !ok || !posInRange(pos, rng) {
+			continue
+		}
+		node, err := result.Palette.Find(strings.Split(rest, "."))
+		if err != nil || len(node.Children) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "**%s**\n\n", path)
+		if node.Color != nil {
+			fmt.Fprintf(&b, "%s `%s` (group color)\n\n", swatchEmoji(*node.Color), node.Color.Hex())
+		}
+		b.WriteString("| Name | Color |\n|------|-------|\n")
+		for _, name := range sortedChildNames(node) {
+			child := node.Children[name]
+			switch {
+			case child.Color != nil:
+				fmt.Fprintf(&b, "| `%s` | %s `%s` |\n", name, swatchEmoji(*child.Color), child.Color.Hex())
+			default:
+				fmt.Fprintf(&b, "| `%s` | group of %d |\n", name, len(child.Children))
+			}
+		}
+
+		return &protocol.Hover{
+			Contents: protocol.MarkupContent{
+				Kind:  protocol.MarkupKindMarkdown,
+				Value: b.String(),
+			},
+			Range: &rng,
+		}
+	}
 	return nil
 }
 
+// sortedChildNames returns the names of the children of n in order, with
+// numeric names, such as the steps of a scale, sorted by value.
+func sortedChildNames(n *color.Node) []string {
+	names := make([]string, 0, len(n.Children))
+	for name := range n.Children {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, errA := strconv.Atoi(names[i])
+		b, errB := strconv.Atoi(names[j])
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// swatches pairs the colored square emojis with the colors they show as.
+var swatches = []struct {
+	emoji string
+	color color.Color
+}{
+	{"🟥", color.Color{R: 221, G: 46, B: 68}},
+	{"🟧", color.Color{R: 244, G: 144, B: 12}},
+	{"🟨", color.Color{R: 253, G: 203, B: 88}},
+	{"🟩", color.Color{R: 120, G: 177, B: 89}},
+	{"🟦", color.Color{R: 85, G: 172, B: 238}},
+	{"🟪", color.Color{R: 170, G: 142, B: 214}},
+	{"🟫", color.Color{R: 193, G: 105, B: 79}},
+	{"⬛", color.Color{R: 49, G: 55, B: 61}},
+	{"⬜", color.Color{R: 230, G: 231, B: 232}},
+}
+
+// swatchEmoji returns the square emoji perceptually closest to c, as a
+// rough swatch in clients that cannot render colors in hovers.
+func swatchEmoji(c color.Color) string {
+	candidates := make([]color.Color, len(swatches))
+	for i, s := range swatches {
+		candidates[i] = s.color
+	}
+	return swatches[color.Nearest(c, candidates)].emoji
+}
+
 // textDocumentHover handles textDocument/hover requests.
 func (s *Server) textDocumentHover(_ *glsp.Context, params *protocol.HoverParams) (*protocol.Hover, error) {
 	uri := string(params.TextDocument.URI)
@@ -211,3 +211,152 @@ func TestHover_FunctionDirect(t *testing.T) {
 		t.Error("expected nil hover for position outside color range")
 	}
 }
+
+func TestExtractText_CRLF(t *testing.T) {
+	content := "theme {\r\n  bg = mix(palette.a,\r\n    palette.b, 0.5)\r\n}\r\n"
+	r := protocol.Range{
+		Start: protocol.Position{Line: 1, Character: 7},
+		End:   protocol.Position{Line: 2, Character: 19},
+	}
+
+	want := "mix(palette.a,\n    palette.b, 0.5)"
+	if got := extractText(content, r); got != want {
+		t.Errorf("extractText() = %q, want %q", got, want)
+	}
+}
+
+func TestHover_NonASCII(t *testing.T) {
+	// "/*🎨*/ " is 9 bytes but 7 UTF-16 code units, so the reference
+	// starts at character 22 rather than byte 24.
+	content := "palette {\n  base = \"#191724\"\n}\n\ntheme {\n  /*🎨*/ background = palette.base\n}\n"
+	result := Analyze("test.pstheme", content)
+
+	var ref *ColorLocation
+	for i, cl := range result.Colors {
+		if cl.IsRef {
+			ref = &result.Colors[i]
+		}
+	}
+	if ref == nil {
+		t.Fatal("expected a palette reference ColorLocation")
+	}
+	if ref.Range.Start.Character != 22 || ref.Range.End.Character != 34 {
+		t.Errorf("reference range = %v, want characters 22 to 34", ref.Range)
+	}
+
+	h := hover(result, content, protocol.Position{Line: 5, Character: 33})
+	if h == nil {
+		t.Fatal("expected hover on the last character of palette.base")
+	}
+	if mc := h.Contents.(protocol.MarkupContent); !strings.Contains(mc.Value, "**palette.base**") {
+		t.Errorf("hover should show the reference text, got %q", mc.Value)
+	}
+
+	loc := definition(result, content, "file:///test.pstheme", protocol.Position{Line: 5, Character: 31})
+	if loc == nil || loc.Range != result.Symbols["palette.base"] {
+		t.Errorf("definition() = %v, want palette.base", loc)
+	}
+
+	items := complete(result, "theme {\n  /*🎨*/ background = palette.\n}\n", protocol.Position{Line: 1, Character: 30})
+	if !hasLabel(items, "base") {
+		t.Errorf("expected base in completions after non-ASCII text, got %v", items)
+	}
+}
+
+func TestHover_PaletteGroup(t *testing.T) {
+	content := `palette {
+  highlight {
+    color = "#403d52"
+    low   = "#21202e"
+    high  = "#ff0000"
+    deep {
+      a = "#000000"
+    }
+  }
+  scale "gray" {
+    from  = "#000000"
+    to    = "#ffffff"
+    steps = 10
+  }
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	t.Run("group", func(t *testing.T) {
+		h := hover(result, content, protocol.Position{Line: 1, Character: 4})
+		if h == nil {
+			t.Fatal("expected hover over group name")
+		}
+		md := h.Contents.(protocol.MarkupContent).Value
+		for _, want := range []string{
+			"**palette.highlight**",
+			"`#403d52` (group color)",
+			"| `high` | 🟥 `#ff0000` |",
+			"| `low` | ⬛ `#21202e` |",
+			"| `deep` | group of 1 |",
+		} {
+			if !strings.Contains(md, want) {
+				t.Errorf("hover missing %q, got:\n%s", want, md)
+			}
+		}
+		if strings.Index(md, "`deep`") > strings.Index(md, "`high`") {
+			t.Errorf("children should be sorted by name, got:\n%s", md)
+		}
+	})
+
+	t.Run("scale", func(t *testing.T) {
+		h := hover(result, content, protocol.Position{Line: 9, Character: 10})
+		if h == nil {
+			t.Fatal("expected hover over scale block")
+		}
+		md := h.Contents.(protocol.MarkupContent).Value
+		if i, j := strings.Index(md, "`2`"), strings.Index(md, "`10`"); i < 0 || j < 0 || i > j {
+			t.Errorf("scale steps should be sorted numerically, got:\n%s", md)
+		}
+	})
+
+	t.Run("leaf name", func(t *testing.T) {
+		if h := hover(result, content, protocol.Position{Line: 3, Character: 4}); h != nil {
+			t.Errorf("expected no hover over a leaf name, got %v", h.Contents)
+		}
+	})
+}
+
+func TestHover_Transform(t *testing.T) {
+	content := `palette {
+  base = "#808080"
+  transform {
+    lightness {
+      range = [0.1, 0.9]
+      steps = 5
+    }
+  }
+}
+`
+	result := Analyze("test.pstheme", content)
+
+	h := hover(result, content, protocol.Position{Line: 2, Character: 4})
+	if h == nil {
+		t.Fatal("expected hover over transform block")
+	}
+	md := h.Contents.(protocol.MarkupContent).Value
+	for _, want := range []string{
+		"**transform lightness**",
+		"5 children",
+		"`palette.base.l1`",
+		"| `l1` | 0.1 |",
+		"| `l2` | 0.3 |",
+		"| `l5` | 0.9 |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("hover missing %q, got:\n%s", want, md)
+		}
+	}
+
+	if h := hover(result, content, protocol.Position{Line: 5, Character: 8}); h == nil {
+		t.Error("expected hover inside the lightness block")
+	}
+	if h := hover(result, content, protocol.Position{Line: 8, Character: 0}); h != nil {
+		t.Errorf("expected no transform hover outside the block, got %v", h.Contents)
+	}
+}
@@ -0,0 +1,70 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/jsvensson/paletteswap/internal/theme"
+)
+
+// Options configures a language server process. It is shared by the
+// standalone pstheme-lsp binary and the `paletteswap lsp` subcommand.
+type Options struct {
+	Version string
+	Limits  theme.Limits
+	Logger  *slog.Logger // nil discards logs
+	Listen  string       // TCP address to accept connections on; empty means stdio
+	NodeIPC bool         // use the Node.js IPC channel instead of stdio
+}
+
+// Serve runs the language server over the transport selected by opts until
+// the client exits or ctx is cancelled.
+func Serve(ctx context.Context, opts Options) error {
+	newServer := func() *Server {
+		s := NewServer(opts.Version)
+		s.SetLimits(opts.Limits)
+		if opts.Logger != nil {
+			s.SetLogger(opts.Logger)
+		}
+		return s
+	}
+
+	switch {
+	case opts.Listen != "":
+		if opts.Logger != nil {
+			opts.Logger.Info("listening for connections", "address", opts.Listen)
+		}
+		return ListenAndServe(ctx, opts.Listen, newServer)
+	case opts.NodeIPC:
+		return newServer().RunNodeIPC(ctx)
+	default:
+		return newServer().Run(ctx)
+	}
+}
+
+// NewLogger builds the server logger. Logs go to logFile if set, otherwise to
+// stderr when verbose (stdout carries the protocol), and are discarded
+// otherwise. Verbose enables debug-level traces. The returned func closes the
+// log file.
+func NewLogger(logFile string, verbose bool) (*slog.Logger, func(), error) {
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	}
+
+	switch {
+	case logFile != "":
+		f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, func() {}, fmt.Errorf("opening log file: %w", err)
+		}
+		return slog.New(slog.NewTextHandler(f, &slog.HandlerOptions{Level: level})), func() { f.Close() }, nil
+	case verbose:
+		return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})), func() {}, nil
+	default:
+		return slog.New(slog.NewTextHandler(io.Discard, nil)), func() {}, nil
+	}
+}
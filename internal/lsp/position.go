@@ -0,0 +1,102 @@
+package lsp
+
+import (
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// PositionMapper converts between byte offsets into a document and LSP
+// positions. LSP counts Character in UTF-16 code units, while HCL ranges and
+// Go strings index bytes, so the two differ on lines with non-ASCII text.
+type PositionMapper struct {
+	content    string
+	lineStarts []int // byte offset of the first byte of each line
+}
+
+// NewPositionMapper indexes the line starts of content.
+func NewPositionMapper(content string) *PositionMapper {
+	starts := []int{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &PositionMapper{content: content, lineStarts: starts}
+}
+
+// Position returns the LSP position of a byte offset. Offsets past the end
+// of the content are clamped to it.
+func (m *PositionMapper) Position(offset int) protocol.Position {
+	offset = max(0, min(offset, len(m.content)))
+	line := sort.Search(len(m.lineStarts), func(i int) bool { return m.lineStarts[i] > offset }) - 1
+	start := m.lineStarts[line]
+	return protocol.Position{
+		Line:      uint32(line),
+		Character: uint32(utf16Len(m.content[start:offset])),
+	}
+}
+
+// Offset returns the byte offset of an LSP position. A Character past the
+// end of its line is clamped to the line end, before any "\r\n".
+func (m *PositionMapper) Offset(pos protocol.Position) int {
+	if int(pos.Line) >= len(m.lineStarts) {
+		return len(m.content)
+	}
+	start := m.lineStarts[pos.Line]
+	end := len(m.content)
+	if int(pos.Line)+1 < len(m.lineStarts) {
+		end = m.lineStarts[pos.Line+1] - 1
+		if end > start && m.content[end-1] == '\r' {
+			end--
+		}
+	}
+	return start + byteColumn(m.content[start:end], pos.Character)
+}
+
+// Range converts an HCL range to an LSP range using its byte offsets.
+func (m *PositionMapper) Range(r hcl.Range) protocol.Range {
+	return protocol.Range{
+		Start: m.Position(r.Start.Byte),
+		End:   m.Position(r.End.Byte),
+	}
+}
+
+// End returns the position just past the last character of the content.
+func (m *PositionMapper) End() protocol.Position {
+	return m.Position(len(m.content))
+}
+
+// utf16Len returns the length of s in UTF-16 code units.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// byteColumn returns the byte index in line of a UTF-16 column, clamped to
+// len(line). A column inside a surrogate pair maps to the start of its rune.
+func byteColumn(line string, character uint32) int {
+	units := 0
+	for i, r := range line {
+		if units >= int(character) {
+			return i
+		}
+		if r >= 0x10000 {
+			units += 2
+		} else {
+			units++
+		}
+		if units > int(character) {
+			return i
+		}
+	}
+	return len(line)
+}
@@ -0,0 +1,74 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestPositionMapper(t *testing.T) {
+	// "é" is 2 bytes and 1 UTF-16 unit; "🎨" is 4 bytes and 2 UTF-16 units.
+	content := "a = \"é\"\r\n# 🎨 x\nz"
+	m := NewPositionMapper(content)
+
+	tests := []struct {
+		name   string
+		offset int
+		pos    protocol.Position
+	}{
+		{"start", 0, protocol.Position{Line: 0, Character: 0}},
+		{"after two-byte rune", 7, protocol.Position{Line: 0, Character: 6}},
+		{"start of second line", 10, protocol.Position{Line: 1, Character: 0}},
+		{"after surrogate pair", 16, protocol.Position{Line: 1, Character: 4}},
+		{"last line", 19, protocol.Position{Line: 2, Character: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Position(tt.offset); got != tt.pos {
+				t.Errorf("Position(%d) = %v, want %v", tt.offset, got, tt.pos)
+			}
+			if got := m.Offset(tt.pos); got != tt.offset {
+				t.Errorf("Offset(%v) = %d, want %d", tt.pos, got, tt.offset)
+			}
+		})
+	}
+
+	if got := m.Offset(protocol.Position{Line: 0, Character: 99}); got != 8 {
+		t.Errorf("Offset past line end = %d, want 8 (before \\r\\n)", got)
+	}
+	if got := m.End(); got != (protocol.Position{Line: 2, Character: 1}) {
+		t.Errorf("End() = %v, want line 2 character 1", got)
+	}
+
+	r := m.Range(hcl.Range{Start: hcl.Pos{Byte: 12}, End: hcl.Pos{Byte: 17}})
+	want := protocol.Range{
+		Start: protocol.Position{Line: 1, Character: 2},
+		End:   protocol.Position{Line: 1, Character: 5},
+	}
+	if r != want {
+		t.Errorf("Range() = %v, want %v", r, want)
+	}
+}
+
+func TestByteColumn(t *testing.T) {
+	line := "é🎨x"
+	tests := []struct {
+		character uint32
+		want      int
+	}{
+		{0, 0},
+		{1, 2},
+		{2, 2}, // inside the surrogate pair
+		{3, 6},
+		{4, 7},
+		{10, 7},
+	}
+
+	for _, tt := range tests {
+		if got := byteColumn(line, tt.character); got != tt.want {
+			t.Errorf("byteColumn(%q, %d) = %d, want %d", line, tt.character, got, tt.want)
+		}
+	}
+}
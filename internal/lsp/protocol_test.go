@@ -0,0 +1,85 @@
+package lsp
+
+import (
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// protocolTheme puts non-ASCII text before references on the same line, so
+// that positions in requests and responses differ between UTF-16 code units
+// and bytes. 🌹 is two UTF-16 code units and four bytes; é is one and two.
+const protocolTheme = `meta {
+  name = "Rosé"
+}
+
+palette {
+  base = "#191724"
+  love = "#eb6f92"
+}
+
+theme {
+  background = palette.base
+  foreground = /* 🌹 */ palette.love
+  cursor     = /* é */ palette.lvoe
+}
+`
+
+const protocolURI = "file:///theme.pstheme"
+
+func TestProtocol_Initialize(t *testing.T) {
+	c := newTestClient(t)
+	checkGolden(t, "initialize", c.initialize())
+}
+
+func TestProtocol_Diagnostics(t *testing.T) {
+	c := newTestClient(t)
+	c.initialize()
+	checkGolden(t, "diagnostics", c.open(protocolURI, protocolTheme))
+}
+
+func TestProtocol_Hover(t *testing.T) {
+	c := newTestClient(t)
+	c.initialize()
+	c.open(protocolURI, protocolTheme)
+
+	// "love" on line 11 starts at UTF-16 column 32: 18 characters, the
+	// two-unit 🌹, 4 more characters and "palette.".
+	hover := c.call(protocol.MethodTextDocumentHover, protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocolURI},
+			Position:     protocol.Position{Line: 11, Character: 33},
+		},
+	})
+	checkGolden(t, "hover", hover)
+}
+
+func TestProtocol_Completion(t *testing.T) {
+	c := newTestClient(t)
+	c.initialize()
+	c.open(protocolURI, protocolTheme)
+
+	// Just after "palette." on line 12, at UTF-16 column 31: 18 characters, the
+	// one-unit é, 4 more characters and "palette.".
+	completion := c.call(protocol.MethodTextDocumentCompletion, protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocolURI},
+			Position:     protocol.Position{Line: 12, Character: 31},
+		},
+	})
+	checkGolden(t, "completion", completion)
+}
+
+func TestProtocol_Definition(t *testing.T) {
+	c := newTestClient(t)
+	c.initialize()
+	c.open(protocolURI, protocolTheme)
+
+	definition := c.call(protocol.MethodTextDocumentDefinition, protocol.DefinitionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocolURI},
+			Position:     protocol.Position{Line: 11, Character: 33},
+		},
+	})
+	checkGolden(t, "definition", definition)
+}
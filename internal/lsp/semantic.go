@@ -14,7 +14,7 @@ var semanticTokenTypes = []string{
 	"variable",  // 2: palette references (kept for non-palette refs if needed)
 	"namespace", // 3: the "palette" namespace identifier
 	"string",    // 4: hex color literals
-	"function",  // 5: brighten(), darken()
+	"function",  // 5: brighten(), darken(), mix(), ...
 	"number",    // 6: numeric literals
 	"comment",   // 7: comments
 }
@@ -37,8 +37,8 @@ func init() {
 // SemanticToken represents a single token with its metadata
 type SemanticToken struct {
 	Line      uint32 // 0-based line number
-	StartChar uint32 // 0-based character offset
-	Length    uint32
+	StartChar uint32 // 0-based character offset, in UTF-16 code units
+	Length    uint32 // in UTF-16 code units
 	Type      uint32 // index into semanticTokenTypes
 	Modifiers uint32 // bit flags
 }
@@ -100,90 +100,82 @@ func semanticTokensFull(content string) []uint32 {
 		return []uint32{}
 	}
 
+	m := NewPositionMapper(content)
 	var tokens []SemanticToken
-	tokens = extractTokensFromBody(body, tokens)
+	tokens = extractTokensFromBody(m, body, tokens)
 
 	return encodeTokens(tokens)
 }
 
+// newToken returns the token covering length bytes from a byte offset, with
+// its position and length in UTF-16 code units.
+func newToken(m *PositionMapper, offset, length int, typ string, modifiers uint32) SemanticToken {
+	offset = min(offset, len(m.content))
+	pos := m.Position(offset)
+	end := max(offset, min(offset+length, len(m.content)))
+	return SemanticToken{
+		Line:      pos.Line,
+		StartChar: pos.Character,
+		Length:    uint32(utf16Len(m.content[offset:end])),
+		Type:      tokenTypeIndices[typ],
+		Modifiers: modifiers,
+	}
+}
+
 // extractTokensFromBody extracts tokens from an HCL body
-func extractTokensFromBody(body *hclsyntax.Body, tokens []SemanticToken) []SemanticToken {
+func extractTokensFromBody(m *PositionMapper, body *hclsyntax.Body, tokens []SemanticToken) []SemanticToken {
 	// Extract block type tokens
 	for _, block := range body.Blocks {
-		tokens = append(tokens, SemanticToken{
-			Line:      uint32(block.DefRange().Start.Line - 1),
-			StartChar: uint32(block.DefRange().Start.Column - 1),
-			Length:    uint32(len(block.Type)),
-			Type:      tokenTypeIndices["keyword"],
-			Modifiers: 0,
-		})
+		tokens = append(tokens, newToken(m, block.DefRange().Start.Byte, len(block.Type), "keyword", 0))
 
 		// Recurse into block body
-		tokens = extractTokensFromBody(block.Body, tokens)
+		tokens = extractTokensFromBody(m, block.Body, tokens)
 	}
 
 	// Extract attribute tokens
 	for name, attr := range body.Attributes {
 		// Attribute name (with declaration modifier)
-		tokens = append(tokens, SemanticToken{
-			Line:      uint32(attr.SrcRange.Start.Line - 1),
-			StartChar: uint32(attr.SrcRange.Start.Column - 1),
-			Length:    uint32(len(name)),
-			Type:      tokenTypeIndices["property"],
-			Modifiers: 1, // declaration bit
-		})
+		tokens = append(tokens, newToken(m, attr.SrcRange.Start.Byte, len(name), "property", 1))
 
 		// Extract tokens from the expression
-		tokens = extractTokensFromExpr(attr.Expr, tokens)
+		tokens = extractTokensFromExpr(m, attr.Expr, tokens)
 	}
 
 	return tokens
 }
 
 // extractTokensFromExpr extracts tokens from an HCL expression
-func extractTokensFromExpr(expr hclsyntax.Expression, tokens []SemanticToken) []SemanticToken {
+func extractTokensFromExpr(m *PositionMapper, expr hclsyntax.Expression, tokens []SemanticToken) []SemanticToken {
 	switch e := expr.(type) {
 	case *hclsyntax.LiteralValueExpr:
-		tokens = extractTokensFromLiteral(e, tokens)
+		tokens = extractTokensFromLiteral(m, e, tokens)
 	case *hclsyntax.ScopeTraversalExpr:
-		tokens = extractTokensFromTraversal(e, tokens)
+		tokens = extractTokensFromTraversal(m, e, tokens)
 	case *hclsyntax.FunctionCallExpr:
-		tokens = extractTokensFromFunctionCall(e, tokens)
+		tokens = extractTokensFromFunctionCall(m, e, tokens)
 	case *hclsyntax.RelativeTraversalExpr:
-		tokens = extractTokensFromRelativeTraversal(e, tokens)
+		tokens = extractTokensFromRelativeTraversal(m, e, tokens)
 	}
 	return tokens
 }
 
 // extractTokensFromLiteral handles string and number literals
-func extractTokensFromLiteral(expr *hclsyntax.LiteralValueExpr, tokens []SemanticToken) []SemanticToken {
+func extractTokensFromLiteral(m *PositionMapper, expr *hclsyntax.LiteralValueExpr, tokens []SemanticToken) []SemanticToken {
 	val := expr.Val
 	if val.Type().FriendlyName() == "string" {
 		str := val.AsString()
 		// Check if it's a hex color
 		if len(str) == 7 && str[0] == '#' {
-			tokens = append(tokens, SemanticToken{
-				Line:      uint32(expr.SrcRange.Start.Line - 1),
-				StartChar: uint32(expr.SrcRange.Start.Column - 1),
-				Length:    uint32(len(str)),
-				Type:      tokenTypeIndices["string"],
-				Modifiers: 0,
-			})
+			tokens = append(tokens, newToken(m, expr.SrcRange.Start.Byte, len(str), "string", 0))
 		}
 	} else if val.Type().FriendlyName() == "number" {
-		tokens = append(tokens, SemanticToken{
-			Line:      uint32(expr.SrcRange.Start.Line - 1),
-			StartChar: uint32(expr.SrcRange.Start.Column - 1),
-			Length:    uint32(expr.SrcRange.End.Column - expr.SrcRange.Start.Column),
-			Type:      tokenTypeIndices["number"],
-			Modifiers: 0,
-		})
+		tokens = append(tokens, newToken(m, expr.SrcRange.Start.Byte, expr.SrcRange.End.Byte-expr.SrcRange.Start.Byte, "number", 0))
 	}
 	return tokens
 }
 
 // extractTokensFromTraversal handles any block reference like palette.base or ansi.red
-func extractTokensFromTraversal(expr *hclsyntax.ScopeTraversalExpr, tokens []SemanticToken) []SemanticToken {
+func extractTokensFromTraversal(m *PositionMapper, expr *hclsyntax.ScopeTraversalExpr, tokens []SemanticToken) []SemanticToken {
 	if len(expr.Traversal) == 0 {
 		return tokens
 	}
@@ -195,31 +187,19 @@ func extractTokensFromTraversal(expr *hclsyntax.ScopeTraversalExpr, tokens []Sem
 	}
 
 	// Check if it's a referenceable block
-	if _, exists := BlockTypes[first.Name]; !exists {
+	if _, exists := blockTypeForRef(first.Name); !exists {
 		return tokens
 	}
 
 	// Tokenize block name as namespace
-	tokens = append(tokens, SemanticToken{
-		Line:      uint32(first.SrcRange.Start.Line - 1),
-		StartChar: uint32(first.SrcRange.Start.Column - 1),
-		Length:    uint32(len(first.Name)),
-		Type:      tokenTypeIndices["namespace"],
-		Modifiers: 0,
-	})
+	tokens = append(tokens, newToken(m, first.SrcRange.Start.Byte, len(first.Name), "namespace", 0))
 
 	// Tokenize each subsequent segment as property
 	for i := 1; i < len(expr.Traversal); i++ {
 		switch seg := expr.Traversal[i].(type) {
 		case hcl.TraverseAttr:
 			// SrcRange includes the leading dot, so add 1 to skip it
-			tokens = append(tokens, SemanticToken{
-				Line:      uint32(seg.SrcRange.Start.Line - 1),
-				StartChar: uint32(seg.SrcRange.Start.Column),
-				Length:    uint32(len(seg.Name)),
-				Type:      tokenTypeIndices["property"],
-				Modifiers: 0,
-			})
+			tokens = append(tokens, newToken(m, seg.SrcRange.Start.Byte+1, len(seg.Name), "property", 0))
 		case hcl.TraverseIndex:
 			// Handle index access like palette.colors[0] if needed
 			// For now, skip or handle as needed
@@ -230,26 +210,20 @@ func extractTokensFromTraversal(expr *hclsyntax.ScopeTraversalExpr, tokens []Sem
 }
 
 // extractTokensFromFunctionCall handles function calls like brighten()
-func extractTokensFromFunctionCall(expr *hclsyntax.FunctionCallExpr, tokens []SemanticToken) []SemanticToken {
+func extractTokensFromFunctionCall(m *PositionMapper, expr *hclsyntax.FunctionCallExpr, tokens []SemanticToken) []SemanticToken {
 	// Tokenize the function name
-	tokens = append(tokens, SemanticToken{
-		Line:      uint32(expr.NameRange.Start.Line - 1),
-		StartChar: uint32(expr.NameRange.Start.Column - 1),
-		Length:    uint32(len(expr.Name)),
-		Type:      tokenTypeIndices["function"],
-		Modifiers: 0,
-	})
+	tokens = append(tokens, newToken(m, expr.NameRange.Start.Byte, len(expr.Name), "function", 0))
 
 	// Recurse into arguments
 	for _, arg := range expr.Args {
-		tokens = extractTokensFromExpr(arg, tokens)
+		tokens = extractTokensFromExpr(m, arg, tokens)
 	}
 
 	return tokens
 }
 
 // extractTokensFromRelativeTraversal handles relative traversals
-func extractTokensFromRelativeTraversal(expr *hclsyntax.RelativeTraversalExpr, tokens []SemanticToken) []SemanticToken {
+func extractTokensFromRelativeTraversal(m *PositionMapper, expr *hclsyntax.RelativeTraversalExpr, tokens []SemanticToken) []SemanticToken {
 	// For now, just recurse into the source
-	return extractTokensFromExpr(expr.Source, tokens)
+	return extractTokensFromExpr(m, expr.Source, tokens)
 }
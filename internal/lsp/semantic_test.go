@@ -119,6 +119,28 @@ func TestSemanticTokensFull_WithFunction(t *testing.T) {
 	}
 }
 
+func TestSemanticTokensFull_WithConstructor(t *testing.T) {
+	content := `palette {
+  love = rgb(235, 111, 146)
+  rose = hsl(2, 0.55, 0.83)
+}`
+	result := semanticTokensFull(content)
+
+	// Should have: love(property), rgb(function), 3 numbers,
+	//              rose(property), hsl(function), 3 numbers
+	// plus palette(keyword): 11 tokens = 55 integers
+	if len(result) != 55 {
+		t.Fatalf("semanticTokensFull() returned %d integers, want 55", len(result))
+	}
+	// rgb is the third token, 7 columns after love on the same line
+	if result[10] != 0 || result[11] != 7 || result[12] != 3 {
+		t.Errorf("rgb token = %v, want line delta 0, column delta 7, length 3", result[10:15])
+	}
+	if got := semanticTokenTypes[result[13]]; got != "function" {
+		t.Errorf("rgb token type = %q, want function", got)
+	}
+}
+
 func TestSemanticTokensFull_ParseError(t *testing.T) {
 	content := `palette {`
 	result := semanticTokensFull(content)
@@ -204,3 +226,15 @@ theme {
 		t.Errorf("semantic tokens data length %d is not a multiple of 5", len(result))
 	}
 }
+
+func TestSemanticTokensFull_NonASCII(t *testing.T) {
+	// The comment is 10 bytes but 7 UTF-16 code units ("é" is 2 bytes, 1 unit;
+	// "🎨" is 4 bytes, 2 units), so "base" starts at character 10, not 13.
+	content := "palette {\n  /*é🎨*/ base = \"#191724\"\n}"
+	result := semanticTokensFull(content)
+
+	// Second token: deltaLine 1, start 10, length 4
+	if len(result) < 10 || result[5] != 1 || result[6] != 10 || result[7] != 4 {
+		t.Errorf("semanticTokensFull() = %v, want base at line 1 character 10", result)
+	}
+}
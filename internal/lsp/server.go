@@ -1,17 +1,19 @@
 package lsp
 
 import (
-	"strings"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
-	"github.com/tliron/glsp/server"
-
-	"github.com/tliron/commonlog"
-	_ "github.com/tliron/commonlog/simple"
 
 	"github.com/jsvensson/paletteswap/internal/format"
+	"github.com/jsvensson/paletteswap/internal/theme"
 )
 
 const serverName = "pstheme-lsp"
@@ -23,14 +25,27 @@ type Server struct {
 	mu         sync.RWMutex
 	results    map[string]*AnalysisResult
 	docVersion map[string]int // Track document versions to prevent stale diagnostics
+	limits     theme.Limits
+	logger     *slog.Logger
+
+	lastAnalysis *AnalysisStatus // most recent analysis, guarded by mu
+	shuttingDown bool            // set once shutdown is requested, guarded by mu
+	pending      sync.WaitGroup  // diagnostics notifications not yet sent
 }
 
+// errExitWithoutShutdown is returned by Run and RunNodeIPC when the client disconnects or
+// sends exit without a prior shutdown request. Per the LSP spec the server
+// should then exit with code 1.
+var errExitWithoutShutdown = errors.New("connection closed without shutdown request")
+
 func NewServer(version string) *Server {
 	s := &Server{
 		docs:       NewDocumentStore(),
 		version:    version,
 		results:    make(map[string]*AnalysisResult),
 		docVersion: make(map[string]int),
+		limits:     theme.DefaultLimits,
+		logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
 	}
 
 	s.handler = protocol.Handler{
@@ -48,15 +63,56 @@ func NewServer(version string) *Server {
 		TextDocumentColorPresentation:  s.textDocumentColorPresentation,
 		TextDocumentSemanticTokensFull: s.textDocumentSemanticTokensFull,
 		TextDocumentFormatting:         s.textDocumentFormatting,
+		TextDocumentDocumentSymbol:     s.textDocumentDocumentSymbol,
+		TextDocumentCodeAction:         s.textDocumentCodeAction,
 	}
 
 	return s
 }
 
-func (s *Server) Run() error {
-	commonlog.Configure(1, nil)
-	srv := server.NewServer(&s.handler, serverName, false)
-	return srv.RunStdio()
+// SetLimits replaces the limits used when analyzing documents.
+func (s *Server) SetLimits(limits theme.Limits) {
+	s.limits = limits
+}
+
+// SetLogger sets the logger for message traces and analysis timings.
+// By default nothing is logged.
+func (s *Server) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// Handle implements glsp.Handler. It serves the custom pstheme/* requests,
+// delegates standard LSP methods to the protocol handler, and logs each
+// message with its handling time.
+func (s *Server) Handle(ctx *glsp.Context) (r any, validMethod bool, validParams bool, err error) {
+	start := time.Now()
+	switch {
+	case ctx.Method != protocol.MethodExit && s.isShuttingDown():
+		// The spec requires every request after shutdown, other than exit,
+		// to fail with InvalidRequest.
+		validMethod, validParams = true, true
+		err = errors.New("server is shutting down")
+	case ctx.Method == protocol.MethodExit:
+		// Handled here because the protocol handler rejects every method
+		// once shutdown has marked it uninitialized.
+		validMethod, validParams = true, true
+		err = s.exit(ctx)
+	case ctx.Method == statusMethod:
+		r, validMethod, validParams = s.status(), true, true
+	default:
+		r, validMethod, validParams, err = s.handler.Handle(ctx)
+	}
+
+	attrs := []any{"method", ctx.Method, "duration", time.Since(start)}
+	switch {
+	case err != nil:
+		s.logger.Warn("message failed", append(attrs, "error", err)...)
+	case !validMethod:
+		s.logger.Debug("unhandled message", attrs...)
+	default:
+		s.logger.Debug("handled message", append(attrs, "params", string(ctx.Params))...)
+	}
+	return r, validMethod, validParams, err
 }
 
 func (s *Server) initialize(_ *glsp.Context, params *protocol.InitializeParams) (any, error) {
@@ -82,6 +138,10 @@ func (s *Server) initialize(_ *glsp.Context, params *protocol.InitializeParams)
 	}
 	capabilities.DocumentFormattingProvider = true
 	capabilities.DefinitionProvider = true
+	capabilities.DocumentSymbolProvider = true
+	capabilities.CodeActionProvider = protocol.CodeActionOptions{
+		CodeActionKinds: []protocol.CodeActionKind{protocol.CodeActionKindQuickFix},
+	}
 
 	return protocol.InitializeResult{
 		Capabilities: capabilities,
@@ -98,9 +158,31 @@ func (s *Server) initialized(_ *glsp.Context, _ *protocol.InitializedParams) err
 
 func (s *Server) shutdown(_ *glsp.Context) error {
 	protocol.SetTraceValue(protocol.TraceValueOff)
+	s.stop()
 	return nil
 }
 
+// exit flushes pending diagnostics before the connection is closed.
+func (s *Server) exit(_ *glsp.Context) error {
+	s.pending.Wait()
+	return nil
+}
+
+// stop marks the server as shutting down, so no new analysis is started, and
+// waits for pending diagnostics to be sent.
+func (s *Server) stop() {
+	s.mu.Lock()
+	s.shuttingDown = true
+	s.mu.Unlock()
+	s.pending.Wait()
+}
+
+func (s *Server) isShuttingDown() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shuttingDown
+}
+
 func (s *Server) setTrace(_ *glsp.Context, params *protocol.SetTraceParams) error {
 	protocol.SetTraceValue(params.Value)
 	return nil
@@ -155,20 +237,37 @@ func (s *Server) analyzeAndPublish(notify glsp.NotifyFunc, uri string, version i
 		return
 	}
 
-	result := Analyze(uri, content)
+	start := time.Now()
+	result := AnalyzeWithLimits(uri, content, s.limits)
+	elapsed := time.Since(start)
+	s.logger.Debug("analyzed document", "uri", uri, "version", version,
+		"duration", elapsed, "diagnostics", len(result.Diagnostics))
 
 	s.mu.Lock()
 	s.results[uri] = result
-	currentVersion := s.docVersion[uri]
+	s.lastAnalysis = &AnalysisStatus{
+		URI:         uri,
+		DurationMs:  durationMs(elapsed),
+		Diagnostics: len(result.Diagnostics),
+		Phases:      phaseTimings(result.Timings),
+	}
+	// Only publish diagnostics if this is still the latest version
+	// This prevents stale diagnostics from being published when rapid changes occur.
+	// The pending count is raised under mu so that stop never misses a send.
+	publish := version == s.docVersion[uri] && !s.shuttingDown
+	if publish {
+		s.pending.Add(1)
+	}
 	s.mu.Unlock()
 
-	// Only publish diagnostics if this is still the latest version
-	// This prevents stale diagnostics from being published when rapid changes occur
-	if version == currentVersion {
-		go notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
-			URI:         protocol.DocumentUri(uri),
-			Diagnostics: result.Diagnostics,
-		})
+	if publish {
+		go func() {
+			defer s.pending.Done()
+			notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
+				URI:         protocol.DocumentUri(uri),
+				Diagnostics: result.Diagnostics,
+			})
+		}()
 	}
 }
 
@@ -190,6 +289,43 @@ func (s *Server) textDocumentSemanticTokensFull(_ *glsp.Context, params *protoco
 	return &protocol.SemanticTokens{Data: data}, nil
 }
 
+// formatOptions returns the format options for the document at uri, as fmt
+// formats the file: the indent size the .editorconfig files that apply to it
+// set, like fmt, or else the client's tab size, unless the client asks for
+// tabs, which the formatter does not write. An unreadable or invalid
+// .editorconfig is ignored.
+func formatOptions(uri string, opts protocol.FormattingOptions) format.Options {
+	var fo format.Options
+	if path, ok := uriPath(uri); ok {
+		if size, err := format.EditorConfigIndent(path); err == nil && size > 0 {
+			fo.IndentSize = size
+			return fo
+		}
+	}
+	if spaces, ok := opts[protocol.FormattingOptionInsertSpaces].(bool); ok && !spaces {
+		return fo
+	}
+	// JSON numbers decode as float64.
+	if size, ok := opts[protocol.FormattingOptionTabSize].(float64); ok && size >= 1 {
+		fo.IndentSize = int(size)
+	}
+	return fo
+}
+
+// uriPath returns the file path of a file:// URI.
+func uriPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return "", false
+	}
+	p := u.Path
+	// file:///C:/themes/dark.pstheme
+	if len(p) > 2 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p), true
+}
+
 // textDocumentFormatting handles textDocument/formatting requests
 func (s *Server) textDocumentFormatting(_ *glsp.Context, params *protocol.DocumentFormattingParams) ([]protocol.TextEdit, error) {
 	uri := string(params.TextDocument.URI)
@@ -198,7 +334,7 @@ func (s *Server) textDocumentFormatting(_ *glsp.Context, params *protocol.Docume
 		return nil, nil
 	}
 
-	formatted, err := format.Format(content)
+	formatted, err := format.FormatWithOptions(content, formatOptions(uri, params.Options))
 	if err != nil {
 		return nil, err
 	}
@@ -209,15 +345,16 @@ func (s *Server) textDocumentFormatting(_ *glsp.Context, params *protocol.Docume
 	}
 
 	// Return a single text edit that replaces the entire document
-	lines := strings.Split(content, "\n")
-	endLine := uint32(len(lines) - 1)
-	endChar := uint32(len(lines[len(lines)-1]))
+	mapper, ok := s.docs.Mapper(uri)
+	if !ok {
+		return nil, nil
+	}
 
 	return []protocol.TextEdit{
 		{
 			Range: protocol.Range{
 				Start: protocol.Position{Line: 0, Character: 0},
-				End:   protocol.Position{Line: endLine, Character: endChar},
+				End:   mapper.End(),
 			},
 			NewText: formatted,
 		},
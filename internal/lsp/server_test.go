@@ -0,0 +1,140 @@
+package lsp
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestShutdownFlushesDiagnostics(t *testing.T) {
+	s := NewServer("test")
+	s.handler.SetInitialized(true)
+
+	var mu sync.Mutex
+	var published []string
+	notify := func(method string, _ any) {
+		mu.Lock()
+		defer mu.Unlock()
+		published = append(published, method)
+	}
+
+	err := s.textDocumentDidOpen(&glsp.Context{Notify: notify}, &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI:  "file:///test.pstheme",
+			Text: "palette {\n  base = \"#191724\"\n}\n",
+		},
+	})
+	if err != nil {
+		t.Fatalf("didOpen error: %v", err)
+	}
+
+	if _, _, _, err := s.Handle(&glsp.Context{Method: protocol.MethodShutdown}); err != nil {
+		t.Fatalf("shutdown error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(published) != 1 || published[0] != protocol.ServerTextDocumentPublishDiagnostics {
+		t.Errorf("published = %v, want one diagnostics notification before shutdown returns", published)
+	}
+}
+
+func TestRequestsAfterShutdown(t *testing.T) {
+	s := NewServer("test")
+	s.handler.SetInitialized(true)
+	if _, _, _, err := s.Handle(&glsp.Context{Method: protocol.MethodShutdown}); err != nil {
+		t.Fatalf("shutdown error: %v", err)
+	}
+
+	tests := []struct {
+		method  string
+		wantErr bool
+	}{
+		{statusMethod, true},
+		{protocol.MethodShutdown, true},
+		{protocol.MethodExit, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			_, validMethod, _, err := s.Handle(&glsp.Context{Method: tt.method})
+			if !validMethod {
+				t.Errorf("Handle(%s) reported an unknown method", tt.method)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Handle(%s) error = %v, wantErr %v", tt.method, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFormattingUsesTabSize(t *testing.T) {
+	s := NewServer("test")
+	s.handler.SetInitialized(true)
+
+	uri := protocol.DocumentUri("file:///test.pstheme")
+	err := s.textDocumentDidOpen(&glsp.Context{Notify: func(string, any) {}}, &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: uri, Text: "palette {\nbase = \"#191724\"\n}\n"},
+	})
+	if err != nil {
+		t.Fatalf("didOpen error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		options protocol.FormattingOptions
+		want    string
+	}{
+		{"tab size", protocol.FormattingOptions{"tabSize": float64(4), "insertSpaces": true}, "palette {\n    base = \"#191724\"\n}\n"},
+		{"tabs requested", protocol.FormattingOptions{"tabSize": float64(4), "insertSpaces": false}, "palette {\n  base = \"#191724\"\n}\n"},
+		{"no options", nil, "palette {\n  base = \"#191724\"\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edits, err := s.textDocumentFormatting(nil, &protocol.DocumentFormattingParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Options:      tt.options,
+			})
+			if err != nil {
+				t.Fatalf("formatting error: %v", err)
+			}
+			if len(edits) != 1 || edits[0].NewText != tt.want {
+				t.Errorf("edits = %+v, want one edit with %q", edits, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormattingUsesEditorConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".editorconfig"), []byte("root = true\n\n[*.pstheme]\nindent_size = 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer("test")
+	s.handler.SetInitialized(true)
+
+	uri := protocol.DocumentUri((&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(dir, "test.pstheme"))}).String())
+	err := s.textDocumentDidOpen(&glsp.Context{Notify: func(string, any) {}}, &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: uri, Text: "palette {\nbase = \"#191724\"\n}\n"},
+	})
+	if err != nil {
+		t.Fatalf("didOpen error: %v", err)
+	}
+
+	// .editorconfig takes precedence over the client's tab size, as in fmt.
+	edits, err := s.textDocumentFormatting(nil, &protocol.DocumentFormattingParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Options:      protocol.FormattingOptions{"tabSize": float64(4), "insertSpaces": true},
+	})
+	if err != nil {
+		t.Fatalf("formatting error: %v", err)
+	}
+	if want := "palette {\n   base = \"#191724\"\n}\n"; len(edits) != 1 || edits[0].NewText != want {
+		t.Errorf("edits = %+v, want one edit with %q", edits, want)
+	}
+}
@@ -0,0 +1,77 @@
+package lsp
+
+import (
+	"time"
+
+	"github.com/jsvensson/paletteswap/internal/metrics"
+)
+
+// statusMethod is the custom request editor extensions send to inspect the
+// server itself.
+const statusMethod = "pstheme/status"
+
+// StatusResult is the response to a pstheme/status request.
+type StatusResult struct {
+	Version       string          `json:"version"`
+	OpenDocuments []string        `json:"openDocuments"`
+	LastAnalysis  *AnalysisStatus `json:"lastAnalysis,omitempty"` // nil until a document is analyzed
+	Settings      StatusSettings  `json:"settings"`
+}
+
+// AnalysisStatus describes the most recent document analysis.
+type AnalysisStatus struct {
+	URI         string        `json:"uri"`
+	DurationMs  float64       `json:"durationMs"`
+	Diagnostics int           `json:"diagnostics"`
+	Phases      []PhaseTiming `json:"phases"` // parse, eval of each block, and lint, in order
+}
+
+// PhaseTiming is how long a phase of an analysis took.
+type PhaseTiming struct {
+	Phase      string  `json:"phase"`          // parse, eval or lint
+	Name       string  `json:"name,omitempty"` // block type for eval, e.g. "palette"
+	DurationMs float64 `json:"durationMs"`
+}
+
+// phaseTimings converts the timings of an analysis for a status response.
+func phaseTimings(timings []metrics.Timing) []PhaseTiming {
+	phases := make([]PhaseTiming, len(timings))
+	for i, t := range timings {
+		phases[i] = PhaseTiming{Phase: t.Phase, Name: t.Name, DurationMs: durationMs(t.Duration)}
+	}
+	return phases
+}
+
+// durationMs returns d in milliseconds, to the microsecond.
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// StatusSettings reports the settings in effect.
+type StatusSettings struct {
+	MaxFileSize       int64 `json:"maxFileSize"`
+	MaxPaletteEntries int   `json:"maxPaletteEntries"`
+	MaxTransformSteps int   `json:"maxTransformSteps"`
+}
+
+// status builds the response to a pstheme/status request.
+func (s *Server) status() StatusResult {
+	s.mu.RLock()
+	var last *AnalysisStatus
+	if s.lastAnalysis != nil {
+		copied := *s.lastAnalysis
+		last = &copied
+	}
+	s.mu.RUnlock()
+
+	return StatusResult{
+		Version:       s.version,
+		OpenDocuments: s.docs.URIs(),
+		LastAnalysis:  last,
+		Settings: StatusSettings{
+			MaxFileSize:       s.limits.MaxFileSize,
+			MaxPaletteEntries: s.limits.MaxPaletteEntries,
+			MaxTransformSteps: s.limits.MaxTransformSteps,
+		},
+	}
+}
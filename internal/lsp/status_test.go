@@ -0,0 +1,54 @@
+package lsp
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestStatusRequest(t *testing.T) {
+	s := NewServer("1.2.3")
+
+	notify := func(string, any) {}
+	err := s.textDocumentDidOpen(&glsp.Context{Notify: notify}, &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI:  "file:///test.pstheme",
+			Text: "palette {\n  base = \"#191724\"\n}\n",
+		},
+	})
+	if err != nil {
+		t.Fatalf("didOpen error: %v", err)
+	}
+
+	r, validMethod, validParams, err := s.Handle(&glsp.Context{Method: statusMethod, Params: json.RawMessage("null")})
+	if err != nil || !validMethod || !validParams {
+		t.Fatalf("Handle(%s) = valid %v/%v, err %v", statusMethod, validMethod, validParams, err)
+	}
+
+	status, ok := r.(StatusResult)
+	if !ok {
+		t.Fatalf("result type = %T, want StatusResult", r)
+	}
+	if status.Version != "1.2.3" {
+		t.Errorf("Version = %q, want %q", status.Version, "1.2.3")
+	}
+	if len(status.OpenDocuments) != 1 || status.OpenDocuments[0] != "file:///test.pstheme" {
+		t.Errorf("OpenDocuments = %v, want [file:///test.pstheme]", status.OpenDocuments)
+	}
+	if status.LastAnalysis == nil || status.LastAnalysis.URI != "file:///test.pstheme" {
+		t.Fatalf("LastAnalysis = %+v, want analysis of file:///test.pstheme", status.LastAnalysis)
+	}
+	var phases []string
+	for _, p := range status.LastAnalysis.Phases {
+		phases = append(phases, p.Phase+" "+p.Name)
+	}
+	if want := "parse file:///test.pstheme,eval palette,lint "; strings.Join(phases, ",") != want {
+		t.Errorf("Phases = %q, want %q", phases, want)
+	}
+	if status.Settings.MaxFileSize == 0 {
+		t.Error("Settings.MaxFileSize should report the default limit")
+	}
+}
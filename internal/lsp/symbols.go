@@ -0,0 +1,116 @@
+package lsp
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// styleAttrs are the attributes that make a block outside the palette a
+// style block rather than a group.
+var styleAttrs = []string{color.ColorKey, "bold", "italic", "underline"}
+
+// documentSymbols returns the outline of the analyzed document: a symbol
+// per block and attribute, nested as in the file. Top-level blocks and
+// groups are namespaces, style blocks and other leaf blocks such as
+// theme.cursor are structs, and colors are constants, as the protocol has
+// no color symbol kind, with their resolved hex as the detail. Block ranges
+// span the whole body, so editors can tell which block the cursor is in for
+// breadcrumbs and sticky headers.
+func documentSymbols(result *AnalysisResult) []protocol.DocumentSymbol {
+	if result == nil || result.body == nil {
+		return []protocol.DocumentSymbol{}
+	}
+
+	colors := make(map[protocol.Range]color.Color, len(result.Colors))
+	for _, cl := range result.Colors {
+		if _, ok := colors[cl.Range]; !ok {
+			colors[cl.Range] = cl.Color
+		}
+	}
+	return result.bodySymbols(result.body, "", colors)
+}
+
+// bodySymbols returns the symbols of the attributes and blocks of body, in
+// source order. block is the type of the top-level block body belongs to,
+// or "" for the file body.
+func (r *AnalysisResult) bodySymbols(body *hclsyntax.Body, block string, colors map[protocol.Range]color.Color) []protocol.DocumentSymbol {
+	symbols := make([]protocol.DocumentSymbol, 0, len(body.Attributes)+len(body.Blocks))
+	for _, attr := range body.Attributes {
+		sym := protocol.DocumentSymbol{
+			Name:           attr.Name,
+			Kind:           protocol.SymbolKindProperty,
+			Range:          r.lspRange(attr.SrcRange),
+			SelectionRange: r.lspRange(attr.NameRange),
+		}
+		if c, ok := colors[r.colorRange(attr.Expr)]; ok {
+			sym.Kind = protocol.SymbolKindConstant
+			sym.Detail = strPtr(c.Hex())
+		}
+		symbols = append(symbols, sym)
+	}
+
+	for _, b := range body.Blocks {
+		name := b.Type
+		for _, label := range b.Labels {
+			name += " " + strconv.Quote(label)
+		}
+		sym := protocol.DocumentSymbol{
+			Name:           name,
+			Kind:           protocol.SymbolKindNamespace,
+			Range:          r.lspRange(b.Range()),
+			SelectionRange: r.lspRange(b.DefRange()),
+		}
+		topLevel := block
+		if block == "" {
+			topLevel = b.Type
+		} else if isStructBlock(b, block) {
+			sym.Kind = protocol.SymbolKindStruct
+		}
+		if attr, ok := b.Body.Attributes[color.ColorKey]; ok {
+			if c, ok := colors[r.colorRange(attr.Expr)]; ok {
+				sym.Detail = strPtr(c.Hex())
+			}
+		}
+		sym.Children = r.bodySymbols(b.Body, topLevel, colors)
+		symbols = append(symbols, sym)
+	}
+
+	slices.SortFunc(symbols, func(a, b protocol.DocumentSymbol) int {
+		if a.Range.Start.Line != b.Range.Start.Line {
+			return int(a.Range.Start.Line) - int(b.Range.Start.Line)
+		}
+		return int(a.Range.Start.Character) - int(b.Range.Start.Character)
+	})
+	return symbols
+}
+
+// isStructBlock reports whether b, nested in the top-level block named
+// block, is a leaf block of settings rather than a group: a style block,
+// which has a color or a style flag, a theme sub-block such as cursor, or a
+// block of options such as transform or lint. Palette groups, including
+// scale blocks, are never structs.
+func isStructBlock(b *hclsyntax.Block, block string) bool {
+	switch {
+	case block == "palette":
+		return b.Type == "transform"
+	case block == "syntax" || block == "semantic":
+		for name := range b.Body.Attributes {
+			if slices.Contains(styleAttrs, name) {
+				return true
+			}
+		}
+		return false
+	default:
+		return len(b.Body.Blocks) == 0
+	}
+}
+
+// textDocumentDocumentSymbol handles textDocument/documentSymbol requests.
+func (s *Server) textDocumentDocumentSymbol(_ *glsp.Context, params *protocol.DocumentSymbolParams) (any, error) {
+	return documentSymbols(s.getResult(string(params.TextDocument.URI))), nil
+}
@@ -0,0 +1,107 @@
+package lsp
+
+import (
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestDocumentSymbols(t *testing.T) {
+	content := `palette {
+  base = "#191724"
+  highlight {
+    low = "#21202e"
+  }
+}
+
+theme {
+  background = palette.base
+  cursor {
+    cursor = palette.highlight.low
+    text   = palette.base
+  }
+}
+
+syntax {
+  markup {
+    heading = palette.base
+    bold {
+      color = palette.highlight.low
+      bold  = true
+    }
+  }
+}
+`
+	result := Analyze("test.pstheme", content)
+	symbols := documentSymbols(result)
+
+	type want struct {
+		name   string
+		kind   protocol.SymbolKind
+		detail string
+	}
+	var flatten func(prefix string, syms []protocol.DocumentSymbol) map[string]protocol.DocumentSymbol
+	flatten = func(prefix string, syms []protocol.DocumentSymbol) map[string]protocol.DocumentSymbol {
+		out := make(map[string]protocol.DocumentSymbol)
+		for _, s := range syms {
+			path := prefix + s.Name
+			out[path] = s
+			for k, v := range flatten(path+".", s.Children) {
+				out[k] = v
+			}
+		}
+		return out
+	}
+	got := flatten("", symbols)
+
+	for _, w := range []want{
+		{"palette", protocol.SymbolKindNamespace, ""},
+		{"palette.base", protocol.SymbolKindConstant, "#191724"},
+		{"palette.highlight", protocol.SymbolKindNamespace, ""},
+		{"palette.highlight.low", protocol.SymbolKindConstant, "#21202e"},
+		{"theme.background", protocol.SymbolKindConstant, "#191724"},
+		{"theme.cursor", protocol.SymbolKindStruct, ""},
+		{"syntax.markup", protocol.SymbolKindNamespace, ""},
+		{"syntax.markup.bold", protocol.SymbolKindStruct, "#21202e"},
+		{"syntax.markup.bold.bold", protocol.SymbolKindProperty, ""},
+	} {
+		sym, ok := got[w.name]
+		if !ok {
+			t.Errorf("missing symbol %s", w.name)
+			continue
+		}
+		if sym.Kind != w.kind {
+			t.Errorf("%s: kind = %d, want %d", w.name, sym.Kind, w.kind)
+		}
+		detail := ""
+		if sym.Detail != nil {
+			detail = *sym.Detail
+		}
+		if detail != w.detail {
+			t.Errorf("%s: detail = %q, want %q", w.name, detail, w.detail)
+		}
+	}
+
+	if len(symbols) != 3 || symbols[0].Name != "palette" || symbols[1].Name != "theme" || symbols[2].Name != "syntax" {
+		t.Fatalf("top-level symbols not in source order: %v", symbols)
+	}
+
+	// Block ranges span the body; the selection range is the header.
+	theme := symbols[1]
+	wantRange := protocol.Range{Start: protocol.Position{Line: 7, Character: 0}, End: protocol.Position{Line: 13, Character: 1}}
+	if theme.Range != wantRange {
+		t.Errorf("theme range = %v, want %v", theme.Range, wantRange)
+	}
+	if theme.SelectionRange.Start != wantRange.Start || theme.SelectionRange.End.Line != 7 {
+		t.Errorf("theme selection range = %v, want the block header", theme.SelectionRange)
+	}
+	if names := []string{theme.Children[0].Name, theme.Children[1].Name}; names[0] != "background" || names[1] != "cursor" {
+		t.Errorf("theme children = %v, want [background cursor]", names)
+	}
+}
+
+func TestDocumentSymbols_Nil(t *testing.T) {
+	if got := documentSymbols(nil); got == nil || len(got) != 0 {
+		t.Errorf("documentSymbols(nil) = %v, want empty slice", got)
+	}
+}
@@ -0,0 +1,158 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/tliron/commonlog"
+	_ "github.com/tliron/commonlog/simple"
+)
+
+var configureLogging sync.Once
+
+// Run serves a single client over stdin/stdout until the client sends exit,
+// the connection closes, or ctx is cancelled (e.g. on SIGTERM). Pending
+// diagnostics are flushed before it returns. It returns nil after an orderly
+// shutdown or cancellation and errExitWithoutShutdown otherwise.
+func (s *Server) Run(ctx context.Context) error {
+	return s.serve(ctx, jsonrpc2.NewBufferedStream(stdio{}, jsonrpc2.VSCodeObjectCodec{}))
+}
+
+// RunNodeIPC serves a single client over the Node.js IPC channel whose file
+// descriptor is passed in NODE_CHANNEL_FD, as used by the vscode-languageclient
+// ipc transport. Messages are newline-delimited JSON. It behaves like Run otherwise.
+func (s *Server) RunNodeIPC(ctx context.Context) error {
+	fd, err := strconv.Atoi(os.Getenv("NODE_CHANNEL_FD"))
+	if err != nil {
+		return fmt.Errorf("node IPC: NODE_CHANNEL_FD is missing or invalid: %w", err)
+	}
+	channel := os.NewFile(uintptr(fd), "NODE_CHANNEL_FD")
+	return s.serve(ctx, jsonrpc2.NewPlainObjectStream(channel))
+}
+
+// ListenAndServe accepts TCP connections on address and serves each one with
+// a new Server from newServer, so that editor windows sharing the process keep
+// their own documents and shutdown state. It returns nil once ctx is cancelled
+// and all connections have finished.
+func ListenAndServe(ctx context.Context, address string, newServer func() *Server) error {
+	var lc net.ListenConfig
+	ln, err := lc.Listen(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", address, err)
+	}
+	return serveListener(ctx, ln, newServer)
+}
+
+func serveListener(ctx context.Context, ln net.Listener, newServer func() *Server) error {
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accepting connection: %w", err)
+		}
+
+		s := newServer()
+		s.logger.Info("accepted connection", "remote", conn.RemoteAddr())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.serve(ctx, jsonrpc2.NewBufferedStream(conn, jsonrpc2.VSCodeObjectCodec{}))
+			if err != nil {
+				s.logger.Warn("connection closed", "remote", conn.RemoteAddr(), "error", err)
+			}
+		}()
+	}
+}
+
+// serve runs the protocol over stream until the client sends exit, the
+// stream closes, or ctx is cancelled.
+func (s *Server) serve(ctx context.Context, stream jsonrpc2.ObjectStream) error {
+	configureLogging.Do(func() { commonlog.Configure(1, nil) })
+	s.logger.Info("starting server", "version", s.version)
+	conn := jsonrpc2.NewConn(ctx, stream, jsonrpc2.HandlerWithError(s.handleRPC))
+
+	select {
+	case <-conn.DisconnectNotify():
+	case <-ctx.Done():
+		s.logger.Info("stopping server", "reason", context.Cause(ctx))
+		s.stop()
+		conn.Close()
+	}
+	s.pending.Wait()
+
+	if !s.isShuttingDown() {
+		return errExitWithoutShutdown
+	}
+	return nil
+}
+
+// handleRPC adapts a JSON-RPC request to Handle and maps its result to a
+// JSON-RPC response, closing the connection after exit.
+func (s *Server) handleRPC(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+	glspCtx := &glsp.Context{
+		Method: req.Method,
+		Notify: func(method string, params any) {
+			if err := conn.Notify(ctx, method, params); err != nil {
+				s.logger.Warn("notification failed", "method", method, "error", err)
+			}
+		},
+		Call: func(method string, params any, result any) {
+			if err := conn.Call(ctx, method, params, result); err != nil {
+				s.logger.Warn("call failed", "method", method, "error", err)
+			}
+		},
+	}
+	if req.Params != nil {
+		glspCtx.Params = *req.Params
+	}
+
+	r, validMethod, validParams, err := s.Handle(glspCtx)
+	switch {
+	case req.Method == protocol.MethodExit:
+		return nil, conn.Close()
+	case !validMethod:
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeMethodNotFound,
+			Message: fmt.Sprintf("method not supported: %s", req.Method),
+		}
+	case !validParams:
+		e := &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		if err != nil {
+			e.Message = err.Error()
+		}
+		return nil, e
+	case err != nil:
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: err.Error()}
+	default:
+		return r, nil
+	}
+}
+
+// stdio joins stdin and stdout into a single stream.
+type stdio struct{}
+
+func (stdio) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
+func (stdio) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+
+func (stdio) Close() error {
+	if err := os.Stdin.Close(); err != nil {
+		return err
+	}
+	return os.Stdout.Close()
+}
@@ -0,0 +1,64 @@
+package lsp
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestServeListener_SeparateSessions(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serveListener(ctx, ln, func() *Server { return NewServer("test") })
+	}()
+
+	dial := func() *jsonrpc2.Conn {
+		t.Helper()
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		conn := jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(c, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(
+			func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) { return nil, nil },
+		))
+		var result protocol.InitializeResult
+		if err := conn.Call(ctx, protocol.MethodInitialize, protocol.InitializeParams{}, &result); err != nil {
+			t.Fatalf("initialize: %v", err)
+		}
+		return conn
+	}
+
+	first, second := dial(), dial()
+
+	// Shutting down the first session must not affect the second.
+	if err := first.Call(ctx, protocol.MethodShutdown, nil, nil); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if err := first.Call(ctx, statusMethod, nil, nil); err == nil {
+		t.Error("status after shutdown should fail on the first connection")
+	}
+
+	var status StatusResult
+	if err := second.Call(ctx, statusMethod, nil, &status); err != nil {
+		t.Fatalf("status on second connection: %v", err)
+	}
+	if status.Version != "test" {
+		t.Errorf("Version = %q, want %q", status.Version, "test")
+	}
+
+	first.Close()
+	second.Close()
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("serveListener() error = %v", err)
+	}
+}
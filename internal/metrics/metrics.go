@@ -0,0 +1,44 @@
+// Package metrics records how long the phases of loading a theme and
+// rendering its templates take, so that slow themes can be diagnosed. A
+// caller installs a Recorder on the context passed to the parser and the
+// Engine, which report each phase to it; without one, nothing is recorded.
+// The language server's analyzer keeps its Timings with its result.
+package metrics
+
+import (
+	"context"
+	"time"
+)
+
+// Phases reported to a Recorder.
+const (
+	PhaseParse  = "parse"  // reading and parsing the HCL file; the name is its path
+	PhaseEval   = "eval"   // evaluating a top-level block; the name is its type, e.g. "palette"
+	PhaseLint   = "lint"   // running the lint rules
+	PhaseRender = "render" // rendering a template; the name is its path
+)
+
+// Recorder receives the duration of a phase.
+type Recorder func(phase, name string, d time.Duration)
+
+type recorderKey struct{}
+
+// WithRecorder returns a copy of ctx that carries rec.
+func WithRecorder(ctx context.Context, rec Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, rec)
+}
+
+// Since reports the time elapsed since start to the Recorder of ctx, if it
+// has one.
+func Since(ctx context.Context, phase, name string, start time.Time) {
+	if rec, ok := ctx.Value(recorderKey{}).(Recorder); ok && rec != nil {
+		rec(phase, name, time.Since(start))
+	}
+}
+
+// Timing is the recorded duration of a phase.
+type Timing struct {
+	Phase    string
+	Name     string
+	Duration time.Duration
+}
@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSince(t *testing.T) {
+	// Without a Recorder, Since does nothing.
+	Since(context.Background(), PhaseParse, "theme.pstheme", time.Now())
+
+	var got []string
+	ctx := WithRecorder(context.Background(), func(phase, name string, d time.Duration) {
+		if d < 0 {
+			t.Errorf("duration = %v, want non-negative", d)
+		}
+		got = append(got, phase+" "+name)
+	})
+	Since(ctx, PhaseEval, "palette", time.Now())
+	Since(ctx, PhaseRender, "kitty.conf.tmpl", time.Now())
+	if len(got) != 2 || got[0] != "eval palette" || got[1] != "render kitty.conf.tmpl" {
+		t.Errorf("recorded %q, want eval palette and render kitty.conf.tmpl", got)
+	}
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -112,6 +113,106 @@ theme {
 	}
 }
 
+func TestValidateANSI_Profile(t *testing.T) {
+	const basic = `
+settings {
+  ansi_profile = %q
+}
+
+palette {
+  base = "#191724"
+}
+
+ansi {
+  black   = "#000000"
+  red     = "#ff0000"
+  green   = "#00ff00"
+  yellow  = "#ffff00"
+  blue    = "#0000ff"
+  magenta = "#ff00ff"
+  cyan    = "#00ffff"
+  white   = "#ffffff"
+%s}
+`
+	const bright = `  bright_black   = "#808080"
+  bright_red     = "#ff8080"
+  bright_green   = "#80ff80"
+  bright_yellow  = "#ffff80"
+  bright_blue    = "#8080ff"
+  bright_magenta = "#ff80ff"
+  bright_cyan    = "#80ffff"
+  bright_white   = "#ffffff"
+`
+	const extra = `  foreground = "#e0def4"
+  background = palette.base
+  cursor     = "#524f67"
+`
+	tests := []struct {
+		name    string
+		profile string
+		entries string
+		wantErr string
+	}{
+		{"basic8", "basic8", "", ""},
+		{"standard16 with 8 colors", "standard16", "", "Missing colors: bright_black"},
+		{"extended", "extended", bright + extra, ""},
+		{"extended without defaults", "extended", bright, "Missing colors: foreground, background, cursor"},
+		{"unknown profile", "256", bright, "ansi_profile must be one of"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Parse(writeThemeFile(t, fmt.Sprintf(basic, tt.profile, tt.entries)))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if tt.profile == "extended" && result.ANSI["background"].Hex() != "#191724" {
+					t.Errorf("ANSI[background] = %v, want #191724", result.ANSI["background"])
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateANSI_LintSeverity(t *testing.T) {
+	const theme = `
+settings {
+  lint {
+    missing-ansi = %q
+  }
+}
+
+palette {
+  base = "#191724"
+}
+
+ansi {
+  black = "#000000"
+}
+`
+	for severity, wantErr := range map[string]bool{"error": true, "warning": false, "off": false} {
+		t.Run(severity, func(t *testing.T) {
+			_, err := Parse(writeThemeFile(t, fmt.Sprintf(theme, severity)))
+			if wantErr && (err == nil || !strings.Contains(err.Error(), "ansi block incomplete")) {
+				t.Errorf("error = %v, want ansi block incomplete", err)
+			}
+			if !wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	// Without an ansi block the theme never loads.
+	noBlock := "settings {\n  lint {\n    PS001 = \"off\"\n  }\n}\n\npalette {\n  base = \"#191724\"\n}\n"
+	if _, err := Parse(writeThemeFile(t, noBlock)); err == nil || !strings.Contains(err.Error(), "missing required ansi block") {
+		t.Errorf("error = %v, want missing required ansi block", err)
+	}
+}
+
 func writeThemeFile(t *testing.T, content string) string {
 	tmpFile := filepath.Join(t.TempDir(), "theme.hcl")
 	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
@@ -1,17 +1,27 @@
 package parser
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/lint"
+	"github.com/jsvensson/paletteswap/internal/metrics"
 	"github.com/jsvensson/paletteswap/internal/theme"
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
 )
 
 // ParseResult holds the raw parsed theme data.
@@ -19,16 +29,79 @@ type ParseResult struct {
 	Meta    Meta
 	Palette *color.Node
 	Syntax  color.Tree
-	Theme   map[string]color.Color
-	ANSI    map[string]color.Color
+	// LanguageSyntax holds per-language overrides from labeled syntax blocks,
+	// keyed by label (e.g. syntax "go" { ... }).
+	LanguageSyntax map[string]color.Tree
+	// Semantic holds LSP semantic token styles keyed by standard token type.
+	Semantic  map[string]color.Style
+	Theme     map[string]color.Color
+	Cursor    *Cursor
+	Selection *Selection
+	ANSI      map[string]color.Color
+	// Sources holds where each color path is defined, see collectSources.
+	Sources map[string]Location
+	// MigrationHints describes constructs that a later spec version no
+	// longer accepts, one "file:line: message; hint" string each.
+	MigrationHints []string
+	// SchemaVersion is the schema_version setting, or 0 if it is not set.
+	SchemaVersion int
+}
+
+// Cursor holds the colors from the theme block's cursor sub-block.
+type Cursor struct {
+	Color color.Color // the cursor itself (cursor attribute)
+	Text  color.Color // text under the cursor
+}
+
+// Selection holds the colors from the theme block's selection sub-block.
+type Selection struct {
+	Background color.Color
+	Foreground color.Color
 }
 
 // Meta holds theme metadata.
 type Meta struct {
-	Name       string `hcl:"name,optional"`
-	Author     string `hcl:"author,optional"`
-	Appearance string `hcl:"appearance,optional"`
-	URL        string `hcl:"url,optional"`
+	Name       string
+	Author     string
+	Appearance string
+	URL        string
+	// SpecVersion is the theme language version from the spec_version
+	// attribute, or 1 if it is not set.
+	SpecVersion int
+	Extra       map[string]string // any additional meta attributes, keyed by name
+}
+
+// Map returns every meta field that is set, keyed by its attribute name:
+// name, author, appearance, url, spec_version and the extra attributes.
+func (m Meta) Map() map[string]string {
+	fields := make(map[string]string, len(m.Extra)+5)
+	for k, v := range m.Extra {
+		fields[k] = v
+	}
+	for k, v := range map[string]string{
+		"name":       m.Name,
+		"author":     m.Author,
+		"appearance": m.Appearance,
+		"url":        m.URL,
+	} {
+		if v != "" {
+			fields[k] = v
+		}
+	}
+	if m.SpecVersion != 0 {
+		fields[theme.SpecVersionAttr] = strconv.Itoa(m.SpecVersion)
+	}
+	return fields
+}
+
+// MetaBlock decodes the meta block. Attributes beyond the well-known ones
+// are captured in Remain and surfaced as Meta.Extra.
+type MetaBlock struct {
+	Name       string   `hcl:"name,optional"`
+	Author     string   `hcl:"author,optional"`
+	Appearance string   `hcl:"appearance,optional"`
+	URL        string   `hcl:"url,optional"`
+	Remain     hcl.Body `hcl:",remain"`
 }
 
 // PaletteBlock wraps a single palette block for gohcl decoding.
@@ -36,9 +109,10 @@ type PaletteBlock struct {
 	Entries hcl.Body `hcl:",remain"`
 }
 
-// RawConfig captures the palette block first (no EvalContext needed).
+// RawConfig captures the palette and locals blocks first (no EvalContext needed).
 type RawConfig struct {
 	Palette *PaletteBlock `hcl:"palette,block"`
+	Locals  *ColorBlock   `hcl:"locals,block"`
 	Remain  hcl.Body      `hcl:",remain"`
 }
 
@@ -47,12 +121,33 @@ type ColorBlock struct {
 	Entries hcl.Body `hcl:",remain"`
 }
 
+// ThemeBlock decodes the theme block: typed cursor and selection sub-blocks
+// plus arbitrary flat color attributes.
+type ThemeBlock struct {
+	Cursor    *CursorBlock    `hcl:"cursor,block"`
+	Selection *SelectionBlock `hcl:"selection,block"`
+	Entries   hcl.Body        `hcl:",remain"`
+}
+
+// CursorBlock decodes theme { cursor { ... } }.
+type CursorBlock struct {
+	Cursor hcl.Expression `hcl:"cursor"`
+	Text   hcl.Expression `hcl:"text"`
+}
+
+// SelectionBlock decodes theme { selection { ... } }.
+type SelectionBlock struct {
+	Background hcl.Expression `hcl:"background"`
+	Foreground hcl.Expression `hcl:"foreground"`
+}
+
 // ResolvedConfig decodes blocks that reference palette.
 type ResolvedConfig struct {
-	Meta   *Meta       `hcl:"meta,block"`
-	Theme  *ColorBlock `hcl:"theme,block"`
-	ANSI   *ColorBlock `hcl:"ansi,block"`
-	Remain hcl.Body    `hcl:",remain"` // captures syntax for manual parsing
+	Meta     *MetaBlock  `hcl:"meta,block"`
+	Theme    *ThemeBlock `hcl:"theme,block"`
+	ANSI     *ColorBlock `hcl:"ansi,block"`
+	Semantic *ColorBlock `hcl:"semantic,block"`
+	Remain   hcl.Body    `hcl:",remain"` // captures syntax for manual parsing
 }
 
 // LightnessTransform holds the parsed transform lightness configuration.
@@ -62,16 +157,50 @@ type LightnessTransform struct {
 	Steps int
 }
 
+// Lightness returns the OKLCH lightness of each generated step, l1 first.
+func (t *LightnessTransform) Lightness() []float64 {
+	values := make([]float64, t.Steps)
+	for i := range values {
+		if t.Steps == 1 {
+			values[i] = (t.Low + t.High) / 2
+		} else {
+			values[i] = t.Low + (t.High-t.Low)*float64(i)/float64(t.Steps-1)
+		}
+	}
+	return values
+}
+
+// TransformAttributes lists the attributes of a transform's lightness block.
+var TransformAttributes = []string{"range", "steps"}
+
+// TransformError is an error in a palette transform block. Range covers the
+// attribute or block at fault, so editors can point at it.
+type TransformError struct {
+	Range hcl.Range
+	Msg   string
+}
+
+func (e *TransformError) Error() string { return e.Msg }
+
+// transformErrorf returns a *TransformError at rng.
+func transformErrorf(rng hcl.Range, format string, args ...any) error {
+	return &TransformError{Range: rng, Msg: fmt.Sprintf(format, args...)}
+}
+
 // ParseTransformBlock extracts and parses a transform { lightness { ... } } block
 // from an *hclsyntax.Body. Returns (nil, nil) if no transform block is present.
+// Errors are *TransformError values.
 func ParseTransformBlock(body *hclsyntax.Body) (*LightnessTransform, error) {
 	// Find transform block
 	var transformBlock *hclsyntax.Block
 	for _, block := range body.Blocks {
-		if block.Type == "transform" {
-			transformBlock = block
-			break
+		if block.Type != "transform" {
+			continue
 		}
+		if transformBlock != nil {
+			return nil, transformErrorf(block.DefRange(), "duplicate transform block")
+		}
+		transformBlock = block
 	}
 	if transformBlock == nil {
 		return nil, nil
@@ -79,78 +208,252 @@ func ParseTransformBlock(body *hclsyntax.Body) (*LightnessTransform, error) {
 
 	// Find lightness block inside transform
 	var lightnessBlock *hclsyntax.Block
-	for _, block := range transformBlock.Body.Blocks {
-		if block.Type == "lightness" {
-			lightnessBlock = block
-			break
+	for _, item := range sortedItems(transformBlock.Body) {
+		switch {
+		case item.attr != nil:
+			return nil, transformErrorf(item.attr.NameRange, "transform: unexpected attribute %q (valid blocks: lightness)", item.attr.Name)
+		case item.block.Type != "lightness":
+			return nil, transformErrorf(item.block.DefRange(), "transform: unexpected block %q (valid: lightness)", item.block.Type)
+		case lightnessBlock != nil:
+			return nil, transformErrorf(item.block.DefRange(), "transform: duplicate lightness block")
 		}
+		lightnessBlock = item.block
 	}
 	if lightnessBlock == nil {
-		return nil, fmt.Errorf("transform block has no lightness block")
+		return nil, transformErrorf(transformBlock.DefRange(), "transform block has no lightness block")
+	}
+	for _, item := range sortedItems(lightnessBlock.Body) {
+		switch {
+		case item.block != nil:
+			return nil, transformErrorf(item.block.DefRange(), "lightness: unexpected block %q", item.block.Type)
+		case !slices.Contains(TransformAttributes, item.attr.Name):
+			return nil, transformErrorf(item.attr.NameRange, "lightness: unexpected attribute %q (valid: range, steps)", item.attr.Name)
+		}
 	}
 
 	// Parse range attribute
 	rangeAttr, ok := lightnessBlock.Body.Attributes["range"]
 	if !ok {
-		return nil, fmt.Errorf("lightness block missing 'range' attribute")
+		return nil, transformErrorf(lightnessBlock.DefRange(), "lightness block missing 'range' attribute")
 	}
+	rangeRng := rangeAttr.Expr.Range()
 	rangeVal, diags := rangeAttr.Expr.Value(nil)
 	if diags.HasErrors() {
-		return nil, fmt.Errorf("evaluating lightness range: %s", diags.Error())
+		return nil, transformErrorf(rangeRng, "evaluating lightness range: %s", diags.Error())
+	}
+	rangeType := rangeVal.Type()
+	if rangeVal.IsNull() || !rangeVal.IsWhollyKnown() ||
+		!(rangeType.IsTupleType() || rangeType.IsListType()) || rangeVal.LengthInt() != 2 {
+		return nil, transformErrorf(rangeRng, "lightness range must be a list of two numbers, e.g. [0.1, 0.9]")
 	}
 
-	lowVal := rangeVal.Index(cty.NumberIntVal(0))
-	highVal := rangeVal.Index(cty.NumberIntVal(1))
-	low, _ := lowVal.AsBigFloat().Float64()
-	high, _ := highVal.AsBigFloat().Float64()
+	var bounds [2]float64
+	for i := range bounds {
+		v, ok := numberValue(rangeVal.Index(cty.NumberIntVal(int64(i))))
+		if !ok {
+			return nil, transformErrorf(rangeRng, "lightness range must be a list of two numbers, e.g. [0.1, 0.9]")
+		}
+		if v < 0 || v > 1 {
+			return nil, transformErrorf(rangeRng, "lightness range values must be between 0 and 1, got %g", v)
+		}
+		bounds[i] = v
+	}
 
 	// Parse steps attribute
 	stepsAttr, ok := lightnessBlock.Body.Attributes["steps"]
 	if !ok {
-		return nil, fmt.Errorf("lightness block missing 'steps' attribute")
+		return nil, transformErrorf(lightnessBlock.DefRange(), "lightness block missing 'steps' attribute")
 	}
+	stepsRng := stepsAttr.Expr.Range()
 	stepsVal, diags := stepsAttr.Expr.Value(nil)
 	if diags.HasErrors() {
-		return nil, fmt.Errorf("evaluating lightness steps: %s", diags.Error())
+		return nil, transformErrorf(stepsRng, "evaluating lightness steps: %s", diags.Error())
+	}
+	steps, ok := numberValue(stepsVal)
+	if !ok || steps != float64(int64(steps)) {
+		return nil, transformErrorf(stepsRng, "lightness steps must be a whole number")
 	}
-	stepsInt, _ := stepsVal.AsBigFloat().Int64()
+	stepsInt := int64(steps)
 	if stepsInt < 1 {
-		return nil, fmt.Errorf("lightness steps must be >= 1, got %d", stepsInt)
+		return nil, transformErrorf(stepsRng, "lightness steps must be >= 1, got %d", stepsInt)
 	}
 
 	return &LightnessTransform{
-		Low:   low,
-		High:  high,
+		Low:   bounds[0],
+		High:  bounds[1],
 		Steps: int(stepsInt),
 	}, nil
 }
 
+// IsScaleBlock reports whether a palette block is a scale "name" { ... }
+// block. An unlabeled block named scale is an ordinary color group.
+func IsScaleBlock(block *hclsyntax.Block) bool {
+	return block.Type == "scale" && len(block.Labels) == 1
+}
+
+// ParseScaleBlock evaluates a scale "name" { from = ..., to = ..., steps = N }
+// block. It returns a node whose children "1".."N" are evenly interpolated
+// from the from color to the to color, including both endpoints.
+func ParseScaleBlock(block *hclsyntax.Block, ctx *hcl.EvalContext, limits theme.Limits) (*color.Node, error) {
+	name := block.Labels[0]
+	if len(block.Body.Blocks) > 0 {
+		return nil, fmt.Errorf("scale %q: unexpected block %q", name, block.Body.Blocks[0].Type)
+	}
+	for attrName := range block.Body.Attributes {
+		if attrName != "from" && attrName != "to" && attrName != "steps" {
+			return nil, fmt.Errorf("scale %q: unexpected attribute %q (valid: from, to, steps)", name, attrName)
+		}
+	}
+
+	endpoint := func(attrName string) (color.Color, error) {
+		attr, ok := block.Body.Attributes[attrName]
+		if !ok {
+			return color.Color{}, fmt.Errorf("scale %q missing '%s' attribute", name, attrName)
+		}
+		c, err := evalColor(attr.Expr, ctx)
+		if err != nil {
+			return color.Color{}, fmt.Errorf("scale %q %s: %w", name, attrName, err)
+		}
+		return c, nil
+	}
+	from, err := endpoint("from")
+	if err != nil {
+		return nil, err
+	}
+	to, err := endpoint("to")
+	if err != nil {
+		return nil, err
+	}
+
+	stepsAttr, ok := block.Body.Attributes["steps"]
+	if !ok {
+		return nil, fmt.Errorf("scale %q missing 'steps' attribute", name)
+	}
+	stepsVal, diags := stepsAttr.Expr.Value(ctx)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("evaluating scale %q steps: %s", name, diags.Error())
+	}
+	steps, ok := numberValue(stepsVal)
+	if !ok || steps != float64(int64(steps)) {
+		return nil, fmt.Errorf("scale %q steps must be a whole number", name)
+	}
+	if steps < 2 {
+		return nil, fmt.Errorf("scale %q steps must be >= 2, got %d", name, int64(steps))
+	}
+	if err := limits.CheckScaleSteps(int(steps)); err != nil {
+		return nil, fmt.Errorf("scale %q: %w", name, err)
+	}
+
+	node := &color.Node{Children: make(map[string]*color.Node, int(steps))}
+	for i, c := range color.Scale(from, to, int(steps)) {
+		node.SetChild(strconv.Itoa(i+1), &color.Node{Color: &c})
+	}
+	return node, nil
+}
+
+// numberValue returns v as a float64 if it is a known, non-null number that
+// a float64 can hold.
+func numberValue(v cty.Value) (float64, bool) {
+	if v.IsNull() || !v.IsKnown() || v.Type() != cty.Number {
+		return 0, false
+	}
+	f, _ := v.AsBigFloat().Float64()
+	return f, !math.IsInf(f, 0)
+}
+
 // Loader handles two-pass HCL decoding with palette resolution.
 type Loader struct {
-	body    hcl.Body
-	ctx     *hcl.EvalContext
-	palette *color.Node
+	body        hcl.Body
+	ctx         *hcl.EvalContext
+	palette     *color.Node
+	specVersion int
+	hints       []string
+	appearance  string // appearance the when attributes were applied for
 }
 
-// NewLoader parses an HCL file and builds the evaluation context from palette.
+// NewLoader parses an HCL file and builds the evaluation context from palette,
+// using theme.DefaultLimits.
 func NewLoader(path string) (*Loader, error) {
-	src, err := os.ReadFile(path)
+	return NewLoaderWithLimits(path, theme.DefaultLimits)
+}
+
+// NewLoaderWithLimits is like NewLoader but enforces the given limits.
+// Errors carry a theme.Kind; those without a more specific one are KindConfig.
+func NewLoaderWithLimits(path string, limits theme.Limits) (*Loader, error) {
+	return NewLoaderContext(context.Background(), path, limits)
+}
+
+// NewLoaderContext is like NewLoaderWithLimits but stops once ctx is done,
+// returning an error that wraps the context's error and carries no Kind.
+func NewLoaderContext(ctx context.Context, path string, limits theme.Limits) (*Loader, error) {
+	loader, err := newLoader(ctx, path, limits, "")
+	return loader, withConfigKind(err)
+}
+
+// withConfigKind marks err as KindConfig unless it already has a Kind or is
+// a cancellation, which is not a fault of the theme.
+func withConfigKind(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return theme.WithKind(err, theme.KindConfig)
+}
+
+func newLoader(ctx context.Context, path string, limits theme.Limits, appearance string) (*Loader, error) {
+	info, err := os.Stat(path)
 	if err != nil {
+		return nil, theme.Errorf(theme.KindIO, "reading theme file: %w", err)
+	}
+	if err := limits.CheckFileSize(info.Size()); err != nil {
 		return nil, fmt.Errorf("reading theme file: %w", err)
 	}
 
+	start := time.Now()
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, theme.Errorf(theme.KindIO, "reading theme file: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	file, diags := hclsyntax.ParseConfig(src, path, hcl.Pos{Line: 1, Column: 1})
 	if diags.HasErrors() {
 		return nil, fmt.Errorf("parsing HCL: %s", diags.Error())
 	}
 
+	specVersion := 1
+	var hints []string
+	if body, ok := file.Body.(*hclsyntax.Body); ok {
+		if appearance == "" {
+			appearance = theme.MetaAppearance(body)
+		}
+		body, diags := theme.ApplyWhen(body, appearance)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("applying %s: %s", theme.WhenAttr, diags.Error())
+		}
+		file.Body = body
+
+		if err := checkDuplicateBlocks(body); err != nil {
+			return nil, err
+		}
+		if err := checkReferenceOrder(body); err != nil {
+			return nil, err
+		}
+		specVersion, hints, err = checkSpec(body, filepath.Base(path))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var raw RawConfig
 	if diags := gohcl.DecodeBody(file.Body, nil, &raw); diags.HasErrors() {
 		return nil, fmt.Errorf("decoding palette: %s", diags.Error())
 	}
+	metrics.Since(ctx, metrics.PhaseParse, path, start)
 
 	if raw.Palette == nil {
-		return nil, fmt.Errorf("no palette block found")
+		return nil, theme.Errorf(theme.KindValidation, "no palette block found")
 	}
 
 	paletteBody, ok := raw.Palette.Entries.(*hclsyntax.Body)
@@ -158,8 +461,14 @@ func NewLoader(path string) (*Loader, error) {
 		return nil, fmt.Errorf("palette block is not an hclsyntax.Body")
 	}
 
+	if err := limits.CheckPaletteEntries(paletteBody); err != nil {
+		return nil, fmt.Errorf("parsing palette: %w", err)
+	}
+
+	start = time.Now()
+
 	palette := &color.Node{}
-	if err := parsePaletteBody(paletteBody, palette, palette); err != nil {
+	if err := parsePaletteBody(ctx, paletteBody, palette, palette, limits); err != nil {
 		return nil, fmt.Errorf("parsing palette: %w", err)
 	}
 
@@ -168,16 +477,125 @@ func NewLoader(path string) (*Loader, error) {
 		return nil, fmt.Errorf("parsing transform: %w", err)
 	}
 	if transform != nil {
+		if err := limits.CheckTransformSteps(transform.Steps); err != nil {
+			return nil, fmt.Errorf("parsing transform: %w", err)
+		}
 		color.ApplyLightnessSteps(palette, transform.Low, transform.High, transform.Steps)
 	}
+	metrics.Since(ctx, metrics.PhaseEval, "palette", start)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	evalCtx := theme.BuildEvalContext(palette)
+	locals := make(map[string]cty.Value)
+	if raw.Locals != nil {
+		localsBody, ok := raw.Locals.Entries.(*hclsyntax.Body)
+		if !ok {
+			return nil, fmt.Errorf("locals block is not an hclsyntax.Body")
+		}
+		start = time.Now()
+		locals, err = parseLocals(localsBody, evalCtx)
+		if err != nil {
+			return nil, fmt.Errorf("parsing locals: %w", err)
+		}
+		metrics.Since(ctx, metrics.PhaseEval, "locals", start)
+	}
+	evalCtx.Variables["local"] = cty.ObjectVal(locals)
 
 	return &Loader{
-		body:    file.Body,
-		ctx:     theme.BuildEvalContext(palette),
-		palette: palette,
+		body:        file.Body,
+		ctx:         evalCtx,
+		palette:     palette,
+		specVersion: specVersion,
+		hints:       hints,
+		appearance:  appearance,
 	}, nil
 }
 
+// checkSpec reads the spec_version of body and checks the file against it.
+// A construct the declared version does not accept is a KindValidation
+// error; one only a later version rejects becomes a migration hint of the
+// form "file:line: message; hint".
+func checkSpec(body *hclsyntax.Body, file string) (int, []string, error) {
+	version, _, err := theme.SpecVersion(body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("parsing meta: %w", err)
+	}
+	var hints []string
+	for _, issue := range theme.CheckSpec(body) {
+		if version >= issue.Removed {
+			return 0, nil, theme.Errorf(theme.KindValidation, "line %d: %s", issue.Range.Start.Line, issue)
+		}
+		loc := Location{File: file, Line: issue.Range.Start.Line}
+		hints = append(hints, loc.String()+": "+issue.String())
+	}
+	return version, hints, nil
+}
+
+// parseLocals evaluates the locals block in source order, so each local may
+// reference the palette and earlier locals. Values are not restricted to
+// colors; a local may also hold e.g. a number used as a brighten() amount.
+func parseLocals(body *hclsyntax.Body, ctx *hcl.EvalContext) (map[string]cty.Value, error) {
+	if len(body.Blocks) > 0 {
+		return nil, fmt.Errorf("locals block does not support nesting (line %d)", body.Blocks[0].DefRange().Start.Line)
+	}
+
+	locals := make(map[string]cty.Value, len(body.Attributes))
+	for _, attr := range sortedAttributes(body) {
+		localCtx := ctx.NewChild()
+		localCtx.Variables = map[string]cty.Value{"local": cty.ObjectVal(locals)}
+
+		val, diags := attr.Expr.Value(localCtx)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("evaluating local.%s: %s", attr.Name, diags.Error())
+		}
+		locals[attr.Name] = val
+	}
+	return locals, nil
+}
+
+// checkDuplicateBlocks returns an error if any block listed in theme.UniqueBlocks
+// is declared more than once at the top level.
+func checkDuplicateBlocks(body *hclsyntax.Body) error {
+	seen := make(map[string]hcl.Range)
+	for _, block := range body.Blocks {
+		if !slices.Contains(theme.UniqueBlocks, block.Type) {
+			continue
+		}
+		if first, ok := seen[block.Type]; ok {
+			return theme.Errorf(theme.KindValidation, "duplicate %s block at line %d (first defined at line %d); only one %s block is allowed",
+				block.Type, block.DefRange().Start.Line, first.Start.Line, block.Type)
+		}
+		seen[block.Type] = block.DefRange()
+	}
+	return nil
+}
+
+// checkReferenceOrder returns an error for the first reference, in source
+// order, that breaks theme.BlockOrder, e.g. an ansi color referencing syntax.
+func checkReferenceOrder(body *hclsyntax.Body) error {
+	var first hcl.Range
+	var firstErr error
+	for _, block := range body.Blocks {
+		_ = hclsyntax.VisitAll(block.Body, func(node hclsyntax.Node) hcl.Diagnostics {
+			expr, ok := node.(*hclsyntax.ScopeTraversalExpr)
+			if !ok {
+				return nil
+			}
+			rng, err := theme.CheckReferenceOrder(block.Type, expr)
+			if err != nil && (firstErr == nil || rng.Start.Byte < first.Start.Byte) {
+				first, firstErr = rng, err
+			}
+			return nil
+		})
+	}
+	if firstErr != nil {
+		return fmt.Errorf("%w (line %d)", firstErr, first.Start.Line)
+	}
+	return nil
+}
+
 // Decode decodes a value using the palette context.
 // Reusable for any blocks that reference palette values.
 func (l *Loader) Decode(target any) error {
@@ -227,7 +645,7 @@ func decodeBodyToMap(body hcl.Body, ctx *hcl.EvalContext) (map[string]string, er
 		if diags.HasErrors() {
 			return nil, fmt.Errorf("evaluating %s: %s", name, diags.Error())
 		}
-		hexStr, err := theme.ResolveColor(val)
+		hexStr, err := theme.ResolveColorExpr(attr.Expr, val)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", name, err)
 		}
@@ -236,31 +654,256 @@ func decodeBodyToMap(body hcl.Body, ctx *hcl.EvalContext) (map[string]string, er
 	return result, nil
 }
 
-// validateANSI checks that all 16 required ANSI colors are present.
-func validateANSI(ansi map[string]color.Color) error {
+// decodeThemeAttributes decodes the flat attributes of the theme block into a map.
+// The typed sub-blocks have already been consumed by gohcl, which hides them from
+// the remaining body but not from JustAttributes, so attributes are read directly.
+func decodeThemeAttributes(body hcl.Body, ctx *hcl.EvalContext) (map[string]string, error) {
+	syntaxBody, ok := body.(*hclsyntax.Body)
+	if !ok {
+		return decodeBodyToMap(body, ctx)
+	}
+
+	for _, block := range syntaxBody.Blocks {
+		if _, ok := theme.ThemeSubBlocks[block.Type]; !ok {
+			return nil, fmt.Errorf("unexpected %q block (valid: cursor, selection)", block.Type)
+		}
+	}
+
+	// Attributes are evaluated in source order so each may reference
+	// earlier theme attributes.
+	result := make(map[string]string, len(syntaxBody.Attributes))
+	resolved := make(map[string]cty.Value, len(syntaxBody.Attributes))
+	for _, attr := range sortedAttributes(syntaxBody) {
+		attrCtx := ctx.NewChild()
+		attrCtx.Variables = map[string]cty.Value{"theme": cty.ObjectVal(resolved)}
+
+		val, diags := attr.Expr.Value(attrCtx)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("evaluating %s: %s", attr.Name, diags.Error())
+		}
+		hexStr, err := theme.ResolveColorExpr(attr.Expr, val)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", attr.Name, err)
+		}
+		result[attr.Name] = hexStr
+		resolved[attr.Name] = cty.StringVal(hexStr)
+	}
+	return result, nil
+}
+
+// sortedAttributes returns the attributes of body in source order.
+func sortedAttributes(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte
+	})
+	return attrs
+}
+
+// colorsToCty converts a flat color map to the values of an evaluation
+// context object.
+func colorsToCty(colors map[string]color.Color) map[string]cty.Value {
+	vals := make(map[string]cty.Value, len(colors))
+	for name, c := range colors {
+		vals[name] = cty.StringVal(c.Hex())
+	}
+	return vals
+}
+
+// themeToCty builds the theme evaluation context variable. The cursor and
+// selection sub-blocks become objects, as they are in the analyzer.
+func themeToCty(colors map[string]color.Color, cursor *Cursor, selection *Selection) cty.Value {
+	vals := colorsToCty(colors)
+	if cursor != nil {
+		vals["cursor"] = cty.ObjectVal(map[string]cty.Value{
+			"cursor": cty.StringVal(cursor.Color.Hex()),
+			"text":   cty.StringVal(cursor.Text.Hex()),
+		})
+	}
+	if selection != nil {
+		vals["selection"] = cty.ObjectVal(map[string]cty.Value{
+			"background": cty.StringVal(selection.Background.Hex()),
+			"foreground": cty.StringVal(selection.Foreground.Hex()),
+		})
+	}
+	return cty.ObjectVal(vals)
+}
+
+// treeToCty converts a syntax tree to an evaluation context object. A style
+// becomes its color's hex string.
+func treeToCty(tree color.Tree) cty.Value {
+	vals := make(map[string]cty.Value, len(tree))
+	for name, v := range tree {
+		switch v := v.(type) {
+		case color.Style:
+			vals[name] = cty.StringVal(v.Color.Hex())
+		case color.Tree:
+			vals[name] = treeToCty(v)
+		}
+	}
+	return cty.ObjectVal(vals)
+}
+
+// parseMeta converts a decoded meta block into Meta. Extra attributes must
+// be literal strings, numbers or booleans and are stored as strings.
+func parseMeta(block *MetaBlock) (Meta, error) {
+	meta := Meta{
+		Name:       block.Name,
+		Author:     block.Author,
+		Appearance: block.Appearance,
+		URL:        block.URL,
+		Extra:      make(map[string]string),
+	}
+	if block.Remain == nil {
+		return meta, nil
+	}
+
+	attrs, diags := block.Remain.JustAttributes()
+	if diags.HasErrors() {
+		return Meta{}, fmt.Errorf("getting attributes: %s", diags.Error())
+	}
+	for name, attr := range attrs {
+		if name == theme.SpecVersionAttr {
+			continue // validated by checkSpec
+		}
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return Meta{}, fmt.Errorf("evaluating %s: %s", name, diags.Error())
+		}
+		str, err := convert.Convert(val, cty.String)
+		if err != nil || str.IsNull() || !str.IsKnown() {
+			return Meta{}, fmt.Errorf("%s: expected a string, number or bool, got %s", name, val.Type().FriendlyName())
+		}
+		meta.Extra[name] = str.AsString()
+	}
+	return meta, nil
+}
+
+// evalColor evaluates an expression and parses the result as a color.
+func evalColor(expr hcl.Expression, ctx *hcl.EvalContext) (color.Color, error) {
+	val, diags := expr.Value(ctx)
+	if diags.HasErrors() {
+		return color.Color{}, fmt.Errorf("evaluating: %s", diags.Error())
+	}
+	hexStr, err := theme.ResolveColorExpr(expr, val)
+	if err != nil {
+		return color.Color{}, err
+	}
+	return color.ParseHex(hexStr)
+}
+
+// evalBool evaluates an expression that must produce a boolean.
+func evalBool(expr hcl.Expression, ctx *hcl.EvalContext) (bool, error) {
+	val, diags := expr.Value(ctx)
+	if diags.HasErrors() {
+		return false, fmt.Errorf("evaluating: %s", diags.Error())
+	}
+	if val.IsNull() || !val.IsKnown() || val.Type() != cty.Bool {
+		return false, fmt.Errorf("expected true or false, got %s", val.Type().FriendlyName())
+	}
+	return val.True(), nil
+}
+
+// parseThemeSubBlocks resolves the typed cursor and selection sub-blocks.
+// For compatibility with flat themes, the primary color of each sub-block is
+// also stored in flat under its block name ("cursor" and "selection").
+func parseThemeSubBlocks(block *ThemeBlock, ctx *hcl.EvalContext, flat map[string]color.Color) (*Cursor, *Selection, error) {
+	var cursor *Cursor
+	if block.Cursor != nil {
+		if _, ok := flat["cursor"]; ok {
+			return nil, nil, fmt.Errorf("cursor is defined both as an attribute and as a block")
+		}
+		c, err := evalColor(block.Cursor.Cursor, ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cursor.cursor: %w", err)
+		}
+		text, err := evalColor(block.Cursor.Text, ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cursor.text: %w", err)
+		}
+		cursor = &Cursor{Color: c, Text: text}
+		flat["cursor"] = c
+	}
+
+	var selection *Selection
+	if block.Selection != nil {
+		if _, ok := flat["selection"]; ok {
+			return nil, nil, fmt.Errorf("selection is defined both as an attribute and as a block")
+		}
+		bg, err := evalColor(block.Selection.Background, ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("selection.background: %w", err)
+		}
+		fg, err := evalColor(block.Selection.Foreground, ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("selection.foreground: %w", err)
+		}
+		selection = &Selection{Background: bg, Foreground: fg}
+		flat["selection"] = bg
+	}
+
+	return cursor, selection, nil
+}
+
+// validateANSI checks that the ANSI colors profile requires are present, as
+// the missing-ansi lint rule does.
+func validateANSI(ansi map[string]color.Color, profile theme.ANSIProfile) error {
 	if len(ansi) == 0 {
-		return fmt.Errorf("ansi block incomplete: no colors defined")
+		return theme.Errorf(theme.KindValidation, "ansi block incomplete: no colors defined")
 	}
 
 	var missing []string
-	for _, colorName := range theme.RequiredANSIColors {
+	for _, colorName := range profile.Required() {
 		if _, ok := ansi[colorName]; !ok {
 			missing = append(missing, colorName)
 		}
 	}
 
 	if len(missing) > 0 {
-		return fmt.Errorf("ansi block incomplete\nMissing colors: %s\nRequired colors: %s",
-			strings.Join(missing, ", "),
-			strings.Join(theme.RequiredANSIColors, ", "))
+		return theme.Errorf(theme.KindValidation, "ansi block incomplete\nMissing colors: %s\nRequired colors (ansi_profile %s): %s",
+			strings.Join(missing, ", "), profile,
+			strings.Join(profile.Required(), ", "))
 	}
 
 	return nil
 }
 
-// Parse parses an HCL theme file and returns a fully-resolved ParseResult.
+// Parse parses an HCL theme file and returns a fully-resolved ParseResult,
+// using theme.DefaultLimits.
 func Parse(path string) (*ParseResult, error) {
-	loader, err := NewLoader(path)
+	return ParseWithLimits(path, theme.DefaultLimits)
+}
+
+// ParseWithLimits is like Parse but enforces the given limits. Errors carry
+// a theme.Kind; those without a more specific one are KindConfig.
+func ParseWithLimits(path string, limits theme.Limits) (*ParseResult, error) {
+	return ParseContext(context.Background(), path, limits)
+}
+
+// ParseContext is like ParseWithLimits but stops once ctx is done, returning
+// an error that wraps the context's error and carries no Kind.
+func ParseContext(ctx context.Context, path string, limits theme.Limits) (*ParseResult, error) {
+	return ParseForAppearance(ctx, path, limits, "")
+}
+
+// ParseForAppearance is like ParseContext but resolves the theme for
+// appearance, "dark" or "light", instead of its meta.appearance: blocks
+// whose when attribute names the other appearance are left out, and
+// Meta.Appearance is set to appearance. An empty appearance is that of the
+// meta block.
+func ParseForAppearance(ctx context.Context, path string, limits theme.Limits, appearance string) (*ParseResult, error) {
+	if appearance != "" && !slices.Contains(theme.Appearances, appearance) {
+		return nil, theme.Errorf(theme.KindConfig, "appearance must be one of %s, got %q", strings.Join(theme.Appearances, ", "), appearance)
+	}
+	result, err := parse(ctx, path, limits, appearance)
+	return result, withConfigKind(err)
+}
+
+func parse(ctx context.Context, path string, limits theme.Limits, appearance string) (*ParseResult, error) {
+	loader, err := newLoader(ctx, path, limits, appearance)
 	if err != nil {
 		return nil, err
 	}
@@ -271,10 +914,16 @@ func Parse(path string) (*ParseResult, error) {
 		return nil, err
 	}
 
+	// Blocks are evaluated in theme.BlockOrder, each exposed to the blocks
+	// after it through evalCtx.
+	evalCtx := loader.Context().NewChild()
+	evalCtx.Variables = make(map[string]cty.Value)
+
 	// Convert ColorBlock entries to color maps
+	start := time.Now()
 	var themeStrings map[string]string
 	if resolved.Theme != nil {
-		themeStrings, err = decodeBodyToMap(resolved.Theme.Entries, loader.Context())
+		themeStrings, err = decodeThemeAttributes(resolved.Theme.Entries, evalCtx)
 		if err != nil {
 			return nil, fmt.Errorf("parsing theme: %w", err)
 		}
@@ -284,9 +933,26 @@ func Parse(path string) (*ParseResult, error) {
 		return nil, fmt.Errorf("parsing theme: %w", err)
 	}
 
+	var cursor *Cursor
+	var selection *Selection
+	if resolved.Theme != nil {
+		themeCtx := evalCtx.NewChild()
+		themeCtx.Variables = map[string]cty.Value{"theme": themeToCty(themeColors, nil, nil)}
+		cursor, selection, err = parseThemeSubBlocks(resolved.Theme, themeCtx, themeColors)
+		if err != nil {
+			return nil, fmt.Errorf("parsing theme: %w", err)
+		}
+	}
+	evalCtx.Variables["theme"] = themeToCty(themeColors, cursor, selection)
+	metrics.Since(ctx, metrics.PhaseEval, "theme", start)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	start = time.Now()
 	var ansiStrings map[string]string
 	if resolved.ANSI != nil {
-		ansiStrings, err = decodeBodyToMap(resolved.ANSI.Entries, loader.Context())
+		ansiStrings, err = decodeBodyToMap(resolved.ANSI.Entries, evalCtx)
 		if err != nil {
 			return nil, fmt.Errorf("parsing ansi: %w", err)
 		}
@@ -296,27 +962,86 @@ func Parse(path string) (*ParseResult, error) {
 		return nil, fmt.Errorf("parsing ansi: %w", err)
 	}
 
-	if err := validateANSI(ansiColors); err != nil {
-		return nil, err
+	if resolved.ANSI == nil {
+		return nil, theme.Errorf(theme.KindValidation, "missing required ansi block")
+	}
+	profile, checkANSI, schemaVersion := theme.ANSIStandard16, true, 0
+	if body, ok := loader.body.(*hclsyntax.Body); ok {
+		if profile, _, err = theme.ANSIProfileSetting(body); err != nil {
+			return nil, fmt.Errorf("parsing settings: %w", err)
+		}
+		if schemaVersion, _, err = theme.SchemaVersionSetting(body); err != nil {
+			return nil, fmt.Errorf("parsing settings: %w", err)
+		}
+		// Invalid lint settings are reported by check and the language
+		// server; the severity of missing-ansi decides here as there.
+		cfg, _ := lint.ParseConfig(body)
+		checkANSI = cfg.Severity(lint.MissingANSIRule) == lint.SeverityError
 	}
+	if checkANSI {
+		if err := validateANSI(ansiColors, profile); err != nil {
+			return nil, err
+		}
+	}
+	evalCtx.Variables["ansi"] = cty.ObjectVal(colorsToCty(ansiColors))
+	metrics.Since(ctx, metrics.PhaseEval, "ansi", start)
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	// Parse syntax manually (nested blocks with style properties)
-	syntax, err := parseSyntax(resolved.Remain, loader.Context())
+	start = time.Now()
+	syntax, languageSyntax, err := parseSyntax(resolved.Remain, evalCtx)
 	if err != nil {
 		return nil, fmt.Errorf("parsing syntax: %w", err)
 	}
+	evalCtx.Variables["syntax"] = treeToCty(syntax)
+	metrics.Since(ctx, metrics.PhaseEval, "syntax", start)
 
-	meta := Meta{}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	semantic := make(map[string]color.Style)
+	if resolved.Semantic != nil {
+		start = time.Now()
+		semantic, err = parseSemantic(resolved.Semantic.Entries, evalCtx)
+		if err != nil {
+			return nil, fmt.Errorf("parsing semantic: %w", err)
+		}
+		metrics.Since(ctx, metrics.PhaseEval, "semantic", start)
+	}
+
+	meta := Meta{Extra: make(map[string]string)}
 	if resolved.Meta != nil {
-		meta = *resolved.Meta
+		meta, err = parseMeta(resolved.Meta)
+		if err != nil {
+			return nil, fmt.Errorf("parsing meta: %w", err)
+		}
+	}
+
+	meta.SpecVersion = loader.specVersion
+	if loader.appearance != "" {
+		meta.Appearance = loader.appearance
+	}
+
+	var sources map[string]Location
+	if body, ok := loader.body.(*hclsyntax.Body); ok {
+		sources = collectSources(body, filepath.Base(path), loader.Palette())
 	}
 
 	return &ParseResult{
-		Meta:    meta,
-		Palette: loader.Palette(),
-		Theme:   themeColors,
-		Syntax:  syntax,
-		ANSI:    ansiColors,
+		Meta:           meta,
+		Palette:        loader.Palette(),
+		Theme:          themeColors,
+		Cursor:         cursor,
+		Selection:      selection,
+		Syntax:         syntax,
+		LanguageSyntax: languageSyntax,
+		Semantic:       semantic,
+		ANSI:           ansiColors,
+		Sources:        sources,
+		MigrationHints: loader.hints,
+		SchemaVersion:  schemaVersion,
 	}, nil
 }
 
@@ -327,18 +1052,13 @@ type paletteItem struct {
 	block *hclsyntax.Block     // non-nil for blocks
 }
 
-// parsePaletteBody parses a palette block body into a *color.Node.
-// Items are processed in source order so later entries can reference earlier ones.
-func parsePaletteBody(body *hclsyntax.Body, paletteRoot *color.Node, node *color.Node) error {
-	// Collect all items and sort by source position
+// sortedItems returns the attributes and blocks of body in source order.
+func sortedItems(body *hclsyntax.Body) []paletteItem {
 	var items []paletteItem
 	for _, attr := range body.Attributes {
 		items = append(items, paletteItem{pos: attr.SrcRange.Start, attr: attr})
 	}
 	for _, block := range body.Blocks {
-		if block.Type == "transform" {
-			continue
-		}
 		items = append(items, paletteItem{pos: block.DefRange().Start, block: block})
 	}
 	sort.Slice(items, func(i, j int) bool {
@@ -347,18 +1067,30 @@ func parsePaletteBody(body *hclsyntax.Body, paletteRoot *color.Node, node *color
 		}
 		return items[i].pos.Column < items[j].pos.Column
 	})
+	return items
+}
+
+// parsePaletteBody parses a palette block body into a *color.Node.
+// Items are processed in source order so later entries can reference earlier ones.
+func parsePaletteBody(ctx context.Context, body *hclsyntax.Body, paletteRoot *color.Node, node *color.Node, limits theme.Limits) error {
+	for _, item := range sortedItems(body) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if item.block != nil && item.block.Type == "transform" {
+			continue
+		}
 
-	for _, item := range items {
 		// Rebuild eval context with current state of palette root
-		ctx := theme.BuildEvalContext(paletteRoot)
+		evalCtx := theme.BuildEvalContext(paletteRoot)
 
 		if item.attr != nil {
-			val, diags := item.attr.Expr.Value(ctx)
+			val, diags := item.attr.Expr.Value(evalCtx)
 			if diags.HasErrors() {
 				return fmt.Errorf("evaluating palette.%s: %s", item.attr.Name, diags.Error())
 			}
 
-			hexStr, err := theme.ResolveColor(val)
+			hexStr, err := theme.ResolveColorExpr(item.attr.Expr, val)
 			if err != nil {
 				return fmt.Errorf("palette.%s: %w", item.attr.Name, err)
 			}
@@ -368,24 +1100,30 @@ func parsePaletteBody(body *hclsyntax.Body, paletteRoot *color.Node, node *color
 				return fmt.Errorf("palette.%s: %w", item.attr.Name, err)
 			}
 
-			if item.attr.Name == "color" {
+			if item.attr.Name == color.ColorKey {
 				// Reserved keyword: set this node's own color
 				node.Color = &c
 			} else {
 				// Child leaf node
-				if node.Children == nil {
-					node.Children = make(map[string]*color.Node)
-				}
-				node.Children[item.attr.Name] = &color.Node{Color: &c}
+				node.SetChild(item.attr.Name, &color.Node{Color: &c})
+			}
+		} else if IsScaleBlock(item.block) {
+			name := item.block.Labels[0]
+			scale, err := ParseScaleBlock(item.block, evalCtx, limits)
+			if err != nil {
+				return err
 			}
+			node.SetChild(name, scale)
 		} else {
-			// Block: recurse
-			if node.Children == nil {
-				node.Children = make(map[string]*color.Node)
+			// Block: recurse. A group declared again is merged into the
+			// first, as the language server does, with later entries
+			// replacing earlier ones at the same path.
+			child, ok := node.Children[item.block.Type]
+			if !ok || child.Children == nil {
+				child = &color.Node{}
+				node.SetChild(item.block.Type, child)
 			}
-			child := &color.Node{}
-			node.Children[item.block.Type] = child
-			if err := parsePaletteBody(item.block.Body, paletteRoot, child); err != nil {
+			if err := parsePaletteBody(ctx, item.block.Body, paletteRoot, child, limits); err != nil {
 				return fmt.Errorf("palette.%s: %w", item.block.Type, err)
 			}
 		}
@@ -394,89 +1132,147 @@ func parsePaletteBody(body *hclsyntax.Body, paletteRoot *color.Node, node *color
 	return nil
 }
 
-// parseSyntax extracts and parses the syntax block from an hcl.Body.
+// parseSyntax extracts and parses the syntax blocks from an hcl.Body.
 // It handles the mixed structure (flat attributes + nested style blocks).
-func parseSyntax(body hcl.Body, ctx *hcl.EvalContext) (color.Tree, error) {
+// Multiple syntax blocks are deep-merged in source order; later entries
+// override earlier ones at the same path. Labeled blocks (syntax "go" { ... })
+// are collected separately as per-language overrides.
+func parseSyntax(body hcl.Body, ctx *hcl.EvalContext) (color.Tree, map[string]color.Tree, error) {
+	languages := make(map[string]color.Tree)
 	if body == nil {
-		return make(color.Tree), nil
+		return make(color.Tree), languages, nil
 	}
 
 	// The remain body contains unparsed blocks including syntax.
-	// We need to find the syntax block within it.
+	// We need to find the syntax blocks within it.
 	syntaxBody, ok := body.(*hclsyntax.Body)
 	if !ok {
 		// If not hclsyntax.Body, return empty tree (no syntax block)
-		return make(color.Tree), nil
+		return make(color.Tree), languages, nil
 	}
 
-	// Find the syntax block
+	// Merge all unlabeled syntax blocks into a single tree. Entries are
+	// evaluated in source order and may reference earlier syntax entries.
+	syntaxCtx := ctx.NewChild()
+	syntaxCtx.Variables = map[string]cty.Value{"syntax": cty.EmptyObjectVal}
+	dest := make(color.Tree)
+	var labeled []*hclsyntax.Block
 	for _, block := range syntaxBody.Blocks {
-		if block.Type == "syntax" {
-			dest := make(color.Tree)
-			if err := parseSyntaxBody(block.Body, ctx, dest); err != nil {
-				return nil, err
+		if block.Type != "syntax" {
+			continue
+		}
+		switch len(block.Labels) {
+		case 0:
+			if err := parseSyntaxBody(block.Body, syntaxCtx, dest, dest); err != nil {
+				return nil, nil, err
 			}
-			return dest, nil
+		case 1:
+			labeled = append(labeled, block)
+		default:
+			return nil, nil, fmt.Errorf("syntax block at line %d has %d labels; expected at most one language label",
+				block.DefRange().Start.Line, len(block.Labels))
 		}
 	}
 
-	return make(color.Tree), nil
-}
-
-func parseSyntaxBody(body *hclsyntax.Body, ctx *hcl.EvalContext, dest color.Tree) error {
-	// Parse attributes at this level
-	attrs, diags := body.JustAttributes()
-	if diags.HasErrors() {
-		// JustAttributes fails if there are blocks; use manual iteration instead
-		for _, attr := range body.Attributes {
-			val, diags := attr.Expr.Value(ctx)
-			if diags.HasErrors() {
-				return fmt.Errorf("evaluating syntax attribute %s: %s", attr.Name, diags.Error())
-			}
-			c, err := color.ParseHex(val.AsString())
-			if err != nil {
-				return fmt.Errorf("syntax.%s: %w", attr.Name, err)
-			}
-			dest[attr.Name] = color.Style{Color: c}
+	// Language blocks may reference the merged base syntax, but not each other.
+	for _, block := range labeled {
+		lang := block.Labels[0]
+		tree, ok := languages[lang]
+		if !ok {
+			tree = make(color.Tree)
+			languages[lang] = tree
 		}
-	} else {
-		for name, attr := range attrs {
-			val, diags := attr.Expr.Value(ctx)
-			if diags.HasErrors() {
-				return fmt.Errorf("evaluating syntax.%s: %s", name, diags.Error())
-			}
-			c, err := color.ParseHex(val.AsString())
-			if err != nil {
-				return fmt.Errorf("syntax.%s: %w", name, err)
-			}
-			dest[name] = color.Style{Color: c}
+		if err := parseSyntaxBody(block.Body, syntaxCtx, tree, nil); err != nil {
+			return nil, nil, fmt.Errorf("syntax %q: %w", lang, err)
 		}
 	}
 
-	// Recurse into nested blocks
-	for _, block := range body.Blocks {
-		if isStyleBlock(block.Body) {
-			style, err := parseStyleBlock(block.Body, ctx)
+	return dest, languages, nil
+}
+
+// parseSyntaxBody parses body into dest in source order. If root is non-nil,
+// the syntax variable in ctx is updated from root after each entry, so later
+// entries can reference earlier ones.
+func parseSyntaxBody(body *hclsyntax.Body, ctx *hcl.EvalContext, dest, root color.Tree) error {
+	for _, item := range sortedItems(body) {
+		if item.attr != nil {
+			c, err := evalColor(item.attr.Expr, ctx)
+			if err != nil {
+				return fmt.Errorf("syntax.%s: %w", item.attr.Name, err)
+			}
+			dest[item.attr.Name] = color.Style{Color: c}
+		} else if isStyleBlock(item.block.Body) {
+			style, err := parseStyleBlock(item.block.Body, ctx)
 			if err != nil {
-				return fmt.Errorf("syntax.%s: %w", block.Type, err)
+				return fmt.Errorf("syntax.%s: %w", item.block.Type, err)
 			}
-			dest[block.Type] = style
+			dest[item.block.Type] = style
 		} else {
-			subtree := make(color.Tree)
-			dest[block.Type] = subtree
-			if err := parseSyntaxBody(block.Body, ctx, subtree); err != nil {
+			// Reuse an existing scope so repeated blocks merge
+			subtree, ok := dest[item.block.Type].(color.Tree)
+			if !ok {
+				subtree = make(color.Tree)
+				dest[item.block.Type] = subtree
+			}
+			if err := parseSyntaxBody(item.block.Body, ctx, subtree, root); err != nil {
 				return err
 			}
 		}
+
+		if root != nil {
+			ctx.Variables["syntax"] = treeToCty(root)
+		}
 	}
 
 	return nil
 }
 
+// parseSemantic parses the semantic block. Each entry must be a standard LSP
+// semantic token type and is either a plain color or a style block.
+func parseSemantic(body hcl.Body, ctx *hcl.EvalContext) (map[string]color.Style, error) {
+	semanticBody, ok := body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("semantic block is not an hclsyntax.Body")
+	}
+
+	result := make(map[string]color.Style)
+	for name, attr := range semanticBody.Attributes {
+		if !slices.Contains(theme.SemanticTokenTypes, name) {
+			return nil, fmt.Errorf("unknown semantic token type %q", name)
+		}
+		val, diags := attr.Expr.Value(ctx)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("evaluating semantic.%s: %s", name, diags.Error())
+		}
+		hexStr, err := theme.ResolveColorExpr(attr.Expr, val)
+		if err != nil {
+			return nil, fmt.Errorf("semantic.%s: %w", name, err)
+		}
+		c, err := color.ParseHex(hexStr)
+		if err != nil {
+			return nil, fmt.Errorf("semantic.%s: %w", name, err)
+		}
+		result[name] = color.Style{Color: c}
+	}
+
+	for _, block := range semanticBody.Blocks {
+		if !slices.Contains(theme.SemanticTokenTypes, block.Type) {
+			return nil, fmt.Errorf("unknown semantic token type %q", block.Type)
+		}
+		style, err := parseStyleBlock(block.Body, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("semantic.%s: %w", block.Type, err)
+		}
+		result[block.Type] = style
+	}
+
+	return result, nil
+}
+
 // isStyleBlock returns true if the body contains a "color" attribute,
 // indicating it is a style block rather than a nested scope.
 func isStyleBlock(body *hclsyntax.Body) bool {
-	_, hasColor := body.Attributes["color"]
+	_, hasColor := body.Attributes[color.ColorKey]
 	return hasColor
 }
 
@@ -491,17 +1287,12 @@ func parseStyleBlock(body *hclsyntax.Body, ctx *hcl.EvalContext) (color.Style, e
 		}
 	}
 
-	colorAttr, ok := body.Attributes["color"]
+	colorAttr, ok := body.Attributes[color.ColorKey]
 	if !ok {
 		return color.Style{}, fmt.Errorf("missing required 'color' attribute")
 	}
 
-	val, diags := colorAttr.Expr.Value(ctx)
-	if diags.HasErrors() {
-		return color.Style{}, fmt.Errorf("evaluating color: %s", diags.Error())
-	}
-
-	c, err := color.ParseHex(val.AsString())
+	c, err := evalColor(colorAttr.Expr, ctx)
 	if err != nil {
 		return color.Style{}, fmt.Errorf("color: %w", err)
 	}
@@ -509,27 +1300,21 @@ func parseStyleBlock(body *hclsyntax.Body, ctx *hcl.EvalContext) (color.Style, e
 	style := color.Style{Color: c}
 
 	if attr, ok := body.Attributes["bold"]; ok {
-		val, diags := attr.Expr.Value(ctx)
-		if diags.HasErrors() {
-			return color.Style{}, fmt.Errorf("evaluating bold: %s", diags.Error())
+		if style.Bold, err = evalBool(attr.Expr, ctx); err != nil {
+			return color.Style{}, fmt.Errorf("bold: %w", err)
 		}
-		style.Bold = val.True()
 	}
 
 	if attr, ok := body.Attributes["italic"]; ok {
-		val, diags := attr.Expr.Value(ctx)
-		if diags.HasErrors() {
-			return color.Style{}, fmt.Errorf("evaluating italic: %s", diags.Error())
+		if style.Italic, err = evalBool(attr.Expr, ctx); err != nil {
+			return color.Style{}, fmt.Errorf("italic: %w", err)
 		}
-		style.Italic = val.True()
 	}
 
 	if attr, ok := body.Attributes["underline"]; ok {
-		val, diags := attr.Expr.Value(ctx)
-		if diags.HasErrors() {
-			return color.Style{}, fmt.Errorf("evaluating underline: %s", diags.Error())
+		if style.Underline, err = evalBool(attr.Expr, ctx); err != nil {
+			return color.Style{}, fmt.Errorf("underline: %w", err)
 		}
-		style.Underline = val.True()
 	}
 
 	return style, nil
@@ -1,12 +1,19 @@
 package parser
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/metrics"
+	"github.com/jsvensson/paletteswap/internal/theme"
 )
 
 const completeANSI = `
@@ -116,6 +123,37 @@ func TestLoadMeta(t *testing.T) {
 	}
 }
 
+func TestLoadMetaExtra(t *testing.T) {
+	hcl := `
+meta {
+  name    = "Rose Pine"
+  variant = "moon"
+  version = 2
+}
+
+palette {
+  base = "#191724"
+}
+` + completeANSI
+	path := writeTempHCL(t, hcl)
+	theme, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if theme.Meta.Name != "Rose Pine" {
+		t.Errorf("Meta.Name = %q, want %q", theme.Meta.Name, "Rose Pine")
+	}
+	if got := theme.Meta.Extra["variant"]; got != "moon" {
+		t.Errorf("Meta.Extra[variant] = %q, want %q", got, "moon")
+	}
+	if got := theme.Meta.Extra["version"]; got != "2" {
+		t.Errorf("Meta.Extra[version] = %q, want %q", got, "2")
+	}
+	if _, ok := theme.Meta.Extra["name"]; ok {
+		t.Error("well-known key name should not appear in Meta.Extra")
+	}
+}
+
 func TestLoadPalette(t *testing.T) {
 	path := writeTempHCL(t, sampleHCL)
 	theme, err := Parse(path)
@@ -150,6 +188,92 @@ func TestLoadTheme(t *testing.T) {
 	}
 }
 
+func TestLoadThemeSubBlocks(t *testing.T) {
+	hcl := `
+palette {
+  base = "#191724"
+  text = "#e0def4"
+  love = "#eb6f92"
+  mid  = "#403d52"
+}
+
+theme {
+  background = palette.base
+  cursor {
+    cursor = palette.love
+    text   = palette.base
+  }
+  selection {
+    background = palette.mid
+    foreground = palette.text
+  }
+}
+` + completeANSI
+	path := writeTempHCL(t, hcl)
+	theme, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	if theme.Cursor == nil {
+		t.Fatal("Cursor is nil")
+	}
+	if theme.Cursor.Color.Hex() != "#eb6f92" || theme.Cursor.Text.Hex() != "#191724" {
+		t.Errorf("Cursor = %+v, want cursor #eb6f92 and text #191724", *theme.Cursor)
+	}
+	if theme.Selection == nil {
+		t.Fatal("Selection is nil")
+	}
+	if theme.Selection.Background.Hex() != "#403d52" || theme.Selection.Foreground.Hex() != "#e0def4" {
+		t.Errorf("Selection = %+v, want background #403d52 and foreground #e0def4", *theme.Selection)
+	}
+
+	// Flat aliases keep existing templates working
+	if got := theme.Theme["cursor"].Hex(); got != "#eb6f92" {
+		t.Errorf("Theme[cursor] = %q, want %q", got, "#eb6f92")
+	}
+	if got := theme.Theme["selection"].Hex(); got != "#403d52" {
+		t.Errorf("Theme[selection] = %q, want %q", got, "#403d52")
+	}
+}
+
+func TestLoadThemeSubBlockErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		theme string
+	}{
+		{"missing attribute", `theme {
+  cursor {
+    cursor = palette.base
+  }
+}`},
+		{"unknown attribute", `theme {
+  selection {
+    background = palette.base
+    foreground = palette.base
+    border     = palette.base
+  }
+}`},
+		{"attribute and block", `theme {
+  cursor = palette.base
+  cursor {
+    cursor = palette.base
+    text   = palette.base
+  }
+}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hcl := "palette {\n  base = \"#191724\"\n}\n" + tt.theme + completeANSI
+			path := writeTempHCL(t, hcl)
+			if _, err := Parse(path); err == nil {
+				t.Fatal("expected error, got nil")
+			}
+		})
+	}
+}
+
 func TestLoadSyntax(t *testing.T) {
 	path := writeTempHCL(t, sampleHCL)
 	theme, err := Parse(path)
@@ -198,6 +322,345 @@ func TestLoadSyntax(t *testing.T) {
 	}
 }
 
+func TestLoadSyntaxMultipleBlocks(t *testing.T) {
+	hcl := `
+palette {
+  pine = "#31748f"
+  gold = "#f6c177"
+  love = "#eb6f92"
+}
+
+syntax {
+  keyword = palette.pine
+  string  = palette.pine
+  markup {
+    heading = palette.love
+  }
+}
+
+syntax {
+  string = palette.gold
+  markup {
+    bold = palette.gold
+  }
+}
+` + completeANSI
+	path := writeTempHCL(t, hcl)
+	theme, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	kw, ok := theme.Syntax["keyword"].(color.Style)
+	if !ok || kw.Color.Hex() != "#31748f" {
+		t.Errorf("Syntax[keyword] = %v, want color #31748f", theme.Syntax["keyword"])
+	}
+
+	// Later blocks override earlier entries
+	str, ok := theme.Syntax["string"].(color.Style)
+	if !ok || str.Color.Hex() != "#f6c177" {
+		t.Errorf("Syntax[string] = %v, want color #f6c177", theme.Syntax["string"])
+	}
+
+	// Nested scopes are deep-merged
+	markup, ok := theme.Syntax["markup"].(color.Tree)
+	if !ok {
+		t.Fatal("Syntax[markup] is not a Tree")
+	}
+	if _, ok := markup["heading"].(color.Style); !ok {
+		t.Error("Syntax[markup][heading] missing after merge")
+	}
+	if _, ok := markup["bold"].(color.Style); !ok {
+		t.Error("Syntax[markup][bold] missing after merge")
+	}
+}
+
+func TestLoadSyntaxLanguageBlocks(t *testing.T) {
+	hcl := `
+palette {
+  pine = "#31748f"
+  gold = "#f6c177"
+}
+
+syntax {
+  keyword = palette.pine
+}
+
+syntax "go" {
+  keyword = palette.gold
+}
+` + completeANSI
+	path := writeTempHCL(t, hcl)
+	theme, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	kw, ok := theme.Syntax["keyword"].(color.Style)
+	if !ok || kw.Color.Hex() != "#31748f" {
+		t.Errorf("Syntax[keyword] = %v, want base color #31748f", theme.Syntax["keyword"])
+	}
+
+	goSyntax, ok := theme.LanguageSyntax["go"]
+	if !ok {
+		t.Fatal("LanguageSyntax[go] missing")
+	}
+	goKw, ok := goSyntax["keyword"].(color.Style)
+	if !ok || goKw.Color.Hex() != "#f6c177" {
+		t.Errorf("LanguageSyntax[go][keyword] = %v, want color #f6c177", goSyntax["keyword"])
+	}
+}
+
+func TestLoadSemantic(t *testing.T) {
+	hcl := `
+palette {
+  pine = "#31748f"
+  gold = "#f6c177"
+}
+
+semantic {
+  parameter = palette.pine
+  decorator {
+    color  = palette.gold
+    italic = true
+  }
+}
+` + completeANSI
+	path := writeTempHCL(t, hcl)
+	theme, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	param, ok := theme.Semantic["parameter"]
+	if !ok || param.Color.Hex() != "#31748f" {
+		t.Errorf("Semantic[parameter] = %v, want color #31748f", param)
+	}
+	dec, ok := theme.Semantic["decorator"]
+	if !ok || dec.Color.Hex() != "#f6c177" || !dec.Italic {
+		t.Errorf("Semantic[decorator] = %v, want italic #f6c177", dec)
+	}
+}
+
+func TestLoadLocals(t *testing.T) {
+	hcl := `
+palette {
+  base = "#191724"
+  love = "#eb6f92"
+}
+
+locals {
+  step   = 0.5
+  accent = palette.love
+  dimmed = darken(local.accent, local.step)
+}
+
+theme {
+  background = local.dimmed
+  foreground = brighten(palette.base, local.step)
+}
+
+syntax {
+  keyword = local.accent
+}
+` + completeANSI
+	path := writeTempHCL(t, hcl)
+	theme, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	want := color.Darken(color.Color{R: 0xeb, G: 0x6f, B: 0x92}, 0.5)
+	if got := theme.Theme["background"]; got != want {
+		t.Errorf("Theme[background] = %v, want %v", got.Hex(), want.Hex())
+	}
+	if got := theme.Syntax["keyword"].(color.Style).Color.Hex(); got != "#eb6f92" {
+		t.Errorf("Syntax[keyword] = %q, want %q", got, "#eb6f92")
+	}
+}
+
+func TestLoadLocalsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		locals  string
+		wantErr string
+	}{
+		{
+			name:    "forward reference",
+			locals:  "locals {\n  a = local.b\n  b = \"#ffffff\"\n}\n",
+			wantErr: "local.a",
+		},
+		{
+			name:    "nested block",
+			locals:  "locals {\n  group {\n    a = \"#ffffff\"\n  }\n}\n",
+			wantErr: "does not support nesting",
+		},
+		{
+			name:    "duplicate block",
+			locals:  "locals {\n  a = 1\n}\nlocals {\n  b = 2\n}\n",
+			wantErr: "duplicate locals block",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hcl := "palette {\n  base = \"#191724\"\n}\n" + tt.locals + completeANSI
+			path := writeTempHCL(t, hcl)
+			_, err := Parse(path)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadScale(t *testing.T) {
+	hcl := `
+palette {
+  base    = "#000000"
+  overlay = "#ffffff"
+
+  scale "surface" {
+    from  = palette.base
+    to    = palette.overlay
+    steps = 5
+  }
+
+  raised = palette.surface.2
+
+  scale {
+    plain = "#123456"
+  }
+}
+
+theme {
+  background = palette.surface.4
+}
+` + completeANSI
+	path := writeTempHCL(t, hcl)
+	theme, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	wants := map[string]string{
+		"surface.1":   "#000000",
+		"surface.2":   "#404040",
+		"surface.3":   "#808080",
+		"surface.5":   "#ffffff",
+		"raised":      "#404040",
+		"scale.plain": "#123456",
+	}
+	for path, want := range wants {
+		c, err := theme.Palette.Lookup(strings.Split(path, "."))
+		if err != nil {
+			t.Errorf("Lookup(%s) error: %v", path, err)
+			continue
+		}
+		if got := c.Hex(); got != want {
+			t.Errorf("palette.%s = %s, want %s", path, got, want)
+		}
+	}
+	if got := theme.Theme["background"].Hex(); got != "#bfbfbf" {
+		t.Errorf("Theme[background] = %s, want #bfbfbf", got)
+	}
+}
+
+func TestLoadScaleErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		scale   string
+		wantErr string
+	}{
+		{"missing to", "scale \"s\" {\n  from = palette.base\n  steps = 3\n}", "missing 'to'"},
+		{"missing steps", "scale \"s\" {\n  from = palette.base\n  to = palette.base\n}", "missing 'steps'"},
+		{"one step", "scale \"s\" {\n  from = palette.base\n  to = palette.base\n  steps = 1\n}", "steps must be >= 2"},
+		{"fractional steps", "scale \"s\" {\n  from = palette.base\n  to = palette.base\n  steps = 2.5\n}", "whole number"},
+		{"unknown attribute", "scale \"s\" {\n  from = palette.base\n  to = palette.base\n  steps = 3\n  mode = 1\n}", "unexpected attribute"},
+		{"bad color", "scale \"s\" {\n  from = \"nope\"\n  to = palette.base\n  steps = 3\n}", `scale "s" from`},
+		{"too many steps", "scale \"s\" {\n  from = palette.base\n  to = palette.base\n  steps = 1000\n}", "scale steps is 1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hcl := "palette {\n  base = \"#191724\"\n" + tt.scale + "\n}\n" + completeANSI
+			path := writeTempHCL(t, hcl)
+			_, err := Parse(path)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseWithLimits(t *testing.T) {
+	hcl := `
+palette {
+  base = "#191724"
+  love = "#eb6f92"
+  transform {
+    lightness {
+      range = [0.1, 0.9]
+      steps = 10
+    }
+  }
+}
+` + completeANSI
+	path := writeTempHCL(t, hcl)
+
+	tests := []struct {
+		name    string
+		limits  theme.Limits
+		wantErr string
+	}{
+		{"defaults", theme.DefaultLimits, ""},
+		{"file size", theme.Limits{MaxFileSize: 64}, "exceeding the limit of 64 bytes"},
+		{"palette entries", theme.Limits{MaxPaletteEntries: 1}, "palette has more than 1 entries"},
+		{"transform steps", theme.Limits{MaxTransformSteps: 5}, "lightness steps is 10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWithLimits(path, tt.limits)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ParseWithLimits() error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadSemanticUnknownType(t *testing.T) {
+	hcl := `
+palette {
+  pine = "#31748f"
+}
+
+semantic {
+  keywrod = palette.pine
+}
+` + completeANSI
+	path := writeTempHCL(t, hcl)
+	_, err := Parse(path)
+	if err == nil {
+		t.Fatal("expected error for unknown semantic token type")
+	}
+	if !strings.Contains(err.Error(), "keywrod") {
+		t.Errorf("error = %q, want mention of keywrod", err.Error())
+	}
+}
+
 func TestLoadANSI(t *testing.T) {
 	path := writeTempHCL(t, sampleHCL)
 	theme, err := Parse(path)
@@ -227,6 +690,26 @@ meta {
 	}
 }
 
+func TestLoadDuplicateBlock(t *testing.T) {
+	hcl := `
+palette {
+  base = "#191724"
+}
+
+palette {
+  base = "#ffffff"
+}
+` + completeANSI
+	path := writeTempHCL(t, hcl)
+	_, err := Parse(path)
+	if err == nil {
+		t.Fatal("expected error for duplicate palette block")
+	}
+	if !strings.Contains(err.Error(), "duplicate palette block at line 6") {
+		t.Errorf("error = %q, want mention of duplicate palette block at line 6", err.Error())
+	}
+}
+
 func TestLoadInvalidHex(t *testing.T) {
 	hcl := `
 palette {
@@ -355,8 +838,7 @@ syntax {
 
 func TestLoadStyleMissingColor(t *testing.T) {
 	// A block without "color" is treated as a nested scope, not a style block.
-	// Parsing "bold = true" as a hex color string will panic on AsString().
-	// This verifies the block is not silently accepted.
+	// "bold = true" is then not a color, which must be reported as an error.
 	hcl := `
 palette {
   love = "#eb6f92"
@@ -368,12 +850,51 @@ syntax {
 }
 ` + completeANSI
 	path := writeTempHCL(t, hcl)
-	defer func() {
-		if r := recover(); r == nil {
-			t.Fatal("expected panic when style block is missing color attribute")
-		}
-	}()
-	_, _ = Parse(path)
+	_, err := Parse(path)
+	if err == nil {
+		t.Fatal("expected error when style block is missing color attribute")
+	}
+	if !strings.Contains(err.Error(), "bold") {
+		t.Errorf("error = %q, want mention of bold", err.Error())
+	}
+}
+
+func TestLoadInvalidValueTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "number as syntax color",
+			body:    "syntax {\n  keyword = 5\n}\n",
+			wantErr: "syntax.keyword",
+		},
+		{
+			name:    "non-bool style flag",
+			body:    "syntax {\n  comment {\n    color = palette.base\n    italic = \"yes\"\n  }\n}\n",
+			wantErr: "italic: expected true or false",
+		},
+		{
+			name:    "null theme color",
+			body:    "theme {\n  background = null\n}\n",
+			wantErr: "expected a color, got null",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hcl := "palette {\n  base = \"#191724\"\n}\n" + tt.body + completeANSI
+			path := writeTempHCL(t, hcl)
+			_, err := Parse(path)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
 }
 
 func TestLoadStyleUnknownAttribute(t *testing.T) {
@@ -454,14 +975,28 @@ theme {
 	}
 }
 
-func TestBrightenInTheme(t *testing.T) {
+func TestLoadPaletteOrder(t *testing.T) {
 	hcl := `
 palette {
-  base = "#000000"
+  text = "#e0def4"
+  base = "#191724"
+
+  highlight {
+    high = "#524f67"
+    low  = "#21202e"
+  }
+
+  scale "ramp" {
+    from  = palette.base
+    to    = palette.text
+    steps = 3
+  }
+
+  love = "#eb6f92"
 }
 
 theme {
-  background = brighten(palette.base, 0.5)
+  background = palette.base
 }
 ` + completeANSI
 	path := writeTempHCL(t, hcl)
@@ -469,20 +1004,26 @@ theme {
 	if err != nil {
 		t.Fatalf("Parse() error: %v", err)
 	}
-	bg := theme.Theme["background"]
-	if bg.Hex() != "#7f7f7f" {
-		t.Errorf("Theme[background].Hex() = %q, want %q", bg.Hex(), "#7f7f7f")
+
+	if got, want := theme.Palette.Names(), []string{"text", "base", "highlight", "ramp", "love"}; !slices.Equal(got, want) {
+		t.Errorf("palette Names() = %v, want %v", got, want)
+	}
+	if got, want := theme.Palette.Children["highlight"].Names(), []string{"high", "low"}; !slices.Equal(got, want) {
+		t.Errorf("highlight Names() = %v, want %v", got, want)
+	}
+	if got, want := theme.Palette.Children["ramp"].Names(), []string{"1", "2", "3"}; !slices.Equal(got, want) {
+		t.Errorf("ramp Names() = %v, want %v", got, want)
 	}
 }
 
-func TestBrightenWithLiteralHex(t *testing.T) {
+func TestBrightenInTheme(t *testing.T) {
 	hcl := `
 palette {
   base = "#000000"
 }
 
 theme {
-  background = brighten("#000000", 0.5)
+  background = brighten(palette.base, 0.5)
 }
 ` + completeANSI
 	path := writeTempHCL(t, hcl)
@@ -491,15 +1032,36 @@ theme {
 		t.Fatalf("Parse() error: %v", err)
 	}
 	bg := theme.Theme["background"]
-	if bg.Hex() != "#7f7f7f" {
-		t.Errorf("Theme[background].Hex() = %q, want %q", bg.Hex(), "#7f7f7f")
+	if bg.Hex() != "#808080" {
+		t.Errorf("Theme[background].Hex() = %q, want %q", bg.Hex(), "#808080")
 	}
 }
 
-func TestBrightenNegative(t *testing.T) {
+func TestBrightenWithLiteralHex(t *testing.T) {
 	hcl := `
 palette {
-  white = "#ffffff"
+  base = "#000000"
+}
+
+theme {
+  background = brighten("#000000", 0.5)
+}
+` + completeANSI
+	path := writeTempHCL(t, hcl)
+	theme, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	bg := theme.Theme["background"]
+	if bg.Hex() != "#808080" {
+		t.Errorf("Theme[background].Hex() = %q, want %q", bg.Hex(), "#808080")
+	}
+}
+
+func TestBrightenNegative(t *testing.T) {
+	hcl := `
+palette {
+  white = "#ffffff"
 }
 
 theme {
@@ -512,8 +1074,8 @@ theme {
 		t.Fatalf("Parse() error: %v", err)
 	}
 	bg := theme.Theme["background"]
-	if bg.Hex() != "#7f7f7f" {
-		t.Errorf("Theme[background].Hex() = %q, want %q", bg.Hex(), "#7f7f7f")
+	if bg.Hex() != "#808080" {
+		t.Errorf("Theme[background].Hex() = %q, want %q", bg.Hex(), "#808080")
 	}
 }
 
@@ -548,8 +1110,8 @@ ansi {
 		t.Fatalf("Parse() error: %v", err)
 	}
 	black := theme.ANSI["black"]
-	if black.Hex() != "#7f7f7f" {
-		t.Errorf("ANSI[black].Hex() = %q, want %q", black.Hex(), "#7f7f7f")
+	if black.Hex() != "#808080" {
+		t.Errorf("ANSI[black].Hex() = %q, want %q", black.Hex(), "#808080")
 	}
 }
 
@@ -573,12 +1135,12 @@ syntax {
 		t.Fatalf("Parse() error: %v", err)
 	}
 	kw := theme.Syntax["keyword"].(color.Style)
-	if kw.Color.Hex() != "#7f7f7f" {
-		t.Errorf("Syntax[keyword].Color.Hex() = %q, want %q", kw.Color.Hex(), "#7f7f7f")
+	if kw.Color.Hex() != "#808080" {
+		t.Errorf("Syntax[keyword].Color.Hex() = %q, want %q", kw.Color.Hex(), "#808080")
 	}
 	comment := theme.Syntax["comment"].(color.Style)
-	if comment.Color.Hex() != "#3f3f3f" {
-		t.Errorf("Syntax[comment].Color.Hex() = %q, want %q", comment.Color.Hex(), "#3f3f3f")
+	if comment.Color.Hex() != "#404040" {
+		t.Errorf("Syntax[comment].Color.Hex() = %q, want %q", comment.Color.Hex(), "#404040")
 	}
 }
 
@@ -615,8 +1177,8 @@ theme {
 		t.Fatalf("Parse() error: %v", err)
 	}
 	bg := theme.Theme["background"]
-	if bg.Hex() != "#7f7f7f" {
-		t.Errorf("Theme[background].Hex() = %q, want %q", bg.Hex(), "#7f7f7f")
+	if bg.Hex() != "#808080" {
+		t.Errorf("Theme[background].Hex() = %q, want %q", bg.Hex(), "#808080")
 	}
 }
 
@@ -636,8 +1198,8 @@ theme {
 		t.Fatalf("Parse() error: %v", err)
 	}
 	bg := theme.Theme["background"]
-	if bg.Hex() != "#7f7f7f" {
-		t.Errorf("Theme[background].Hex() = %q, want %q", bg.Hex(), "#7f7f7f")
+	if bg.Hex() != "#808080" {
+		t.Errorf("Theme[background].Hex() = %q, want %q", bg.Hex(), "#808080")
 	}
 }
 
@@ -658,6 +1220,94 @@ theme {
 	}
 }
 
+func TestMixInTheme(t *testing.T) {
+	hcl := `
+palette {
+  love = "#eb6f92"
+  base = "#191724"
+}
+
+theme {
+  background = mix(palette.love, palette.base, 0.3)
+  foreground = mix("#000000", "#ffffff", 0.5)
+}
+` + completeANSI
+	path := writeTempHCL(t, hcl)
+	theme, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	tests := map[string]string{
+		"background": "#ac5571",
+		"foreground": "#808080",
+	}
+	for name, want := range tests {
+		if got := theme.Theme[name].Hex(); got != want {
+			t.Errorf("Theme[%s].Hex() = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSaturateInSyntax(t *testing.T) {
+	hcl := `
+palette {
+  love = "#cc3333"
+}
+
+syntax {
+  keyword = saturate(palette.love, 0.4)
+  comment = desaturate(palette.love, 0.6)
+}
+` + completeANSI
+	path := writeTempHCL(t, hcl)
+	theme, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	tests := map[string]string{
+		"keyword": "#ff0000",
+		"comment": "#808080",
+	}
+	for name, want := range tests {
+		style, ok := theme.Syntax[name].(color.Style)
+		if !ok {
+			t.Fatalf("Syntax[%s] is %T, want a style", name, theme.Syntax[name])
+		}
+		if got := style.Color.Hex(); got != want {
+			t.Errorf("Syntax[%s].Hex() = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestLightnessPercentageOutOfRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr string
+	}{
+		{"brighten above 1", "brighten(palette.base, 1.5)", `theme.hcl:7,39-42: Invalid function argument; Invalid value for "percentage" parameter: percentage must be between -1 and 1, got 1.5`},
+		{"darken negative", "darken(palette.base, -0.1)", "percentage must be between 0 and 1, got -0.1; use brighten to lighten"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hcl := `
+palette {
+  base = "#191724"
+}
+
+theme {
+  background = ` + tt.expr + `
+}
+` + completeANSI
+			path := writeTempHCL(t, hcl)
+			_, err := Parse(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestPaletteNestedColor(t *testing.T) {
 	hcl := `
 palette {
@@ -874,6 +1524,37 @@ theme {
 	}
 }
 
+func TestPaletteTransformInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		lightness string
+		wantErr   string
+	}{
+		{"range not a list", "range = 0.5\n      steps = 2", "lightness range must be a list of two numbers"},
+		{"range too short", "range = [0.5]\n      steps = 2", "lightness range must be a list of two numbers"},
+		{"range not numbers", "range = [\"a\", \"b\"]\n      steps = 2", "lightness range must be a list of two numbers"},
+		{"steps not a number", "range = [0.1, 0.9]\n      steps = \"x\"", "lightness steps must be a whole number"},
+		{"fractional steps", "range = [0.1, 0.9]\n      steps = 2.5", "lightness steps must be a whole number"},
+		{"range out of bounds", "range = [-0.1, 0.9]\n      steps = 2", "lightness range values must be between 0 and 1, got -0.1"},
+		{"unknown attribute", "range = [0.1, 0.9]\n      steps = 2\n      stepz = 3", `lightness: unexpected attribute "stepz"`},
+		{"nested block", "range = [0.1, 0.9]\n      steps = 2\n      chroma {}", `lightness: unexpected block "chroma"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hcl := "palette {\n  base = \"#191724\"\n  transform {\n    lightness {\n      " + tt.lightness + "\n    }\n  }\n}\n" + completeANSI
+			path := writeTempHCL(t, hcl)
+			_, err := Parse(path)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestPaletteNoTransform(t *testing.T) {
 	// Verify existing sampleHCL (no transform) still works, no stepped children
 	path := writeTempHCL(t, sampleHCL)
@@ -909,3 +1590,683 @@ theme {
 		t.Fatal("expected error for forward reference in palette")
 	}
 }
+
+// referenceOrderTheme has a REF slot in every block. Each slot is placed after
+// the entry a self-reference would target.
+const referenceOrderTheme = `
+palette {
+  base = "#111111"
+  ref  = PALETTE
+}
+
+locals {
+  accent = "#222222"
+  ref    = LOCALS
+}
+
+theme {
+  background = "#333333"
+  ref        = THEME
+}
+
+ansi {
+  black          = "#444444"
+  red            = "#000000"
+  green          = "#000000"
+  yellow         = "#000000"
+  blue           = "#000000"
+  magenta        = "#000000"
+  cyan           = "#000000"
+  white          = ANSI
+  bright_black   = "#000000"
+  bright_red     = "#000000"
+  bright_green   = "#000000"
+  bright_yellow  = "#000000"
+  bright_blue    = "#000000"
+  bright_magenta = "#000000"
+  bright_cyan    = "#000000"
+  bright_white   = "#000000"
+}
+
+syntax {
+  keyword = "#555555"
+  ref     = SYNTAX
+}
+
+semantic {
+  keyword = "#666666"
+  comment = SEMANTIC
+}
+`
+
+func TestParseReferenceOrder(t *testing.T) {
+	blocks := []string{"palette", "locals", "theme", "ansi", "syntax", "semantic"}
+	refs := map[string]string{
+		"palette":  "palette.base",
+		"locals":   "local.accent",
+		"theme":    "theme.background",
+		"ansi":     "ansi.black",
+		"syntax":   "syntax.keyword",
+		"semantic": "semantic.keyword",
+	}
+	want := map[string]string{
+		"palette":  "#111111",
+		"locals":   "#222222",
+		"theme":    "#333333",
+		"ansi":     "#444444",
+		"syntax":   "#555555",
+		"semantic": "#666666",
+	}
+	selfReferencing := map[string]bool{"palette": true, "locals": true, "theme": true, "syntax": true}
+
+	for i, from := range blocks {
+		for j, to := range blocks {
+			t.Run(from+" to "+to, func(t *testing.T) {
+				slots := make([]string, 0, 2*len(blocks))
+				for _, b := range blocks {
+					value := `"#999999"`
+					if b == from {
+						value = refs[to]
+					}
+					slots = append(slots, strings.ToUpper(b), value)
+				}
+				path := writeTempHCL(t, strings.NewReplacer(slots...).Replace(referenceOrderTheme))
+
+				result, err := Parse(path)
+				allowed := j < i || (j == i && selfReferencing[from])
+				if !allowed {
+					if err == nil || !strings.Contains(err.Error(), "cannot reference") {
+						t.Fatalf("expected reference order error, got %v", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				var got color.Color
+				switch from {
+				case "palette":
+					got, _ = result.Palette.Lookup([]string{"ref"})
+				case "locals":
+					return // locals are not part of the result
+				case "theme":
+					got = result.Theme["ref"]
+				case "ansi":
+					got = result.ANSI["white"]
+				case "syntax":
+					got = result.Syntax["ref"].(color.Style).Color
+				case "semantic":
+					got = result.Semantic["comment"].Color
+				}
+				if got.Hex() != want[to] {
+					t.Errorf("%s ref = %s, want %s", from, got.Hex(), want[to])
+				}
+			})
+		}
+	}
+}
+
+func TestParseReferenceOrder_ThemeSubBlocksAndLanguages(t *testing.T) {
+	path := writeTempHCL(t, `
+palette {
+  base = "#191724"
+  text = "#e0def4"
+}
+
+theme {
+  background = palette.base
+  cursor {
+    cursor = palette.text
+    text   = theme.background
+  }
+}
+
+ansi {
+  black          = theme.cursor.text
+  red            = "#000000"
+  green          = "#000000"
+  yellow         = "#000000"
+  blue           = "#000000"
+  magenta        = "#000000"
+  cyan           = "#000000"
+  white          = theme.cursor.cursor
+  bright_black   = "#000000"
+  bright_red     = "#000000"
+  bright_green   = "#000000"
+  bright_yellow  = "#000000"
+  bright_blue    = "#000000"
+  bright_magenta = "#000000"
+  bright_cyan    = "#000000"
+  bright_white   = "#000000"
+}
+
+syntax "go" {
+  keyword = syntax.markup.heading
+}
+
+syntax {
+  markup {
+    heading {
+      color = ansi.white
+      bold  = true
+    }
+  }
+}
+`)
+	result, err := Parse(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := result.ANSI["black"].Hex(); got != "#191724" {
+		t.Errorf("ansi.black = %s, want #191724", got)
+	}
+	if got := result.LanguageSyntax["go"]["keyword"].(color.Style).Color.Hex(); got != "#e0def4" {
+		t.Errorf(`syntax "go".keyword = %s, want #e0def4`, got)
+	}
+}
+
+func TestParseErrorKinds(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    theme.Kind
+	}{
+		{"syntax error", "palette {\n  base = \n", theme.KindConfig},
+		{"bad hex", "palette {\n  base = \"#zzzzzz\"\n}\n" + completeANSI, theme.KindConfig},
+		{"missing palette", "theme {\n  background = \"#000000\"\n}\n" + completeANSI, theme.KindValidation},
+		{"duplicate block", "palette {\n  base = \"#000000\"\n}\npalette {\n}\n" + completeANSI, theme.KindValidation},
+		{"incomplete ansi", "palette {\n  base = \"#000000\"\n}\n", theme.KindValidation},
+		{"reference order", "palette {\n  base = theme.background\n}\n" + completeANSI, theme.KindValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(writeTempHCL(t, tt.content))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if got := theme.KindOf(err); got != tt.want {
+				t.Errorf("KindOf(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := Parse(filepath.Join(t.TempDir(), "missing.pstheme"))
+		if got := theme.KindOf(err); got != theme.KindIO {
+			t.Errorf("KindOf(%v) = %v, want %v", err, got, theme.KindIO)
+		}
+	})
+}
+
+func TestParseForAppearance(t *testing.T) {
+	path := writeTempHCL(t, `
+meta {
+  name       = "Rosé Pine"
+  appearance = "dark"
+}
+
+palette {
+  base = "#191724"
+  surface {
+    when  = "dark"
+    color = "#1f1d2e"
+  }
+  surface {
+    when  = "light"
+    color = "#fffaf3"
+  }
+}
+
+theme {
+  when       = "dark"
+  background = palette.base
+  surface    = palette.surface
+}
+
+theme {
+  when       = "light"
+  background = "#faf4ed"
+  surface    = palette.surface
+}
+`+completeANSI)
+
+	tests := []struct {
+		appearance string
+		wantBg     string
+		wantSurf   string
+		wantMeta   string
+	}{
+		{"", "#191724", "#1f1d2e", "dark"},
+		{"light", "#faf4ed", "#fffaf3", "light"},
+	}
+	for _, tt := range tests {
+		t.Run("appearance "+tt.appearance, func(t *testing.T) {
+			result, err := ParseForAppearance(context.Background(), path, theme.DefaultLimits, tt.appearance)
+			if err != nil {
+				t.Fatalf("ParseForAppearance() error: %v", err)
+			}
+			if got := result.Theme["background"].Hex(); got != tt.wantBg {
+				t.Errorf("theme.background = %s, want %s", got, tt.wantBg)
+			}
+			if got := result.Theme["surface"].Hex(); got != tt.wantSurf {
+				t.Errorf("theme.surface = %s, want %s", got, tt.wantSurf)
+			}
+			if result.Meta.Appearance != tt.wantMeta {
+				t.Errorf("Meta.Appearance = %q, want %q", result.Meta.Appearance, tt.wantMeta)
+			}
+		})
+	}
+
+	_, err := ParseForAppearance(context.Background(), path, theme.DefaultLimits, "dim")
+	if theme.KindOf(err) != theme.KindConfig || !strings.Contains(err.Error(), `appearance must be one of dark, light, got "dim"`) {
+		t.Errorf("ParseForAppearance(dim) error = %v, want a config error", err)
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	path := writeTempHCL(t, "palette {\n  base = \"#191724\"\n}\n"+completeANSI)
+
+	t.Run("live context", func(t *testing.T) {
+		result, err := ParseContext(context.Background(), path, theme.DefaultLimits)
+		if err != nil {
+			t.Fatalf("ParseContext() error: %v", err)
+		}
+		if got := result.Palette.Children["base"].Color.Hex(); got != "#191724" {
+			t.Errorf("palette.base = %s, want #191724", got)
+		}
+	})
+
+	t.Run("timings", func(t *testing.T) {
+		var got []string
+		ctx := metrics.WithRecorder(context.Background(), func(phase, name string, _ time.Duration) {
+			got = append(got, phase+" "+name)
+		})
+		if _, err := ParseContext(ctx, path, theme.DefaultLimits); err != nil {
+			t.Fatalf("ParseContext() error: %v", err)
+		}
+		want := []string{"parse " + path, "eval palette", "eval theme", "eval ansi", "eval syntax"}
+		if !slices.Equal(got, want) {
+			t.Errorf("recorded %q, want %q", got, want)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := ParseContext(ctx, path, theme.DefaultLimits)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("ParseContext() error = %v, want context.Canceled", err)
+		}
+		if got := theme.KindOf(err); got != 0 {
+			t.Errorf("KindOf(%v) = %v, want no kind", err, got)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+		defer cancel()
+		if _, err := ParseContext(ctx, path, theme.DefaultLimits); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("ParseContext() error = %v, want context.DeadlineExceeded", err)
+		}
+	})
+}
+
+func TestParseSources(t *testing.T) {
+	content := `palette {
+  base = "#191724"
+  highlight {
+    color = "#403d52"
+    low   = "#21202e"
+  }
+  scale "gray" {
+    from  = "#000000"
+    to    = "#ffffff"
+    steps = 3
+  }
+  transform {
+    lightness {
+      range = [0.2, 0.8]
+      steps = 2
+    }
+  }
+}
+
+theme {
+  background = palette.base
+  cursor {
+    cursor = palette.base
+    text   = palette.highlight.low
+  }
+}
+
+syntax {
+  keyword = palette.base
+  comment {
+    color  = palette.highlight.low
+    italic = true
+  }
+}
+
+syntax "go" {
+  keyword = palette.highlight
+}
+` + completeANSI
+
+	result, err := Parse(writeTempHCL(t, content))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"palette.base", "theme.hcl:2"},
+		{"palette.highlight", "theme.hcl:4"},
+		{"palette.highlight.low", "theme.hcl:5"},
+		{"palette.gray", "theme.hcl:7"},
+		{"palette.gray.2", "theme.hcl:7"},
+		{"palette.base.l1", "theme.hcl:12"},
+		{"palette.highlight.low.l2", "theme.hcl:12"},
+		{"theme.background", "theme.hcl:21"},
+		{"theme.cursor.text", "theme.hcl:24"},
+		{"syntax.keyword", "theme.hcl:29"},
+		{"syntax.comment", "theme.hcl:30"},
+		{"ansi.red", "theme.hcl:42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			loc, ok := result.Sources[tt.path]
+			if !ok {
+				t.Fatalf("no source for %s", tt.path)
+			}
+			if got := loc.String(); got != tt.want {
+				t.Errorf("Sources[%s] = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{"syntax.comment.italic", "palette.base.l3"} {
+		if _, ok := result.Sources[path]; ok {
+			t.Errorf("unexpected source for %s", path)
+		}
+	}
+}
+
+func TestParseSpecVersion(t *testing.T) {
+	const palette = `
+palette {
+  highlight {
+    color = "#ff0000"
+    low   = "#110000"
+  }
+}
+
+theme {
+  cursor = palette.highlight.color
+}
+`
+	t.Run("v1 gives migration hints", func(t *testing.T) {
+		path := writeTempHCL(t, "meta {\n  name = \"x\"\n}\n"+palette+completeANSI)
+		result, err := Parse(path)
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		if result.Meta.SpecVersion != 1 {
+			t.Errorf("Meta.SpecVersion = %d, want 1", result.Meta.SpecVersion)
+		}
+		want := "theme.hcl:13: palette.highlight.color: the color key is implicit, and referencing it is no longer valid in v2; use palette.highlight"
+		if len(result.MigrationHints) != 1 || result.MigrationHints[0] != want {
+			t.Errorf("MigrationHints = %q, want [%q]", result.MigrationHints, want)
+		}
+	})
+
+	t.Run("v2 rejects removed constructs", func(t *testing.T) {
+		path := writeTempHCL(t, "meta {\n  spec_version = 2\n}\n"+palette+completeANSI)
+		_, err := Parse(path)
+		if err == nil {
+			t.Fatal("Parse() succeeded, want error")
+		}
+		if got := theme.KindOf(err); got != theme.KindValidation {
+			t.Errorf("KindOf(%v) = %v, want %v", err, got, theme.KindValidation)
+		}
+		if !strings.Contains(err.Error(), "line 13: palette.highlight.color") {
+			t.Errorf("error = %v, want it to name line 13 and the reference", err)
+		}
+	})
+
+	t.Run("v2 clean file", func(t *testing.T) {
+		path := writeTempHCL(t, "meta {\n  spec_version = 2\n}\n"+strings.Replace(palette, ".highlight.color", ".highlight", 1)+completeANSI)
+		result, err := Parse(path)
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		if result.Meta.SpecVersion != 2 {
+			t.Errorf("Meta.SpecVersion = %d, want 2", result.Meta.SpecVersion)
+		}
+		if _, ok := result.Meta.Extra["spec_version"]; ok {
+			t.Error("spec_version should not appear in Meta.Extra")
+		}
+		if len(result.MigrationHints) != 0 {
+			t.Errorf("MigrationHints = %q, want none", result.MigrationHints)
+		}
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		path := writeTempHCL(t, "meta {\n  spec_version = 9\n}\n"+palette+completeANSI)
+		_, err := Parse(path)
+		if got := theme.KindOf(err); got != theme.KindConfig {
+			t.Errorf("KindOf(%v) = %v, want %v", err, got, theme.KindConfig)
+		}
+	})
+}
+
+func TestParseHeredocColors(t *testing.T) {
+	path := writeTempHCL(t, `
+palette {
+  base = <<EOT
+#191724
+EOT
+  love = <<-EOT
+    #eb6f92
+    EOT
+}
+
+theme {
+  background = palette.base
+  foreground = (
+    palette.love
+  )
+}
+`+completeANSI)
+	result, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if got := result.Theme["background"].Hex(); got != "#191724" {
+		t.Errorf("background = %s, want #191724", got)
+	}
+	if got := result.Theme["foreground"].Hex(); got != "#eb6f92" {
+		t.Errorf("foreground = %s, want #eb6f92", got)
+	}
+}
+
+func TestParseInterpolatedColors(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		path := writeTempHCL(t, `
+palette {
+  base = "#191724"
+}
+
+locals {
+  hue = "eb"
+}
+
+theme {
+  background = "${palette.base}"
+  foreground = "#${local.hue}6f92"
+}
+`+completeANSI)
+		result, err := Parse(path)
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		if got := result.Theme["foreground"].Hex(); got != "#eb6f92" {
+			t.Errorf("foreground = %s, want #eb6f92", got)
+		}
+		if got := result.Theme["background"].Hex(); got != "#191724" {
+			t.Errorf("background = %s, want #191724", got)
+		}
+	})
+
+	t.Run("invalid result", func(t *testing.T) {
+		path := writeTempHCL(t, `
+palette {
+  base = "#191724"
+  bad  = "#${palette.base}"
+}
+`+completeANSI)
+		_, err := Parse(path)
+		if err == nil || !strings.Contains(err.Error(), `palette.bad: interpolation produced "##191724", which is not a hex color`) {
+			t.Errorf("Parse() error = %v, want interpolation error for palette.bad", err)
+		}
+	})
+}
+
+func TestParseRGBTuples(t *testing.T) {
+	path := writeTempHCL(t, `
+palette {
+  base = [25, 23, 36]
+  highlight {
+    color = [82, 79, 103]
+    low   = [33, 32, 46]
+  }
+}
+
+theme {
+  background = palette.base
+  foreground = [224, 222, 244]
+  cursor     = palette.highlight
+}
+`+completeANSI)
+	result, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	for name, want := range map[string]string{
+		"background": "#191724",
+		"foreground": "#e0def4",
+		"cursor":     "#524f67",
+	} {
+		if got := result.Theme[name].Hex(); got != want {
+			t.Errorf("theme.%s = %s, want %s", name, got, want)
+		}
+	}
+	if got := result.Palette.Children["highlight"].Children["low"].Color.Hex(); got != "#21202e" {
+		t.Errorf("palette.highlight.low = %s, want #21202e", got)
+	}
+}
+
+func TestParseSchemaVersion(t *testing.T) {
+	const theme = `
+settings {
+  ansi_profile = "basic8"
+%s}
+
+palette {
+  base = "#191724"
+}
+
+ansi {
+  black   = "#000000"
+  red     = "#ff0000"
+  green   = "#00ff00"
+  yellow  = "#ffff00"
+  blue    = "#0000ff"
+  magenta = "#ff00ff"
+  cyan    = "#00ffff"
+  white   = "#ffffff"
+}
+`
+	tests := []struct {
+		name    string
+		setting string
+		want    int
+		wantErr string
+	}{
+		{"unset", "", 0, ""},
+		{"pinned", "  schema_version = 1\n", 1, ""},
+		{"not a number", "  schema_version = \"v1\"\n", 0, "schema_version must be a positive whole number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Parse(writeThemeFile(t, fmt.Sprintf(theme, tt.setting)))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.SchemaVersion != tt.want {
+				t.Errorf("SchemaVersion = %d, want %d", result.SchemaVersion, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRepeatedPaletteGroup(t *testing.T) {
+	const theme = `
+settings {
+  ansi_profile = "basic8"
+}
+
+palette {
+  highlight {
+    low  = "#21202e"
+    high = "#524f67"
+  }
+  base = palette.highlight.low
+  highlight {
+    mid  = "#403d52"
+    high = "#6e6a86"
+  }
+}
+
+ansi {
+  black   = "#000000"
+  red     = "#ff0000"
+  green   = "#00ff00"
+  yellow  = "#ffff00"
+  blue    = "#0000ff"
+  magenta = "#ff00ff"
+  cyan    = "#00ffff"
+  white   = "#ffffff"
+}
+`
+	result, err := Parse(writeThemeFile(t, theme))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	group := result.Palette.Children["highlight"]
+	if group == nil {
+		t.Fatal("palette.highlight missing")
+	}
+	for name, want := range map[string]string{"low": "#21202e", "mid": "#403d52", "high": "#6e6a86"} {
+		child := group.Children[name]
+		if child == nil || child.Color == nil || child.Color.Hex() != want {
+			t.Errorf("palette.highlight.%s = %v, want %s", name, child, want)
+		}
+	}
+	if got := group.Names(); !slices.Equal(got, []string{"low", "high", "mid"}) {
+		t.Errorf("Names() = %v, want [low high mid]", got)
+	}
+	if got := result.Palette.Children["base"].Color.Hex(); got != "#21202e" {
+		t.Errorf("palette.base = %s, want #21202e", got)
+	}
+}
@@ -0,0 +1,26 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func FuzzParse(f *testing.F) {
+	f.Add(sampleHCL)
+	f.Add("palette {\n  base = \"#191724\"\n}\nsyntax {\n  keyword = 5\n}\n" + completeANSI)
+	f.Add("palette {\n  base = \"#191724\"\n}\nsyntax {\n  comment {\n    color = true\n  }\n}\n" + completeANSI)
+	f.Add("palette {\n  base = \"#191724\"\n  transform {\n    lightness {\n      range = 1\n      steps = \"x\"\n    }\n  }\n}\n")
+	f.Add("palette {\n  base = null\n}\n")
+
+	dir := f.TempDir()
+	path := filepath.Join(dir, "theme.pstheme")
+
+	f.Fuzz(func(t *testing.T, content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Errors are expected; panics are not.
+		_, _ = Parse(path)
+	})
+}
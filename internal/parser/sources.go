@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/jsvensson/paletteswap/internal/color"
+)
+
+// Location is where a value is defined in a theme file.
+type Location struct {
+	File string // base name of the theme file
+	Line int    // 1-based
+}
+
+// String formats the location as "file:line".
+func (l Location) String() string {
+	return fmt.Sprintf("%s:%d", l.File, l.Line)
+}
+
+// collectSources records where each color path of the file body is defined,
+// keyed by dot-notation path as accepted by the template functions, e.g.
+// "palette.love", "theme.cursor.text" or "syntax.markup.heading". Groups are
+// recorded at their block, or at their color attribute if they have one.
+// Labeled syntax blocks are per-language overrides and are not recorded.
+//
+// The steps of palette, the resolved palette, are recorded at the block that
+// generates them: scale steps such as "palette.gray.2" at their scale
+// block, and lightness steps such as "palette.base.l1" at the transform
+// block. Steps of colors defined in other files are not recorded.
+func collectSources(body *hclsyntax.Body, file string, palette *color.Node) map[string]Location {
+	sources := make(map[string]Location)
+	loc := func(line int) Location { return Location{File: file, Line: line} }
+	scales := make(map[string]bool)
+	var transform *Location
+
+	var walk func(prefix string, body *hclsyntax.Body, palette bool)
+	walk = func(prefix string, body *hclsyntax.Body, palette bool) {
+		for name, attr := range body.Attributes {
+			if name == color.ColorKey {
+				sources[prefix] = loc(attr.SrcRange.Start.Line)
+				continue
+			}
+			sources[prefix+"."+name] = loc(attr.SrcRange.Start.Line)
+		}
+		for _, block := range body.Blocks {
+			switch {
+			case palette && block.Type == "transform":
+				if prefix == "palette" {
+					l := loc(block.DefRange().Start.Line)
+					transform = &l
+				}
+			case palette && IsScaleBlock(block):
+				path := prefix + "." + block.Labels[0]
+				sources[path] = loc(block.DefRange().Start.Line)
+				scales[path] = true
+			case len(block.Labels) > 0:
+			case !palette && isStyleBlock(block.Body):
+				sources[prefix+"."+block.Type] = loc(block.DefRange().Start.Line)
+			default:
+				path := prefix + "." + block.Type
+				sources[path] = loc(block.DefRange().Start.Line)
+				walk(path, block.Body, palette)
+			}
+		}
+	}
+
+	for _, block := range body.Blocks {
+		switch block.Type {
+		case "palette", "theme", "ansi", "syntax", "semantic":
+			if len(block.Labels) == 0 {
+				walk(block.Type, block.Body, block.Type == "palette")
+			}
+		}
+	}
+
+	var addSteps func(prefix string, node *color.Node)
+	addSteps = func(prefix string, node *color.Node) {
+		for _, name := range node.Names() {
+			path := prefix + "." + name
+			if _, ok := sources[path]; !ok {
+				switch {
+				case scales[prefix]:
+					sources[path] = sources[prefix]
+				case transform != nil && isLightnessStep(name):
+					sources[path] = *transform
+				default:
+					continue
+				}
+			}
+			addSteps(path, node.Children[name])
+		}
+	}
+	if palette != nil {
+		addSteps("palette", palette)
+	}
+	return sources
+}
+
+// isLightnessStep reports whether name is that of a step the transform
+// block generates, such as "l1".
+func isLightnessStep(name string) bool {
+	digits, ok := strings.CutPrefix(name, "l")
+	if !ok || digits == "" {
+		return false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
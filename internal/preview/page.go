@@ -0,0 +1,189 @@
+// Package preview renders an HTML preview of a theme and serves it over HTTP,
+// optionally reloading connected browsers when the theme file changes.
+package preview
+
+import (
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/jsvensson/paletteswap"
+	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/theme"
+)
+
+// swatch is a named color shown in the palette grid or fake terminal.
+// Palette swatches also show how many entries use them, and that count as a
+// percentage of the most used entry's.
+type swatch struct {
+	Name string
+	Hex  string
+	Uses int
+	Heat int
+}
+
+// token is a run of code sample text in one color.
+type token struct {
+	Text string
+	Hex  string
+}
+
+type pageData struct {
+	Title      string
+	Background string
+	Foreground string
+	Palette    []swatch
+	ANSI       []swatch
+	Code       [][]token
+	Error      string
+	Watch      bool
+	ShowUses   bool
+}
+
+// codeSample is a short Go program; each run is "scope|text" where scope is
+// a syntax path, or empty for plain foreground text.
+var codeSample = [][]string{
+	{"comment|// Greet prints a greeting."},
+	{"keyword|func ", "function|Greet", "|(", "variable|name ", "type|string", "|) {"},
+	{"|\t", "variable|count ", "operator|:= ", "number|3"},
+	{"|\t", "keyword|for ", "variable|i ", "operator|:= ", "number|0", "|; ", "variable|i ", "operator|< ", "variable|count", "|; ", "variable|i", "operator|++ ", "|{"},
+	{"|\t\t", "function|println", "|(", "string|\"hello, \"", "operator| + ", "variable|name", "|)"},
+	{"|\t}"},
+	{"|}"},
+}
+
+var page = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>
+body { margin: 0; padding: 2rem; font-family: system-ui, sans-serif; background: {{ .Background }}; color: {{ .Foreground }}; }
+h2 { font-weight: 500; }
+.grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(9rem, 1fr)); gap: 0.75rem; }
+.swatch { border-radius: 6px; overflow: hidden; font-size: 0.8rem; border: 1px solid #8884; }
+.swatch div { height: 3rem; }
+.swatch .heat { height: 4px; background: {{ .Foreground }}; }
+.swatch p { margin: 0.4rem; }
+pre { padding: 1rem; border-radius: 6px; border: 1px solid #8884; font-size: 0.95rem; }
+.error { padding: 1rem; border: 2px solid #e5484d; border-radius: 6px; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+{{ if .Error }}<pre class="error">{{ .Error }}</pre>{{ end }}
+<h2>Palette</h2>
+<div class="grid">
+{{ range .Palette }}<div class="swatch"><div style="background: {{ .Hex }}"></div><p>{{ .Name }}<br>{{ .Hex }}{{ if $.ShowUses }}<br>used by {{ .Uses }}{{ end }}</p>{{ if $.ShowUses }}<div class="heat" style="width: {{ .Heat }}%"></div>{{ end }}</div>
+{{ end }}</div>
+<h2>Terminal</h2>
+<pre>{{ range .ANSI }}<span style="color: {{ .Hex }}">{{ .Name }}</span>
+{{ end }}</pre>
+<h2>Code</h2>
+<pre>{{ range .Code }}{{ range . }}<span style="color: {{ .Hex }}">{{ .Text }}</span>{{ end }}
+{{ end }}</pre>
+{{ if .Watch }}<script>new EventSource("/events").onmessage = () => location.reload();</script>{{ end }}
+</body>
+</html>
+`))
+
+// Render writes the HTML preview of t to w. uses holds how many entries
+// use each palette path, as reported by graph.Usage; palette swatches show
+// it as a heatmap unless it is nil. If watch is true, the page reloads
+// itself when the server reports a theme change.
+func Render(w io.Writer, t *paletteswap.Theme, uses map[string]int, watch bool) error {
+	return page.Execute(w, newPageData(t, uses, watch))
+}
+
+// renderError writes a page showing err, which still reloads on changes so
+// that fixing the theme file recovers the preview.
+func renderError(w io.Writer, title string, err error, watch bool) error {
+	return page.Execute(w, pageData{
+		Title:      title,
+		Background: "#ffffff",
+		Foreground: "#000000",
+		Error:      err.Error(),
+		Watch:      watch,
+	})
+}
+
+func newPageData(t *paletteswap.Theme, uses map[string]int, watch bool) pageData {
+	data := pageData{
+		Title:      t.Meta.Name,
+		Background: themeColor(t, "background", "#ffffff"),
+		Foreground: themeColor(t, "foreground", "#000000"),
+		Watch:      watch,
+		ShowUses:   uses != nil,
+	}
+	if data.Title == "" {
+		data.Title = "Theme preview"
+	}
+
+	if t.Palette != nil {
+		walkPalette(t.Palette, "palette", &data.Palette)
+	}
+	most := 0
+	for _, n := range uses {
+		most = max(most, n)
+	}
+	for i, sw := range data.Palette {
+		if n := uses[sw.Name]; n > 0 {
+			data.Palette[i].Uses = n
+			data.Palette[i].Heat = n * 100 / most
+		}
+	}
+	for _, name := range theme.RequiredANSIColors {
+		if c, ok := t.ANSI[name]; ok {
+			data.ANSI = append(data.ANSI, swatch{Name: name, Hex: c.Hex()})
+		}
+	}
+	for _, line := range codeSample {
+		var tokens []token
+		for _, run := range line {
+			scope, text, _ := strings.Cut(run, "|")
+			hex := data.Foreground
+			if scope != "" {
+				if style, ok := lookupStyle(t.Syntax, scope); ok {
+					hex = style.Color.Hex()
+				}
+			}
+			tokens = append(tokens, token{Text: text, Hex: hex})
+		}
+		data.Code = append(data.Code, tokens)
+	}
+	return data
+}
+
+func themeColor(t *paletteswap.Theme, name, fallback string) string {
+	if c, ok := t.Theme[name]; ok {
+		return c.Hex()
+	}
+	return fallback
+}
+
+// walkPalette appends a swatch for every colored node under node, including
+// groups with their own color, in the order the theme declares them.
+func walkPalette(node *color.Node, path string, out *[]swatch) {
+	if node.Color != nil {
+		*out = append(*out, swatch{Name: path, Hex: node.Color.Hex()})
+	}
+	for _, name := range node.Names() {
+		walkPalette(node.Children[name], path+"."+name, out)
+	}
+}
+
+// lookupStyle finds the style at a dot-separated path in a syntax tree.
+func lookupStyle(tree color.Tree, path string) (color.Style, bool) {
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		switch v := tree[part].(type) {
+		case color.Style:
+			return v, i == len(parts)-1
+		case color.Tree:
+			tree = v
+		default:
+			return color.Style{}, false
+		}
+	}
+	return color.Style{}, false
+}
@@ -0,0 +1,209 @@
+package preview
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsvensson/paletteswap"
+	"github.com/jsvensson/paletteswap/internal/color"
+)
+
+const testThemeHCL = `
+meta {
+  name = "Preview Test"
+}
+
+palette {
+  base = "#191724"
+  text = "#e0def4"
+  highlight {
+    color = "#403d52"
+    low   = "#21202e"
+  }
+}
+
+theme {
+  background = palette.base
+  foreground = palette.text
+}
+
+ansi {
+  black          = "#000000"
+  red            = "#eb6f92"
+  green          = "#000000"
+  yellow         = "#000000"
+  blue           = "#000000"
+  magenta        = "#000000"
+  cyan           = "#000000"
+  white          = "#000000"
+  bright_black   = "#000000"
+  bright_red     = "#000000"
+  bright_green   = "#000000"
+  bright_yellow  = "#000000"
+  bright_blue    = "#000000"
+  bright_magenta = "#000000"
+  bright_cyan    = "#000000"
+  bright_white   = "#000000"
+}
+
+syntax {
+  keyword = "#31748f"
+}
+`
+
+func writeTheme(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "theme.pstheme")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRender(t *testing.T) {
+	base := color.Color{R: 25, G: 23, B: 36}
+	group := color.Color{R: 64, G: 61, B: 82}
+	theme := &paletteswap.Theme{
+		Meta: paletteswap.Meta{Name: "Rosé"},
+		Palette: &color.Node{Children: map[string]*color.Node{
+			"base":      {Color: &base},
+			"highlight": {Color: &group, Children: map[string]*color.Node{"low": {Color: &base}}},
+		}},
+		Theme:  map[string]color.Color{"background": base},
+		Syntax: color.Tree{"keyword": color.Style{Color: color.Color{R: 49, G: 116, B: 143}}},
+		ANSI:   map[string]color.Color{"red": {R: 235, G: 111, B: 146}},
+	}
+
+	var buf bytes.Buffer
+	uses := map[string]int{"palette.base": 4, "palette.highlight": 1}
+	if err := Render(&buf, theme, uses, false); err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		"<title>Rosé</title>",
+		"background: #191724",
+		"palette.base<br>#191724<br>used by 4</p><div class=\"heat\" style=\"width: 100%\">",
+		"palette.highlight<br>#403d52<br>used by 1</p><div class=\"heat\" style=\"width: 25%\">",
+		"palette.highlight.low<br>#191724<br>used by 0</p><div class=\"heat\" style=\"width: 0%\">",
+		`<span style="color: #eb6f92">red</span>`,
+		`<span style="color: #31748f">func </span>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+	if strings.Contains(got, "EventSource") {
+		t.Error("page without watch should not subscribe to events")
+	}
+}
+
+func TestServerPage(t *testing.T) {
+	path := writeTheme(t, testThemeHCL)
+	srv := httptest.NewServer((&Server{ThemePath: path, Watch: true}).Handler())
+	defer srv.Close()
+
+	body := get(t, srv.URL)
+	if !strings.Contains(body, "Preview Test") || !strings.Contains(body, "EventSource") {
+		t.Errorf("unexpected page:\n%s", body)
+	}
+	if !strings.Contains(body, "palette.text<br>#e0def4<br>used by 1") {
+		t.Errorf("page missing the usage of palette.text:\n%s", body)
+	}
+
+	// A broken theme shows the error instead of failing the request.
+	if err := os.WriteFile(path, []byte("palette {"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	body = get(t, srv.URL)
+	if !strings.Contains(body, `class="error"`) || !strings.Contains(body, "EventSource") {
+		t.Errorf("expected error page that still reloads, got:\n%s", body)
+	}
+}
+
+func TestServerReloadsOnChange(t *testing.T) {
+	path := writeTheme(t, testThemeHCL)
+	s := &Server{ThemePath: path, Watch: true, Interval: 10 * time.Millisecond}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve() error: %v", err)
+		}
+	}()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	// Wait for the subscription before changing the file.
+	for deadline := time.Now().Add(time.Second); ; {
+		s.mu.Lock()
+		n := len(s.clients)
+		s.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("events client never subscribed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := os.WriteFile(path, []byte(testThemeHCL+"\n# edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	line := make(chan string, 1)
+	go func() {
+		l, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		line <- l
+	}()
+	select {
+	case got := <-line:
+		if got != "data: reload\n" {
+			t.Errorf("event = %q, want %q", got, "data: reload\n")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reload event after theme change")
+	}
+}
+
+func get(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %s\n%s", url, resp.Status, body)
+	}
+	return string(body)
+}
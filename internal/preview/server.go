@@ -0,0 +1,203 @@
+package preview
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jsvensson/paletteswap"
+	"github.com/jsvensson/paletteswap/internal/graph"
+)
+
+// DefaultInterval is how often a watching Server checks the theme file.
+const DefaultInterval = 500 * time.Millisecond
+
+// Server serves the preview of a theme file. The theme is loaded on every
+// page request, so a reload always shows the current file.
+//
+// With Watch set, the page subscribes to /events, a Server-Sent Events
+// stream that sends a "reload" message whenever the theme file changes.
+type Server struct {
+	ThemePath string
+	Watch     bool
+	Interval  time.Duration // zero means DefaultInterval
+	Logger    *slog.Logger  // if non-nil, receives reload and error logs
+
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+func (s *Server) logger() *slog.Logger {
+	if s.Logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return s.Logger
+}
+
+// Handler returns the HTTP handler serving the preview page and, if
+// watching, the /events stream.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.servePage)
+	if s.Watch {
+		mux.HandleFunc("GET /events", s.serveEvents)
+	}
+	return mux
+}
+
+func (s *Server) servePage(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	t, err := paletteswap.LoadContext(r.Context(), s.ThemePath)
+	if err != nil {
+		s.logger().Warn("loading theme", "path", s.ThemePath, "error", err)
+		err = renderError(&buf, filepath.Base(s.ThemePath), err, s.Watch)
+	} else {
+		err = Render(&buf, t, s.paletteUses(), s.Watch)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// paletteUses returns how many entries use each palette path of the theme
+// file, or nil if it cannot be read or parsed.
+func (s *Server) paletteUses() map[string]int {
+	src, err := os.ReadFile(s.ThemePath)
+	if err != nil {
+		return nil
+	}
+	g, err := graph.Build(s.ThemePath, src)
+	if err != nil {
+		return nil
+	}
+	uses := make(map[string]int)
+	for _, u := range g.Usage() {
+		uses[u.Path] = u.Uses
+	}
+	return uses
+}
+
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			if _, err := fmt.Fprint(w, "data: reload\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clients == nil {
+		s.clients = make(map[chan struct{}]struct{})
+	}
+	s.clients[ch] = struct{}{}
+	return ch
+}
+
+func (s *Server) unsubscribe(ch chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, ch)
+}
+
+// notify signals every connected client to reload. A client that has not
+// yet handled an earlier signal is not signalled twice.
+func (s *Server) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watch polls the theme file until ctx is done, notifying clients when its
+// size or modification time changes.
+func (s *Server) watch(ctx context.Context) {
+	interval := s.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := s.stat()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if cur := s.stat(); cur != last {
+				last = cur
+				s.logger().Debug("theme changed, reloading", "path", s.ThemePath)
+				s.notify()
+			}
+		}
+	}
+}
+
+// fileState identifies a version of the theme file.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func (s *Server) stat() fileState {
+	info, err := os.Stat(s.ThemePath)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{size: info.Size(), modTime: info.ModTime()}
+}
+
+// Serve serves the preview on ln until ctx is done.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{Handler: s.Handler()}
+	if s.Watch {
+		go s.watch(ctx)
+	}
+	go func() {
+		<-ctx.Done()
+		// Event streams never finish on their own, so close rather than
+		// waiting for a graceful shutdown.
+		_ = srv.Close()
+	}()
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package theme
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ANSIProfileAttr is the settings attribute selecting the ANSI profile.
+const ANSIProfileAttr = "ansi_profile"
+
+// ANSIProfile selects which colors the ansi block must define, for
+// terminals that use 8 colors, 16, or also take their default colors from
+// the same scheme.
+type ANSIProfile string
+
+const (
+	// ANSIBasic8 requires the 8 normal colors, black to white.
+	ANSIBasic8 ANSIProfile = "basic8"
+	// ANSIStandard16 requires the 8 normal and 8 bright colors. It is the
+	// default.
+	ANSIStandard16 ANSIProfile = "standard16"
+	// ANSIExtended requires the 16 colors of ANSIStandard16 and
+	// ExtendedANSIColors.
+	ANSIExtended ANSIProfile = "extended"
+)
+
+// ANSIProfiles lists the valid ANSI profiles.
+var ANSIProfiles = []ANSIProfile{ANSIBasic8, ANSIStandard16, ANSIExtended}
+
+// ANSIExtraColors lists the optional colors the ansi block may define
+// besides the 16 terminal colors. Terminal scheme formats often carry them
+// with the palette; templates get them from TemplateData.ANSIExtras.
+var ANSIExtraColors = []string{"foreground", "background", "cursor", "selection_background", "selection_foreground"}
+
+// ExtendedANSIColors lists the colors of ANSIExtraColors that the extended
+// profile requires.
+var ExtendedANSIColors = ANSIExtraColors[:3:3]
+
+// ANSIColorNames lists every name valid in the ansi block, in canonical
+// order: the terminal colors in ANSI index order, then the extra colors.
+var ANSIColorNames = slices.Concat(RequiredANSIColors, ANSIExtraColors)
+
+// Required returns the names of the colors the ansi block must define.
+func (p ANSIProfile) Required() []string {
+	switch p {
+	case ANSIBasic8:
+		return RequiredANSIColors[:8]
+	case ANSIExtended:
+		return slices.Concat(RequiredANSIColors, ExtendedANSIColors)
+	default:
+		return RequiredANSIColors
+	}
+}
+
+// ANSIProfileSetting returns the ansi_profile of the settings block in body,
+// or ANSIStandard16 if there is none. The range is that of the attribute's
+// value, or the empty range when it is absent. An invalid value is a
+// KindConfig error.
+func ANSIProfileSetting(body *hclsyntax.Body) (ANSIProfile, hcl.Range, error) {
+	for _, block := range body.Blocks {
+		if block.Type != "settings" {
+			continue
+		}
+		attr, ok := block.Body.Attributes[ANSIProfileAttr]
+		if !ok {
+			continue
+		}
+		rng := attr.Expr.Range()
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || val.IsNull() || !val.IsKnown() || val.Type() != cty.String ||
+			!slices.Contains(ANSIProfiles, ANSIProfile(val.AsString())) {
+			return "", rng, Errorf(KindConfig, "%s must be one of: %s", ANSIProfileAttr, ansiProfileList())
+		}
+		return ANSIProfile(val.AsString()), rng, nil
+	}
+	return ANSIStandard16, hcl.Range{}, nil
+}
+
+func ansiProfileList() string {
+	names := make([]string, len(ANSIProfiles))
+	for i, p := range ANSIProfiles {
+		names[i] = string(p)
+	}
+	return strings.Join(names, ", ")
+}
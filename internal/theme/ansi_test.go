@@ -0,0 +1,58 @@
+package theme
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+func TestANSIProfileSetting(t *testing.T) {
+	tests := []struct {
+		src      string
+		want     ANSIProfile
+		required int
+		wantErr  string
+	}{
+		{"", ANSIStandard16, 16, ""},
+		{"settings {\n  lint {}\n}\n", ANSIStandard16, 16, ""},
+		{"settings {\n  ansi_profile = \"basic8\"\n}\n", ANSIBasic8, 8, ""},
+		{"settings {\n  ansi_profile = \"standard16\"\n}\n", ANSIStandard16, 16, ""},
+		{"settings {\n  ansi_profile = \"extended\"\n}\n", ANSIExtended, 19, ""},
+		{"settings {\n  ansi_profile = \"256\"\n}\n", "", 0, "ansi_profile must be one of: basic8, standard16, extended"},
+		{"settings {\n  ansi_profile = 8\n}\n", "", 0, "ansi_profile must be one of"},
+	}
+	for _, tt := range tests {
+		file, diags := hclsyntax.ParseConfig([]byte(tt.src), "test.pstheme", hcl.InitialPos)
+		if diags.HasErrors() {
+			t.Fatal(diags.Error())
+		}
+		got, rng, err := ANSIProfileSetting(file.Body.(*hclsyntax.Body))
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: error = %v, want containing %q", tt.src, err, tt.wantErr)
+			}
+			if KindOf(err) != KindConfig || rng.Start.Line != 2 {
+				t.Errorf("%q: kind %v at line %d, want config error at line 2", tt.src, KindOf(err), rng.Start.Line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if got != tt.want || len(got.Required()) != tt.required {
+			t.Errorf("%q: profile %q requiring %d colors, want %q requiring %d", tt.src, got, len(got.Required()), tt.want, tt.required)
+		}
+	}
+}
+
+func TestANSIColorNames(t *testing.T) {
+	if len(ANSIColorNames) != 21 || ANSIColorNames[15] != "bright_white" || ANSIColorNames[16] != "foreground" {
+		t.Errorf("ANSIColorNames = %v, want the 16 terminal colors followed by the extras", ANSIColorNames)
+	}
+	if got := strings.Join(ANSIExtended.Required()[16:], " "); got != "foreground background cursor" {
+		t.Errorf("extended requires %s beyond the terminal colors, want foreground background cursor", got)
+	}
+}
@@ -0,0 +1,67 @@
+package theme
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies a failure so callers, such as the CLI choosing an exit
+// code, can branch on it without matching error messages.
+type Kind int
+
+const (
+	KindConfig     Kind = iota + 1 // the theme file is malformed or cannot be evaluated
+	KindValidation                 // the theme evaluates but breaks a rule, e.g. missing ANSI colors
+	KindTemplate                   // a template failed to parse or execute
+	KindIO                         // reading or writing a file failed
+)
+
+// String returns the lowercase name of the kind.
+func (k Kind) String() string {
+	switch k {
+	case KindConfig:
+		return "config"
+	case KindValidation:
+		return "validation"
+	case KindTemplate:
+		return "template"
+	case KindIO:
+		return "io"
+	default:
+		return "unknown"
+	}
+}
+
+// Error attaches a Kind to an underlying error. Its message is that of Err.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Errorf is like fmt.Errorf but returns an *Error of the given kind.
+func Errorf(kind Kind, format string, args ...any) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// KindOf returns the Kind of the first *Error in err's chain, or 0 if there
+// is none.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return 0
+}
+
+// WithKind returns err unchanged if it already carries a Kind, and otherwise
+// wraps it in an *Error of the given kind. A nil err stays nil.
+func WithKind(err error, kind Kind) error {
+	if err == nil || KindOf(err) != 0 {
+		return err
+	}
+	return &Error{Kind: kind, Err: err}
+}
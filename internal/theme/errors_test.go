@@ -0,0 +1,38 @@
+package theme
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	base := errors.New("boom")
+	tests := []struct {
+		name string
+		err  error
+		want Kind
+	}{
+		{"nil", nil, 0},
+		{"untyped", base, 0},
+		{"typed", Errorf(KindIO, "reading: %w", base), KindIO},
+		{"wrapped", fmt.Errorf("loading: %w", Errorf(KindTemplate, "bad")), KindTemplate},
+		{"with kind keeps existing", WithKind(Errorf(KindValidation, "bad"), KindConfig), KindValidation},
+		{"with kind defaults", WithKind(base, KindConfig), KindConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KindOf(tt.err); got != tt.want {
+				t.Errorf("KindOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if WithKind(nil, KindConfig) != nil {
+		t.Error("WithKind(nil) should be nil")
+	}
+	if err := Errorf(KindIO, "reading: %w", base); !errors.Is(err, base) || err.Error() != "reading: boom" {
+		t.Errorf("Errorf() = %v, should wrap %v", err, base)
+	}
+}
@@ -0,0 +1,72 @@
+package theme
+
+import "github.com/hashicorp/hcl/v2/hclsyntax"
+
+// Limits bounds the work done when loading or analyzing a theme file, so that
+// giant or adversarial input produces a clear error instead of a hang or OOM.
+// A zero field disables that limit. Exceeding a limit is a KindValidation error.
+type Limits struct {
+	MaxFileSize       int64 // maximum file size in bytes
+	MaxPaletteEntries int   // maximum palette attributes and blocks, counted recursively
+	MaxTransformSteps int   // maximum lightness transform steps and scale steps
+}
+
+// DefaultLimits are generous enough for any hand-written theme.
+var DefaultLimits = Limits{
+	MaxFileSize:       1 << 20, // 1 MiB
+	MaxPaletteEntries: 5000,
+	MaxTransformSteps: 100,
+}
+
+// CheckFileSize returns an error if size exceeds MaxFileSize.
+func (l Limits) CheckFileSize(size int64) error {
+	if l.MaxFileSize > 0 && size > l.MaxFileSize {
+		return Errorf(KindValidation, "file is %d bytes, exceeding the limit of %d bytes", size, l.MaxFileSize)
+	}
+	return nil
+}
+
+// CheckPaletteEntries returns an error if the palette body holds more than
+// MaxPaletteEntries attributes and nested blocks. The transform block is not counted.
+func (l Limits) CheckPaletteEntries(body *hclsyntax.Body) error {
+	if l.MaxPaletteEntries <= 0 {
+		return nil
+	}
+	if n := countEntries(body, l.MaxPaletteEntries); n > l.MaxPaletteEntries {
+		return Errorf(KindValidation, "palette has more than %d entries", l.MaxPaletteEntries)
+	}
+	return nil
+}
+
+// CheckTransformSteps returns an error if steps exceeds MaxTransformSteps.
+func (l Limits) CheckTransformSteps(steps int) error {
+	if l.MaxTransformSteps > 0 && steps > l.MaxTransformSteps {
+		return Errorf(KindValidation, "lightness steps is %d, exceeding the limit of %d", steps, l.MaxTransformSteps)
+	}
+	return nil
+}
+
+// CheckScaleSteps returns an error if a scale block's steps exceeds MaxTransformSteps.
+func (l Limits) CheckScaleSteps(steps int) error {
+	if l.MaxTransformSteps > 0 && steps > l.MaxTransformSteps {
+		return Errorf(KindValidation, "scale steps is %d, exceeding the limit of %d", steps, l.MaxTransformSteps)
+	}
+	return nil
+}
+
+// countEntries counts attributes and blocks in body recursively, stopping
+// early once the count passes max.
+func countEntries(body *hclsyntax.Body, max int) int {
+	n := len(body.Attributes)
+	for _, block := range body.Blocks {
+		if block.Type == "transform" {
+			continue
+		}
+		n++
+		if n > max {
+			return n
+		}
+		n += countEntries(block.Body, max-n)
+	}
+	return n
+}
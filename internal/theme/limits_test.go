@@ -0,0 +1,70 @@
+package theme
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+func TestLimits_CheckPaletteEntries(t *testing.T) {
+	src := `
+palette {
+  a = "#000000"
+  b = "#111111"
+  group {
+    c = "#222222"
+  }
+  transform {
+    lightness {
+      range = [0.1, 0.9]
+      steps = 3
+    }
+  }
+}
+`
+	file, diags := hclsyntax.ParseConfig([]byte(src), "test.pstheme", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatalf("parse error: %s", diags.Error())
+	}
+	body := file.Body.(*hclsyntax.Body).Blocks[0].Body
+
+	tests := []struct {
+		name    string
+		max     int
+		wantErr bool
+	}{
+		{"unlimited", 0, false},
+		{"exact", 4, false},
+		{"exceeded", 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Limits{MaxPaletteEntries: tt.max}.CheckPaletteEntries(body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckPaletteEntries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLimits_CheckFileSizeAndSteps(t *testing.T) {
+	l := Limits{MaxFileSize: 10, MaxTransformSteps: 5}
+
+	if err := l.CheckFileSize(10); err != nil {
+		t.Errorf("CheckFileSize(10) error = %v", err)
+	}
+	if err := l.CheckFileSize(11); err == nil {
+		t.Error("CheckFileSize(11) expected error")
+	}
+	if err := l.CheckTransformSteps(6); err == nil {
+		t.Error("CheckTransformSteps(6) expected error")
+	}
+	if err := l.CheckScaleSteps(6); err == nil {
+		t.Error("CheckScaleSteps(6) expected error")
+	}
+	if err := (Limits{}).CheckFileSize(1 << 40); err != nil {
+		t.Errorf("zero limit should disable check, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package theme
+
+import (
+	"math"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// SchemaVersionAttr is the settings attribute pinning the template data
+// contract version.
+const SchemaVersionAttr = "schema_version"
+
+// SchemaVersionSetting returns the schema_version of the settings block in
+// body, or 0 if there is none. The range is that of the attribute's value,
+// or the empty range when it is absent. A value that is not a positive
+// whole number is a KindConfig error; whether the version is supported is
+// left to the template engine.
+func SchemaVersionSetting(body *hclsyntax.Body) (int, hcl.Range, error) {
+	for _, block := range body.Blocks {
+		if block.Type != "settings" {
+			continue
+		}
+		attr, ok := block.Body.Attributes[SchemaVersionAttr]
+		if !ok {
+			continue
+		}
+		rng := attr.Expr.Range()
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || val.IsNull() || !val.IsKnown() || val.Type() != cty.Number {
+			return 0, rng, Errorf(KindConfig, "%s must be a positive whole number, such as 1", SchemaVersionAttr)
+		}
+		v, acc := val.AsBigFloat().Int64()
+		if acc != 0 || v < 1 || v > math.MaxInt32 {
+			return 0, rng, Errorf(KindConfig, "%s must be a positive whole number, such as 1", SchemaVersionAttr)
+		}
+		return int(v), rng, nil
+	}
+	return 0, hcl.Range{}, nil
+}
@@ -0,0 +1,44 @@
+package theme
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+func TestSchemaVersionSetting(t *testing.T) {
+	tests := []struct {
+		src     string
+		want    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"settings {\n  lint {}\n}\n", 0, false},
+		{"settings {\n  schema_version = 1\n}\n", 1, false},
+		{"settings {\n  schema_version = 2\n}\n", 2, false},
+		{"settings {\n  schema_version = 0\n}\n", 0, true},
+		{"settings {\n  schema_version = 1.5\n}\n", 0, true},
+		{"settings {\n  schema_version = \"1\"\n}\n", 0, true},
+	}
+	for _, tt := range tests {
+		file, diags := hclsyntax.ParseConfig([]byte(tt.src), "test.pstheme", hcl.InitialPos)
+		if diags.HasErrors() {
+			t.Fatal(diags.Error())
+		}
+		got, rng, err := SchemaVersionSetting(file.Body.(*hclsyntax.Body))
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "schema_version must be a positive whole number") {
+				t.Errorf("%q: error = %v, want a positive whole number error", tt.src, err)
+			}
+			if KindOf(err) != KindConfig || rng.Start.Line != 2 {
+				t.Errorf("%q: kind %v at line %d, want config error at line 2", tt.src, KindOf(err), rng.Start.Line)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q: SchemaVersionSetting() = %d, %v, want %d", tt.src, got, err, tt.want)
+		}
+	}
+}
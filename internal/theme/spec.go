@@ -0,0 +1,169 @@
+package theme
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// SpecVersionAttr is the meta attribute selecting the theme language version.
+const SpecVersionAttr = "spec_version"
+
+// LatestSpecVersion is the newest theme language version. Files without a
+// spec_version are version 1.
+const LatestSpecVersion = 2
+
+// SpecVersions lists the theme language versions the loader understands.
+var SpecVersions = []int{1, 2}
+
+// SpecVersion returns the spec_version declared in the meta block of body,
+// or 1 if there is none. The range is that of the attribute's value, or the
+// empty range when it is absent. An invalid or unsupported value is a
+// KindConfig error.
+func SpecVersion(body *hclsyntax.Body) (int, hcl.Range, error) {
+	for _, block := range body.Blocks {
+		if block.Type != "meta" {
+			continue
+		}
+		attr, ok := block.Body.Attributes[SpecVersionAttr]
+		if !ok {
+			continue
+		}
+		rng := attr.Expr.Range()
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || val.IsNull() || !val.IsKnown() || val.Type() != cty.Number || !val.AsBigFloat().IsInt() {
+			return 0, rng, Errorf(KindConfig, "%s must be a whole number (supported: %s)", SpecVersionAttr, specVersionList())
+		}
+		v, _ := val.AsBigFloat().Int64()
+		if !slices.Contains(SpecVersions, int(v)) {
+			return 0, rng, Errorf(KindConfig, "unsupported %s %d (supported: %s)", SpecVersionAttr, v, specVersionList())
+		}
+		return int(v), rng, nil
+	}
+	return 1, hcl.Range{}, nil
+}
+
+func specVersionList() string {
+	names := make([]string, len(SpecVersions))
+	for i, v := range SpecVersions {
+		names[i] = strconv.Itoa(v)
+	}
+	return strings.Join(names, ", ")
+}
+
+// SpecIssue is a construct that a later spec version no longer accepts.
+// In files declaring that version or later it is an error; in older files
+// it is reported as a migration hint.
+type SpecIssue struct {
+	Range   hcl.Range
+	Removed int    // the spec version that no longer accepts the construct
+	Message string // what changed, e.g. `meta.appearance "dim" is no longer valid in v2`
+	Hint    string // how to migrate
+}
+
+// String formats the issue as "message; hint".
+func (i SpecIssue) String() string {
+	return i.Message + "; " + i.Hint
+}
+
+// CheckSpec returns the constructs in body that some spec version after 1
+// no longer accepts, in source order. Callers compare each issue's Removed
+// version against the file's SpecVersion.
+func CheckSpec(body *hclsyntax.Body) []SpecIssue {
+	var issues []SpecIssue
+	for _, block := range body.Blocks {
+		switch block.Type {
+		case "meta":
+			issues = append(issues, checkAppearance(block.Body)...)
+		case "palette":
+			issues = append(issues, checkScaleGroups(block.Body, "palette")...)
+		}
+	}
+	_ = hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+		if st, ok := node.(*hclsyntax.ScopeTraversalExpr); ok {
+			if issue, ok := checkColorKeyReference(st.Traversal); ok {
+				issues = append(issues, issue)
+			}
+		}
+		return nil
+	})
+	slices.SortStableFunc(issues, func(a, b SpecIssue) int {
+		return a.Range.Start.Byte - b.Range.Start.Byte
+	})
+	return issues
+}
+
+// checkAppearance reports a meta appearance other than "dark" or "light".
+func checkAppearance(meta *hclsyntax.Body) []SpecIssue {
+	attr, ok := meta.Attributes["appearance"]
+	if !ok {
+		return nil
+	}
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || val.IsNull() || !val.IsKnown() || val.Type() != cty.String {
+		return nil
+	}
+	if s := val.AsString(); s != "dark" && s != "light" {
+		return []SpecIssue{{
+			Range:   attr.Expr.Range(),
+			Removed: 2,
+			Message: fmt.Sprintf("meta.appearance %q is no longer valid in v2", s),
+			Hint:    `use "dark" or "light"`,
+		}}
+	}
+	return nil
+}
+
+// checkScaleGroups reports unlabeled palette blocks named scale, which v2
+// reserves for scale blocks.
+func checkScaleGroups(body *hclsyntax.Body, prefix string) []SpecIssue {
+	var issues []SpecIssue
+	for _, block := range body.Blocks {
+		if block.Type == "transform" || len(block.Labels) > 0 {
+			continue
+		}
+		path := prefix + "." + block.Type
+		if block.Type == "scale" {
+			issues = append(issues, SpecIssue{
+				Range:   block.TypeRange,
+				Removed: 2,
+				Message: fmt.Sprintf("%s: a palette group named scale is no longer valid in v2", path),
+				Hint:    "rename the group; the name is reserved for scale blocks",
+			})
+		}
+		issues = append(issues, checkScaleGroups(block.Body, path)...)
+	}
+	return issues
+}
+
+// checkColorKeyReference reports a palette reference ending in the implicit
+// color key, such as palette.highlight.color.
+func checkColorKeyReference(t hcl.Traversal) (SpecIssue, bool) {
+	if len(t) < 3 || t.RootName() != "palette" {
+		return SpecIssue{}, false
+	}
+	last, ok := t[len(t)-1].(hcl.TraverseAttr)
+	if !ok || last.Name != color.ColorKey {
+		return SpecIssue{}, false
+	}
+	path := "palette"
+	for _, step := range t[1 : len(t)-1] {
+		attr, ok := step.(hcl.TraverseAttr)
+		if !ok {
+			return SpecIssue{}, false
+		}
+		path += "." + attr.Name
+	}
+	return SpecIssue{
+		Range:   last.SrcRange,
+		Removed: 2,
+		Message: fmt.Sprintf("%s.%s: the color key is implicit, and referencing it is no longer valid in v2", path, color.ColorKey),
+		Hint:    "use " + path,
+	}, true
+}
@@ -0,0 +1,106 @@
+package theme
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+func parseBody(t *testing.T, src string) *hclsyntax.Body {
+	t.Helper()
+	file, diags := hclsyntax.ParseConfig([]byte(src), "test.pstheme", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatalf("parse error: %s", diags.Error())
+	}
+	return file.Body.(*hclsyntax.Body)
+}
+
+func TestSpecVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		want     int
+		wantKind Kind
+	}{
+		{"absent", `meta { name = "x" }`, 1, 0},
+		{"no meta", `palette { a = "#000000" }`, 1, 0},
+		{"v1", `meta { spec_version = 1 }`, 1, 0},
+		{"v2", `meta { spec_version = 2 }`, 2, 0},
+		{"unsupported", `meta { spec_version = 3 }`, 0, KindConfig},
+		{"fraction", `meta { spec_version = 1.5 }`, 0, KindConfig},
+		{"string", `meta { spec_version = "2" }`, 0, KindConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := SpecVersion(parseBody(t, tt.src))
+			if tt.wantKind != 0 {
+				if KindOf(err) != tt.wantKind {
+					t.Fatalf("SpecVersion() error = %v, want kind %s", err, tt.wantKind)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SpecVersion() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SpecVersion() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSpec(t *testing.T) {
+	body := parseBody(t, `
+meta {
+  appearance = "dim"
+}
+
+palette {
+  highlight {
+    color = "#ff0000"
+    low   = "#110000"
+  }
+  scale {
+    a = "#000000"
+  }
+  scale "ramp" {
+    from  = "#000000"
+    to    = "#ffffff"
+    steps = 3
+  }
+}
+
+theme {
+  cursor     = palette.highlight.color
+  background = palette.highlight.low
+}
+`)
+
+	issues := CheckSpec(body)
+	want := []struct {
+		line    int
+		message string
+	}{
+		{3, `meta.appearance "dim"`},
+		{11, "palette.scale: a palette group named scale"},
+		{22, "palette.highlight.color: the color key is implicit"},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("CheckSpec() returned %d issues, want %d: %v", len(issues), len(want), issues)
+	}
+	for i, w := range want {
+		if issues[i].Range.Start.Line != w.line || !strings.Contains(issues[i].Message, w.message) {
+			t.Errorf("issue %d = line %d %q, want line %d containing %q",
+				i, issues[i].Range.Start.Line, issues[i].Message, w.line, w.message)
+		}
+		if issues[i].Removed != 2 {
+			t.Errorf("issue %d Removed = %d, want 2", i, issues[i].Removed)
+		}
+	}
+	if got := issues[2].Hint; got != "use palette.highlight" {
+		t.Errorf("hint = %q, want %q", got, "use palette.highlight")
+	}
+}
@@ -2,9 +2,13 @@ package theme
 
 import (
 	"fmt"
+	"math"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/jsvensson/paletteswap/internal/color"
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/function"
@@ -18,23 +22,143 @@ var RequiredANSIColors = []string{
 	"bright_blue", "bright_magenta", "bright_cyan", "bright_white",
 }
 
+// UniqueBlocks lists the top-level blocks that may appear at most once in a theme file.
+var UniqueBlocks = []string{"meta", "palette", "locals", "theme", "ansi", "semantic", "settings"}
+
+// BlockOrder is the order in which top-level blocks are evaluated. A block may
+// reference blocks evaluated before it, and palette, locals, theme and syntax
+// may also reference their own earlier entries.
+var BlockOrder = []string{"palette", "locals", "theme", "ansi", "syntax", "semantic"}
+
+// selfReferencing lists the blocks whose entries may reference earlier
+// entries of the same block.
+var selfReferencing = []string{"palette", "locals", "theme", "syntax"}
+
+// blockVariables maps evaluation context variable names to the block defining them.
+var blockVariables = map[string]string{
+	"palette":  "palette",
+	"local":    "locals",
+	"theme":    "theme",
+	"ansi":     "ansi",
+	"syntax":   "syntax",
+	"semantic": "semantic",
+}
+
+// CheckReferenceOrder returns an error if expr, found in the named block,
+// references a block evaluated after it, or its own block when that block
+// is not self-referencing. The returned range is that of the offending
+// reference. Blocks not listed in BlockOrder are not checked.
+func CheckReferenceOrder(block string, expr hcl.Expression) (hcl.Range, error) {
+	pos := slices.Index(BlockOrder, block)
+	if pos < 0 {
+		return hcl.Range{}, nil
+	}
+	for _, traversal := range expr.Variables() {
+		target, ok := blockVariables[traversal.RootName()]
+		if !ok {
+			continue
+		}
+		switch i := slices.Index(BlockOrder, target); {
+		case i > pos:
+			return traversal.SourceRange(), Errorf(KindValidation, "%s cannot reference %s: blocks may only reference blocks evaluated before them (%s)",
+				block, traversal.RootName(), strings.Join(BlockOrder, ", "))
+		case i == pos && !slices.Contains(selfReferencing, block):
+			return traversal.SourceRange(), Errorf(KindValidation, "%s entries cannot reference other %s entries", block, block)
+		}
+	}
+	return hcl.Range{}, nil
+}
+
+// ThemeSubBlocks lists the typed sub-blocks allowed in the theme block and
+// the attributes each one requires.
+var ThemeSubBlocks = map[string][]string{
+	"cursor":    {"cursor", "text"},
+	"selection": {"background", "foreground"},
+}
+
+// SemanticTokenTypes lists the standard LSP semantic token types that may be
+// styled in the semantic block.
+var SemanticTokenTypes = []string{
+	"namespace", "type", "class", "enum", "interface", "struct",
+	"typeParameter", "parameter", "variable", "property", "enumMember",
+	"event", "function", "method", "macro", "keyword", "modifier",
+	"comment", "string", "number", "regexp", "operator", "decorator",
+}
+
 // ResolveColor extracts a color hex string from a cty.Value.
-// If the value is a string, return it directly.
+// If the value is a string, return it with surrounding whitespace, such as
+// the trailing newline of a heredoc, removed.
+// If the value is an [r, g, b] tuple, return it as a hex string.
 // If the value is an object, extract the "color" key.
 func ResolveColor(val cty.Value) (string, error) {
+	if val.IsNull() || !val.IsWhollyKnown() {
+		return "", fmt.Errorf("expected a color, got null")
+	}
 	if val.Type() == cty.String {
-		return val.AsString(), nil
+		return strings.TrimSpace(val.AsString()), nil
+	}
+	if val.Type().IsTupleType() || val.Type().IsListType() {
+		return resolveRGBTuple(val)
 	}
 	if val.Type().IsObjectType() {
-		if val.Type().HasAttribute("color") {
-			colorVal := val.GetAttr("color")
-			if colorVal.Type() == cty.String {
+		if val.Type().HasAttribute(color.ColorKey) {
+			colorVal := val.GetAttr(color.ColorKey)
+			if colorVal.Type() == cty.String && !colorVal.IsNull() {
 				return colorVal.AsString(), nil
 			}
 		}
 		return "", fmt.Errorf("object has no 'color' attribute; reference a specific child or add a color attribute")
 	}
-	return "", fmt.Errorf("expected string or object with color attribute, got %s", val.Type().FriendlyName())
+	return "", fmt.Errorf("expected string, RGB tuple or object with color attribute, got %s", val.Type().FriendlyName())
+}
+
+// resolveRGBTuple converts an [r, g, b] tuple of whole numbers from 0 to
+// 255 to a hex string.
+func resolveRGBTuple(val cty.Value) (string, error) {
+	if n := val.LengthInt(); n != 3 {
+		return "", fmt.Errorf("RGB tuple must have 3 elements, got %d", n)
+	}
+	var rgb [3]uint8
+	for i, v := range val.AsValueSlice() {
+		if v.Type() != cty.Number {
+			return "", fmt.Errorf("RGB tuple element %d must be a number, got %s", i+1, v.Type().FriendlyName())
+		}
+		f := v.AsBigFloat()
+		n, _ := f.Int64()
+		if !f.IsInt() || n < 0 || n > 255 {
+			return "", fmt.Errorf("RGB tuple element %d must be a whole number from 0 to 255, got %s", i+1, f.Text('g', -1))
+		}
+		rgb[i] = uint8(n)
+	}
+	return color.Color{R: rgb[0], G: rgb[1], B: rgb[2]}.Hex(), nil
+}
+
+// ResolveColorExpr is like ResolveColor, for the value val of expr. When
+// expr builds a string by interpolation, such as "#${local.hue}1724", the
+// string it produced is validated here, so that a bad result is reported
+// as such rather than as a hex digit error the source does not show.
+func ResolveColorExpr(expr hcl.Expression, val cty.Value) (string, error) {
+	s, err := ResolveColor(val)
+	if err != nil || !isInterpolated(expr) {
+		return s, err
+	}
+	if _, err := color.ParseHex(s); err != nil {
+		return "", fmt.Errorf("interpolation produced %q, which is not a hex color (#rrggbb)", s)
+	}
+	return s, nil
+}
+
+// isInterpolated reports whether expr is a string template containing an
+// interpolation or directive.
+func isInterpolated(expr hcl.Expression) bool {
+	switch e := expr.(type) {
+	case *hclsyntax.TemplateExpr:
+		return !e.IsStringLiteral()
+	case *hclsyntax.TemplateWrapExpr:
+		return true
+	default:
+		return false
+	}
 }
 
 // NodeToCty converts a color.Node to a cty.Value for HCL evaluation context.
@@ -54,7 +178,7 @@ func NodeToCty(node *color.Node) cty.Value {
 
 	// Add the block's own color as "color" key
 	if node.Color != nil {
-		vals["color"] = cty.StringVal(node.Color.Hex())
+		vals[color.ColorKey] = cty.StringVal(node.Color.Hex())
 	}
 
 	// Add children
@@ -74,75 +198,323 @@ func NodeToCty(node *color.Node) cty.Value {
 // MakeBrightenFunc creates an HCL function that brightens a color.
 // Usage: brighten("#hex", 0.1) or brighten(palette.color, 0.1)
 func MakeBrightenFunc() function.Function {
+	return makeAdjustFunc("Brightens a color by the given percentage (-1.0 to 1.0)", "percentage", -1, "", color.Brighten)
+}
+
+// MakeDarkenFunc creates an HCL function that darkens a color.
+// Usage: darken("#hex", 0.1) or darken(palette.color, 0.1)
+func MakeDarkenFunc() function.Function {
+	return makeAdjustFunc("Darkens a color by the given percentage (0.0 to 1.0)", "percentage", 0, "use brighten to lighten", color.Darken)
+}
+
+// MakeLightenOKFunc creates an HCL function that adds to the OKLCH lightness
+// of a color. Usage: lighten_ok(palette.love, 0.1)
+func MakeLightenOKFunc() function.Function {
+	return makeAdjustFunc("Lightens a color by the given amount of OKLCH lightness (-1.0 to 1.0), keeping its hue", "amount", -1, "", color.LightenOK)
+}
+
+// MakeDarkenOKFunc creates an HCL function that subtracts from the OKLCH
+// lightness of a color. Usage: darken_ok(palette.love, 0.1)
+func MakeDarkenOKFunc() function.Function {
+	return makeAdjustFunc("Darkens a color by the given amount of OKLCH lightness (0.0 to 1.0), keeping its hue", "amount", 0, "use lighten_ok to lighten", color.DarkenOK)
+}
+
+// MakeSaturateFunc creates an HCL function that saturates a color.
+// Usage: saturate("#hex", 0.1) or saturate(palette.color, 0.1)
+func MakeSaturateFunc() function.Function {
+	return makeAdjustFunc("Saturates a color by the given amount (-1.0 to 1.0)", "amount", -1, "", color.Saturate)
+}
+
+// MakeDesaturateFunc creates an HCL function that desaturates a color.
+// Usage: desaturate("#hex", 0.1) or desaturate(palette.color, 0.1)
+func MakeDesaturateFunc() function.Function {
+	return makeAdjustFunc("Desaturates a color by the given amount (0.0 to 1.0)", "amount", 0, "use saturate to saturate", color.Desaturate)
+}
+
+// makeAdjustFunc creates an HCL function taking a color and a number named
+// param, from min to 1, and returning the color adjusted by it. hint, if
+// set, is added to the error for a number out of range.
+func makeAdjustFunc(description, param string, min float64, hint string, adjust func(color.Color, float64) color.Color) function.Function {
 	return function.New(&function.Spec{
-		Description: "Brightens a color by the given percentage (-1.0 to 1.0)",
+		Description: description,
 		Params: []function.Parameter{
 			{
 				Name: "color",
 				Type: cty.String,
 			},
 			{
-				Name: "percentage",
+				Name: param,
 				Type: cty.Number,
 			},
 		},
 		Type: function.StaticReturnType(cty.String),
 		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
 			colorHex := args[0].AsString()
-			pct, _ := args[1].AsBigFloat().Float64()
+			amount, _ := args[1].AsBigFloat().Float64()
+			if math.IsInf(amount, 0) || amount < min || amount > 1 {
+				msg := fmt.Sprintf("%s must be between %g and 1, got %s", param, min, formatNumber(args[1]))
+				if hint != "" {
+					msg += "; " + hint
+				}
+				return cty.NilVal, function.NewArgErrorf(1, "%s", msg)
+			}
 
 			c, err := color.ParseHex(colorHex)
 			if err != nil {
 				return cty.NilVal, err
 			}
 
-			brightened := color.Brighten(c, pct)
-			return cty.StringVal(brightened.Hex()), nil
+			return cty.StringVal(adjust(c, amount).Hex()), nil
 		},
 	})
 }
 
-// MakeDarkenFunc creates an HCL function that darkens a color.
-// Usage: darken("#hex", 0.1) or darken(palette.color, 0.1)
-func MakeDarkenFunc() function.Function {
+// MakeShadeFunc creates an HCL function that mixes a color toward black.
+// Usage: shade(palette.love, 0.2)
+func MakeShadeFunc() function.Function {
+	return makeAdjustFunc("Mixes a color toward black by the given amount (0.0 to 1.0)", "amount", 0, "use tint to lighten", color.Shade)
+}
+
+// MakeTintFunc creates an HCL function that mixes a color toward white.
+// Usage: tint(palette.love, 0.2)
+func MakeTintFunc() function.Function {
+	return makeAdjustFunc("Mixes a color toward white by the given amount (0.0 to 1.0)", "amount", 0, "use shade to darken", color.Tint)
+}
+
+// MakeRotateHueFunc creates an HCL function that rotates the OKLCH hue of a
+// color. Usage: rotate_hue(palette.love, 120)
+func MakeRotateHueFunc() function.Function {
 	return function.New(&function.Spec{
-		Description: "Darkens a color by the given percentage (0.0 to 1.0)",
+		Description: "Rotates the hue of a color by the given degrees in OKLCH, keeping its lightness",
 		Params: []function.Parameter{
 			{
 				Name: "color",
 				Type: cty.String,
 			},
 			{
-				Name: "percentage",
+				Name: "degrees",
 				Type: cty.Number,
 			},
 		},
 		Type: function.StaticReturnType(cty.String),
 		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
-			colorHex := args[0].AsString()
-			pct, _ := args[1].AsBigFloat().Float64()
+			degrees, _ := args[1].AsBigFloat().Float64()
+			if math.IsInf(degrees, 0) {
+				return cty.NilVal, function.NewArgErrorf(1, "degrees must be finite, got %s", formatNumber(args[1]))
+			}
 
-			c, err := color.ParseHex(colorHex)
+			c, err := color.ParseHex(args[0].AsString())
 			if err != nil {
 				return cty.NilVal, err
 			}
 
-			darkened := color.Darken(c, pct)
-			return cty.StringVal(darkened.Hex()), nil
+			return cty.StringVal(color.RotateHue(c, degrees).Hex()), nil
 		},
 	})
 }
 
+// MakeComplementFunc creates an HCL function that returns the complement of
+// a color. Usage: complement(palette.base)
+func MakeComplementFunc() function.Function {
+	return makeColorFunc("Returns the complement of a color, its OKLCH hue rotated by 180 degrees", color.Complement)
+}
+
+// MakeInvertFunc creates an HCL function that inverts a color in RGB.
+// Usage: invert(palette.base)
+func MakeInvertFunc() function.Function {
+	return makeColorFunc("Inverts a color, subtracting each RGB channel from 255", color.Invert)
+}
+
+// makeColorFunc creates an HCL function taking a color and returning it
+// transformed by fn.
+func makeColorFunc(description string, fn func(color.Color) color.Color) function.Function {
+	return function.New(&function.Spec{
+		Description: description,
+		Params: []function.Parameter{
+			{
+				Name: "color",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			c, err := color.ParseHex(args[0].AsString())
+			if err != nil {
+				return cty.NilVal, function.NewArgError(0, err)
+			}
+			return cty.StringVal(fn(c).Hex()), nil
+		},
+	})
+}
+
+// MakeGrayFunc creates an HCL function that returns a neutral gray of the
+// given OKLCH lightness. Usage: gray(0.2)
+func MakeGrayFunc() function.Function {
+	return function.New(&function.Spec{
+		Description: "Returns a neutral gray of the given OKLCH lightness (0.0 for black to 1.0 for white)",
+		Params: []function.Parameter{
+			{
+				Name: "lightness",
+				Type: cty.Number,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			lightness, _ := args[0].AsBigFloat().Float64()
+			if math.IsInf(lightness, 0) || lightness < 0 || lightness > 1 {
+				return cty.NilVal, function.NewArgErrorf(0, "lightness must be between 0 and 1, got %s", formatNumber(args[0]))
+			}
+			return cty.StringVal(color.Gray(lightness).Hex()), nil
+		},
+	})
+}
+
+// MakeHSLFunc creates an HCL function that returns the color with the given
+// HSL hue in degrees and saturation and lightness from 0 to 1.
+// Usage: hsl(343, 0.76, 0.68)
+func MakeHSLFunc() function.Function {
+	return function.New(&function.Spec{
+		Description: "Returns the color with the given HSL hue (degrees), saturation and lightness (0.0 to 1.0)",
+		Params: []function.Parameter{
+			{
+				Name: "hue",
+				Type: cty.Number,
+			},
+			{
+				Name: "saturation",
+				Type: cty.Number,
+			},
+			{
+				Name: "lightness",
+				Type: cty.Number,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			hue, _ := args[0].AsBigFloat().Float64()
+			if math.IsInf(hue, 0) {
+				return cty.NilVal, function.NewArgErrorf(0, "hue must be finite, got %s", formatNumber(args[0]))
+			}
+			saturation, _ := args[1].AsBigFloat().Float64()
+			if math.IsInf(saturation, 0) || saturation < 0 || saturation > 1 {
+				return cty.NilVal, function.NewArgErrorf(1, "saturation must be between 0 and 1, got %s", formatNumber(args[1]))
+			}
+			lightness, _ := args[2].AsBigFloat().Float64()
+			if math.IsInf(lightness, 0) || lightness < 0 || lightness > 1 {
+				return cty.NilVal, function.NewArgErrorf(2, "lightness must be between 0 and 1, got %s", formatNumber(args[2]))
+			}
+			return cty.StringVal(color.HSL(hue, saturation, lightness).Hex()), nil
+		},
+	})
+}
+
+// MakeRGBFunc creates an HCL function that returns the color with the given
+// red, green and blue channels, whole numbers from 0 to 255, like an
+// [r, g, b] tuple. Usage: rgb(235, 111, 146)
+func MakeRGBFunc() function.Function {
+	return function.New(&function.Spec{
+		Description: "Returns the color with the given red, green and blue channels (0 to 255)",
+		Params: []function.Parameter{
+			{
+				Name: "red",
+				Type: cty.Number,
+			},
+			{
+				Name: "green",
+				Type: cty.Number,
+			},
+			{
+				Name: "blue",
+				Type: cty.Number,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			var rgb [3]uint8
+			for i, name := range []string{"red", "green", "blue"} {
+				f := args[i].AsBigFloat()
+				n, _ := f.Int64()
+				if !f.IsInt() || n < 0 || n > 255 {
+					return cty.NilVal, function.NewArgErrorf(i, "%s must be a whole number from 0 to 255, got %s", name, formatNumber(args[i]))
+				}
+				rgb[i] = uint8(n)
+			}
+			return cty.StringVal(color.Color{R: rgb[0], G: rgb[1], B: rgb[2]}.Hex()), nil
+		},
+	})
+}
+
+// MakeMixFunc creates an HCL function that blends two colors.
+// Usage: mix(palette.love, palette.base, 0.3), where the weight is the
+// proportion of the second color.
+func MakeMixFunc() function.Function {
+	return function.New(&function.Spec{
+		Description: "Blends two colors; the weight (0.0 to 1.0) is the proportion of the second color",
+		Params: []function.Parameter{
+			{
+				Name: "color1",
+				Type: cty.String,
+			},
+			{
+				Name: "color2",
+				Type: cty.String,
+			},
+			{
+				Name: "weight",
+				Type: cty.Number,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			weight, _ := args[2].AsBigFloat().Float64()
+			if math.IsInf(weight, 0) || weight < 0 || weight > 1 {
+				return cty.NilVal, function.NewArgErrorf(2, "weight must be between 0 and 1, got %s", formatNumber(args[2]))
+			}
+
+			a, err := color.ParseHex(args[0].AsString())
+			if err != nil {
+				return cty.NilVal, function.NewArgError(0, err)
+			}
+			b, err := color.ParseHex(args[1].AsString())
+			if err != nil {
+				return cty.NilVal, function.NewArgError(1, err)
+			}
+
+			return cty.StringVal(color.Mix(a, b, weight).Hex()), nil
+		},
+	})
+}
+
+// formatNumber formats a number value the way it is written in a theme.
+func formatNumber(v cty.Value) string {
+	return v.AsBigFloat().Text('g', -1)
+}
+
 // BuildEvalContext creates an HCL evaluation context with palette variables
-// and brighten/darken functions.
+// and the color functions: brighten, darken, lighten_ok, darken_ok,
+// saturate, desaturate, rotate_hue, complement, invert, mix, shade, tint
+// and gray, and the constructors hsl and rgb.
 func BuildEvalContext(palette *color.Node) *hcl.EvalContext {
 	return &hcl.EvalContext{
 		Variables: map[string]cty.Value{
 			"palette": NodeToCty(palette),
 		},
 		Functions: map[string]function.Function{
-			"brighten": MakeBrightenFunc(),
-			"darken":   MakeDarkenFunc(),
+			"brighten":   MakeBrightenFunc(),
+			"darken":     MakeDarkenFunc(),
+			"lighten_ok": MakeLightenOKFunc(),
+			"darken_ok":  MakeDarkenOKFunc(),
+			"saturate":   MakeSaturateFunc(),
+			"desaturate": MakeDesaturateFunc(),
+			"rotate_hue": MakeRotateHueFunc(),
+			"complement": MakeComplementFunc(),
+			"invert":     MakeInvertFunc(),
+			"mix":        MakeMixFunc(),
+			"shade":      MakeShadeFunc(),
+			"tint":       MakeTintFunc(),
+			"gray":       MakeGrayFunc(),
+			"hsl":        MakeHSLFunc(),
+			"rgb":        MakeRGBFunc(),
 		},
 	}
 }
@@ -1,8 +1,11 @@
 package theme
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/jsvensson/paletteswap/internal/color"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -90,3 +93,160 @@ func TestResolveColor_ObjectWithoutColor(t *testing.T) {
 		t.Fatal("expected error for object without color key")
 	}
 }
+
+func TestResolveColorExpr(t *testing.T) {
+	ctx := &hcl.EvalContext{Variables: map[string]cty.Value{
+		"local": cty.ObjectVal(map[string]cty.Value{
+			"hue":  cty.StringVal("eb"),
+			"base": cty.StringVal("#191724"),
+		}),
+	}}
+
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+	}{
+		{"literal", `"#191724"`, "#191724", ""},
+		{"literal invalid", `"#19172"`, "#19172", ""}, // left to ParseHex
+		{"interpolated", `"#${local.hue}6f92"`, "#eb6f92", ""},
+		{"wrapped reference", `"${local.base}"`, "#191724", ""},
+		{"interpolated invalid", `"#${local.base}"`, "", `interpolation produced "##191724"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, diags := hclsyntax.ParseExpression([]byte(tt.src), "test.pstheme", hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				t.Fatalf("parse error: %s", diags.Error())
+			}
+			val, diags := expr.Value(ctx)
+			if diags.HasErrors() {
+				t.Fatalf("eval error: %s", diags.Error())
+			}
+			got, err := ResolveColorExpr(expr, val)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveColorExpr() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveColorExpr() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveColorExpr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveColor_RGBTuple(t *testing.T) {
+	num := func(f float64) cty.Value { return cty.NumberFloatVal(f) }
+	tests := []struct {
+		name    string
+		val     cty.Value
+		want    string
+		wantErr string
+	}{
+		{"tuple", cty.TupleVal([]cty.Value{num(25), num(23), num(36)}), "#191724", ""},
+		{"list", cty.ListVal([]cty.Value{num(255), num(0), num(128)}), "#ff0080", ""},
+		{"too short", cty.TupleVal([]cty.Value{num(25), num(23)}), "", "must have 3 elements, got 2"},
+		{"out of range", cty.TupleVal([]cty.Value{num(25), num(256), num(36)}), "", "element 2 must be a whole number from 0 to 255, got 256"},
+		{"negative", cty.TupleVal([]cty.Value{num(-1), num(0), num(0)}), "", "element 1 must be a whole number"},
+		{"fraction", cty.TupleVal([]cty.Value{num(0), num(0), num(0.5)}), "", "element 3 must be a whole number"},
+		{"string element", cty.TupleVal([]cty.Value{num(0), cty.StringVal("0"), num(0)}), "", "element 2 must be a number, got string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveColor(tt.val)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveColor() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveColor() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveColor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLightnessFuncPercentage(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+		// wantSubject is the text the error's subject covers, if any.
+		wantSubject string
+	}{
+		{`brighten("#191724", 1)`, "", ""},
+		{`brighten("#191724", -1)`, "", ""},
+		{`darken("#191724", 0)`, "", ""},
+		{`brighten("#191724", 1.5)`, "percentage must be between -1 and 1, got 1.5", "1.5"},
+		{`brighten("#191724", -2)`, "percentage must be between -1 and 1, got -2", "-"},
+		{`darken("#191724", 10)`, "percentage must be between 0 and 1, got 10", "10"},
+		{`darken("#191724", -0.2)`, "percentage must be between 0 and 1, got -0.2; use brighten to lighten", "-"},
+		{`brighten("#191724", 1e400)`, "percentage must be between -1 and 1, got 1e+400", "1e400"},
+		{`darken("#191724", -1e400)`, "percentage must be between 0 and 1", "-"},
+		{`lighten_ok("#eb6f92", -0.1)`, "", ""},
+		{`darken_ok("#eb6f92", 0.1)`, "", ""},
+		{`lighten_ok("#eb6f92", 1.5)`, "amount must be between -1 and 1, got 1.5", "1.5"},
+		{`darken_ok("#eb6f92", -0.1)`, "amount must be between 0 and 1, got -0.1; use lighten_ok to lighten", "-"},
+		{`saturate("#191724", -1)`, "", ""},
+		{`saturate("#191724", 2)`, "amount must be between -1 and 1, got 2", "2"},
+		{`desaturate("#191724", -0.1)`, "amount must be between 0 and 1, got -0.1; use saturate to saturate", "-"},
+		{`rotate_hue("#eb6f92", -120)`, "", ""},
+		{`rotate_hue("#eb6f92", 1e400)`, "degrees must be finite, got 1e+400", "1e400"},
+		{`complement("#eb6f92")`, "", ""},
+		{`invert("#191724")`, "", ""},
+		{`invert("nope")`, "invalid hex", "nope"},
+		{`gray(0.25)`, "", ""},
+		{`gray(1.5)`, "lightness must be between 0 and 1, got 1.5", "1.5"},
+		{`hsl(343, 0.76, 0.68)`, "", ""},
+		{`hsl(-30, 1, 0.5)`, "", ""},
+		{`hsl(1e400, 1, 0.5)`, "hue must be finite, got 1e+400", "1e400"},
+		{`hsl(343, 76, 0.68)`, "saturation must be between 0 and 1, got 76", "76"},
+		{`hsl(343, 0.76, -0.5)`, "lightness must be between 0 and 1, got -0.5", "-"},
+		{`rgb(235, 111, 146)`, "", ""},
+		{`rgb(235, 256, 146)`, "green must be a whole number from 0 to 255, got 256", "256"},
+		{`rgb(235, 111, 14.5)`, "blue must be a whole number from 0 to 255, got 14.5", "14.5"},
+		{`shade("#eb6f92", 0.2)`, "", ""},
+		{`tint("#eb6f92", 1)`, "", ""},
+		{`shade("#eb6f92", -0.2)`, "amount must be between 0 and 1, got -0.2; use tint to lighten", "-"},
+		{`tint("#eb6f92", 1.5)`, "amount must be between 0 and 1, got 1.5; use shade to darken", "1.5"},
+		{`mix("#191724", "#e0def4", 0.3)`, "", ""},
+		{`mix("#191724", "#e0def4", 1.2)`, "weight must be between 0 and 1, got 1.2", "1.2"},
+		{`mix("#191724", "nope", 0.3)`, "invalid hex", "nope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, diags := hclsyntax.ParseExpression([]byte(tt.expr), "test.pstheme", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+			_, diags = expr.Value(BuildEvalContext(&color.Node{}))
+			if tt.wantErr == "" {
+				if diags.HasErrors() {
+					t.Errorf("unexpected error: %s", diags.Error())
+				}
+				return
+			}
+			if !diags.HasErrors() || !strings.Contains(diags.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want containing %q", diags, tt.wantErr)
+			}
+			subject := diags[0].Subject
+			if subject == nil {
+				t.Fatal("error has no subject")
+			}
+			if got := tt.expr[subject.Start.Byte:subject.End.Byte]; got != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", got, tt.wantSubject)
+			}
+		})
+	}
+}
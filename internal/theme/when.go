@@ -0,0 +1,123 @@
+package theme
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// WhenAttr is the block attribute that limits a block to one appearance:
+//
+//	theme {
+//	  when       = "light"
+//	  background = palette.dawn
+//	}
+//
+// Like color, it is reserved in every block.
+const WhenAttr = "when"
+
+// Appearances are the values of meta.appearance, and of when attributes.
+var Appearances = []string{"dark", "light"}
+
+// whenExempt are the top-level blocks that may not carry a when attribute,
+// because the appearance is read from them before when is applied.
+var whenExempt = []string{"meta", "settings"}
+
+// MetaAppearance returns the appearance declared in the meta block of body,
+// or "" if there is none or it is not a string.
+func MetaAppearance(body *hclsyntax.Body) string {
+	for _, block := range body.Blocks {
+		if block.Type != "meta" {
+			continue
+		}
+		if attr, ok := block.Body.Attributes["appearance"]; ok {
+			val, diags := attr.Expr.Value(nil)
+			if !diags.HasErrors() && val.IsKnown() && !val.IsNull() && val.Type() == cty.String {
+				return val.AsString()
+			}
+		}
+	}
+	return ""
+}
+
+// ApplyWhen returns body for appearance: blocks at any depth whose when
+// attribute names another appearance are left out, and those it names keep
+// their content without the attribute. Files that differ between
+// appearances in a few values can so declare both in one file. body is not
+// modified; the result shares its unchanged parts.
+//
+// A when value other than a known appearance, a when attribute in the meta
+// or settings block, and a when block loaded without an appearance are
+// error diagnostics, and the block is left out.
+func ApplyWhen(body *hclsyntax.Body, appearance string) (*hclsyntax.Body, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	var apply func(body *hclsyntax.Body, topLevel bool) *hclsyntax.Body
+	apply = func(body *hclsyntax.Body, topLevel bool) *hclsyntax.Body {
+		changed := false
+		blocks := make(hclsyntax.Blocks, 0, len(body.Blocks))
+		for _, block := range body.Blocks {
+			b := block
+			if attr, ok := b.Body.Attributes[WhenAttr]; ok {
+				if !whenApplies(attr, b, topLevel, appearance, &diags) {
+					changed = true
+					continue
+				}
+				b = withoutWhen(b)
+			}
+			if inner := apply(b.Body, false); inner != b.Body {
+				if b == block {
+					copied := *b
+					b = &copied
+				}
+				b.Body = inner
+			}
+			changed = changed || b != block
+			blocks = append(blocks, b)
+		}
+		if !changed {
+			return body
+		}
+		copied := *body
+		copied.Blocks = blocks
+		return &copied
+	}
+	return apply(body, true), diags
+}
+
+// whenApplies reports whether block, with the when attribute attr, applies
+// to appearance, adding a diagnostic to diags for an invalid one.
+func whenApplies(attr *hclsyntax.Attribute, block *hclsyntax.Block, topLevel bool, appearance string, diags *hcl.Diagnostics) bool {
+	fail := func(format string, args ...any) bool {
+		*diags = append(*diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  fmt.Sprintf(format, args...),
+			Subject:  attr.Expr.Range().Ptr(),
+		})
+		return false
+	}
+	if topLevel && slices.Contains(whenExempt, block.Type) {
+		return fail("%s is not allowed in the %s block", WhenAttr, block.Type)
+	}
+	val, valDiags := attr.Expr.Value(nil)
+	if valDiags.HasErrors() || !val.IsKnown() || val.IsNull() || val.Type() != cty.String || !slices.Contains(Appearances, val.AsString()) {
+		return fail(`%s must be "dark" or "light"`, WhenAttr)
+	}
+	if appearance == "" {
+		return fail("%s needs meta.appearance to be set", WhenAttr)
+	}
+	return val.AsString() == appearance
+}
+
+// withoutWhen returns a copy of block without its when attribute.
+func withoutWhen(block *hclsyntax.Block) *hclsyntax.Block {
+	body := *block.Body
+	body.Attributes = maps.Clone(block.Body.Attributes)
+	delete(body.Attributes, WhenAttr)
+	copied := *block
+	copied.Body = &body
+	return &copied
+}
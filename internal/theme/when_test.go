@@ -0,0 +1,110 @@
+package theme
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// blockPaths returns the paths of the blocks in body and the attributes
+// of each, e.g. "theme.selection" and "theme.selection.background".
+func blockPaths(body *hclsyntax.Body, prefix string) []string {
+	var paths []string
+	for name := range body.Attributes {
+		paths = append(paths, prefix+name)
+	}
+	for _, block := range body.Blocks {
+		paths = append(paths, prefix+block.Type)
+		paths = append(paths, blockPaths(block.Body, prefix+block.Type+".")...)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+func TestApplyWhen(t *testing.T) {
+	src := `
+meta {
+  appearance = "dark"
+}
+theme {
+  background = "#191724"
+  selection {
+    when       = "light"
+    background = "#dfdad9"
+  }
+  selection {
+    when       = "dark"
+    background = "#403d52"
+  }
+}
+syntax {
+  when = "light"
+  comment = "#9893a5"
+}
+`
+	body := parseBody(t, src)
+	if got := MetaAppearance(body); got != "dark" {
+		t.Errorf("MetaAppearance() = %q, want dark", got)
+	}
+
+	tests := []struct {
+		appearance string
+		want       []string
+	}{
+		{"dark", []string{"meta", "meta.appearance", "theme", "theme.background", "theme.selection", "theme.selection.background"}},
+		{"light", []string{"meta", "meta.appearance", "syntax", "syntax.comment", "theme", "theme.background", "theme.selection", "theme.selection.background"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.appearance, func(t *testing.T) {
+			got, diags := ApplyWhen(body, tt.appearance)
+			if diags.HasErrors() {
+				t.Fatalf("ApplyWhen() error: %s", diags.Error())
+			}
+			if paths := blockPaths(got, ""); !slices.Equal(paths, tt.want) {
+				t.Errorf("ApplyWhen() = %v, want %v", paths, tt.want)
+			}
+		})
+	}
+
+	// body itself is not modified.
+	if n := len(body.Blocks[1].Body.Blocks); n != 2 {
+		t.Errorf("theme has %d blocks after ApplyWhen, want the original 2", n)
+	}
+
+	plain := parseBody(t, `theme { background = "#191724" }`)
+	if got, _ := ApplyWhen(plain, "dark"); got != plain {
+		t.Error("ApplyWhen() without when attributes should return body itself")
+	}
+}
+
+func TestApplyWhenErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		src        string
+		appearance string
+		want       string
+	}{
+		{"bad value", `theme {
+  when = "dim"
+}`, "dark", `when must be "dark" or "light"`},
+		{"meta", `meta {
+  when = "dark"
+}`, "dark", "when is not allowed in the meta block"},
+		{"no appearance", `theme {
+  when = "dark"
+}`, "", "when needs meta.appearance to be set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, diags := ApplyWhen(parseBody(t, tt.src), tt.appearance)
+			if !diags.HasErrors() || !strings.Contains(diags.Error(), tt.want) {
+				t.Fatalf("ApplyWhen() diagnostics = %v, want %q", diags, tt.want)
+			}
+			if len(got.Blocks) != 0 {
+				t.Errorf("ApplyWhen() kept %d blocks, want the invalid block left out", len(got.Blocks))
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package paletteswap
+
+import (
+	"os"
+
+	"github.com/jsvensson/paletteswap/internal/lint"
+	"github.com/jsvensson/paletteswap/internal/lsp"
+)
+
+// LintRule is a lint rule, run by the check command and the language server
+// alongside the built-in rules once added with RegisterLintRule.
+type LintRule = lint.Rule
+
+// LintInput is the theme a lint rule inspects: the parsed file and its
+// resolved top-level theme colors.
+type LintInput = lint.Input
+
+// LintFinding is a problem reported by a lint rule.
+type LintFinding = lint.Finding
+
+// LintSeverity is how a lint finding is reported.
+type LintSeverity = lint.Severity
+
+const (
+	LintOff     = lint.SeverityOff // the rule does not run
+	LintInfo    = lint.SeverityInfo
+	LintWarning = lint.SeverityWarning
+	LintError   = lint.SeverityError // check exits with a validation error
+)
+
+// RegisterLintRule adds a project-specific lint rule, such as one requiring
+// every syntax color to come from the palette. Programs embedding
+// paletteswap call it before checking themes or serving the language
+// server; the rule can then be configured by ID or name in the lint block of
+// a theme's settings like any built-in rule. The rule's ID and name must not
+// be taken, and it must have a Check function.
+func RegisterLintRule(rule LintRule) error {
+	return lint.RegisterRule(rule)
+}
+
+// Lint runs the lint rules, built-in and registered, on the theme file at
+// path with the severities of its lint block, as the check command does.
+// Findings are in source order. If the theme has problems outside the lint
+// rules, such as a syntax error, the findings come with an error of kind
+// KindConfig describing the first of them.
+func Lint(path string) ([]LintFinding, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errorf(KindIO, "reading theme: %w", err)
+	}
+
+	result := lsp.Analyze(path, string(src))
+	for _, d := range result.Diagnostics {
+		if d.Severity == nil || *d.Severity != lsp.DiagError {
+			continue
+		}
+		if d.Code != nil {
+			if id, ok := d.Code.Value.(string); ok {
+				if _, ok := lint.Lookup(id); ok {
+					continue
+				}
+			}
+		}
+		err = errorf(KindConfig, "%s:%d: %s", path, d.Range.Start.Line+1, d.Message)
+		break
+	}
+	return result.Findings, err
+}
+
+// LintCatalog maps lint rule IDs to message formats in one locale.
+type LintCatalog = lint.Catalog
+
+// RegisterLintCatalog adds or replaces the lint message catalog for locale,
+// which the locale setting of a theme selects. Catalogs translate lint
+// findings only; other errors and diagnostics are always in English.
+func RegisterLintCatalog(locale string, c LintCatalog) {
+	lint.RegisterCatalog(locale, c)
+}
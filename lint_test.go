@@ -0,0 +1,102 @@
+package paletteswap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintRegisteredRule(t *testing.T) {
+	// Off unless the lint block enables it, so that it does not affect the
+	// other tests.
+	rule := LintRule{
+		ID:       "XP001",
+		Name:     "lowercase-palette",
+		Severity: LintOff,
+		Doc:      "Palette color names are lowercase.",
+		Message:  "palette color %s is not lowercase",
+		Check: func(in *LintInput) []LintFinding {
+			var findings []LintFinding
+			for _, block := range in.Body.Blocks {
+				if block.Type != "palette" {
+					continue
+				}
+				for name, attr := range block.Body.Attributes {
+					if name != strings.ToLower(name) {
+						findings = append(findings, LintFinding{Range: attr.NameRange, Args: []any{name}})
+					}
+				}
+			}
+			return findings
+		},
+	}
+	if err := RegisterLintRule(rule); err != nil {
+		t.Fatalf("RegisterLintRule() error: %v", err)
+	}
+
+	theme := `settings {
+  lint {
+    lowercase-palette = "warning"
+  }
+}
+
+palette {
+  base = "#191724"
+  Love = "#eb6f92"
+}
+
+theme {
+  background = palette.base
+  foreground = palette.Love
+}
+
+ansi {
+  black          = palette.base
+  red            = palette.Love
+  green          = palette.base
+  yellow         = palette.base
+  blue           = palette.base
+  magenta        = palette.base
+  cyan           = palette.base
+  white          = palette.base
+  bright_black   = palette.base
+  bright_red     = palette.base
+  bright_green   = palette.base
+  bright_yellow  = palette.base
+  bright_blue    = palette.base
+  bright_magenta = palette.base
+  bright_cyan    = palette.base
+  bright_white   = palette.base
+}
+`
+	path := filepath.Join(t.TempDir(), "theme.pstheme")
+	if err := os.WriteFile(path, []byte(theme), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err := Lint(path)
+	if err != nil {
+		t.Fatalf("Lint() error: %v", err)
+	}
+	var custom []LintFinding
+	for _, f := range findings {
+		if f.RuleID == "XP001" {
+			custom = append(custom, f)
+		}
+	}
+	if len(custom) != 1 {
+		t.Fatalf("XP001 findings = %+v, want one", custom)
+	}
+	if f := custom[0]; f.Severity != LintWarning || f.Message != "palette color Love is not lowercase" || f.Range.Start.Line != 9 {
+		t.Errorf("XP001 finding = %+v, want a warning for Love on line 9", f)
+	}
+
+	// A theme that does not analyze cleanly is reported as a config error.
+	if err := os.WriteFile(path, []byte("palette {\n  base = \n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Lint(path); KindOf(err) != KindConfig {
+		t.Errorf("Lint() of a malformed theme error = %v, want a config error", err)
+	}
+}
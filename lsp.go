@@ -0,0 +1,30 @@
+package paletteswap
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jsvensson/paletteswap/internal/lsp"
+)
+
+// LSPOptions configures ServeLSP.
+type LSPOptions struct {
+	Version string       // server version reported to the client
+	Limits  Limits       // as in LoadWithLimits; the lsp command uses DefaultLimits
+	Logger  *slog.Logger // nil discards logs
+	Listen  string       // TCP address to accept connections on; empty means stdio
+	NodeIPC bool         // use the Node.js IPC channel instead of stdio
+}
+
+// ServeLSP runs the pstheme language server, as the lsp command does, until
+// the client exits or ctx is cancelled. Programs embedding paletteswap use
+// it to serve the lint rules they add with RegisterLintRule.
+func ServeLSP(ctx context.Context, opts LSPOptions) error {
+	return lsp.Serve(ctx, lsp.Options{
+		Version: opts.Version,
+		Limits:  opts.Limits,
+		Logger:  opts.Logger,
+		Listen:  opts.Listen,
+		NodeIPC: opts.NodeIPC,
+	})
+}
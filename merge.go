@@ -0,0 +1,107 @@
+package paletteswap
+
+import (
+	"maps"
+
+	"github.com/jsvensson/paletteswap/internal/color"
+)
+
+// Merge returns a new Theme with overlay applied on top of base, so themes
+// can be composed at runtime, e.g. to put a dynamic accent color over a
+// static base theme. Neither argument is modified, and the result shares no
+// maps or palette nodes with them.
+//
+// Every value set in overlay replaces the value at the same path in base:
+//
+//   - Palette, Syntax and LanguageSyntax are merged recursively, so an
+//     overlay group only replaces the entries it defines.
+//   - Theme, ANSI, Semantic and Meta.Extra are merged key by key.
+//   - Cursor, Selection, non-empty Meta fields and a non-zero
+//     SchemaVersion replace those of base.
+//   - Sources are merged key by key and MigrationHints are concatenated.
+//
+// Merge works on resolved colors: replacing palette.love does not change a
+// theme color that base resolved from palette.love. Set those in overlay too.
+func Merge(base, overlay *Theme) *Theme {
+	if base == nil {
+		base = &Theme{}
+	}
+	if overlay == nil {
+		overlay = &Theme{}
+	}
+
+	merged := &Theme{
+		Meta:           mergeMeta(base.Meta, overlay.Meta),
+		Palette:        color.MergeNodes(base.Palette, overlay.Palette),
+		Syntax:         color.MergeTrees(base.Syntax, overlay.Syntax),
+		LanguageSyntax: make(map[string]color.Tree, len(base.LanguageSyntax)+len(overlay.LanguageSyntax)),
+		Semantic:       mergeMaps(base.Semantic, overlay.Semantic),
+		Theme:          mergeMaps(base.Theme, overlay.Theme),
+		Cursor:         base.Cursor,
+		Selection:      base.Selection,
+		ANSI:           mergeMaps(base.ANSI, overlay.ANSI),
+		Sources:        mergeMaps(base.Sources, overlay.Sources),
+		MigrationHints: append(append([]string(nil), base.MigrationHints...), overlay.MigrationHints...),
+		SchemaVersion:  base.SchemaVersion,
+	}
+	for lang, tree := range base.LanguageSyntax {
+		merged.LanguageSyntax[lang] = color.MergeTrees(tree, overlay.LanguageSyntax[lang])
+	}
+	for lang, tree := range overlay.LanguageSyntax {
+		if _, ok := base.LanguageSyntax[lang]; !ok {
+			merged.LanguageSyntax[lang] = color.MergeTrees(tree, nil)
+		}
+	}
+	if overlay.Cursor != nil {
+		merged.Cursor = overlay.Cursor
+	}
+	if overlay.Selection != nil {
+		merged.Selection = overlay.Selection
+	}
+	if overlay.SchemaVersion != 0 {
+		merged.SchemaVersion = overlay.SchemaVersion
+	}
+	if merged.Cursor != nil {
+		c := *merged.Cursor
+		merged.Cursor = &c
+	}
+	if merged.Selection != nil {
+		s := *merged.Selection
+		merged.Selection = &s
+	}
+	return merged
+}
+
+// mergeMeta applies the non-empty fields of overlay to base.
+func mergeMeta(base, overlay Meta) Meta {
+	merged := base
+	if overlay.Name != "" {
+		merged.Name = overlay.Name
+	}
+	if overlay.Author != "" {
+		merged.Author = overlay.Author
+	}
+	if overlay.Appearance != "" {
+		merged.Appearance = overlay.Appearance
+	}
+	if overlay.URL != "" {
+		merged.URL = overlay.URL
+	}
+	if overlay.SpecVersion != 0 {
+		merged.SpecVersion = overlay.SpecVersion
+	}
+	merged.Extra = mergeMaps(base.Extra, overlay.Extra)
+	return merged
+}
+
+// mergeMaps returns a new map holding the entries of base and overlay, with
+// overlay winning on conflicts. It returns nil if both are empty.
+func mergeMaps[K comparable, V any](base, overlay map[K]V) map[K]V {
+	if len(base) == 0 && len(overlay) == 0 {
+		return nil
+	}
+	merged := make(map[K]V, len(base)+len(overlay))
+	maps.Copy(merged, base)
+	maps.Copy(merged, overlay)
+	return merged
+}
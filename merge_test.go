@@ -0,0 +1,87 @@
+package paletteswap
+
+import (
+	"testing"
+
+	"github.com/jsvensson/paletteswap/internal/color"
+)
+
+func TestMerge(t *testing.T) {
+	base := testTheme()
+	base.Meta.Extra = map[string]string{"variant": "main", "family": "rose"}
+	base.Cursor = &Cursor{Color: color.Color{R: 1}, Text: color.Color{R: 2}}
+	base.SchemaVersion = 1
+
+	accent := color.Color{R: 156, G: 207, B: 216}
+	overlay := &Theme{
+		Meta: Meta{Name: "Dynamic", Extra: map[string]string{"variant": "wallpaper"}},
+		Palette: &color.Node{Children: map[string]*color.Node{
+			"accent": {Color: &accent},
+			"highlight": {Children: map[string]*color.Node{
+				"low": {Color: &accent},
+			}},
+		}},
+		Theme:  map[string]color.Color{"cursor": accent},
+		Syntax: color.Tree{"keyword": color.Style{Color: accent, Bold: true}},
+	}
+
+	got := Merge(base, overlay)
+
+	if got.Meta.Name != "Dynamic" || got.Meta.Author != "Tester" {
+		t.Errorf("Meta = %+v, want overlay name and base author", got.Meta)
+	}
+	if got.Meta.Extra["variant"] != "wallpaper" || got.Meta.Extra["family"] != "rose" {
+		t.Errorf("Meta.Extra = %v, want merged", got.Meta.Extra)
+	}
+
+	for path, want := range map[string]string{
+		"palette.accent":         "#9ccfd8",
+		"palette.highlight.low":  "#9ccfd8",
+		"palette.highlight.high": "#524f67",
+		"palette.base":           "#191724",
+		"theme.cursor":           "#9ccfd8",
+		"theme.background":       "#191724",
+		"syntax.keyword":         "#9ccfd8",
+		"syntax.comment":         "#6e6a86",
+		"ansi.red":               "#eb6f92",
+	} {
+		c, err := resolveColorPath(path, buildTemplateData(got))
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if c.Hex() != want {
+			t.Errorf("%s = %s, want %s", path, c.Hex(), want)
+		}
+	}
+	if got.SchemaVersion != 1 {
+		t.Errorf("SchemaVersion = %d, want base version 1", got.SchemaVersion)
+	}
+	if got.Cursor == nil || got.Cursor.Text != (color.Color{R: 2}) {
+		t.Errorf("Cursor = %v, want base cursor", got.Cursor)
+	}
+
+	// The inputs are unchanged and not shared with the result.
+	if base.Meta.Extra["variant"] != "main" {
+		t.Error("base Meta.Extra was modified")
+	}
+	if _, ok := base.Palette.Children["accent"]; ok {
+		t.Error("base palette was modified")
+	}
+	got.Theme["background"] = accent
+	got.Cursor.Color = accent
+	if base.Theme["background"] == accent || base.Cursor.Color == accent {
+		t.Error("result shares data with base")
+	}
+}
+
+func TestMergeNil(t *testing.T) {
+	base := testTheme()
+	got := Merge(base, nil)
+	if c, err := resolveColorPath("theme.background", buildTemplateData(got)); err != nil || c.Hex() != "#191724" {
+		t.Errorf("theme.background = %v (%v), want #191724", c, err)
+	}
+	if Merge(nil, nil) == nil {
+		t.Error("Merge(nil, nil) = nil, want an empty theme")
+	}
+}
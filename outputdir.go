@@ -0,0 +1,69 @@
+package paletteswap
+
+import (
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// OutputDirData is the value an output directory pattern is executed with:
+// the theme's TemplateData, so patterns can use .Meta and the template
+// functions, plus the theme's variant.
+type OutputDirData struct {
+	TemplateData
+	// Variant is the theme's meta variant attribute, or the name of the
+	// theme file without its extension if the meta block has none.
+	Variant string
+}
+
+// ExpandOutputDir executes pattern, an output directory that may contain
+// template actions such as "dist/{{ .Meta.Name | slug }}/{{ .Variant }}",
+// for the theme loaded from themePath. Generating many themes or variants
+// into an expanded pattern gives each its own predictable directory. A
+// pattern without actions is returned unchanged.
+func ExpandOutputDir(pattern, themePath string, theme *Theme) (string, error) {
+	if !strings.Contains(pattern, "{{") {
+		return pattern, nil
+	}
+
+	data := OutputDirData{TemplateData: buildTemplateData(theme), Variant: theme.Meta.Extra["variant"]}
+	if data.Variant == "" {
+		name := filepath.Base(themePath)
+		data.Variant = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	tmpl, err := template.New("out").Funcs(data.FuncMap).Option("missingkey=error").Parse(pattern)
+	if err != nil {
+		return "", errorf(KindConfig, "output directory %q: %w", pattern, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", errorf(KindConfig, "output directory %q: %w", pattern, err)
+	}
+	dir := strings.TrimSpace(b.String())
+	if dir == "" {
+		return "", errorf(KindConfig, "output directory %q expands to an empty path", pattern)
+	}
+	return filepath.Clean(dir), nil
+}
+
+// slug lowercases s and replaces every run of characters other than letters
+// and digits with a single hyphen, e.g. "Rosé Pine Moon" becomes
+// "rosé-pine-moon", for use in file and directory names.
+func slug(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package paletteswap
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandOutputDir(t *testing.T) {
+	moon := testTheme()
+	moon.Meta.Extra = map[string]string{"variant": "moon"}
+
+	tests := []struct {
+		name    string
+		pattern string
+		theme   *Theme
+		want    string
+	}{
+		{"plain", "output", testTheme(), "output"},
+		{"name and file variant", "dist/{{ .Meta.Name | slug }}/{{ .Variant }}", testTheme(), filepath.Join("dist", "test-theme", "dark")},
+		{"meta variant", "dist/{{ .Variant }}", moon, filepath.Join("dist", "moon")},
+		{"template functions", `{{ metaOr "family" "misc" }}/{{ bhex "theme.background" }}`, testTheme(), filepath.Join("misc", "191724")},
+		{"cleaned", "dist//{{ .Variant }}/", testTheme(), filepath.Join("dist", "dark")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandOutputDir(tt.pattern, "themes/dark.pstheme", tt.theme)
+			if err != nil {
+				t.Fatalf("ExpandOutputDir() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandOutputDir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandOutputDirErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		wantErr string
+	}{
+		{"syntax", "dist/{{ .Variant", "unclosed action"},
+		{"unknown field", "dist/{{ .Flavor }}", "can't evaluate field Flavor"},
+		{"unknown meta", `{{ meta "family" }}`, `unknown key "family"`},
+		{"empty", "{{ .Meta.URL }}", "expands to an empty path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExpandOutputDir(tt.pattern, "dark.pstheme", testTheme())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ExpandOutputDir() error = %v, want containing %q", err, tt.wantErr)
+			}
+			if KindOf(err) != KindConfig {
+				t.Errorf("KindOf() = %v, want %v", KindOf(err), KindConfig)
+			}
+		})
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Rosé Pine Moon", "rosé-pine-moon"},
+		{"  Tokyo Night (Storm) ", "tokyo-night-storm"},
+		{"catppuccin_latte", "catppuccin-latte"},
+		{"a/b", "a-b"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := slug(tt.in); got != tt.want {
+			t.Errorf("slug(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
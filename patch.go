@@ -0,0 +1,68 @@
+package paletteswap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Marker lines delimiting the region of an output file that a template with
+// a patch directive manages:
+//
+//	#!ps: patch=#
+//
+// Lines containing the markers are matched in any comment syntax, such as
+// "# pstheme:start" or "/* pstheme:start */". When the file has no region,
+// one is appended with markers written after the directive's comment
+// prefix.
+const (
+	patchStart = "pstheme:start"
+	patchEnd   = "pstheme:end"
+)
+
+// patchRegion returns existing, the content of an output file, with the
+// lines between its start and end marker lines replaced by content. If
+// existing has no markers, the region is appended, marked with comments
+// starting with prefix; the file's own line endings are used for the
+// markers. Everything outside the region is kept byte for byte.
+func patchRegion(existing, content, prefix string) (string, error) {
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	lines := strings.SplitAfter(existing, "\n")
+	start, end := -1, -1
+	for i, line := range lines {
+		switch {
+		case start < 0 && strings.Contains(line, patchStart):
+			start = i
+		case start >= 0 && strings.Contains(line, patchEnd):
+			end = i
+		case start < 0 && strings.Contains(line, patchEnd):
+			return "", fmt.Errorf("line %d: %s marker without a %s marker before it", i+1, patchEnd, patchStart)
+		}
+		if end >= 0 {
+			break
+		}
+	}
+
+	switch {
+	case start >= 0 && end < 0:
+		return "", fmt.Errorf("line %d: %s marker without a %s marker after it", start+1, patchStart, patchEnd)
+	case start >= 0:
+		return strings.Join(lines[:start+1], "") + content + strings.Join(lines[end:], ""), nil
+	}
+
+	nl := "\n"
+	if strings.Contains(existing, "\r\n") {
+		nl = "\r\n"
+	}
+	var b strings.Builder
+	b.WriteString(existing)
+	if existing != "" && !strings.HasSuffix(existing, "\n") {
+		b.WriteString(nl)
+	}
+	b.WriteString(prefix + " " + patchStart + nl)
+	b.WriteString(content)
+	b.WriteString(prefix + " " + patchEnd + nl)
+	return b.String(), nil
+}
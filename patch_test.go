@@ -0,0 +1,73 @@
+package paletteswap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPatchRegion(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		content  string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "replace",
+			existing: "font = mono\n# pstheme:start\nbg = old\n# pstheme:end\nsize = 12\n",
+			content:  "bg = new\nfg = new",
+			want:     "font = mono\n# pstheme:start\nbg = new\nfg = new\n# pstheme:end\nsize = 12\n",
+		},
+		{
+			name:     "other comment syntax",
+			existing: "/* pstheme:start */\nold\n/* pstheme:end */",
+			content:  "new\n",
+			want:     "/* pstheme:start */\nnew\n/* pstheme:end */",
+		},
+		{
+			name:     "append",
+			existing: "font = mono",
+			content:  "bg = new\n",
+			want:     "font = mono\n# pstheme:start\nbg = new\n# pstheme:end\n",
+		},
+		{
+			name:     "append crlf",
+			existing: "font = mono\r\n",
+			content:  "bg = new\r\n",
+			want:     "font = mono\r\n# pstheme:start\r\nbg = new\r\n# pstheme:end\r\n",
+		},
+		{
+			name:    "create",
+			content: "bg = new\n",
+			want:    "# pstheme:start\nbg = new\n# pstheme:end\n",
+		},
+		{
+			name:     "no end",
+			existing: "a\n# pstheme:start\nb\n",
+			wantErr:  "line 2: pstheme:start marker without a pstheme:end marker after it",
+		},
+		{
+			name:     "end first",
+			existing: "# pstheme:end\n# pstheme:start\n",
+			wantErr:  "line 1: pstheme:end marker without a pstheme:start marker before it",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patchRegion(tt.existing, tt.content, "#")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("patchRegion() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("patchRegion() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("patchRegion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package paletteswap
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/jsvensson/paletteswap/internal/color"
@@ -9,7 +10,7 @@ import (
 func TestResolveColorPath_Palette(t *testing.T) {
 	base := color.Color{R: 25, G: 23, B: 36}
 	low := color.Color{R: 33, G: 32, B: 46}
-	data := templateData{
+	data := TemplateData{
 		Palette: &color.Node{
 			Children: map[string]*color.Node{
 				"base": {Color: &base},
@@ -44,7 +45,7 @@ func TestResolveColorPath_Palette(t *testing.T) {
 }
 
 func TestResolveColorPath_Theme(t *testing.T) {
-	data := templateData{
+	data := TemplateData{
 		Theme: map[string]color.Color{
 			"background": {R: 25, G: 23, B: 36},
 			"foreground": {R: 224, G: 222, B: 244},
@@ -61,8 +62,50 @@ func TestResolveColorPath_Theme(t *testing.T) {
 	}
 }
 
+func TestResolveColorPath_ThemeSubBlocks(t *testing.T) {
+	data := TemplateData{
+		Cursor: &Cursor{
+			Color: color.Color{R: 235, G: 111, B: 146},
+			Text:  color.Color{R: 25, G: 23, B: 36},
+		},
+		Selection: &Selection{
+			Background: color.Color{R: 64, G: 61, B: 82},
+			Foreground: color.Color{R: 224, G: 222, B: 244},
+		},
+	}
+
+	tests := []struct {
+		path string
+		want color.Color
+	}{
+		{"theme.cursor.cursor", color.Color{R: 235, G: 111, B: 146}},
+		{"theme.cursor.text", color.Color{R: 25, G: 23, B: 36}},
+		{"theme.selection.background", color.Color{R: 64, G: 61, B: 82}},
+		{"theme.selection.foreground", color.Color{R: 224, G: 222, B: 244}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := resolveColorPath(tt.path, data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := resolveColorPath("theme.cursor.border", data); err == nil {
+		t.Error("expected error for unknown cursor attribute, got nil")
+	}
+	if _, err := resolveColorPath("theme.cursor.text", TemplateData{}); err == nil {
+		t.Error("expected error when theme has no cursor block, got nil")
+	}
+}
+
 func TestResolveColorPath_ANSI(t *testing.T) {
-	data := templateData{
+	data := TemplateData{
 		ANSI: map[string]color.Color{
 			"black":        {R: 0, G: 0, B: 0},
 			"bright_black": {R: 128, G: 128, B: 128},
@@ -91,7 +134,7 @@ func TestResolveColorPath_ANSI(t *testing.T) {
 }
 
 func TestResolveColorPath_Syntax(t *testing.T) {
-	data := templateData{
+	data := TemplateData{
 		Syntax: color.Tree{
 			"keyword": color.Style{Color: color.Color{R: 49, G: 116, B: 143}},
 		},
@@ -107,8 +150,29 @@ func TestResolveColorPath_Syntax(t *testing.T) {
 	}
 }
 
+func TestResolveColorPath_Semantic(t *testing.T) {
+	data := TemplateData{
+		Semantic: map[string]color.Style{
+			"parameter": {Color: color.Color{R: 156, G: 207, B: 216}},
+		},
+	}
+
+	got, err := resolveColorPath("semantic.parameter", data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := color.Color{R: 156, G: 207, B: 216}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, err := resolveColorPath("semantic.missing", data); err == nil {
+		t.Error("expected error for missing semantic token, got nil")
+	}
+}
+
 func TestResolveColorPath_InvalidBlock(t *testing.T) {
-	data := templateData{}
+	data := TemplateData{}
 
 	_, err := resolveColorPath("invalid.path", data)
 	if err == nil {
@@ -117,7 +181,7 @@ func TestResolveColorPath_InvalidBlock(t *testing.T) {
 }
 
 func TestResolveColorPath_PathNotFound(t *testing.T) {
-	data := templateData{
+	data := TemplateData{
 		Theme: map[string]color.Color{
 			"background": {R: 25, G: 23, B: 36},
 		},
@@ -128,3 +192,49 @@ func TestResolveColorPath_PathNotFound(t *testing.T) {
 		t.Fatal("expected error for path not found, got nil")
 	}
 }
+
+func TestResolveColorPath_Black(t *testing.T) {
+	black := color.Color{}
+	data := TemplateData{
+		Palette:   &color.Node{Children: map[string]*color.Node{"black": {Color: &black}}},
+		Theme:     map[string]color.Color{"background": black},
+		Cursor:    &Cursor{Text: black},
+		Selection: &Selection{Background: black},
+		ANSI:      map[string]color.Color{"black": black},
+		Syntax: color.Tree{
+			"comment": color.Style{Color: black, Italic: true},
+			"markup":  color.Tree{"bold": color.Style{Color: black, Bold: true}},
+		},
+		Semantic: map[string]color.Style{"parameter": {Color: black}},
+	}
+
+	for _, path := range []string{
+		"palette.black",
+		"theme.background",
+		"theme.cursor.text",
+		"theme.selection.background",
+		"ansi.black",
+		"syntax.comment",
+		"syntax.markup.bold",
+		"semantic.parameter",
+	} {
+		t.Run(path, func(t *testing.T) {
+			got, err := resolveColorPath(path, data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != black {
+				t.Errorf("got %+v, want black", got)
+			}
+		})
+	}
+
+	for _, path := range []string{"syntax.keyword", "syntax.markup", "syntax.markup.italic", "syntax.comment.italic"} {
+		t.Run(path, func(t *testing.T) {
+			_, err := resolveColorPath(path, data)
+			if err == nil || !strings.Contains(err.Error(), "syntax path not found") {
+				t.Errorf("error = %v, want syntax path not found", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package paletteswap
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/theme"
+)
+
+// ColorPath is a color path of a theme, such as "palette.highlight.low",
+// and the color it resolves to.
+type ColorPath struct {
+	Path  string
+	Color color.Color
+}
+
+// Color resolves a color path such as "palette.base", "theme.cursor.text",
+// "ansi.red", "syntax.markup.bold" or "semantic.parameter", as templates do.
+func (t *Theme) Color(path string) (color.Color, error) {
+	return resolveColorPath(path, t.pathData())
+}
+
+// Paths returns every color path the theme resolves, with its color, so
+// that tools listing, completing or comparing paths need not walk the
+// theme's maps themselves. Each path resolves to its color through Color
+// and the template functions.
+//
+// Paths are grouped by block in the order palette, theme, ansi, syntax,
+// semantic. Palette paths follow the theme's declaration order, and include
+// groups that have their own color; ANSI paths are in ANSI index order,
+// followed by the extra colors such as ansi.cursor; the others are sorted.
+func (t *Theme) Paths() []ColorPath {
+	data := t.pathData()
+	var paths []ColorPath
+	add := func(path string) {
+		if c, err := resolveColorPath(path, data); err == nil {
+			paths = append(paths, ColorPath{Path: path, Color: c})
+		}
+	}
+
+	if t.Palette != nil {
+		walkPalettePaths(t.Palette, "palette", add)
+	}
+	for _, name := range sortedKeys(t.Theme) {
+		add("theme." + name)
+	}
+	if t.Cursor != nil {
+		add("theme.cursor.cursor")
+		add("theme.cursor.text")
+	}
+	if t.Selection != nil {
+		add("theme.selection.background")
+		add("theme.selection.foreground")
+	}
+	for _, name := range data.ANSINames() {
+		add("ansi." + name)
+	}
+	for _, name := range theme.ANSIExtraColors {
+		add("ansi." + name)
+	}
+	walkSyntaxPaths(t.Syntax, "syntax", add)
+	for _, name := range sortedKeys(t.Semantic) {
+		add("semantic." + name)
+	}
+	return paths
+}
+
+// ColorMatch is a color path whose color is close to a color looked up with
+// Which.
+type ColorMatch struct {
+	ColorPath
+	Distance float64 // perceptual distance, as measured by color.Distance; 0 if exact
+}
+
+// Which returns the paths that resolve to c. If there are none, it returns
+// the paths whose color is within maxDistance of c, closest first; paths at
+// the same distance keep the order of Paths.
+func (t *Theme) Which(c color.Color, maxDistance float64) []ColorMatch {
+	var exact, near []ColorMatch
+	for _, p := range t.Paths() {
+		switch d := color.Distance(c, p.Color); {
+		case p.Color == c:
+			exact = append(exact, ColorMatch{ColorPath: p})
+		case d <= maxDistance:
+			near = append(near, ColorMatch{ColorPath: p, Distance: d})
+		}
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	slices.SortStableFunc(near, func(a, b ColorMatch) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+	return near
+}
+
+// FlatColor is a palette color under a flattened name; see FlatPalette.
+type FlatColor = color.FlatColor
+
+// FlatPalette returns the palette colors under flat names, for exports
+// without nesting such as CSS custom properties, in declaration order. Each
+// path below the palette is lowercased, and every run of characters other
+// than letters and digits, including the dots between groups, is replaced
+// with sep: palette.highlight.low is "highlight-low" with sep "-".
+//
+// If two paths flatten to the same name, such as palette.highlight.low and
+// palette.highlight_low, FlatPalette returns a validation error naming
+// them, so that an export never silently drops one.
+func (t *Theme) FlatPalette(sep string) ([]FlatColor, error) {
+	colors, err := flatPalette(t.Palette, sep)
+	if err != nil {
+		return nil, errorf(KindValidation, "%w", err)
+	}
+	return colors, nil
+}
+
+// flatPalette flattens palette as FlatPalette does.
+func flatPalette(palette *color.Node, sep string) ([]FlatColor, error) {
+	colors, collisions := color.Flatten(palette, sep)
+	if len(collisions) == 0 {
+		return colors, nil
+	}
+	msgs := make([]string, len(collisions))
+	for i, c := range collisions {
+		paths := make([]string, len(c.Paths))
+		for j, p := range c.Paths {
+			paths[j] = "palette." + p
+		}
+		msgs[i] = fmt.Sprintf("%s flatten to %q", strings.Join(paths, ", "), c.Name)
+	}
+	return nil, fmt.Errorf("palette names collide when flattened: %s; rename one of each", strings.Join(msgs, "; "))
+}
+
+// pathData returns the template data fields color paths resolve against.
+func (t *Theme) pathData() TemplateData {
+	return TemplateData{
+		Palette:   t.Palette,
+		Theme:     t.Theme,
+		Syntax:    t.Syntax,
+		Semantic:  t.Semantic,
+		Cursor:    t.Cursor,
+		Selection: t.Selection,
+		ANSI:      t.ANSI,
+	}
+}
+
+// walkPalettePaths calls add for node, if it has a color, and every node
+// below it, in declaration order.
+func walkPalettePaths(node *color.Node, path string, add func(string)) {
+	if node.Color != nil && path != "palette" {
+		add(path)
+	}
+	for _, name := range node.Names() {
+		walkPalettePaths(node.Children[name], path+"."+name, add)
+	}
+}
+
+// walkSyntaxPaths calls add for every style in tree, sorted by name.
+func walkSyntaxPaths(tree color.Tree, path string, add func(string)) {
+	for _, name := range sortedKeys(tree) {
+		switch v := tree[name].(type) {
+		case color.Style:
+			add(path + "." + name)
+		case color.Tree:
+			walkSyntaxPaths(v, path+"."+name, add)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package paletteswap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jsvensson/paletteswap/internal/color"
+)
+
+func TestThemePaths(t *testing.T) {
+	theme := testTheme()
+	theme.Semantic = map[string]color.Style{"parameter": {Color: color.Color{R: 1, G: 2, B: 3}}}
+	theme.Selection = &Selection{Background: color.Color{R: 4}, Foreground: color.Color{G: 5}}
+
+	var got []string
+	for _, p := range theme.Paths() {
+		got = append(got, p.Path+"="+p.Color.Hex())
+	}
+	want := []string{
+		"palette.base=#191724",
+		"palette.custom.bold=#ff0000",
+		"palette.highlight.high=#524f67",
+		"palette.highlight.low=#21202e",
+		"palette.love=#eb6f92",
+		"theme.background=#191724",
+		"theme.cursor=#eb6f92",
+		"theme.selection.background=#040000",
+		"theme.selection.foreground=#000500",
+		"ansi.black=#000000",
+		"ansi.red=#eb6f92",
+		"syntax.comment=#6e6a86",
+		"syntax.keyword=#31748f",
+		"syntax.markup.bold=#f6c177",
+		"syntax.markup.heading=#eb6f92",
+		"semantic.parameter=#010203",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Paths() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestThemePaths_GroupColorAndOrder(t *testing.T) {
+	group := color.Color{R: 9}
+	low := color.Color{R: 1}
+	base := color.Color{R: 2}
+	palette := &color.Node{}
+	palette.SetChild("zeta", &color.Node{Color: &base})
+	highlight := &color.Node{Color: &group}
+	highlight.SetChild("low", &color.Node{Color: &low})
+	palette.SetChild("highlight", highlight)
+
+	var got []string
+	for _, p := range (&Theme{Palette: palette}).Paths() {
+		got = append(got, p.Path)
+	}
+	want := "palette.zeta palette.highlight palette.highlight.low"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Paths() = %v, want %s", got, want)
+	}
+}
+
+func TestThemeFlatPalette(t *testing.T) {
+	low := color.Color{R: 1}
+	base := color.Color{R: 2}
+	palette := &color.Node{}
+	highlight := &color.Node{}
+	highlight.SetChild("low", &color.Node{Color: &low})
+	palette.SetChild("highlight", highlight)
+	palette.SetChild("Base", &color.Node{Color: &base})
+	theme := &Theme{Palette: palette}
+
+	colors, err := theme.FlatPalette("_")
+	if err != nil {
+		t.Fatalf("FlatPalette() error: %v", err)
+	}
+	var got []string
+	for _, c := range colors {
+		got = append(got, c.Name)
+	}
+	if want := "highlight_low base"; strings.Join(got, " ") != want {
+		t.Errorf("FlatPalette() names = %v, want %s", got, want)
+	}
+
+	palette.SetChild("highlight-low", &color.Node{Color: &base})
+	_, err = theme.FlatPalette("-")
+	want := `palette.highlight.low, palette.highlight-low flatten to "highlight-low"`
+	if KindOf(err) != KindValidation || err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("FlatPalette() with a collision error = %v, want a validation error containing %q", err, want)
+	}
+}
+
+func TestThemeColor(t *testing.T) {
+	theme := testTheme()
+	c, err := theme.Color("palette.highlight.low")
+	if err != nil {
+		t.Fatalf("Color() error: %v", err)
+	}
+	if c.Hex() != "#21202e" {
+		t.Errorf("Color() = %s, want #21202e", c.Hex())
+	}
+	if _, err := theme.Color("ansi.green"); err == nil {
+		t.Error("Color(ansi.green) succeeded, want an error")
+	}
+}
+
+func TestThemeWhich(t *testing.T) {
+	theme := testTheme()
+
+	paths := func(matches []ColorMatch) string {
+		var s []string
+		for _, m := range matches {
+			s = append(s, m.Path)
+		}
+		return strings.Join(s, " ")
+	}
+
+	love := color.Color{R: 0xeb, G: 0x6f, B: 0x92}
+	got := theme.Which(love, 0.1)
+	if want := "palette.love theme.cursor ansi.red syntax.markup.heading"; paths(got) != want {
+		t.Errorf("Which(#eb6f92) = %s, want %s (exact matches only)", paths(got), want)
+	}
+	for _, m := range got {
+		if m.Distance != 0 {
+			t.Errorf("%s: Distance = %g, want 0 for an exact match", m.Path, m.Distance)
+		}
+	}
+
+	got = theme.Which(color.Color{R: 0x1a, G: 0x17, B: 0x24}, 0.02)
+	if want := "palette.base theme.background"; paths(got) != want {
+		t.Errorf("Which(#1a1724) = %s, want %s", paths(got), want)
+	}
+	if got[0].Distance == 0 || got[0].Distance > 0.02 {
+		t.Errorf("Distance = %g, want within (0, 0.02]", got[0].Distance)
+	}
+
+	if got := theme.Which(color.Color{R: 0, G: 0xff, B: 0}, 0.02); len(got) != 0 {
+		t.Errorf("Which(#00ff00) = %s, want no matches", paths(got))
+	}
+}
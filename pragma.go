@@ -0,0 +1,163 @@
+package paletteswap
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jsvensson/paletteswap/internal/color"
+)
+
+// pragmaPrefix starts the optional first line of a template that declares
+// what the template needs from a theme:
+//
+//	#!ps: requires=ansi,syntax.markup missing=skip encoding=utf8-bom
+//
+// requires lists blocks, which must not be empty, and paths, which must be
+// defined. missing is error (the default), to fail before any file is
+// written, or skip, to leave the template out with a warning. encoding is
+// the output encoding, one of outputEncodings. patch makes the template
+// replace only the marked region of its output file, see patchRegion; its
+// value is the comment prefix for markers it adds. The line is not part of
+// the output.
+const pragmaPrefix = "#!ps:"
+
+// templatePragma holds the directives of a template's pragma line.
+type templatePragma struct {
+	requires []string // blocks and paths the theme must define
+	skip     bool     // skip the template instead of failing if one is missing
+	encoding string   // output encoding; empty for utf8
+	patch    string   // comment prefix of the patch markers; empty to write the whole file
+}
+
+// pragmaBlocks lists the blocks a requirement may name.
+var pragmaBlocks = append([]string{"meta"}, pathBlocks...)
+
+// parsePragma splits the pragma line off the template source src. It
+// returns the source to parse, in which the pragma line is swallowed by a
+// template comment so that line numbers in errors are unchanged.
+func parsePragma(src string) (templatePragma, string, error) {
+	var p templatePragma
+	if !strings.HasPrefix(src, pragmaPrefix) {
+		return p, src, nil
+	}
+	line, rest, hasRest := strings.Cut(src, "\n")
+	if hasRest {
+		src = "{{/*\n*/}}" + rest
+	} else {
+		src = ""
+	}
+
+	for _, field := range strings.Fields(strings.TrimPrefix(strings.TrimSuffix(line, "\r"), pragmaPrefix)) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return p, "", fmt.Errorf("pragma: %q is not key=value", field)
+		}
+		switch key {
+		case "requires":
+			for _, req := range strings.Split(value, ",") {
+				block, _, _ := strings.Cut(req, ".")
+				if !slices.Contains(pragmaBlocks, block) || strings.HasSuffix(req, ".") {
+					return p, "", fmt.Errorf("pragma: invalid requirement %q (must start with one of: %s)", req, strings.Join(pragmaBlocks, ", "))
+				}
+				p.requires = append(p.requires, req)
+			}
+		case "missing":
+			switch value {
+			case "error":
+				p.skip = false
+			case "skip":
+				p.skip = true
+			default:
+				return p, "", fmt.Errorf("pragma: missing must be error or skip, got %q", value)
+			}
+		case "encoding":
+			if !slices.Contains(outputEncodings, value) {
+				return p, "", fmt.Errorf("pragma: encoding must be one of %s, got %q", strings.Join(outputEncodings, ", "), value)
+			}
+			p.encoding = value
+		case "patch":
+			if value == "" {
+				return p, "", fmt.Errorf("pragma: patch needs the comment prefix of its markers, e.g. patch=#")
+			}
+			p.patch = value
+		default:
+			return p, "", fmt.Errorf("pragma: unknown directive %q (valid: requires, missing, encoding, patch)", key)
+		}
+	}
+	if p.patch != "" && p.encoding != "" && p.encoding != encodingUTF8 {
+		return p, "", fmt.Errorf("pragma: patch only supports the utf8 encoding, got %q", p.encoding)
+	}
+	return p, src, nil
+}
+
+// missing returns the requirements the theme in data does not meet.
+func (p templatePragma) missing(data TemplateData) []string {
+	var missing []string
+	for _, req := range p.requires {
+		if !themeHas(data, req) {
+			missing = append(missing, req)
+		}
+	}
+	return missing
+}
+
+// themeHas reports whether the theme in data defines path. A path naming
+// only a block, such as "ansi", requires the block to have entries; a meta
+// path requires a non-empty value; any other path must resolve to a color,
+// style or group.
+func themeHas(data TemplateData, path string) bool {
+	parts := strings.Split(path, ".")
+	block, rest := parts[0], parts[1:]
+	if len(rest) == 0 {
+		switch block {
+		case "meta":
+			return true
+		case "palette":
+			return data.Palette != nil && len(data.Palette.Children) > 0
+		case "theme":
+			return len(data.Theme) > 0 || data.Cursor != nil || data.Selection != nil
+		case "ansi":
+			return len(data.ANSI) > 0
+		case "syntax":
+			return len(data.Syntax) > 0
+		case "semantic":
+			return len(data.Semantic) > 0
+		}
+		return false
+	}
+
+	switch block {
+	case "meta":
+		return len(rest) == 1 && data.Meta.Map()[rest[0]] != ""
+	case "palette":
+		_, err := data.Node(path)
+		return err == nil
+	case "theme":
+		if len(rest) == 1 {
+			_, ok := data.Theme[rest[0]]
+			return ok || (rest[0] == "cursor" && data.Cursor != nil) || (rest[0] == "selection" && data.Selection != nil)
+		}
+		_, err := resolveColorPath(path, data)
+		return err == nil
+	case "ansi":
+		_, ok := data.ANSI[path[len("ansi."):]]
+		return ok
+	case "syntax":
+		var node any = data.Syntax
+		for _, part := range rest {
+			tree, ok := node.(color.Tree)
+			if !ok {
+				return false
+			}
+			if node, ok = tree[part]; !ok {
+				return false
+			}
+		}
+		return true
+	case "semantic":
+		_, ok := data.Semantic[path[len("semantic."):]]
+		return ok
+	}
+	return false
+}
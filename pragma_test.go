@@ -0,0 +1,82 @@
+package paletteswap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePragma(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    templatePragma
+		body    string
+		wantErr string
+	}{
+		{"none", "a\nb", templatePragma{}, "a\nb", ""},
+		{"requires", "#!ps: requires=ansi,syntax.markup\nbody", templatePragma{requires: []string{"ansi", "syntax.markup"}}, "{{/*\n*/}}body", ""},
+		{"skip", "#!ps: missing=skip requires=theme", templatePragma{requires: []string{"theme"}, skip: true}, "", ""},
+		{"not first line", "body\n#!ps: requires=ansi", templatePragma{}, "body\n#!ps: requires=ansi", ""},
+		{"unknown block", "#!ps: requires=ansi,colors\n", templatePragma{}, "", `invalid requirement "colors"`},
+		{"trailing dot", "#!ps: requires=syntax.\n", templatePragma{}, "", `invalid requirement "syntax."`},
+		{"unknown directive", "#!ps: needs=ansi\n", templatePragma{}, "", `unknown directive "needs"`},
+		{"bad missing", "#!ps: missing=warn\n", templatePragma{}, "", "missing must be error or skip"},
+		{"no value", "#!ps: requires\n", templatePragma{}, "", "is not key=value"},
+		{"encoding", "#!ps: encoding=utf16le\nbody", templatePragma{encoding: "utf16le"}, "{{/*\n*/}}body", ""},
+		{"bad encoding", "#!ps: encoding=latin1\n", templatePragma{}, "", `encoding must be one of utf8, utf8-bom, utf16le, got "latin1"`},
+		{"patch", "#!ps: patch=//\nbody", templatePragma{patch: "//"}, "{{/*\n*/}}body", ""},
+		{"empty patch", "#!ps: patch=\n", templatePragma{}, "", "patch needs the comment prefix"},
+		{"patch utf16", "#!ps: patch=; encoding=utf16le\n", templatePragma{}, "", `patch only supports the utf8 encoding, got "utf16le"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, body, err := parsePragma(tt.src)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parsePragma() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePragma() error: %v", err)
+			}
+			if strings.Join(got.requires, ",") != strings.Join(tt.want.requires, ",") || got.skip != tt.want.skip || got.encoding != tt.want.encoding || got.patch != tt.want.patch {
+				t.Errorf("parsePragma() = %+v, want %+v", got, tt.want)
+			}
+			if body != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestThemeHas(t *testing.T) {
+	data := buildTemplateData(testTheme())
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"meta.name", true},
+		{"meta.url", false},
+		{"palette", true},
+		{"palette.highlight", true},
+		{"palette.highlight.low", true},
+		{"palette.missing", false},
+		{"theme", true},
+		{"theme.cursor", true},
+		{"theme.selection", false},
+		{"ansi", true},
+		{"ansi.red", true},
+		{"ansi.green", false},
+		{"syntax.markup", true},
+		{"syntax.markup.bold", true},
+		{"syntax.keyword.bold", false},
+		{"semantic", false},
+		{"semantic.parameter", false},
+	}
+	for _, tt := range tests {
+		if got := themeHas(data, tt.path); got != tt.want {
+			t.Errorf("themeHas(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
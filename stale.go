@@ -0,0 +1,68 @@
+package paletteswap
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// StaleOutput is a generated file that does not match what its template
+// renders for the current theme.
+type StaleOutput struct {
+	Template string // path to the .tmpl file
+	Path     string // path to the output file
+	Missing  bool   // the output file does not exist
+}
+
+// Stale renders the templates Run would render for theme, without writing
+// anything, and returns the outputs whose files in e.OutputDir differ from
+// the rendered content or are missing, in template order. It lets CI check
+// that generated files were regenerated after the theme or a template
+// changed.
+func (e *Engine) Stale(theme *Theme) ([]StaleOutput, error) {
+	data, selected, _, err := e.prepare(theme)
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []StaleOutput
+	for _, tmplPath := range selected {
+		p, err := e.parseTemplate(tmplPath)
+		if err != nil {
+			return nil, err
+		}
+		p.tmpl.Funcs(data.FuncMap)
+		outPath, err := e.outputPath(outputName(tmplPath))
+		if err != nil {
+			return nil, err
+		}
+
+		var want bytes.Buffer
+		if err := e.execute(&want, p.tmpl, data, p.pragma.encoding); err != nil {
+			return nil, newTemplateError(tmplPath, outPath, err)
+		}
+		got, err := os.ReadFile(outPath)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			stale = append(stale, StaleOutput{Template: tmplPath, Path: outPath, Missing: true})
+			continue
+		case err != nil:
+			return nil, errorf(KindIO, "reading output file %s: %w", outPath, err)
+		}
+		if p.pragma.patch != "" {
+			// Only the marked region is generated; compare the file as
+			// patchOutput would leave it.
+			patched, err := patchRegion(string(got), want.String(), p.pragma.patch)
+			if err != nil {
+				return nil, errorf(KindIO, "patching output file %s: %w", outPath, err)
+			}
+			want.Reset()
+			want.WriteString(patched)
+		}
+		if !bytes.Equal(got, want.Bytes()) {
+			stale = append(stale, StaleOutput{Template: tmplPath, Path: outPath})
+		}
+	}
+	return stale, nil
+}
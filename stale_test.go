@@ -0,0 +1,58 @@
+package paletteswap
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jsvensson/paletteswap/internal/color"
+)
+
+func TestEngineStale(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"a.txt.tmpl": "a={{ hex .Theme.background }}",
+		"b.txt.tmpl": "b={{ .Meta.Name }}",
+		"c.txt.tmpl": "c={{ .Meta.Name }}",
+	})
+	outDir := filepath.Join(t.TempDir(), "output")
+	e := &Engine{TemplatesDir: tmplDir, OutputDir: outDir}
+	theme := testTheme()
+	if err := e.Run(theme); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	stale, err := e.Stale(theme)
+	if err != nil {
+		t.Fatalf("Stale() error: %v", err)
+	}
+	if len(stale) != 0 {
+		t.Fatalf("Stale() right after Run = %+v, want none", stale)
+	}
+
+	if err := os.WriteFile(filepath.Join(outDir, "b.txt"), []byte("b=edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(outDir, "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+	theme.Theme["background"] = color.Color{R: 255, G: 250, B: 243}
+
+	stale, err = e.Stale(theme)
+	if err != nil {
+		t.Fatalf("Stale() error: %v", err)
+	}
+	want := []StaleOutput{
+		{Template: filepath.Join(tmplDir, "a.txt.tmpl"), Path: filepath.Join(outDir, "a.txt")},
+		{Template: filepath.Join(tmplDir, "b.txt.tmpl"), Path: filepath.Join(outDir, "b.txt")},
+		{Template: filepath.Join(tmplDir, "c.txt.tmpl"), Path: filepath.Join(outDir, "c.txt"), Missing: true},
+	}
+	if !slices.Equal(stale, want) {
+		t.Errorf("Stale() = %+v, want %+v", stale, want)
+	}
+
+	// Stale only reads: the missing file is not created.
+	if _, err := os.Stat(filepath.Join(outDir, "c.txt")); err == nil {
+		t.Error("Stale() should not write output files")
+	}
+}
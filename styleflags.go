@@ -0,0 +1,55 @@
+package paletteswap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jsvensson/paletteswap/internal/color"
+)
+
+// styleFlagFormats lists the formats styleFlags writes, in the order they
+// are listed in errors.
+var styleFlagFormats = []string{"list", "vim", "css", "sgr"}
+
+// styleFlags returns the flags set in s, in the order bold, italic,
+// underline, written for format:
+//
+//	list  bold,italic            ("" if none)
+//	vim   cterm=bold,italic gui=bold,italic  (NONE if none)
+//	css   font-weight: bold; font-style: italic;  ("" if none)
+//	sgr   1;3                    (SGR parameters, "" if none)
+func styleFlags(s color.Style, format string) (string, error) {
+	var names, css, sgr []string
+	if s.Bold {
+		names = append(names, "bold")
+		css = append(css, "font-weight: bold;")
+		sgr = append(sgr, "1")
+	}
+	if s.Italic {
+		names = append(names, "italic")
+		css = append(css, "font-style: italic;")
+		sgr = append(sgr, "3")
+	}
+	if s.Underline {
+		names = append(names, "underline")
+		css = append(css, "text-decoration: underline;")
+		sgr = append(sgr, "4")
+	}
+
+	switch format {
+	case "list":
+		return strings.Join(names, ","), nil
+	case "vim":
+		attrs := strings.Join(names, ",")
+		if attrs == "" {
+			attrs = "NONE"
+		}
+		return "cterm=" + attrs + " gui=" + attrs, nil
+	case "css":
+		return strings.Join(css, " "), nil
+	case "sgr":
+		return strings.Join(sgr, ";"), nil
+	default:
+		return "", fmt.Errorf("styleFlags: unknown format %q (valid: %s)", format, strings.Join(styleFlagFormats, ", "))
+	}
+}
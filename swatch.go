@@ -0,0 +1,37 @@
+package paletteswap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	stdcolor "image/color"
+	"image/png"
+	"math"
+
+	"github.com/jsvensson/paletteswap/internal/color"
+)
+
+// maxSwatchSize is the largest side, in pixels, of a swatch from swatchPNG,
+// which keeps data URIs embedded in generated files small.
+const maxSwatchSize = 256
+
+// swatchPNG returns a data URI of a PNG image of a size by size square of
+// c, for HTML and Markdown previews:
+//
+//	<img src="{{ swatchPNG "palette.love" 16 }}">
+func swatchPNG(c color.Color, size float64) (string, error) {
+	if size < 1 || size > maxSwatchSize || size != math.Trunc(size) {
+		return "", fmt.Errorf("swatchPNG: size must be a whole number of pixels between 1 and %d, got %g", maxSwatchSize, size)
+	}
+	side := int(size)
+	// A one-color paletted image encodes to a few dozen bytes at any size.
+	img := image.NewPaletted(image.Rect(0, 0, side, side), stdcolor.Palette{
+		stdcolor.RGBA{R: c.R, G: c.G, B: c.B, A: 0xff},
+	})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("swatchPNG: %w", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -0,0 +1,269 @@
+package paletteswap
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/theme"
+)
+
+// TemplateDataVersion is the current version of the TemplateData contract.
+// It is increased only when a change could break existing templates.
+const TemplateDataVersion = 1
+
+// SupportedSchemaVersions lists the TemplateData versions Engine can render.
+var SupportedSchemaVersions = []int{1}
+
+// TemplateData is the value passed to templates (version 1 of the template
+// data contract).
+//
+// Templates should use the accessor methods, which are stable across releases:
+//
+//	{{ .Color "palette.base" | hex }}
+//	{{ range .ANSINames }}{{ . }}={{ ($.ANSIColor .) | hex }}{{ end }}
+//
+// The raw fields (Palette, Theme, Syntax, ...) expose the internal
+// representation. They are kept so that templates written before the
+// contract keep working, but their types may change between releases.
+type TemplateData struct {
+	// SchemaVersion is the contract version the data was built for.
+	SchemaVersion int
+	Meta          Meta
+	Cursor        *Cursor
+	Selection     *Selection
+
+	Palette        *color.Node
+	Theme          map[string]color.Color
+	Syntax         color.Tree
+	LanguageSyntax map[string]color.Tree
+	Semantic       map[string]color.Style
+	ANSI           map[string]color.Color
+	FuncMap        template.FuncMap
+
+	sources map[string]Location
+}
+
+// Color resolves a dot-notation path such as "palette.base",
+// "theme.cursor.text", "ansi.black", "syntax.keyword" or "semantic.parameter".
+func (d TemplateData) Color(path string) (color.Color, error) {
+	return resolveColorPath(path, d)
+}
+
+// Node returns the palette node at a path such as "palette" or
+// "palette.highlight". Unlike Color it also resolves groups, giving access
+// to a group's own Color (nil if it has none) and its Children:
+//
+//	{{ with node "palette.highlight" }}{{ .Color | hex }}{{ range $name, $c := .Children }}...{{ end }}{{ end }}
+func (d TemplateData) Node(path string) (*color.Node, error) {
+	parts := strings.Split(path, ".")
+	if parts[0] != "palette" {
+		return nil, fmt.Errorf("node only supports palette paths, got %q", path)
+	}
+	if d.Palette == nil {
+		return nil, fmt.Errorf("palette path not found: %s", path)
+	}
+	node, err := d.Palette.Find(parts[1:])
+	if err != nil {
+		return nil, fmt.Errorf("palette path not found: %s (%w)", path, err)
+	}
+	return node, nil
+}
+
+// pathBlocks lists the top-level blocks that start a color path.
+var pathBlocks = []string{"palette", "theme", "ansi", "syntax", "semantic"}
+
+// Style returns the style at a syntax or semantic path, such as
+// "syntax.comment" or "semantic.parameter". Missing syntax paths return an
+// empty style, whose Color is black like a style defined as #000000; use
+// the has template function to tell them apart.
+//
+// A path without a block prefix is the deprecated form from before paths
+// were block-prefixed, and is looked up in the palette. Palette entries have
+// no style flags, so only the returned style's Color is set.
+func (d TemplateData) Style(path string) (color.Style, error) {
+	block, rest, ok := strings.Cut(path, ".")
+	if !ok || !slices.Contains(pathBlocks, block) {
+		c, err := d.Palette.Lookup(strings.Split(path, "."))
+		if err != nil {
+			return color.Style{}, fmt.Errorf("palette path not found: %s (%w)", path, err)
+		}
+		return color.Style{Color: c}, nil
+	}
+
+	switch block {
+	case "syntax":
+		style, _ := getStyleFromTree(d.Syntax, strings.Split(rest, "."))
+		return style, nil
+	case "semantic":
+		if strings.Contains(rest, ".") {
+			return color.Style{}, fmt.Errorf("semantic paths must be single-level: %s", path)
+		}
+		return d.Semantic[rest], nil
+	default:
+		return color.Style{}, fmt.Errorf("style only supports syntax and semantic blocks, got %q", block)
+	}
+}
+
+// SyntaxFor returns the syntax tree for the given language: the base syntax
+// with that language's overrides deep-merged on top. Unknown languages get
+// the base syntax.
+func (d TemplateData) SyntaxFor(lang string) color.Tree {
+	return color.MergeTrees(d.Syntax, d.LanguageSyntax[lang])
+}
+
+// DefinedAt returns where a color path such as "palette.love" is defined in
+// the theme file, formatted as "theme.pstheme:12", so generated files can
+// point back to the source:
+//
+//	# {{ definedAt "palette.love" }}
+func (d TemplateData) DefinedAt(path string) (string, error) {
+	loc, ok := d.sources[path]
+	if !ok {
+		loc, ok = d.sources[strings.TrimSuffix(path, "."+color.ColorKey)]
+	}
+	if !ok {
+		return "", fmt.Errorf("no source location for %s", path)
+	}
+	return loc.String(), nil
+}
+
+// ThemeColor returns the named color from the theme block.
+func (d TemplateData) ThemeColor(name string) (color.Color, error) {
+	c, ok := d.Theme[name]
+	if !ok {
+		return color.Color{}, fmt.Errorf("theme color not found: %s", name)
+	}
+	return c, nil
+}
+
+// ThemeNames returns the names of the theme block colors, sorted.
+func (d TemplateData) ThemeNames() []string {
+	return sortedKeys(d.Theme)
+}
+
+// ANSIColor returns the named terminal color, e.g. "bright_red".
+func (d TemplateData) ANSIColor(name string) (color.Color, error) {
+	c, ok := d.ANSI[name]
+	if !ok {
+		return color.Color{}, fmt.Errorf("ansi color not found: %s", name)
+	}
+	return c, nil
+}
+
+// ANSIIndex returns the terminal color at ANSI index i, from 0 (black) to
+// 15 (bright_white).
+func (d TemplateData) ANSIIndex(i int) (color.Color, error) {
+	if i < 0 || i >= len(theme.RequiredANSIColors) {
+		return color.Color{}, fmt.Errorf("ansi index %d out of range 0-%d", i, len(theme.RequiredANSIColors)-1)
+	}
+	return d.ANSIColor(theme.RequiredANSIColors[i])
+}
+
+// ANSIIndexOf returns the ANSI index of the terminal color name, from 0
+// (black) to 15 (bright_white), so that templates ranging over ANSINames
+// write the right index when the theme defines only some of the colors.
+func (d TemplateData) ANSIIndexOf(name string) (int, error) {
+	i := slices.Index(theme.RequiredANSIColors, name)
+	if i < 0 {
+		return 0, fmt.Errorf("not a terminal color: %s", name)
+	}
+	return i, nil
+}
+
+// NearestANSI returns the name of the ANSI color perceptually closest to c,
+// so templates for 16-color targets can degrade other colors gracefully.
+func (d TemplateData) NearestANSI(c color.Color) (string, error) {
+	i, err := d.NearestANSIIndex(c)
+	if err != nil {
+		return "", err
+	}
+	return theme.RequiredANSIColors[i], nil
+}
+
+// NearestANSIIndex is like NearestANSI but returns the ANSI index, 0-15.
+func (d TemplateData) NearestANSIIndex(c color.Color) (int, error) {
+	var indexes []int
+	var candidates []color.Color
+	for i, name := range theme.RequiredANSIColors {
+		if ac, ok := d.ANSI[name]; ok {
+			indexes = append(indexes, i)
+			candidates = append(candidates, ac)
+		}
+	}
+	if len(candidates) == 0 {
+		return 0, fmt.Errorf("no ansi colors defined")
+	}
+	return indexes[color.Nearest(c, candidates)], nil
+}
+
+// ANSINames returns the terminal color names in ANSI index order (black, red,
+// ..., bright_white), followed by any other names sorted. The colors of
+// ANSIExtras are not included.
+func (d TemplateData) ANSINames() []string {
+	names := make([]string, 0, len(d.ANSI))
+	for _, name := range theme.RequiredANSIColors {
+		if _, ok := d.ANSI[name]; ok {
+			names = append(names, name)
+		}
+	}
+	for _, name := range sortedKeys(d.ANSI) {
+		if !slices.Contains(theme.ANSIColorNames, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ANSIExtras returns the extra colors the ansi block defines besides the
+// terminal colors, such as "foreground" and "cursor", keyed by name. Colors
+// the theme does not define are absent, so templates can test for them:
+//
+//	{{ with .ANSIExtras.cursor }}cursor = {{ hex . }}{{ end }}
+func (d TemplateData) ANSIExtras() map[string]*color.Color {
+	extras := make(map[string]*color.Color)
+	for _, name := range theme.ANSIExtraColors {
+		if c, ok := d.ANSI[name]; ok {
+			extras[name] = &c
+		}
+	}
+	return extras
+}
+
+// SemanticStyle returns the style for a semantic token type.
+func (d TemplateData) SemanticStyle(token string) (color.Style, error) {
+	s, ok := d.Semantic[token]
+	if !ok {
+		return color.Style{}, fmt.Errorf("semantic token not found: %s", token)
+	}
+	return s, nil
+}
+
+// SemanticTokens returns the styled semantic token types, sorted.
+func (d TemplateData) SemanticTokens() []string {
+	return sortedKeys(d.Semantic)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkSchemaVersion validates a requested contract version, where 0 means
+// the current version, and returns the version to use.
+func checkSchemaVersion(version int) (int, error) {
+	if version == 0 {
+		return TemplateDataVersion, nil
+	}
+	if !slices.Contains(SupportedSchemaVersions, version) {
+		return 0, errorf(KindConfig, "unsupported template schema version %d (supported: %v)", version, SupportedSchemaVersions)
+	}
+	return version, nil
+}
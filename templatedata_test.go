@@ -0,0 +1,267 @@
+package paletteswap
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/jsvensson/paletteswap/internal/color"
+)
+
+func TestTemplateDataAccessors(t *testing.T) {
+	data := buildTemplateData(testTheme())
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"schema version", `{{ .SchemaVersion }}`, "1"},
+		{"color", `{{ .Color "palette.highlight.low" | hex }}`, "#21202e"},
+		{"theme color", `{{ .ThemeColor "background" | hex }}`, "#191724"},
+		{"theme names", `{{ range .ThemeNames }}{{ . }} {{ end }}`, "background cursor "},
+		{"ansi color", `{{ .ANSIColor "red" | hex }}`, "#eb6f92"},
+		{"ansi names in index order", `{{ range .ANSINames }}{{ . }} {{ end }}`, "black red "},
+		{"ansi index", `{{ .ANSIIndex 1 | hex }}`, "#eb6f92"},
+		{"ansi index func", `{{ ansiIndex 1 | hex }}`, "#eb6f92"},
+		{"ansi index of", `{{ range .ANSINames }}{{ ansiIndexOf . }} {{ end }}`, "0 1 "},
+		{"nearest ansi", `{{ nearestAnsi "palette.love" }}`, "red"},
+		{"nearest ansi dark", `{{ nearestAnsi "palette.base" }}`, "black"},
+		{"nearest ansi index", `{{ .Theme.cursor | nearestAnsiIndex }}`, "1"},
+		{"style", `{{ (.Style "syntax.comment").Italic }}`, "true"},
+		{"raw fields still work", `{{ hex .Theme.background }}`, "#191724"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(data.FuncMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateDataANSIExtras(t *testing.T) {
+	theme := testTheme()
+	theme.ANSI["cursor"] = color.Color{R: 0x52, G: 0x4f, B: 0x67}
+	theme.ANSI["selection_background"] = color.Color{R: 0x40, G: 0x3d, B: 0x52}
+	data := buildTemplateData(theme)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"names exclude extras", `{{ range .ANSINames }}{{ . }} {{ end }}`, "black red "},
+		{"defined extra", `{{ with .ANSIExtras.cursor }}{{ hex . }}{{ end }}`, "#524f67"},
+		{"undefined extra", `{{ with .ANSIExtras.foreground }}{{ hex . }}{{ else }}none{{ end }}`, "none"},
+		{"extras in order", `{{ range $name, $c := .ANSIExtras }}{{ $name }}={{ hex $c }} {{ end }}`, "cursor=#524f67 selection_background=#403d52 "},
+		{"path", `{{ hex "ansi.selection_background" }}`, "#403d52"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := template.Must(template.New("test").Funcs(data.FuncMap).Parse(tt.template))
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateDataAccessorErrors(t *testing.T) {
+	data := buildTemplateData(testTheme())
+
+	if _, err := data.ThemeColor("missing"); err == nil {
+		t.Error("ThemeColor(missing) should return an error")
+	}
+	if _, err := data.ANSIColor("missing"); err == nil {
+		t.Error("ANSIColor(missing) should return an error")
+	}
+	if _, err := data.ANSIIndex(16); err == nil {
+		t.Error("ANSIIndex(16) should return an error")
+	}
+	if _, err := data.ANSIIndex(2); err == nil {
+		t.Error("ANSIIndex of an undefined color should return an error")
+	}
+	if _, err := data.ANSIIndexOf("cursor"); err == nil {
+		t.Error("ANSIIndexOf of an extra color should return an error")
+	}
+	if _, err := buildTemplateData(&Theme{}).NearestANSI(color.Color{}); err == nil {
+		t.Error("NearestANSI without ansi colors should return an error")
+	}
+	if _, err := data.SemanticStyle("parameter"); err == nil {
+		t.Error("SemanticStyle on a theme without semantic block should return an error")
+	}
+}
+
+func TestTemplateDataSemantic(t *testing.T) {
+	theme := testTheme()
+	theme.Semantic = map[string]color.Style{
+		"parameter": {Color: color.Color{R: 1, G: 2, B: 3}},
+		"decorator": {Color: color.Color{R: 4, G: 5, B: 6}, Italic: true},
+	}
+	data := buildTemplateData(theme)
+
+	if got, want := data.SemanticTokens(), []string{"decorator", "parameter"}; !slices.Equal(got, want) {
+		t.Errorf("SemanticTokens() = %v, want %v", got, want)
+	}
+	s, err := data.SemanticStyle("decorator")
+	if err != nil || !s.Italic {
+		t.Errorf("SemanticStyle(decorator) = %+v, %v, want italic style", s, err)
+	}
+}
+
+func TestRunSchemaVersion(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"test.txt.tmpl": `v{{ .SchemaVersion }}`,
+	})
+
+	// The Engine's version, as set by --schema-version, overrides the
+	// theme's schema_version setting.
+	tests := []struct {
+		version      int
+		themeVersion int
+		wantErr      bool
+	}{
+		{0, 0, false},
+		{1, 0, false},
+		{2, 0, true},
+		{0, 1, false},
+		{0, 2, true},
+		{1, 2, false},
+	}
+
+	for _, tt := range tests {
+		e := &Engine{
+			TemplatesDir:  tmplDir,
+			OutputDir:     filepath.Join(t.TempDir(), "output"),
+			SchemaVersion: tt.version,
+		}
+		theme := testTheme()
+		theme.SchemaVersion = tt.themeVersion
+		err := e.Run(theme)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SchemaVersion %d, theme %d: Run() error = %v, wantErr %v", tt.version, tt.themeVersion, err, tt.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), "unsupported template schema version") {
+			t.Errorf("SchemaVersion %d, theme %d: unexpected error %v", tt.version, tt.themeVersion, err)
+		}
+	}
+}
+
+func TestTemplateDataNode(t *testing.T) {
+	theme := testTheme()
+	group := color.Color{R: 64, G: 61, B: 82}
+	theme.Palette.Children["highlight"].Color = &group
+	data := buildTemplateData(theme)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"group color", `{{ (node "palette.highlight").Color | hex }}`, "#403d52"},
+		{"leaf color", `{{ (node "palette.base").Color | bhex }}`, "191724"},
+		{"children", `{{ range $name, $c := (node "palette.highlight").Children }}{{ $name }}={{ hex $c.Color }} {{ end }}`, "high=#524f67 low=#21202e "},
+		{"root", `{{ len (node "palette").Children }}`, "4"},
+		{"names", `{{ with node "palette.highlight" }}{{ $g := . }}{{ range .Names }}{{ . }}={{ (index $g.Children .).Color | hex }} {{ end }}{{ end }}`, "high=#524f67 low=#21202e "},
+		{"color math", `{{ (node "palette.highlight").Color | darken 1 | hex }}`, "#000000"},
+		{"method", `{{ (.Node "palette.highlight").Color | hex }}`, "#403d52"},
+		{"guarded without color", `{{ with (node "palette.custom").Color }}{{ hex . }}{{ else }}none{{ end }}`, "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(data.FuncMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	errorTests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"missing path", `{{ node "palette.missing" }}`, "palette path not found"},
+		{"not palette", `{{ node "theme.background" }}`, "only supports palette paths"},
+		{"group without color", `{{ (node "palette.custom").Color | hex }}`, "no color"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := template.Must(template.New("test").Funcs(data.FuncMap).Parse(tt.template))
+			err := tmpl.Execute(&bytes.Buffer{}, data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateDataDefinedAt(t *testing.T) {
+	theme := testTheme()
+	theme.Sources = map[string]Location{
+		"palette.love":      {File: "theme.pstheme", Line: 12},
+		"palette.highlight": {File: "theme.pstheme", Line: 14},
+	}
+	data := buildTemplateData(theme)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"leaf", `{{ definedAt "palette.love" }}`, "theme.pstheme:12"},
+		{"group color key", `{{ definedAt "palette.highlight.color" }}`, "theme.pstheme:14"},
+		{"method", `{{ .DefinedAt "palette.love" }}`, "theme.pstheme:12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(data.FuncMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := data.DefinedAt("palette.base"); err == nil {
+		t.Error("DefinedAt(palette.base): expected error for path without a source")
+	}
+}
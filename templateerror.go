@@ -0,0 +1,79 @@
+package paletteswap
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TemplateError is a template that failed to parse or execute, located in
+// the template source. Run returns it wrapped in an Error of KindTemplate.
+type TemplateError struct {
+	Template string // path of the template file
+	Output   string // output file being rendered; empty for parse errors
+	Line     int    // 1-based line in the template, or 0 if unknown
+	Excerpt  string // the template source at Line, trimmed
+	Err      error  // the underlying text/template error
+}
+
+// maxExcerpt bounds the length of TemplateError.Excerpt in bytes.
+const maxExcerpt = 80
+
+// templateLocation matches the location prefix text/template puts on its
+// errors, e.g. "template: kitty.conf.tmpl:12:5: ".
+var templateLocation = regexp.MustCompile(`^template: [^:]+:(\d+)(?::\d+)?: `)
+
+func (e *TemplateError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Template)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d", e.Line)
+	}
+	b.WriteString(": ")
+	b.WriteString(templateLocation.ReplaceAllString(e.Err.Error(), ""))
+	if e.Output != "" {
+		fmt.Fprintf(&b, " (rendering %s)", e.Output)
+	}
+	if e.Excerpt != "" {
+		fmt.Fprintf(&b, "\n\t%d | %s", e.Line, e.Excerpt)
+	}
+	return b.String()
+}
+
+func (e *TemplateError) Unwrap() error { return e.Err }
+
+// newTemplateError locates err in the template at tmplPath and returns it as
+// a KindTemplate Error holding a *TemplateError.
+func newTemplateError(tmplPath, output string, err error) error {
+	te := &TemplateError{Template: tmplPath, Output: output, Err: err}
+	if m := templateLocation.FindStringSubmatch(err.Error()); m != nil {
+		te.Line, _ = strconv.Atoi(m[1])
+		te.Excerpt = templateExcerpt(tmplPath, te.Line)
+	}
+	return &Error{Kind: KindTemplate, Err: te}
+}
+
+// templateExcerpt returns line n of the file at path, trimmed and cut to
+// maxExcerpt bytes, or "" if it cannot be read.
+func templateExcerpt(path string, n int) string {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(src), "\n")
+	if n < 1 || n > len(lines) {
+		return ""
+	}
+	line := strings.TrimSpace(lines[n-1])
+	if len(line) > maxExcerpt {
+		cut := maxExcerpt
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		line = line[:cut] + "…"
+	}
+	return line
+}
@@ -0,0 +1,194 @@
+package paletteswap
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// TemplateInfo describes a template found in a templates directory.
+type TemplateInfo struct {
+	App    string // name accepted by Engine.Apps: the output name without its extension
+	Path   string // path to the .tmpl file
+	Output string // output file name
+	// References lists the theme paths the template uses, such as
+	// "palette.base" or "theme.background", found by scanning its path
+	// strings and field accesses. Sorted and deduplicated.
+	References []string
+	Err        error // non-nil if the template could not be parsed
+}
+
+// ListTemplates returns the templates in dir, sorted by output name.
+func ListTemplates(dir string) ([]TemplateInfo, error) {
+	matches, err := findTemplates(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]TemplateInfo, 0, len(matches))
+	for _, tmplPath := range matches {
+		output := outputName(tmplPath)
+		info := TemplateInfo{
+			App:    strings.TrimSuffix(output, path.Ext(output)),
+			Path:   tmplPath,
+			Output: output,
+		}
+		info.References, info.Err = templateReferences(tmplPath)
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// findTemplates returns the .tmpl files in dir, or an error if there are
+// none. A leading "~" in dir is the home directory. Templates that are
+// symbolic links to the same file are returned once, under the first name
+// in sorted order, so that no file is rendered twice.
+func findTemplates(dir string) ([]string, error) {
+	dir, err := expandHome(dir)
+	if err != nil {
+		return nil, errorf(KindIO, "templates directory: %w", err)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, errorf(KindIO, "globbing templates: %w", err)
+	}
+	if len(matches) == 0 {
+		return nil, errorf(KindIO, "no .tmpl files found in %s", dir)
+	}
+
+	seen := make(map[string]bool, len(matches)) // resolved template paths
+	unique := matches[:0]
+	for _, m := range matches {
+		// A broken link is kept, so that rendering reports it.
+		resolved, err := filepath.EvalSymlinks(m)
+		if err != nil {
+			resolved = m
+		}
+		if !seen[resolved] {
+			seen[resolved] = true
+			unique = append(unique, m)
+		}
+	}
+	return unique, nil
+}
+
+// expandHome replaces a leading "~" path element in p with the home
+// directory.
+func expandHome(p string) (string, error) {
+	if p != "~" && !strings.HasPrefix(p, "~/") && !strings.HasPrefix(p, "~"+string(filepath.Separator)) {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, p[1:]), nil
+}
+
+// templateFields maps top-level template data fields to their path block.
+var templateFields = map[string]string{
+	"Meta":     "meta",
+	"Palette":  "palette",
+	"Theme":    "theme",
+	"Syntax":   "syntax",
+	"Semantic": "semantic",
+	"ANSI":     "ansi",
+}
+
+// templateReferences parses the template at tmplPath and collects the theme
+// paths it references.
+func templateReferences(tmplPath string) ([]string, error) {
+	src, err := os.ReadFile(tmplPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading template %s: %w", tmplPath, err)
+	}
+
+	_, body, err := parsePragma(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %s: %w", tmplPath, err)
+	}
+	funcs := buildTemplateData(&Theme{}).FuncMap
+	tmpl, err := template.New(filepath.Base(tmplPath)).Funcs(funcs).Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %s: %w", tmplPath, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			collectReferences(t.Tree.Root, seen)
+		}
+	}
+
+	refs := make([]string, 0, len(seen))
+	for ref := range seen {
+		refs = append(refs, ref)
+	}
+	slices.Sort(refs)
+	return refs, nil
+}
+
+// collectReferences walks a template parse tree, recording string literals
+// that look like theme paths and field chains such as .Theme.background.
+func collectReferences(node parse.Node, seen map[string]bool) {
+	walkTree(node, func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.StringNode:
+			block, _, ok := strings.Cut(n.Text, ".")
+			if ok && slices.Contains(pathBlocks, block) {
+				seen[n.Text] = true
+			}
+		case *parse.FieldNode:
+			if block, ok := templateFields[n.Ident[0]]; ok && len(n.Ident) > 1 {
+				seen[block+"."+strings.ToLower(strings.Join(n.Ident[1:], "."))] = true
+			}
+		}
+	})
+}
+
+// walkTree calls visit for node and every node below it in a template parse
+// tree, parents before their children.
+func walkTree(node parse.Node, visit func(parse.Node)) {
+	visit(node)
+	switch n := node.(type) {
+	case *parse.ListNode:
+		for _, child := range n.Nodes {
+			walkTree(child, visit)
+		}
+	case *parse.ActionNode:
+		walkTree(n.Pipe, visit)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			walkTree(n.Pipe, visit)
+		}
+	case *parse.PipeNode:
+		for _, cmd := range n.Cmds {
+			walkTree(cmd, visit)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkTree(arg, visit)
+		}
+	case *parse.ChainNode:
+		walkTree(n.Node, visit)
+	}
+}
+
+func walkBranch(b *parse.BranchNode, visit func(parse.Node)) {
+	walkTree(b.Pipe, visit)
+	walkTree(b.List, visit)
+	if b.ElseList != nil {
+		walkTree(b.ElseList, visit)
+	}
+}
@@ -0,0 +1,54 @@
+package paletteswap
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestListTemplates(t *testing.T) {
+	tmplDir := setupTemplateDir(t, map[string]string{
+		"zed.json.tmpl": `{"name": "{{ .Meta.Name }}", "bg": "{{ hex "theme.background" }}"}
+{{ if (style "syntax.comment").Italic }}{{ "palette.base" | darken 0.1 | hex }}{{ end }}
+{{ range .ANSI }}{{ . | hex }}{{ end }}{{ bhex .ANSI.black }}`,
+		"ghostty.tmpl": `background = {{ bhex "theme.background" }}`,
+		"broken.tmpl":  `{{ hex "palette.base" `,
+	})
+
+	infos, err := ListTemplates(tmplDir)
+	if err != nil {
+		t.Fatalf("ListTemplates() error: %v", err)
+	}
+
+	if len(infos) != 3 {
+		t.Fatalf("got %d templates, want 3", len(infos))
+	}
+
+	byApp := make(map[string]TemplateInfo)
+	for _, info := range infos {
+		byApp[info.App] = info
+	}
+
+	zed := byApp["zed"]
+	if zed.Output != "zed.json" || zed.Path != filepath.Join(tmplDir, "zed.json.tmpl") {
+		t.Errorf("zed = %+v, want output zed.json at zed.json.tmpl", zed)
+	}
+	wantRefs := []string{"ansi.black", "meta.name", "palette.base", "syntax.comment", "theme.background"}
+	if !slices.Equal(zed.References, wantRefs) {
+		t.Errorf("zed references = %v, want %v", zed.References, wantRefs)
+	}
+
+	if ghostty := byApp["ghostty"]; ghostty.Output != "ghostty" || ghostty.Err != nil {
+		t.Errorf("ghostty = %+v, want output ghostty without error", ghostty)
+	}
+
+	if broken := byApp["broken"]; broken.Err == nil {
+		t.Error("broken template should report a parse error")
+	}
+}
+
+func TestListTemplatesEmptyDir(t *testing.T) {
+	if _, err := ListTemplates(t.TempDir()); err == nil {
+		t.Error("ListTemplates() on an empty directory should return an error")
+	}
+}
@@ -1,10 +1,13 @@
 package paletteswap
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/jsvensson/paletteswap/internal/color"
+	"github.com/jsvensson/paletteswap/internal/format"
 	"github.com/jsvensson/paletteswap/internal/parser"
+	"github.com/jsvensson/paletteswap/internal/theme"
 )
 
 // Theme is the fully-resolved theme data, ready for template rendering.
@@ -12,35 +15,102 @@ type Theme struct {
 	Meta    Meta
 	Palette *color.Node
 	Syntax  color.Tree
-	Theme   map[string]color.Color
-	ANSI    map[string]color.Color
+	// LanguageSyntax holds per-language syntax overrides keyed by language
+	// label. Use SyntaxFor in templates to get the merged result.
+	LanguageSyntax map[string]color.Tree
+	// Semantic holds LSP semantic token styles keyed by standard token type.
+	Semantic  map[string]color.Style
+	Theme     map[string]color.Color
+	Cursor    *Cursor    // nil unless the theme block has a cursor sub-block
+	Selection *Selection // nil unless the theme block has a selection sub-block
+	ANSI      map[string]color.Color
+	// Sources holds where each color path, such as "palette.love", is
+	// defined in the theme file. It is nil for themes not loaded from a file.
+	Sources map[string]Location
+	// MigrationHints describes constructs in the theme file that a later
+	// spec version no longer accepts, as "file:line: message; hint" strings.
+	MigrationHints []string
+	// SchemaVersion is the schema_version setting: the TemplateData
+	// contract version the theme's templates are written against, or 0 if
+	// the theme does not pin one. Engine.SchemaVersion overrides it.
+	SchemaVersion int
 }
 
-// Meta holds theme metadata.
-type Meta struct {
-	Name       string
-	Author     string
-	Appearance string
-	URL        string
-}
+// Cursor holds the colors from the theme block's cursor sub-block.
+type Cursor = parser.Cursor
+
+// Selection holds the colors from the theme block's selection sub-block.
+type Selection = parser.Selection
+
+// Location is where a value is defined in a theme file. It formats as
+// "file:line".
+type Location = parser.Location
+
+// Meta holds theme metadata: name, author, appearance, URL and any extra
+// attributes declared in the meta block.
+type Meta = parser.Meta
+
+// Limits bounds file size, palette entry count and transform steps when
+// loading a theme. A zero field disables that limit.
+type Limits = theme.Limits
+
+// DefaultLimits are the limits used by Load.
+var DefaultLimits = theme.DefaultLimits
+
+// LineEnding selects the line endings of generated files, see Engine.LineEnding.
+type LineEnding = format.LineEnding
+
+const (
+	LineEndingPreserve = format.LineEndingPreserve // keep the template's line endings
+	LineEndingLF       = format.LineEndingLF
+	LineEndingCRLF     = format.LineEndingCRLF
+	LineEndingNative   = format.LineEndingNative // CRLF on Windows, LF elsewhere
+)
 
 // Load parses an HCL theme file and returns a fully-resolved Theme.
 func Load(path string) (*Theme, error) {
-	raw, err := parser.Parse(path)
+	return LoadWithLimits(path, DefaultLimits)
+}
+
+// LoadWithLimits is like Load but enforces the given limits.
+func LoadWithLimits(path string, limits Limits) (*Theme, error) {
+	return LoadContextWithLimits(context.Background(), path, limits)
+}
+
+// LoadContext is like Load but gives up once ctx is done, for example when
+// its deadline passes. The error then wraps ctx.Err() and has no ErrorKind.
+func LoadContext(ctx context.Context, path string) (*Theme, error) {
+	return LoadContextWithLimits(ctx, path, DefaultLimits)
+}
+
+// LoadContextWithLimits is like LoadContext but enforces the given limits.
+func LoadContextWithLimits(ctx context.Context, path string, limits Limits) (*Theme, error) {
+	return LoadForAppearance(ctx, path, limits, "")
+}
+
+// LoadForAppearance is like LoadContextWithLimits but loads the theme for
+// appearance, "dark" or "light", rather than its meta.appearance. Blocks
+// with a when attribute naming the other appearance are left out, and
+// Meta.Appearance is set to appearance, so one file can serve both. An
+// empty appearance is that of the meta block.
+func LoadForAppearance(ctx context.Context, path string, limits Limits, appearance string) (*Theme, error) {
+	raw, err := parser.ParseForAppearance(ctx, path, limits, appearance)
 	if err != nil {
 		return nil, fmt.Errorf("loading theme: %w", err)
 	}
 
 	return &Theme{
-		Meta: Meta{
-			Name:       raw.Meta.Name,
-			Author:     raw.Meta.Author,
-			Appearance: raw.Meta.Appearance,
-			URL:        raw.Meta.URL,
-		},
-		Palette: raw.Palette,
-		Theme:   raw.Theme,
-		Syntax:  raw.Syntax,
-		ANSI:    raw.ANSI,
+		Meta:           raw.Meta,
+		Palette:        raw.Palette,
+		Theme:          raw.Theme,
+		Syntax:         raw.Syntax,
+		LanguageSyntax: raw.LanguageSyntax,
+		Semantic:       raw.Semantic,
+		Cursor:         raw.Cursor,
+		Selection:      raw.Selection,
+		ANSI:           raw.ANSI,
+		Sources:        raw.Sources,
+		MigrationHints: raw.MigrationHints,
+		SchemaVersion:  raw.SchemaVersion,
 	}, nil
 }